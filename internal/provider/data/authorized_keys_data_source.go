@@ -0,0 +1,245 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ datasource.DataSource                     = &AuthorizedKeysDataSource{}
+	_ datasource.DataSourceWithConfigure        = &AuthorizedKeysDataSource{}
+	_ datasource.DataSourceWithConfigValidators = &AuthorizedKeysDataSource{}
+)
+
+// AuthorizedKeysDataSource defines the data source implementation.
+type AuthorizedKeysDataSource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// AuthorizedKeyEntry represents a key of an authorized_keys file
+type AuthorizedKeyEntry struct {
+	Line        types.Int64    `tfsdk:"line"`
+	Type        types.String   `tfsdk:"type"`
+	Key         types.String   `tfsdk:"key"`
+	Comment     types.String   `tfsdk:"comment"`
+	Options     []types.String `tfsdk:"options"`
+	Fingerprint types.String   `tfsdk:"fingerprint"`
+	PublicKey   types.String   `tfsdk:"public_key"`
+}
+
+// AuthorizedKeysDataSourceModel describes the data source data model.
+type AuthorizedKeysDataSourceModel struct {
+	SSH          *ssh.SSHBlockModel   `tfsdk:"ssh"`
+	Connection   types.String         `tfsdk:"connection"`
+	Path         types.String         `tfsdk:"path"`
+	Exists       types.Bool           `tfsdk:"exists"`
+	Keys         []AuthorizedKeyEntry `tfsdk:"keys"`
+	Fingerprints []types.String       `tfsdk:"fingerprints"`
+	ID           types.String         `tfsdk:"id"`
+}
+
+// NewAuthorizedKeysDataSource creates a new data source implementation.
+func NewAuthorizedKeysDataSource(pool *ssh.SSHPool) datasource.DataSource {
+	return &AuthorizedKeysDataSource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the data source type name.
+func (d *AuthorizedKeysDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_authorized_keys"
+}
+
+// ConfigValidators returns the validators of the data source configuration.
+func (d *AuthorizedKeysDataSource) ConfigValidators(_ context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the data source.
+func (d *AuthorizedKeysDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads an authorized_keys file on a remote server via SSH and parses it into its keys.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockDataSourceSchema(),
+			},
+			"path": schema.StringAttribute{
+				Description: "The path of the authorized_keys file on the remote server. Defaults to '" + ssh.DefaultAuthorizedKeysPath + "', the file of the SSH user.",
+				Optional:    true,
+			},
+			"exists": schema.BoolAttribute{
+				Description: "Whether the file exists. A missing file has no keys.",
+				Computed:    true,
+			},
+			"keys": schema.ListNestedAttribute{
+				Description: "The keys in the order they appear in the file. Empty lines and comments are skipped.",
+				Computed:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"line": schema.Int64Attribute{
+							Description: "The line number of the key in the file, starting at 1.",
+							Computed:    true,
+						},
+						"type": schema.StringAttribute{
+							Description: "The type of the key (e.g., 'ssh-ed25519').",
+							Computed:    true,
+						},
+						"key": schema.StringAttribute{
+							Description: "The base64 encoded key material.",
+							Computed:    true,
+						},
+						"comment": schema.StringAttribute{
+							Description: "The comment after the key, usually user@host. Empty if there is none.",
+							Computed:    true,
+						},
+						"options": schema.ListAttribute{
+							Description: "The options before the key type (e.g., 'from=\"10.0.0.0/8\"' or 'no-pty').",
+							ElementType: types.StringType,
+							Computed:    true,
+						},
+						"fingerprint": schema.StringAttribute{
+							Description: "The SHA256 fingerprint of the key (e.g., 'SHA256:...').",
+							Computed:    true,
+						},
+						"public_key": schema.StringAttribute{
+							Description: "The key in authorized_keys format without options and comment (e.g., 'ssh-ed25519 AAAA...').",
+							Computed:    true,
+						},
+					},
+				},
+			},
+			"fingerprints": schema.ListAttribute{
+				Description: "The fingerprints of the keys, in the same order as keys.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The path of the file.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (d *AuthorizedKeysDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "AuthorizedKeysDataSource.Read")
+	defer span.End()
+
+	var state AuthorizedKeysDataSourceModel
+	diags := req.Config.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := d.getClient(ctx, state.SSH, state.Connection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	remotePath := ssh.DefaultAuthorizedKeysPath
+	if !state.Path.IsNull() {
+		remotePath = state.Path.ValueString()
+	}
+
+	exists, err := client.Exists(ctx, remotePath)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error checking file existence",
+			fmt.Sprintf("Could not determine file existence: %s", err),
+		)
+		return
+	}
+
+	state.Exists = types.BoolValue(exists)
+	state.Keys = []AuthorizedKeyEntry{}
+	state.Fingerprints = []types.String{}
+	state.ID = types.StringValue(remotePath)
+
+	if exists {
+		content, err := client.ReadFileLimited(ctx, remotePath, ssh.DefaultMaxReadSize)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error reading file",
+				fmt.Sprintf("Could not read authorized_keys file: %s", err),
+			)
+			return
+		}
+
+		// Lines sshd can't parse are ignored by it as well, so they don't fail reading the others
+		keys, errs := ssh.ParseAuthorizedKeys(content)
+		for _, err := range errs {
+			resp.Diagnostics.AddWarning(
+				"Invalid authorized_keys entry",
+				fmt.Sprintf("Skipped an entry of %s that could not be parsed: %s", remotePath, err),
+			)
+		}
+
+		for _, key := range keys {
+			entry := AuthorizedKeyEntry{
+				Line:        types.Int64Value(int64(key.Line)),
+				Type:        types.StringValue(key.Type),
+				Key:         types.StringValue(key.Key),
+				Comment:     types.StringValue(key.Comment),
+				Options:     make([]types.String, 0, len(key.Options)),
+				Fingerprint: types.StringValue(key.Fingerprint),
+				PublicKey:   types.StringValue(key.Type + " " + key.Key),
+			}
+			for _, option := range key.Options {
+				entry.Options = append(entry.Options, types.StringValue(option))
+			}
+			state.Keys = append(state.Keys, entry)
+			state.Fingerprints = append(state.Fingerprints, entry.Fingerprint)
+		}
+	}
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (d *AuthorizedKeysDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	d.connections = providerData.Connections
+}
+
+func (d *AuthorizedKeysDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String) (*ssh.SSHClient, func(), error) {
+	config, err := d.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.pool.Acquire(ctx, config, false)
+}
@@ -0,0 +1,198 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ datasource.DataSource                     = &ConnectionTestDataSource{}
+	_ datasource.DataSourceWithConfigure        = &ConnectionTestDataSource{}
+	_ datasource.DataSourceWithConfigValidators = &ConnectionTestDataSource{}
+)
+
+// ConnectionTestDataSource defines the data source implementation.
+type ConnectionTestDataSource struct {
+	connections ssh.Connections
+}
+
+// ConnectionTestDataSourceModel describes the data source data model.
+type ConnectionTestDataSourceModel struct {
+	SSH                  *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection           types.String       `tfsdk:"connection"`
+	Connected            types.Bool         `tfsdk:"connected"`
+	ServerVersion        types.String       `tfsdk:"server_version"`
+	LatencyMs            types.Int64        `tfsdk:"latency_ms"`
+	KexAlgorithm         types.String       `tfsdk:"kex_algorithm"`
+	HostKeyAlgorithm     types.String       `tfsdk:"host_key_algorithm"`
+	CipherClientToServer types.String       `tfsdk:"cipher_client_to_server"`
+	CipherServerToClient types.String       `tfsdk:"cipher_server_to_client"`
+	MACClientToServer    types.String       `tfsdk:"mac_client_to_server"`
+	MACServerToClient    types.String       `tfsdk:"mac_server_to_client"`
+	ID                   types.String       `tfsdk:"id"`
+}
+
+// NewConnectionTestDataSource creates a new data source implementation.
+func NewConnectionTestDataSource() datasource.DataSource {
+	return &ConnectionTestDataSource{}
+}
+
+// Metadata returns the data source type name.
+func (d *ConnectionTestDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_connection_test"
+}
+
+// ConfigValidators returns the validators of the data source configuration.
+func (d *ConnectionTestDataSource) ConfigValidators(_ context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the data source.
+func (d *ConnectionTestDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Verifies that a connection to a remote server can be established and authenticated via SSH.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockDataSourceSchema(),
+			},
+			"connected": schema.BoolAttribute{
+				Description: "Whether the connection was established and a command could be run. Reading fails otherwise.",
+				Computed:    true,
+			},
+			"server_version": schema.StringAttribute{
+				Description: "The version string the SSH server sent during the handshake (e.g., 'SSH-2.0-OpenSSH_9.6').",
+				Computed:    true,
+			},
+			"latency_ms": schema.Int64Attribute{
+				Description: "The round-trip time of a keepalive request to the SSH server, in milliseconds.",
+				Computed:    true,
+			},
+			"kex_algorithm": schema.StringAttribute{
+				Description: "The key exchange algorithm negotiated with the server (e.g., 'curve25519-sha256').",
+				Computed:    true,
+			},
+			"host_key_algorithm": schema.StringAttribute{
+				Description: "The host key algorithm negotiated with the server (e.g., 'ssh-ed25519').",
+				Computed:    true,
+			},
+			"cipher_client_to_server": schema.StringAttribute{
+				Description: "The cipher negotiated for data sent from the client to the server (e.g., 'chacha20-poly1305@openssh.com').",
+				Computed:    true,
+			},
+			"cipher_server_to_client": schema.StringAttribute{
+				Description: "The cipher negotiated for data sent from the server to the client.",
+				Computed:    true,
+			},
+			"mac_client_to_server": schema.StringAttribute{
+				Description: "The MAC negotiated for data sent from the client to the server. Empty for AEAD ciphers, which include integrity protection.",
+				Computed:    true,
+			},
+			"mac_server_to_client": schema.StringAttribute{
+				Description: "The MAC negotiated for data sent from the server to the client. Empty for AEAD ciphers, which include integrity protection.",
+				Computed:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The host of the remote server.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (d *ConnectionTestDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "ConnectionTestDataSource.Read")
+	defer span.End()
+
+	var state ConnectionTestDataSourceModel
+	diags := req.Config.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	config, err := d.connections.Config(state.SSH, state.Connection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+
+	// A new connection is established instead of reusing a pooled one, so the test covers
+	// the handshake, host key verification and authentication
+	client, err := ssh.NewSSHClient(ctx, config)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error connecting to SSH server",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer client.Close()
+
+	if _, err := client.RunCommand(ctx, "true"); err != nil {
+		resp.Diagnostics.AddError(
+			"Error running command",
+			fmt.Sprintf("Could not run a command on the remote server: %s", err),
+		)
+		return
+	}
+
+	latency, err := client.Ping(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error pinging SSH server",
+			fmt.Sprintf("Could not measure the latency of the connection: %s", err),
+		)
+		return
+	}
+
+	state.Connected = types.BoolValue(true)
+	state.ServerVersion = types.StringValue(client.ServerVersion())
+	state.LatencyMs = types.Int64Value(latency.Milliseconds())
+	state.ID = types.StringValue(config.Host)
+
+	algorithms := client.Algorithms()
+	state.KexAlgorithm = types.StringValue(algorithms.KeyExchange)
+	state.HostKeyAlgorithm = types.StringValue(algorithms.HostKey)
+	state.CipherClientToServer = types.StringValue(algorithms.CipherClientServer)
+	state.CipherServerToClient = types.StringValue(algorithms.CipherServerClient)
+	state.MACClientToServer = types.StringValue(algorithms.MACClientServer)
+	state.MACServerToClient = types.StringValue(algorithms.MACServerClient)
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (d *ConnectionTestDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	d.connections = providerData.Connections
+}
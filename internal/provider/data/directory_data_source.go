@@ -15,53 +15,71 @@ import (
 )
 
 var (
-	_ datasource.DataSource              = &DirectoryDataSource{}
-	_ datasource.DataSourceWithConfigure = &DirectoryDataSource{}
+	_ datasource.DataSource                     = &DirectoryDataSource{}
+	_ datasource.DataSourceWithConfigure        = &DirectoryDataSource{}
+	_ datasource.DataSourceWithConfigValidators = &DirectoryDataSource{}
 )
 
 // DirectoryDataSource defines the data source implementation.
 type DirectoryDataSource struct {
-	pool *ssh.SSHPool
+	pool        *ssh.SSHPool
+	connections ssh.Connections
 }
 
 // DirectoryEntry represents a file or directory entry
 type DirectoryEntry struct {
-	Name        types.String `tfsdk:"name"`
-	Path        types.String `tfsdk:"path"`
-	Size        types.Int64  `tfsdk:"size"`
-	IsDir       types.Bool   `tfsdk:"is_dir"`
-	Permissions types.String `tfsdk:"permissions"`
-	Owner       types.String `tfsdk:"owner"`
-	Group       types.String `tfsdk:"group"`
-	Immutable   types.Bool   `tfsdk:"immutable"`
-	AppendOnly  types.Bool   `tfsdk:"append_only"`
-	NoDump      types.Bool   `tfsdk:"no_dump"`
-	Synchronous types.Bool   `tfsdk:"synchronous"`
-	NoAtime     types.Bool   `tfsdk:"no_atime"`
-	Compressed  types.Bool   `tfsdk:"compressed"`
-	NoCoW       types.Bool   `tfsdk:"no_cow"`
-	Undeletable types.Bool   `tfsdk:"undeletable"`
-	ModTime     types.String `tfsdk:"mod_time"`
+	Name           types.String `tfsdk:"name"`
+	Path           types.String `tfsdk:"path"`
+	Size           types.Int64  `tfsdk:"size"`
+	IsDir          types.Bool   `tfsdk:"is_dir"`
+	Type           types.String `tfsdk:"type"`
+	Permissions    types.String `tfsdk:"permissions"`
+	Owner          types.String `tfsdk:"owner"`
+	Group          types.String `tfsdk:"group"`
+	Immutable      types.Bool   `tfsdk:"immutable"`
+	AppendOnly     types.Bool   `tfsdk:"append_only"`
+	NoDump         types.Bool   `tfsdk:"no_dump"`
+	Synchronous    types.Bool   `tfsdk:"synchronous"`
+	NoAtime        types.Bool   `tfsdk:"no_atime"`
+	Compressed     types.Bool   `tfsdk:"compressed"`
+	NoCoW          types.Bool   `tfsdk:"no_cow"`
+	Undeletable    types.Bool   `tfsdk:"undeletable"`
+	DataJournaling types.Bool   `tfsdk:"data_journaling"`
+	NoTailMerge    types.Bool   `tfsdk:"no_tail_merge"`
+	TopDir         types.Bool   `tfsdk:"top_dir"`
+	DirSync        types.Bool   `tfsdk:"dir_sync"`
+	Extents        types.Bool   `tfsdk:"extents"`
+	Encrypted      types.Bool   `tfsdk:"encrypted"`
+	ModTime        types.String `tfsdk:"mod_time"`
 }
 
 // DirectoryDataSourceModel describes the data source data model.
 type DirectoryDataSourceModel struct {
-	SSH         *ssh.SSHBlockModel `tfsdk:"ssh"`
-	Path        types.String       `tfsdk:"path"`
-	Permissions types.String       `tfsdk:"permissions"`
-	Owner       types.String       `tfsdk:"owner"`
-	Group       types.String       `tfsdk:"group"`
-	Immutable   types.Bool         `tfsdk:"immutable"`
-	AppendOnly  types.Bool         `tfsdk:"append_only"`
-	NoDump      types.Bool         `tfsdk:"no_dump"`
-	Synchronous types.Bool         `tfsdk:"synchronous"`
-	NoAtime     types.Bool         `tfsdk:"no_atime"`
-	Compressed  types.Bool         `tfsdk:"compressed"`
-	NoCoW       types.Bool         `tfsdk:"no_cow"`
-	Undeletable types.Bool         `tfsdk:"undeletable"`
-	Exists      types.Bool         `tfsdk:"exists"`
-	Entries     []DirectoryEntry   `tfsdk:"entries"`
-	ID          types.String       `tfsdk:"id"`
+	SSH             *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection      types.String       `tfsdk:"connection"`
+	Path            types.String       `tfsdk:"path"`
+	Permissions     types.String       `tfsdk:"permissions"`
+	Owner           types.String       `tfsdk:"owner"`
+	Group           types.String       `tfsdk:"group"`
+	Immutable       types.Bool         `tfsdk:"immutable"`
+	AppendOnly      types.Bool         `tfsdk:"append_only"`
+	NoDump          types.Bool         `tfsdk:"no_dump"`
+	Synchronous     types.Bool         `tfsdk:"synchronous"`
+	NoAtime         types.Bool         `tfsdk:"no_atime"`
+	Compressed      types.Bool         `tfsdk:"compressed"`
+	NoCoW           types.Bool         `tfsdk:"no_cow"`
+	Undeletable     types.Bool         `tfsdk:"undeletable"`
+	DataJournaling  types.Bool         `tfsdk:"data_journaling"`
+	NoTailMerge     types.Bool         `tfsdk:"no_tail_merge"`
+	TopDir          types.Bool         `tfsdk:"top_dir"`
+	DirSync         types.Bool         `tfsdk:"dir_sync"`
+	Extents         types.Bool         `tfsdk:"extents"`
+	Encrypted       types.Bool         `tfsdk:"encrypted"`
+	Exists          types.Bool         `tfsdk:"exists"`
+	Entries         []DirectoryEntry   `tfsdk:"entries"`
+	ContinueOnError types.Bool         `tfsdk:"continue_on_error"`
+	ReadErrors      []types.String     `tfsdk:"read_errors"`
+	ID              types.String       `tfsdk:"id"`
 }
 
 // NewDirectoryDataSource creates a new data source implementation.
@@ -76,14 +94,25 @@ func (d *DirectoryDataSource) Metadata(_ context.Context, req datasource.Metadat
 	resp.TypeName = req.ProviderTypeName + "_directory_info"
 }
 
+// ConfigValidators returns the validators of the data source configuration.
+func (d *DirectoryDataSource) ConfigValidators(_ context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
 // Schema defines the schema for the data source.
 func (d *DirectoryDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Description: "Reads information about a directory on a remote server via SSH.",
 		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
 			"ssh": schema.SingleNestedAttribute{
-				Description: "SSH connection configuration.",
-				Required:    true,
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
 				Attributes:  ssh.SSHBlockDataSourceSchema(),
 			},
 			"path": schema.StringAttribute{
@@ -134,6 +163,30 @@ func (d *DirectoryDataSource) Schema(_ context.Context, _ datasource.SchemaReque
 				Description: "Whether content is saved when deleted.",
 				Computed:    true,
 			},
+			"data_journaling": schema.BoolAttribute{
+				Description: "Whether data journaling is enabled for the directory.",
+				Computed:    true,
+			},
+			"no_tail_merge": schema.BoolAttribute{
+				Description: "Whether the directory is not tail-merged with other files.",
+				Computed:    true,
+			},
+			"top_dir": schema.BoolAttribute{
+				Description: "Whether the directory is treated as the top of a directory hierarchy by the block allocator.",
+				Computed:    true,
+			},
+			"dir_sync": schema.BoolAttribute{
+				Description: "Whether changes to the directory are written synchronously to disk.",
+				Computed:    true,
+			},
+			"extents": schema.BoolAttribute{
+				Description: "Whether the directory uses extents for mapping blocks. Read-only.",
+				Computed:    true,
+			},
+			"encrypted": schema.BoolAttribute{
+				Description: "Whether the directory is encrypted by the filesystem. Read-only.",
+				Computed:    true,
+			},
 			"exists": schema.BoolAttribute{
 				Description: "Whether the directory exists.",
 				Computed:    true,
@@ -152,13 +205,17 @@ func (d *DirectoryDataSource) Schema(_ context.Context, _ datasource.SchemaReque
 							Computed:    true,
 						},
 						"size": schema.Int64Attribute{
-							Description: "The size of the file in bytes.",
+							Description: "The size of the file in bytes, as reported by the server. Usually 0 for devices, FIFOs and sockets.",
 							Computed:    true,
 						},
 						"is_dir": schema.BoolAttribute{
 							Description: "Whether this entry is a directory.",
 							Computed:    true,
 						},
+						"type": schema.StringAttribute{
+							Description: "The type of the entry: 'file', 'dir', 'symlink', 'block', 'char', 'fifo' or 'socket'.",
+							Computed:    true,
+						},
 						"permissions": schema.StringAttribute{
 							Description: "The permissions in octal format.",
 							Computed:    true,
@@ -203,6 +260,30 @@ func (d *DirectoryDataSource) Schema(_ context.Context, _ datasource.SchemaReque
 							Description: "Whether content is saved when deleted.",
 							Computed:    true,
 						},
+						"data_journaling": schema.BoolAttribute{
+							Description: "Whether data journaling is enabled for the entry.",
+							Computed:    true,
+						},
+						"no_tail_merge": schema.BoolAttribute{
+							Description: "Whether the entry is not tail-merged with other files.",
+							Computed:    true,
+						},
+						"top_dir": schema.BoolAttribute{
+							Description: "Whether the entry is treated as the top of a directory hierarchy by the block allocator.",
+							Computed:    true,
+						},
+						"dir_sync": schema.BoolAttribute{
+							Description: "Whether changes to the entry are written synchronously to disk.",
+							Computed:    true,
+						},
+						"extents": schema.BoolAttribute{
+							Description: "Whether the entry uses extents for mapping blocks. Read-only.",
+							Computed:    true,
+						},
+						"encrypted": schema.BoolAttribute{
+							Description: "Whether the entry is encrypted by the filesystem. Read-only.",
+							Computed:    true,
+						},
 						"mod_time": schema.StringAttribute{
 							Description: "The last modification time in RFC3339 format.",
 							Computed:    true,
@@ -210,6 +291,16 @@ func (d *DirectoryDataSource) Schema(_ context.Context, _ datasource.SchemaReque
 					},
 				},
 			},
+			"continue_on_error": schema.BoolAttribute{
+				Description: "If true, an entry whose ownership or attributes can't be read doesn't fail the data source. " +
+					"The fields of that entry are left null and the error is added to read_errors instead. Defaults to false.",
+				Optional: true,
+			},
+			"read_errors": schema.ListAttribute{
+				Description: "The errors of entries that could not be read completely, if continue_on_error is true.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
 			"id": schema.StringAttribute{
 				Description: "The path of the directory.",
 				Computed:    true,
@@ -230,18 +321,27 @@ func (d *DirectoryDataSource) Read(ctx context.Context, req datasource.ReadReque
 		return
 	}
 
-	client, err := d.getClient(ctx, state.SSH)
+	client, release, err := d.getClient(ctx, state.SSH, state.Connection)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	remotePath, err := client.ResolvePath(ctx, state.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error resolving path",
+			fmt.Sprintf("Could not resolve path: %s", err),
 		)
 		return
 	}
-	defer client.Close()
 
 	// Check if directory exists
-	dirInfo, err := client.SftpClient.Stat(state.Path.ValueString())
+	dirInfo, err := client.SftpClient.Stat(remotePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			state.Exists = types.BoolValue(false)
@@ -269,7 +369,7 @@ func (d *DirectoryDataSource) Read(ctx context.Context, req datasource.ReadReque
 	state.ID = types.StringValue(state.Path.ValueString())
 
 	// Get directory permissions
-	mode := dirInfo.Mode().Perm()
+	mode := ssh.PermissionBits(dirInfo.Mode())
 	state.Permissions = types.StringValue(fmt.Sprintf("%04o", mode))
 
 	// Get directory ownership
@@ -301,9 +401,15 @@ func (d *DirectoryDataSource) Read(ctx context.Context, req datasource.ReadReque
 	state.Compressed = types.BoolValue(attrs.Compressed)
 	state.NoCoW = types.BoolValue(attrs.NoCoW)
 	state.Undeletable = types.BoolValue(attrs.Undeletable)
+	state.DataJournaling = types.BoolValue(attrs.DataJournaling)
+	state.NoTailMerge = types.BoolValue(attrs.NoTailMerge)
+	state.TopDir = types.BoolValue(attrs.TopDir)
+	state.DirSync = types.BoolValue(attrs.DirSync)
+	state.Extents = types.BoolValue(attrs.Extents)
+	state.Encrypted = types.BoolValue(attrs.Encrypted)
 
 	// Read directory entries
-	entries, err := client.SftpClient.ReadDir(state.Path.ValueString())
+	entries, err := client.SftpClient.ReadDir(remotePath)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error reading directory entries",
@@ -312,82 +418,101 @@ func (d *DirectoryDataSource) Read(ctx context.Context, req datasource.ReadReque
 		return
 	}
 
-	// Convert entries to model
+	// Convert entries to model. With continue_on_error, entries that can't be read completely
+	// keep the fields that could be read and the error is collected instead.
+	continueOnError := state.ContinueOnError.ValueBool()
+	state.ReadErrors = []types.String{}
 	state.Entries = make([]DirectoryEntry, 0, len(entries))
 	for _, entry := range entries {
-		entryPath := filepath.Join(state.Path.ValueString(), entry.Name())
+		entryPath := filepath.Join(remotePath, entry.Name())
+		entryType := ssh.FileType(entry.Mode())
+		dirEntry := DirectoryEntry{
+			Name:        types.StringValue(entry.Name()),
+			Path:        types.StringValue(entryPath),
+			Size:        types.Int64Value(entry.Size()),
+			IsDir:       types.BoolValue(entry.IsDir()),
+			Type:        types.StringValue(entryType),
+			Permissions: types.StringValue(fmt.Sprintf("%04o", ssh.PermissionBits(entry.Mode()))),
+			ModTime:     types.StringValue(entry.ModTime().Format(time.RFC3339)),
+		}
+
 		ownership, err := client.GetFileOwnership(ctx, entryPath)
 		if err != nil {
-			resp.Diagnostics.AddError(
-				"Error reading entry ownership",
-				fmt.Sprintf("Could not read ownership for %s: %s", entryPath, err),
-			)
-			return
+			if !continueOnError {
+				resp.Diagnostics.AddError(
+					"Error reading entry ownership",
+					fmt.Sprintf("Could not read ownership for %s: %s", entryPath, err),
+				)
+				return
+			}
+			state.ReadErrors = append(state.ReadErrors, types.StringValue(fmt.Sprintf("could not read ownership for %s: %s", entryPath, err)))
+		} else {
+			dirEntry.Owner = types.StringValue(ownership.User)
+			dirEntry.Group = types.StringValue(ownership.Group)
 		}
 
 		attrs, err := client.GetFileAttributes(ctx, entryPath)
 		if err != nil {
-			resp.Diagnostics.AddError(
-				"Error reading entry attributes",
-				fmt.Sprintf("Could not read attributes for %s: %s", entryPath, err),
-			)
-			return
+			if !continueOnError {
+				resp.Diagnostics.AddError(
+					"Error reading entry attributes",
+					fmt.Sprintf("Could not read attributes for %s: %s", entryPath, err),
+				)
+				return
+			}
+			state.ReadErrors = append(state.ReadErrors, types.StringValue(fmt.Sprintf("could not read attributes for %s: %s", entryPath, err)))
+		} else {
+			dirEntry.Immutable = types.BoolValue(attrs.Immutable)
+			dirEntry.AppendOnly = types.BoolValue(attrs.AppendOnly)
+			dirEntry.NoDump = types.BoolValue(attrs.NoDump)
+			dirEntry.Synchronous = types.BoolValue(attrs.Synchronous)
+			dirEntry.NoAtime = types.BoolValue(attrs.NoAtime)
+			dirEntry.Compressed = types.BoolValue(attrs.Compressed)
+			dirEntry.NoCoW = types.BoolValue(attrs.NoCoW)
+			dirEntry.Undeletable = types.BoolValue(attrs.Undeletable)
+			dirEntry.DataJournaling = types.BoolValue(attrs.DataJournaling)
+			dirEntry.NoTailMerge = types.BoolValue(attrs.NoTailMerge)
+			dirEntry.TopDir = types.BoolValue(attrs.TopDir)
+			dirEntry.DirSync = types.BoolValue(attrs.DirSync)
+			dirEntry.Extents = types.BoolValue(attrs.Extents)
+			dirEntry.Encrypted = types.BoolValue(attrs.Encrypted)
 		}
 
-		state.Entries = append(state.Entries, DirectoryEntry{
-			Name:        types.StringValue(entry.Name()),
-			Path:        types.StringValue(entryPath),
-			Size:        types.Int64Value(entry.Size()),
-			IsDir:       types.BoolValue(entry.IsDir()),
-			Permissions: types.StringValue(fmt.Sprintf("%04o", entry.Mode().Perm())),
-			Owner:       types.StringValue(ownership.User),
-			Group:       types.StringValue(ownership.Group),
-			Immutable:   types.BoolValue(attrs.Immutable),
-			AppendOnly:  types.BoolValue(attrs.AppendOnly),
-			NoDump:      types.BoolValue(attrs.NoDump),
-			Synchronous: types.BoolValue(attrs.Synchronous),
-			NoAtime:     types.BoolValue(attrs.NoAtime),
-			Compressed:  types.BoolValue(attrs.Compressed),
-			NoCoW:       types.BoolValue(attrs.NoCoW),
-			Undeletable: types.BoolValue(attrs.Undeletable),
-			ModTime:     types.StringValue(entry.ModTime().Format(time.RFC3339)),
-		})
+		state.Entries = append(state.Entries, dirEntry)
+	}
+
+	if len(state.ReadErrors) > 0 {
+		resp.Diagnostics.AddWarning(
+			"Some directory entries could not be read",
+			fmt.Sprintf("%d errors occurred while reading the entries of %s, the affected fields are null. See read_errors for details.", len(state.ReadErrors), state.Path.ValueString()),
+		)
 	}
 
 	diags = resp.State.Set(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 }
 
-func (d *DirectoryDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
-	if req.ProviderData == nil {
+func (d *DirectoryDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
 		return
 	}
-}
-
-func (d *DirectoryDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel) (*ssh.SSHClient, error) {
-	port := int(sshBlock.Port.ValueInt64())
-	if port == 0 {
-		port = 22
+	if providerData == nil {
+		return
 	}
 
-	config := ssh.SSHConfig{
-		Host:       sshBlock.Host.ValueString(),
-		Port:       port,
-		Username:   sshBlock.Username.ValueString(),
-		Password:   sshBlock.Password.ValueString(),
-		PrivateKey: sshBlock.PrivateKey.ValueString(),
-	}
+	d.connections = providerData.Connections
+}
 
-	client, err := d.pool.GetClient(ctx, config)
+func (d *DirectoryDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String) (*ssh.SSHClient, func(), error) {
+	config, err := d.connections.Config(sshBlock, connection)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Release the client when the context is done
-	go func() {
-		<-ctx.Done()
-		d.pool.ReleaseClient(config)
-	}()
-
-	return client, nil
+	return d.pool.Acquire(ctx, config, false)
 }
@@ -0,0 +1,191 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ datasource.DataSource                     = &EnvironmentDataSource{}
+	_ datasource.DataSourceWithConfigure        = &EnvironmentDataSource{}
+	_ datasource.DataSourceWithConfigValidators = &EnvironmentDataSource{}
+)
+
+// EnvironmentDataSource defines the data source implementation.
+type EnvironmentDataSource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// EnvironmentDataSourceModel describes the data source data model.
+type EnvironmentDataSourceModel struct {
+	SSH        *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection types.String       `tfsdk:"connection"`
+	Variables  []types.String     `tfsdk:"variables"`
+	Shell      types.String       `tfsdk:"shell"`
+	Login      types.Bool         `tfsdk:"login"`
+	Values     types.Map          `tfsdk:"values"`
+	ID         types.String       `tfsdk:"id"`
+}
+
+// NewEnvironmentDataSource creates a new data source implementation.
+func NewEnvironmentDataSource(pool *ssh.SSHPool) datasource.DataSource {
+	return &EnvironmentDataSource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the data source type name.
+func (d *EnvironmentDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_environment"
+}
+
+// ConfigValidators returns the validators of the data source configuration.
+func (d *EnvironmentDataSource) ConfigValidators(_ context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the data source.
+func (d *EnvironmentDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads environment variables of the SSH user as a login shell on the remote server sees them, including those set in shell profiles.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockDataSourceSchema(),
+			},
+			"variables": schema.ListAttribute{
+				Description: "The names of the environment variables to read (e.g., ['PATH', 'JAVA_HOME']).",
+				Required:    true,
+				ElementType: types.StringType,
+			},
+			"shell": schema.StringAttribute{
+				Description: "The absolute path of the shell that reads the variables. Defaults to '/bin/bash'.",
+				Optional:    true,
+			},
+			"login": schema.BoolAttribute{
+				Description: "Whether the shell runs as a login shell, which reads /etc/profile and the profile of the user. Defaults to true.",
+				Optional:    true,
+			},
+			"values": schema.MapAttribute{
+				Description: "The values of the variables that are set, by name. Variables that are not set are left out.",
+				Computed:    true,
+				ElementType: types.StringType,
+			},
+			"id": schema.StringAttribute{
+				Description: "The host of the remote server.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (d *EnvironmentDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "EnvironmentDataSource.Read")
+	defer span.End()
+
+	var state EnvironmentDataSourceModel
+	diags := req.Config.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	shell := ssh.DefaultEnvironmentShell
+	if !state.Shell.IsNull() {
+		shell = state.Shell.ValueString()
+	}
+	if err := ssh.ValidateShell(shell); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("shell"),
+			"Invalid shell",
+			fmt.Sprintf("Could not use the shell: %s", err),
+		)
+	}
+	names := make([]string, 0, len(state.Variables))
+	for i, variable := range state.Variables {
+		if err := ssh.ValidateEnvName(variable.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("variables").AtListIndex(i),
+				"Invalid environment variable",
+				fmt.Sprintf("Could not read the environment variable: %s", err),
+			)
+		}
+		names = append(names, variable.ValueString())
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := d.getClient(ctx, state.SSH, state.Connection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	login := state.Login.IsNull() || state.Login.ValueBool()
+	values, err := client.ReadEnvironment(ctx, shell, login, names)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading environment",
+			fmt.Sprintf("Could not read environment variables: %s", err),
+		)
+		return
+	}
+
+	state.Values, diags = types.MapValueFrom(ctx, types.StringType, values)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	// getClient already resolved the configuration successfully, so this can't fail
+	config, _ := d.connections.Config(state.SSH, state.Connection)
+	state.ID = types.StringValue(config.Host)
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (d *EnvironmentDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	d.connections = providerData.Connections
+}
+
+func (d *EnvironmentDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String) (*ssh.SSHClient, func(), error) {
+	config, err := d.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.pool.Acquire(ctx, config, false)
+}
@@ -0,0 +1,197 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ datasource.DataSource                     = &FileChecksumDataSource{}
+	_ datasource.DataSourceWithConfigure        = &FileChecksumDataSource{}
+	_ datasource.DataSourceWithConfigValidators = &FileChecksumDataSource{}
+)
+
+// FileChecksumDataSource defines the data source implementation.
+type FileChecksumDataSource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// FileChecksumDataSourceModel describes the data source data model.
+type FileChecksumDataSourceModel struct {
+	SSH        *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection types.String       `tfsdk:"connection"`
+	Path       types.String       `tfsdk:"path"`
+	Algorithm  types.String       `tfsdk:"algorithm"`
+	Checksum   types.String       `tfsdk:"checksum"`
+	Size       types.Int64        `tfsdk:"size"`
+	ID         types.String       `tfsdk:"id"`
+}
+
+// NewFileChecksumDataSource creates a new data source implementation.
+func NewFileChecksumDataSource(pool *ssh.SSHPool) datasource.DataSource {
+	return &FileChecksumDataSource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the data source type name.
+func (d *FileChecksumDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_file_checksum"
+}
+
+// ConfigValidators returns the validators of the data source configuration.
+func (d *FileChecksumDataSource) ConfigValidators(_ context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the data source.
+func (d *FileChecksumDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Computes the checksum of a file on a remote server via SSH without reading its content into state.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockDataSourceSchema(),
+			},
+			"path": schema.StringAttribute{
+				Description: "The path of the file on the remote server.",
+				Required:    true,
+			},
+			"algorithm": schema.StringAttribute{
+				Description: fmt.Sprintf("The checksum algorithm, one of %s. Defaults to 'sha256'.", strings.Join(ssh.ChecksumAlgorithms, ", ")),
+				Optional:    true,
+			},
+			"checksum": schema.StringAttribute{
+				Description: "The hex encoded checksum of the file.",
+				Computed:    true,
+			},
+			"size": schema.Int64Attribute{
+				Description: "The size of the file in bytes.",
+				Computed:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The path of the file.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (d *FileChecksumDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FileChecksumDataSource.Read")
+	defer span.End()
+
+	var state FileChecksumDataSourceModel
+	diags := req.Config.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	algorithm := state.Algorithm.ValueString()
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+	if !slices.Contains(ssh.ChecksumAlgorithms, algorithm) {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("algorithm"),
+			"Invalid checksum algorithm",
+			fmt.Sprintf("Expected one of %s, got %q.", strings.Join(ssh.ChecksumAlgorithms, ", "), algorithm),
+		)
+		return
+	}
+
+	client, release, err := d.getClient(ctx, state.SSH, state.Connection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	remotePath, err := client.ResolvePath(ctx, state.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error resolving path",
+			fmt.Sprintf("Could not resolve path: %s", err),
+		)
+		return
+	}
+
+	fileInfo, err := client.SftpClient.Stat(remotePath)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading file information",
+			fmt.Sprintf("Could not read file information: %s", err),
+		)
+		return
+	}
+	if fileInfo.IsDir() {
+		resp.Diagnostics.AddError(
+			"Path is a directory",
+			fmt.Sprintf("The path %s exists but is a directory", state.Path.ValueString()),
+		)
+		return
+	}
+
+	checksum, err := client.FileDigest(ctx, state.Path.ValueString(), algorithm)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error computing file checksum",
+			fmt.Sprintf("Could not compute file checksum: %s", err),
+		)
+		return
+	}
+
+	state.Checksum = types.StringValue(checksum)
+	state.Size = types.Int64Value(fileInfo.Size())
+	state.ID = types.StringValue(state.Path.ValueString())
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (d *FileChecksumDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	d.connections = providerData.Connections
+}
+
+func (d *FileChecksumDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String) (*ssh.SSHClient, func(), error) {
+	config, err := d.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.pool.Acquire(ctx, config, false)
+}
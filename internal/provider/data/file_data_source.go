@@ -2,44 +2,73 @@ package data
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"io"
+	"math/big"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-framework/attr"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
 	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"go.opentelemetry.io/otel"
 )
 
+// decodeJSON is the decode format for JSON content
+const decodeJSON = "json"
+
 var (
-	_ datasource.DataSource              = &FileDataSource{}
-	_ datasource.DataSourceWithConfigure = &FileDataSource{}
+	_ datasource.DataSource                     = &FileDataSource{}
+	_ datasource.DataSourceWithConfigure        = &FileDataSource{}
+	_ datasource.DataSourceWithConfigValidators = &FileDataSource{}
 )
 
 // FileDataSource defines the data source implementation.
 type FileDataSource struct {
-	pool *ssh.SSHPool
+	pool        *ssh.SSHPool
+	connections ssh.Connections
 }
 
 // FileDataSourceModel describes the data source data model.
 type FileDataSourceModel struct {
-	SSH         *ssh.SSHBlockModel `tfsdk:"ssh"`
-	Path        types.String       `tfsdk:"path"`
-	Content     types.String       `tfsdk:"content"`
-	Permissions types.String       `tfsdk:"permissions"`
-	Owner       types.String       `tfsdk:"owner"`
-	Group       types.String       `tfsdk:"group"`
-	Immutable   types.Bool         `tfsdk:"immutable"`
-	AppendOnly  types.Bool         `tfsdk:"append_only"`
-	NoDump      types.Bool         `tfsdk:"no_dump"`
-	Synchronous types.Bool         `tfsdk:"synchronous"`
-	NoAtime     types.Bool         `tfsdk:"no_atime"`
-	Compressed  types.Bool         `tfsdk:"compressed"`
-	NoCoW       types.Bool         `tfsdk:"no_cow"`
-	Undeletable types.Bool         `tfsdk:"undeletable"`
-	Exists      types.Bool         `tfsdk:"exists"`
-	ID          types.String       `tfsdk:"id"`
+	SSH            *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection     types.String       `tfsdk:"connection"`
+	Path           types.String       `tfsdk:"path"`
+	Content        types.String       `tfsdk:"content"`
+	SplitLines     types.Bool         `tfsdk:"split_lines"`
+	Lines          types.List         `tfsdk:"lines"`
+	Decode         types.String       `tfsdk:"decode"`
+	Decoded        types.Dynamic      `tfsdk:"decoded"`
+	MaxReadSize    types.Int64        `tfsdk:"max_read_size"`
+	MetadataOnly   types.Bool         `tfsdk:"metadata_only"`
+	Size           types.Int64        `tfsdk:"size"`
+	ModTime        types.String       `tfsdk:"mod_time"`
+	Permissions    types.String       `tfsdk:"permissions"`
+	Owner          types.String       `tfsdk:"owner"`
+	Group          types.String       `tfsdk:"group"`
+	Immutable      types.Bool         `tfsdk:"immutable"`
+	AppendOnly     types.Bool         `tfsdk:"append_only"`
+	NoDump         types.Bool         `tfsdk:"no_dump"`
+	Synchronous    types.Bool         `tfsdk:"synchronous"`
+	NoAtime        types.Bool         `tfsdk:"no_atime"`
+	Compressed     types.Bool         `tfsdk:"compressed"`
+	NoCoW          types.Bool         `tfsdk:"no_cow"`
+	Undeletable    types.Bool         `tfsdk:"undeletable"`
+	DataJournaling types.Bool         `tfsdk:"data_journaling"`
+	NoTailMerge    types.Bool         `tfsdk:"no_tail_merge"`
+	Extents        types.Bool         `tfsdk:"extents"`
+	Encrypted      types.Bool         `tfsdk:"encrypted"`
+	Exists         types.Bool         `tfsdk:"exists"`
+	FollowSymlinks types.Bool         `tfsdk:"follow_symlinks"`
+	IsSymlink      types.Bool         `tfsdk:"is_symlink"`
+	SymlinkTarget  types.String       `tfsdk:"symlink_target"`
+	ID             types.String       `tfsdk:"id"`
 }
 
 // NewFileDataSource creates a new data source implementation.
@@ -54,28 +83,79 @@ func (d *FileDataSource) Metadata(_ context.Context, req datasource.MetadataRequ
 	resp.TypeName = req.ProviderTypeName + "_file_info"
 }
 
+// ConfigValidators returns the validators of the data source configuration.
+func (d *FileDataSource) ConfigValidators(_ context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
 // Schema defines the schema for the data source.
 func (d *FileDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Description: "Reads information about a file on a remote server via SSH.",
 		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
 			"ssh": schema.SingleNestedAttribute{
-				Description: "SSH connection configuration.",
-				Required:    true,
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
 				Attributes:  ssh.SSHBlockDataSourceSchema(),
 			},
 			"path": schema.StringAttribute{
 				Description: "The path of the file on the remote server.",
 				Required:    true,
 			},
+			"follow_symlinks": schema.BoolAttribute{
+				Description: "If true, the metadata and content describe the target if path is a symbolic link. If false, permissions and ownership describe the link itself, and content and attributes are not set. Defaults to true.",
+				Optional:    true,
+			},
+			"max_read_size": schema.Int64Attribute{
+				Description: "Files larger than this many bytes are not read, to avoid loading huge files into memory and state. Defaults to 10 MiB (10485760), 0 disables the limit.",
+				Optional:    true,
+			},
+			"metadata_only": schema.BoolAttribute{
+				Description: "If true, only exists, size, permissions and mod_time are read, from a single stat of the file. " +
+					"The content, ownership and attributes are not read and remain unset, so no commands run on the remote server. " +
+					"Can't be combined with split_lines or decode.",
+				Optional: true,
+			},
 			"content": schema.StringAttribute{
 				Description: "The content of the file.",
 				Computed:    true,
 			},
+			"split_lines": schema.BoolAttribute{
+				Description: "If true, the content is also split into lines, which are available in lines.",
+				Optional:    true,
+			},
+			"lines": schema.ListAttribute{
+				Description: "The lines of the content, without line endings. A trailing newline doesn't produce an empty last line. Only set if split_lines is true.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"decode": schema.StringAttribute{
+				Description: "The format the content is parsed in, which is available in decoded. Only 'json' is supported. " +
+					"Reading fails if the content is not well-formed.",
+				Optional: true,
+			},
+			"decoded": schema.DynamicAttribute{
+				Description: "The parsed content, like jsondecode(content). Only set if decode is set.",
+				Computed:    true,
+			},
 			"permissions": schema.StringAttribute{
 				Description: "The file permissions in octal format (e.g., '0644').",
 				Computed:    true,
 			},
+			"size": schema.Int64Attribute{
+				Description: "The size of the file in bytes.",
+				Computed:    true,
+			},
+			"mod_time": schema.StringAttribute{
+				Description: "The last modification time in RFC3339 format.",
+				Computed:    true,
+			},
 			"owner": schema.StringAttribute{
 				Description: "The user owner of the file.",
 				Computed:    true,
@@ -116,10 +196,34 @@ func (d *FileDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, r
 				Description: "Whether content is saved when deleted.",
 				Computed:    true,
 			},
+			"data_journaling": schema.BoolAttribute{
+				Description: "Whether data journaling is enabled for the file.",
+				Computed:    true,
+			},
+			"no_tail_merge": schema.BoolAttribute{
+				Description: "Whether the file is not tail-merged with other files.",
+				Computed:    true,
+			},
+			"extents": schema.BoolAttribute{
+				Description: "Whether the file uses extents for mapping blocks. Read-only.",
+				Computed:    true,
+			},
+			"encrypted": schema.BoolAttribute{
+				Description: "Whether the file is encrypted by the filesystem. Read-only.",
+				Computed:    true,
+			},
 			"exists": schema.BoolAttribute{
 				Description: "Whether the file exists.",
 				Computed:    true,
 			},
+			"is_symlink": schema.BoolAttribute{
+				Description: "Whether path is a symbolic link.",
+				Computed:    true,
+			},
+			"symlink_target": schema.StringAttribute{
+				Description: "The target of the symbolic link as stored in the link, which may be relative. Only set if is_symlink is true.",
+				Computed:    true,
+			},
 			"id": schema.StringAttribute{
 				Description: "The path of the file.",
 				Computed:    true,
@@ -140,22 +244,67 @@ func (d *FileDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 		return
 	}
 
-	client, err := d.getClient(ctx, state.SSH)
+	state.Lines = types.ListNull(types.StringType)
+	state.Decoded = types.DynamicNull()
+
+	if !state.Decode.IsNull() && state.Decode.ValueString() != decodeJSON {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("decode"),
+			"Invalid decode format",
+			fmt.Sprintf("Expected %q, got %q.", decodeJSON, state.Decode.ValueString()),
+		)
+		return
+	}
+
+	// The content is not read for metadata_only, so nothing can be derived from it
+	if state.MetadataOnly.ValueBool() {
+		if state.SplitLines.ValueBool() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("split_lines"),
+				"Invalid attribute combination",
+				"split_lines can't be true if metadata_only is true, because the content is not read.",
+			)
+		}
+		if !state.Decode.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("decode"),
+				"Invalid attribute combination",
+				"decode can't be set if metadata_only is true, because the content is not read.",
+			)
+		}
+		if resp.Diagnostics.HasError() {
+			return
+		}
+	}
+
+	client, release, err := d.getClient(ctx, state.SSH, state.Connection)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			ssh.ConnectionErrorDetail(err),
 		)
 		return
 	}
-	defer client.Close()
+	defer release()
 
-	// Check if file exists
-	fileInfo, err := client.SftpClient.Stat(state.Path.ValueString())
+	remotePath, err := client.ResolvePath(ctx, state.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error resolving path",
+			fmt.Sprintf("Could not resolve path: %s", err),
+		)
+		return
+	}
+
+	state.ID = types.StringValue(state.Path.ValueString())
+	state.IsSymlink = types.BoolValue(false)
+	state.SymlinkTarget = types.StringNull()
+
+	// Check if file exists, without following a symbolic link
+	fileInfo, err := client.SftpClient.Lstat(remotePath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			state.Exists = types.BoolValue(false)
-			state.ID = types.StringValue(state.Path.ValueString())
 			diags = resp.State.Set(ctx, &state)
 			resp.Diagnostics.Append(diags...)
 			return
@@ -167,15 +316,61 @@ func (d *FileDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 		return
 	}
 
+	// The remaining metadata is read from infoPath, which is the target of a followed symbolic link
+	infoPath := state.Path.ValueString()
+	followSymlinks := state.FollowSymlinks.IsNull() || state.FollowSymlinks.ValueBool()
+	isSymlink := fileInfo.Mode()&os.ModeSymlink != 0
+	if isSymlink {
+		target, err := client.SftpClient.ReadLink(remotePath)
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error reading symbolic link",
+				fmt.Sprintf("Could not read the target of symbolic link %s: %s", remotePath, err),
+			)
+			return
+		}
+		state.IsSymlink = types.BoolValue(true)
+		state.SymlinkTarget = types.StringValue(target)
+
+		if followSymlinks {
+			fileInfo, err = client.SftpClient.Stat(remotePath)
+			if err == nil {
+				infoPath, err = client.SftpClient.RealPath(remotePath)
+			}
+			if err != nil {
+				// A link whose target doesn't exist is reported as a missing file
+				if os.IsNotExist(err) {
+					state.Exists = types.BoolValue(false)
+					diags = resp.State.Set(ctx, &state)
+					resp.Diagnostics.Append(diags...)
+					return
+				}
+				resp.Diagnostics.AddError(
+					"Error reading file information",
+					fmt.Sprintf("Could not read the target of symbolic link %s: %s", remotePath, err),
+				)
+				return
+			}
+		}
+	}
+
 	state.Exists = types.BoolValue(true)
-	state.ID = types.StringValue(state.Path.ValueString())
 
 	// Get file permissions
-	mode := fileInfo.Mode().Perm()
+	mode := ssh.PermissionBits(fileInfo.Mode())
 	state.Permissions = types.StringValue(fmt.Sprintf("%04o", mode))
+	state.Size = types.Int64Value(fileInfo.Size())
+	state.ModTime = types.StringValue(fileInfo.ModTime().Format(time.RFC3339))
+
+	// Only the stat is needed, skip the commands and the content read
+	if state.MetadataOnly.ValueBool() {
+		diags = resp.State.Set(ctx, &state)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
 
 	// Get file ownership
-	ownership, err := client.GetFileOwnership(ctx, state.Path.ValueString())
+	ownership, err := client.GetFileOwnership(ctx, infoPath)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error reading file ownership",
@@ -186,8 +381,15 @@ func (d *FileDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 	state.Owner = types.StringValue(ownership.User)
 	state.Group = types.StringValue(ownership.Group)
 
+	// A symbolic link that is not followed has neither attributes nor content of its own
+	if isSymlink && !followSymlinks {
+		diags = resp.State.Set(ctx, &state)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
+
 	// Get file attributes
-	attrs, err := client.GetFileAttributes(ctx, state.Path.ValueString())
+	attrs, err := client.GetFileAttributes(ctx, infoPath)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error reading file attributes",
@@ -203,52 +405,140 @@ func (d *FileDataSource) Read(ctx context.Context, req datasource.ReadRequest, r
 	state.Compressed = types.BoolValue(attrs.Compressed)
 	state.NoCoW = types.BoolValue(attrs.NoCoW)
 	state.Undeletable = types.BoolValue(attrs.Undeletable)
+	state.DataJournaling = types.BoolValue(attrs.DataJournaling)
+	state.NoTailMerge = types.BoolValue(attrs.NoTailMerge)
+	state.Extents = types.BoolValue(attrs.Extents)
+	state.Encrypted = types.BoolValue(attrs.Encrypted)
 
 	// Read file content
-	content, err := client.ReadFile(ctx, state.Path.ValueString())
+	maxReadSize := ssh.DefaultMaxReadSize
+	if !state.MaxReadSize.IsNull() {
+		maxReadSize = state.MaxReadSize.ValueInt64()
+	}
+	content, err := client.ReadFileLimited(ctx, infoPath, maxReadSize)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error reading file content",
-			fmt.Sprintf("Could not read file content: %s", err),
+			ssh.FileReadErrorDetail(err),
 		)
 		return
 	}
 	state.Content = types.StringValue(content)
 
+	if state.SplitLines.ValueBool() {
+		lines, diags := types.ListValueFrom(ctx, types.StringType, ssh.SplitLines(content))
+		resp.Diagnostics.Append(diags...)
+		state.Lines = lines
+	}
+
+	if state.Decode.ValueString() == decodeJSON {
+		decoded, err := decodeJSONContent(content)
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("decode"),
+				"Error decoding file content",
+				fmt.Sprintf("Could not parse %s as JSON: %s", state.Path.ValueString(), err),
+			)
+			return
+		}
+		state.Decoded = types.DynamicValue(decoded)
+	}
+
 	diags = resp.State.Set(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 }
 
-func (d *FileDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, _ *datasource.ConfigureResponse) {
-	if req.ProviderData == nil {
+func (d *FileDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
 		return
 	}
+	if providerData == nil {
+		return
+	}
+
+	d.connections = providerData.Connections
 }
 
-func (d *FileDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel) (*ssh.SSHClient, error) {
-	port := int(sshBlock.Port.ValueInt64())
-	if port == 0 {
-		port = 22
+func (d *FileDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String) (*ssh.SSHClient, func(), error) {
+	config, err := d.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	config := ssh.SSHConfig{
-		Host:       sshBlock.Host.ValueString(),
-		Port:       port,
-		Username:   sshBlock.Username.ValueString(),
-		Password:   sshBlock.Password.ValueString(),
-		PrivateKey: sshBlock.PrivateKey.ValueString(),
-	}
+	return d.pool.Acquire(ctx, config, false)
+}
 
-	client, err := d.pool.GetClient(ctx, config)
-	if err != nil {
+// decodeJSONContent parses JSON content into a value with the same types jsondecode produces: objects,
+// tuples, strings, numbers and bools. Numbers keep their full precision.
+func decodeJSONContent(content string) (attr.Value, error) {
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
 		return nil, err
 	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected content after the JSON value")
+	}
 
-	// Release the client when the context is done
-	go func() {
-		<-ctx.Done()
-		d.pool.ReleaseClient(config)
-	}()
+	return jsonValue(value)
+}
 
-	return client, nil
+// jsonValue converts a value decoded by encoding/json into a Terraform value
+func jsonValue(value any) (attr.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		// The type of a JSON null is unknown, a null string converts to null in any context
+		return types.StringNull(), nil
+	case bool:
+		return types.BoolValue(v), nil
+	case string:
+		return types.StringValue(v), nil
+	case json.Number:
+		number, _, err := big.ParseFloat(v.String(), 10, 512, big.ToNearestEven)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s: %w", v, err)
+		}
+		return types.NumberValue(number), nil
+	case []any:
+		elemTypes := make([]attr.Type, len(v))
+		elems := make([]attr.Value, len(v))
+		for i, elem := range v {
+			converted, err := jsonValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			elemTypes[i] = converted.Type(context.Background())
+			elems[i] = converted
+		}
+		tuple, diags := types.TupleValue(elemTypes, elems)
+		if diags.HasError() {
+			return nil, fmt.Errorf("invalid array: %v", diags)
+		}
+		return tuple, nil
+	case map[string]any:
+		attrTypes := make(map[string]attr.Type, len(v))
+		attrs := make(map[string]attr.Value, len(v))
+		for key, elem := range v {
+			converted, err := jsonValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			attrTypes[key] = converted.Type(context.Background())
+			attrs[key] = converted
+		}
+		object, diags := types.ObjectValue(attrTypes, attrs)
+		if diags.HasError() {
+			return nil, fmt.Errorf("invalid object: %v", diags)
+		}
+		return object, nil
+	default:
+		return nil, fmt.Errorf("unsupported JSON value of type %T", value)
+	}
 }
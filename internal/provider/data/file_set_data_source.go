@@ -0,0 +1,241 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ datasource.DataSource                     = &FileSetDataSource{}
+	_ datasource.DataSourceWithConfigure        = &FileSetDataSource{}
+	_ datasource.DataSourceWithConfigValidators = &FileSetDataSource{}
+)
+
+// FileSetDataSource defines the data source implementation.
+type FileSetDataSource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// FileSetEntry represents a path matching the pattern
+type FileSetEntry struct {
+	Name        types.String `tfsdk:"name"`
+	Path        types.String `tfsdk:"path"`
+	Size        types.Int64  `tfsdk:"size"`
+	IsDir       types.Bool   `tfsdk:"is_dir"`
+	Permissions types.String `tfsdk:"permissions"`
+	ModTime     types.String `tfsdk:"mod_time"`
+}
+
+// FileSetDataSourceModel describes the data source data model.
+type FileSetDataSourceModel struct {
+	SSH                *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection         types.String       `tfsdk:"connection"`
+	Pattern            types.String       `tfsdk:"pattern"`
+	IncludeDirectories types.Bool         `tfsdk:"include_directories"`
+	Paths              []types.String     `tfsdk:"paths"`
+	Files              []FileSetEntry     `tfsdk:"files"`
+	Count              types.Int64        `tfsdk:"count"`
+	ID                 types.String       `tfsdk:"id"`
+}
+
+// NewFileSetDataSource creates a new data source implementation.
+func NewFileSetDataSource(pool *ssh.SSHPool) datasource.DataSource {
+	return &FileSetDataSource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the data source type name.
+func (d *FileSetDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_file_set"
+}
+
+// ConfigValidators returns the validators of the data source configuration.
+func (d *FileSetDataSource) ConfigValidators(_ context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the data source.
+func (d *FileSetDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Lists the files on a remote server matching a glob pattern via SSH.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockDataSourceSchema(),
+			},
+			"pattern": schema.StringAttribute{
+				Description: "The glob pattern the paths must match (e.g., '/etc/nginx/conf.d/*.conf'). '*', '?' and character classes like '[a-z]' match within a single path segment, and can be used in every segment, e.g. '/srv/*/config/*.yaml'.",
+				Required:    true,
+			},
+			"include_directories": schema.BoolAttribute{
+				Description: "If true, directories matching the pattern are included. Defaults to false.",
+				Optional:    true,
+			},
+			"paths": schema.ListAttribute{
+				Description: "The matching paths, sorted.",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"files": schema.ListNestedAttribute{
+				Description: "The matching paths with their metadata, in the order of paths.",
+				Computed:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							Description: "The name of the file.",
+							Computed:    true,
+						},
+						"path": schema.StringAttribute{
+							Description: "The full path of the file.",
+							Computed:    true,
+						},
+						"size": schema.Int64Attribute{
+							Description: "The size of the file in bytes.",
+							Computed:    true,
+						},
+						"is_dir": schema.BoolAttribute{
+							Description: "Whether this entry is a directory.",
+							Computed:    true,
+						},
+						"permissions": schema.StringAttribute{
+							Description: "The permissions in octal format.",
+							Computed:    true,
+						},
+						"mod_time": schema.StringAttribute{
+							Description: "The last modification time in RFC3339 format.",
+							Computed:    true,
+						},
+					},
+				},
+			},
+			"count": schema.Int64Attribute{
+				Description: "The number of matching paths.",
+				Computed:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The glob pattern.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (d *FileSetDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FileSetDataSource.Read")
+	defer span.End()
+
+	var state FileSetDataSourceModel
+	diags := req.Config.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := d.getClient(ctx, state.SSH, state.Connection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	matches, err := client.Glob(ctx, state.Pattern.ValueString())
+	if err != nil {
+		if errors.Is(err, ssh.ErrBadPattern) {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("pattern"),
+				"Invalid glob pattern",
+				fmt.Sprintf("The pattern %q is malformed, e.g. because of an unclosed character class.", state.Pattern.ValueString()),
+			)
+			return
+		}
+		resp.Diagnostics.AddError(
+			"Error matching files",
+			fmt.Sprintf("Could not list the files matching %s: %s", state.Pattern.ValueString(), err),
+		)
+		return
+	}
+
+	state.ID = types.StringValue(state.Pattern.ValueString())
+	state.Paths = make([]types.String, 0, len(matches))
+	state.Files = make([]FileSetEntry, 0, len(matches))
+	for _, match := range matches {
+		info, err := client.SftpClient.Stat(match)
+		if err != nil {
+			// The file was removed after it was matched
+			if os.IsNotExist(err) {
+				continue
+			}
+			resp.Diagnostics.AddError(
+				"Error reading file information",
+				fmt.Sprintf("Could not read file information for %s: %s", match, err),
+			)
+			return
+		}
+		if info.IsDir() && !state.IncludeDirectories.ValueBool() {
+			continue
+		}
+
+		state.Paths = append(state.Paths, types.StringValue(match))
+		state.Files = append(state.Files, FileSetEntry{
+			Name:        types.StringValue(filepath.Base(match)),
+			Path:        types.StringValue(match),
+			Size:        types.Int64Value(info.Size()),
+			IsDir:       types.BoolValue(info.IsDir()),
+			Permissions: types.StringValue(fmt.Sprintf("%04o", ssh.PermissionBits(info.Mode()))),
+			ModTime:     types.StringValue(info.ModTime().Format(time.RFC3339)),
+		})
+	}
+	state.Count = types.Int64Value(int64(len(state.Paths)))
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (d *FileSetDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	d.connections = providerData.Connections
+}
+
+func (d *FileSetDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String) (*ssh.SSHClient, func(), error) {
+	config, err := d.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.pool.Acquire(ctx, config, false)
+}
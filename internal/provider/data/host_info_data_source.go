@@ -0,0 +1,216 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"path"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ datasource.DataSource                     = &HostInfoDataSource{}
+	_ datasource.DataSourceWithConfigure        = &HostInfoDataSource{}
+	_ datasource.DataSourceWithConfigValidators = &HostInfoDataSource{}
+)
+
+// HostInfoDataSource defines the data source implementation.
+type HostInfoDataSource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// HostInfoDataSourceModel describes the data source data model.
+type HostInfoDataSourceModel struct {
+	SSH                  *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection           types.String       `tfsdk:"connection"`
+	HomeDir              types.String       `tfsdk:"home_dir"`
+	Platform             types.String       `tfsdk:"platform"`
+	ServerVersion        types.String       `tfsdk:"server_version"`
+	DetectedSFTPRoot     types.String       `tfsdk:"detected_sftp_root"`
+	KexAlgorithm         types.String       `tfsdk:"kex_algorithm"`
+	HostKeyAlgorithm     types.String       `tfsdk:"host_key_algorithm"`
+	CipherClientToServer types.String       `tfsdk:"cipher_client_to_server"`
+	CipherServerToClient types.String       `tfsdk:"cipher_server_to_client"`
+	MACClientToServer    types.String       `tfsdk:"mac_client_to_server"`
+	MACServerToClient    types.String       `tfsdk:"mac_server_to_client"`
+	ID                   types.String       `tfsdk:"id"`
+}
+
+// NewHostInfoDataSource creates a new data source implementation.
+func NewHostInfoDataSource(pool *ssh.SSHPool) datasource.DataSource {
+	return &HostInfoDataSource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the data source type name.
+func (d *HostInfoDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_host_info"
+}
+
+// ConfigValidators returns the validators of the data source configuration.
+func (d *HostInfoDataSource) ConfigValidators(_ context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the data source.
+func (d *HostInfoDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads information about a remote server and the SSH user via SSH.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockDataSourceSchema(),
+			},
+			"home_dir": schema.StringAttribute{
+				Description: "The home directory of the SSH user, which paths starting with '~/' resolve to.",
+				Computed:    true,
+			},
+			"platform": schema.StringAttribute{
+				Description: "The operating system of the remote server (e.g., 'linux').",
+				Computed:    true,
+			},
+			"detected_sftp_root": schema.StringAttribute{
+				Description: "The directory the SFTP server appears to be chrooted to, derived from the SFTP working directory and the home directory. Empty if SFTP and remote commands see the same filesystem root.",
+				Computed:    true,
+			},
+			"server_version": schema.StringAttribute{
+				Description: "The version string the SSH server sent during the handshake (e.g., 'SSH-2.0-OpenSSH_9.6').",
+				Computed:    true,
+			},
+			"kex_algorithm": schema.StringAttribute{
+				Description: "The key exchange algorithm negotiated with the server (e.g., 'curve25519-sha256').",
+				Computed:    true,
+			},
+			"host_key_algorithm": schema.StringAttribute{
+				Description: "The host key algorithm negotiated with the server (e.g., 'ssh-ed25519').",
+				Computed:    true,
+			},
+			"cipher_client_to_server": schema.StringAttribute{
+				Description: "The cipher negotiated for data sent from the client to the server (e.g., 'chacha20-poly1305@openssh.com').",
+				Computed:    true,
+			},
+			"cipher_server_to_client": schema.StringAttribute{
+				Description: "The cipher negotiated for data sent from the server to the client.",
+				Computed:    true,
+			},
+			"mac_client_to_server": schema.StringAttribute{
+				Description: "The MAC negotiated for data sent from the client to the server. Empty for AEAD ciphers, which include integrity protection.",
+				Computed:    true,
+			},
+			"mac_server_to_client": schema.StringAttribute{
+				Description: "The MAC negotiated for data sent from the server to the client. Empty for AEAD ciphers, which include integrity protection.",
+				Computed:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The host of the remote server.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (d *HostInfoDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "HostInfoDataSource.Read")
+	defer span.End()
+
+	var state HostInfoDataSourceModel
+	diags := req.Config.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := d.getClient(ctx, state.SSH, state.Connection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	homeDir, err := client.HomeDir(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading home directory",
+			fmt.Sprintf("Could not read home directory: %s", err),
+		)
+		return
+	}
+
+	state.HomeDir = types.StringValue(homeDir)
+	state.Platform = types.StringValue(client.Platform(ctx))
+	// getClient already resolved the configuration successfully, so this can't fail
+	config, _ := d.connections.Config(state.SSH, state.Connection)
+	state.ID = types.StringValue(config.Host)
+
+	sftpRoot, err := client.DetectSFTPRoot(ctx)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error detecting SFTP root",
+			fmt.Sprintf("Could not compare the SFTP working directory with the home directory: %s", err),
+		)
+		return
+	}
+	state.DetectedSFTPRoot = types.StringValue(sftpRoot)
+	if path.Clean("/"+sftpRoot) != path.Clean("/"+config.SFTPRoot) {
+		resp.Diagnostics.AddWarning(
+			"SFTP root mismatch",
+			fmt.Sprintf("The SFTP server appears to be chrooted to %q, but sftp_root is %q. File operations over SFTP and "+
+				"operations that run remote commands, such as setting ownership or attributes, may target different files. "+
+				"Set sftp_root in the ssh block to the directory the SFTP server is chrooted to.", sftpRoot, config.SFTPRoot),
+		)
+	}
+
+	state.ServerVersion = types.StringValue(client.ServerVersion())
+	algorithms := client.Algorithms()
+	state.KexAlgorithm = types.StringValue(algorithms.KeyExchange)
+	state.HostKeyAlgorithm = types.StringValue(algorithms.HostKey)
+	state.CipherClientToServer = types.StringValue(algorithms.CipherClientServer)
+	state.CipherServerToClient = types.StringValue(algorithms.CipherServerClient)
+	state.MACClientToServer = types.StringValue(algorithms.MACClientServer)
+	state.MACServerToClient = types.StringValue(algorithms.MACServerClient)
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (d *HostInfoDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	d.connections = providerData.Connections
+}
+
+func (d *HostInfoDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String) (*ssh.SSHClient, func(), error) {
+	config, err := d.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.pool.Acquire(ctx, config, false)
+}
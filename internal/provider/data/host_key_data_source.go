@@ -0,0 +1,154 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var (
+	_ datasource.DataSource                     = &HostKeyDataSource{}
+	_ datasource.DataSourceWithConfigure        = &HostKeyDataSource{}
+	_ datasource.DataSourceWithConfigValidators = &HostKeyDataSource{}
+)
+
+// HostKeyDataSource defines the data source implementation.
+type HostKeyDataSource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// HostKeyDataSourceModel describes the data source data model.
+type HostKeyDataSourceModel struct {
+	SSH         *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection  types.String       `tfsdk:"connection"`
+	Fingerprint types.String       `tfsdk:"fingerprint"`
+	KeyType     types.String       `tfsdk:"key_type"`
+	PublicKey   types.String       `tfsdk:"public_key"`
+	ID          types.String       `tfsdk:"id"`
+}
+
+// NewHostKeyDataSource creates a new data source implementation.
+func NewHostKeyDataSource(pool *ssh.SSHPool) datasource.DataSource {
+	return &HostKeyDataSource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the data source type name.
+func (d *HostKeyDataSource) Metadata(_ context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_host_key_info"
+}
+
+// ConfigValidators returns the validators of the data source configuration.
+func (d *HostKeyDataSource) ConfigValidators(_ context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the data source.
+func (d *HostKeyDataSource) Schema(_ context.Context, _ datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads the host key a remote server presented when connecting via SSH.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockDataSourceSchema(),
+			},
+			"fingerprint": schema.StringAttribute{
+				Description: "The SHA256 fingerprint of the host key (e.g., 'SHA256:...').",
+				Computed:    true,
+			},
+			"key_type": schema.StringAttribute{
+				Description: "The type of the host key (e.g., 'ssh-ed25519').",
+				Computed:    true,
+			},
+			"public_key": schema.StringAttribute{
+				Description: "The host key in authorized_keys format.",
+				Computed:    true,
+			},
+			"id": schema.StringAttribute{
+				Description: "The fingerprint of the host key.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (d *HostKeyDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "HostKeyDataSource.Read")
+	defer span.End()
+
+	var state HostKeyDataSourceModel
+	diags := req.Config.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := d.getClient(ctx, state.SSH, state.Connection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	hostKey := client.HostKey()
+	if hostKey == nil {
+		resp.Diagnostics.AddError(
+			"Error reading host key",
+			"The SSH connection did not record a host key",
+		)
+		return
+	}
+
+	state.Fingerprint = types.StringValue(client.HostKeyFingerprint())
+	state.KeyType = types.StringValue(hostKey.Type())
+	state.PublicKey = types.StringValue(strings.TrimSpace(string(gossh.MarshalAuthorizedKey(hostKey))))
+	state.ID = state.Fingerprint
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+func (d *HostKeyDataSource) Configure(_ context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	d.connections = providerData.Connections
+}
+
+func (d *HostKeyDataSource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String) (*ssh.SSHClient, func(), error) {
+	config, err := d.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return d.pool.Acquire(ctx, config, false)
+}
@@ -0,0 +1,84 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccAuthorizedKeysDataSource(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), ssh.SSHConfig{
+		Host:     "localhost",
+		Port:     2222,
+		Username: "testuser",
+		Password: "testpass",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	first, err := ssh.GenerateKeyPair(ssh.KeyTypeEd25519, 0, "first@example.com")
+	require.NoError(t, err)
+	second, err := ssh.GenerateKeyPair(ssh.KeyTypeECDSA, 0, "")
+	require.NoError(t, err)
+
+	testFilePath := "/home/testuser/authorized_keys_" + rand.Text()
+	content := "# managed by test\n" + first.PublicKey + "\n" + `no-pty,command="uptime" ` + second.PublicKey + "\n"
+	require.NoError(t, client.CreateFile(context.Background(), testFilePath, content, 0600))
+	defer client.DeleteFile(context.Background(), testFilePath)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAuthorizedKeysDataSourceConfig(testFilePath),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "exists", "true"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.#", "2"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.0.line", "2"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.0.type", "ssh-ed25519"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.0.comment", "first@example.com"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.0.options.#", "0"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.0.public_key", strings.TrimSuffix(first.PublicKey, " first@example.com")),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.1.type", "ecdsa-sha2-nistp256"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.1.options.#", "2"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.1.options.0", "no-pty"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.1.options.1", `command="uptime"`),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "fingerprints.#", "2"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "fingerprints.0", first.Fingerprint),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "fingerprints.1", second.Fingerprint),
+				),
+			},
+			// A missing file has no keys
+			{
+				Config: testAccAuthorizedKeysDataSourceConfig(testFilePath + ".missing"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "exists", "false"),
+					resource.TestCheckResourceAttr("data.ssh_authorized_keys.test", "keys.#", "0"),
+				),
+			},
+		},
+	})
+}
+
+func testAccAuthorizedKeysDataSourceConfig(path string) string {
+	return fmt.Sprintf(`
+data "ssh_authorized_keys" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path = %q
+}
+`, path)
+}
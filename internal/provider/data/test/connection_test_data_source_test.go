@@ -0,0 +1,58 @@
+package test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccConnectionTestDataSource(t *testing.T) {
+	t.Parallel()
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccConnectionTestDataSourceConfig("testpass"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_connection_test.test", "connected", "true"),
+					resource.TestMatchResourceAttr("data.ssh_connection_test.test", "server_version", regexp.MustCompile(`^SSH-2\.0-`)),
+					resource.TestCheckResourceAttrSet("data.ssh_connection_test.test", "latency_ms"),
+					resource.TestCheckResourceAttr("data.ssh_connection_test.test", "id", "localhost"),
+					resource.TestCheckResourceAttrSet("data.ssh_connection_test.test", "kex_algorithm"),
+					resource.TestCheckResourceAttrSet("data.ssh_connection_test.test", "host_key_algorithm"),
+					resource.TestCheckResourceAttrSet("data.ssh_connection_test.test", "cipher_client_to_server"),
+					resource.TestCheckResourceAttrSet("data.ssh_connection_test.test", "cipher_server_to_client"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccConnectionTestDataSourceAuthFailure(t *testing.T) {
+	t.Parallel()
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccConnectionTestDataSourceConfig("wrongpass"),
+				ExpectError: regexp.MustCompile(`Error connecting to SSH server`),
+			},
+		},
+	})
+}
+
+func testAccConnectionTestDataSourceConfig(password string) string {
+	return `
+data "ssh_connection_test" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "` + password + `"
+  }
+}
+`
+}
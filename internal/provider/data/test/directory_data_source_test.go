@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"fmt"
+	"regexp"
 	"testing"
 
 	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
@@ -56,6 +57,7 @@ func TestAccDirectoryDataSource(t *testing.T) {
 					resource.TestCheckResourceAttr("data.ssh_directory_info.test", "entries.0.path", testFilePath),
 					resource.TestCheckResourceAttr("data.ssh_directory_info.test", "entries.0.permissions", "0644"),
 					resource.TestCheckResourceAttr("data.ssh_directory_info.test", "entries.0.is_dir", "false"),
+					resource.TestCheckResourceAttr("data.ssh_directory_info.test", "entries.0.type", "file"),
 				),
 			},
 			// Test non-existent directory
@@ -82,3 +84,133 @@ data "ssh_directory_info" "test" {
 }
 `, path)
 }
+
+func TestAccDirectoryDataSourceContinueOnError(t *testing.T) {
+	t.Parallel()
+
+	sshConfig := ssh.SSHConfig{
+		Host:     "localhost",
+		Port:     2222,
+		Username: "testuser",
+		Password: "testpass",
+	}
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	testDirPath := "/home/testuser/testdir_" + rand.Text()
+	lockedDirPath := testDirPath + "/locked"
+
+	// The attributes of a directory without any permissions can't be read by its owner
+	require.NoError(t, client.CreateDirectory(context.Background(), lockedDirPath, 0755))
+	require.NoError(t, client.SetFileMode(context.Background(), lockedDirPath, 0000))
+	defer func() {
+		_ = client.SetFileMode(context.Background(), lockedDirPath, 0755)
+		_ = client.DeleteDirectory(context.Background(), testDirPath)
+	}()
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccDirectoryDataSourceContinueOnErrorConfig(testDirPath, false),
+				ExpectError: regexp.MustCompile("Error reading entry attributes"),
+			},
+			{
+				Config: testAccDirectoryDataSourceContinueOnErrorConfig(testDirPath, true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_directory_info.test", "entries.#", "1"),
+					resource.TestCheckResourceAttr("data.ssh_directory_info.test", "entries.0.name", "locked"),
+					resource.TestCheckResourceAttr("data.ssh_directory_info.test", "entries.0.owner", "testuser"),
+					resource.TestCheckNoResourceAttr("data.ssh_directory_info.test", "entries.0.immutable"),
+					resource.TestCheckResourceAttr("data.ssh_directory_info.test", "read_errors.#", "1"),
+					resource.TestMatchResourceAttr("data.ssh_directory_info.test", "read_errors.0", regexp.MustCompile("could not read attributes for "+lockedDirPath)),
+				),
+			},
+		},
+	})
+}
+
+func testAccDirectoryDataSourceContinueOnErrorConfig(path string, continueOnError bool) string {
+	return fmt.Sprintf(`
+data "ssh_directory_info" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path              = %q
+  continue_on_error = %t
+}
+`, path, continueOnError)
+}
+
+func TestAccDirectoryDataSourceEntryTypes(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), ssh.SSHConfig{
+		Host:     "localhost",
+		Port:     2222,
+		Username: "testuser",
+		Password: "testpass",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	testDirPath := "/home/testuser/testdir_" + rand.Text()
+	require.NoError(t, client.CreateDirectory(context.Background(), testDirPath+"/subdir", 0755))
+	defer client.DeleteDirectory(context.Background(), testDirPath)
+	require.NoError(t, client.CreateFile(context.Background(), testDirPath+"/file", "content", 0644))
+	require.NoError(t, client.SftpClient.Symlink("file", testDirPath+"/link"))
+	_, err = client.RunCommand(context.Background(), "mkfifo "+testDirPath+"/fifo")
+	require.NoError(t, err)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDirectoryDataSourceEntryTypesConfig(testDirPath),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_directory_info.test", "entries.#", "4"),
+					resource.TestCheckTypeSetElemNestedAttrs("data.ssh_directory_info.test", "entries.*", map[string]string{
+						"name": "file",
+						"type": "file",
+						"size": "7",
+					}),
+					resource.TestCheckTypeSetElemNestedAttrs("data.ssh_directory_info.test", "entries.*", map[string]string{
+						"name":   "subdir",
+						"type":   "dir",
+						"is_dir": "true",
+					}),
+					resource.TestCheckTypeSetElemNestedAttrs("data.ssh_directory_info.test", "entries.*", map[string]string{
+						"name": "link",
+						"type": "symlink",
+					}),
+					resource.TestCheckTypeSetElemNestedAttrs("data.ssh_directory_info.test", "entries.*", map[string]string{
+						"name":   "fifo",
+						"type":   "fifo",
+						"is_dir": "false",
+						"size":   "0",
+					}),
+				),
+			},
+		},
+	})
+}
+
+func testAccDirectoryDataSourceEntryTypesConfig(path string) string {
+	return fmt.Sprintf(`
+data "ssh_directory_info" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path              = %q
+  continue_on_error = true
+}
+`, path)
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccEnvironmentDataSource(t *testing.T) {
+	t.Parallel()
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccEnvironmentDataSourceConfig(`variables = ["HOME", "PATH", "TERRAFORM_PROVIDER_SSH_UNSET"]
+  shell     = "/bin/sh"`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_environment.test", "values.HOME", "/home/testuser"),
+					resource.TestCheckResourceAttrSet("data.ssh_environment.test", "values.PATH"),
+					resource.TestCheckNoResourceAttr("data.ssh_environment.test", "values.TERRAFORM_PROVIDER_SSH_UNSET"),
+					resource.TestCheckResourceAttr("data.ssh_environment.test", "id", "localhost"),
+				),
+			},
+			{
+				Config: testAccEnvironmentDataSourceConfig(`variables = ["HOME"]
+  shell     = "/bin/sh"
+  login     = false`),
+				Check: resource.TestCheckResourceAttr("data.ssh_environment.test", "values.HOME", "/home/testuser"),
+			},
+			{
+				Config:      testAccEnvironmentDataSourceConfig(`variables = ["NOT-VALID"]`),
+				ExpectError: regexp.MustCompile(`Invalid environment variable`),
+			},
+			{
+				Config: testAccEnvironmentDataSourceConfig(`variables = ["HOME"]
+  shell     = "bash"`),
+				ExpectError: regexp.MustCompile(`Invalid shell`),
+			},
+		},
+	})
+}
+
+func testAccEnvironmentDataSourceConfig(attributes string) string {
+	return `
+data "ssh_environment" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  ` + attributes + `
+}
+`
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccFileChecksumDataSource(t *testing.T) {
+	t.Parallel()
+
+	// Setup SSH client for verification
+	sshConfig := ssh.SSHConfig{
+		Host:     "localhost",
+		Port:     2222,
+		Username: "testuser",
+		Password: "testpass",
+	}
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	testFilePath := "/home/testuser/checksum.txt"
+	testContent := "Hello, World!"
+
+	// Create test file
+	err = client.CreateFile(context.Background(), testFilePath, testContent, 0644)
+	require.NoError(t, err)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Default algorithm
+			{
+				Config: testAccFileChecksumDataSourceConfig(testFilePath, ""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_checksum.test", "checksum", ssh.ContentChecksum(testContent)),
+					resource.TestCheckResourceAttr("data.ssh_file_checksum.test", "size", "13"),
+					resource.TestCheckNoResourceAttr("data.ssh_file_checksum.test", "content"),
+				),
+			},
+			{
+				Config: testAccFileChecksumDataSourceConfig(testFilePath, "sha512"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_checksum.test", "checksum", "374d794a95cdcfd8b35993185fef9ba368f160d8daf432d08ba9f1ed1e5abe6cc69291e0fa2fe0006a52570ef18c19def4e617c33ce52ef0a6e5fbe318cb0387"),
+				),
+			},
+			{
+				Config: testAccFileChecksumDataSourceConfig(testFilePath, "md5"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_checksum.test", "checksum", "65a8e27d8879283831b664bd8b7f0ad4"),
+				),
+			},
+			// Unsupported algorithm
+			{
+				Config:      testAccFileChecksumDataSourceConfig(testFilePath, "crc32"),
+				ExpectError: regexp.MustCompile("Invalid checksum algorithm"),
+			},
+		},
+	})
+
+	err = client.DeleteFile(context.Background(), testFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		t.Logf("Failed to cleanup test file: %v", err)
+	}
+}
+
+func testAccFileChecksumDataSourceConfig(path string, algorithm string) string {
+	algorithmAttr := ""
+	if algorithm != "" {
+		algorithmAttr = fmt.Sprintf("algorithm = %q", algorithm)
+	}
+
+	return fmt.Sprintf(`
+data "ssh_file_checksum" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path = %q
+  %s
+}
+`, path, algorithmAttr)
+}
@@ -2,8 +2,10 @@ package test
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"os"
+	"regexp"
 	"testing"
 
 	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
@@ -45,11 +47,26 @@ func TestAccFileDataSource(t *testing.T) {
 					resource.TestCheckResourceAttr("data.ssh_file_info.test", "content", testContent),
 					resource.TestCheckResourceAttr("data.ssh_file_info.test", "permissions", "0644"),
 					resource.TestCheckResourceAttr("data.ssh_file_info.test", "exists", "true"),
+					resource.TestCheckNoResourceAttr("data.ssh_file_info.test", "lines"),
 					resource.TestCheckResourceAttr("data.ssh_file_info.test", "ssh.host", "localhost"),
 					resource.TestCheckResourceAttr("data.ssh_file_info.test", "ssh.port", "2222"),
 					resource.TestCheckResourceAttr("data.ssh_file_info.test", "ssh.username", "testuser"),
 				),
 			},
+			// Lines are only set on request
+			{
+				Config: testAccFileDataSourceSplitLinesConfig(testFilePath),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "content", testContent),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "lines.#", "1"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "lines.0", testContent),
+				),
+			},
+			// Files above the size limit are not read
+			{
+				Config:      testAccFileDataSourceMaxReadSizeConfig(testFilePath, 5),
+				ExpectError: regexp.MustCompile(`larger than max_read_size`),
+			},
 			// Test non-existent file
 			{
 				Config: testAccFileDataSourceConfig("/home/testuser/nonexistent.txt"),
@@ -80,3 +97,243 @@ data "ssh_file_info" "test" {
 }
 `, path)
 }
+
+func testAccFileDataSourceMaxReadSizeConfig(path string, maxReadSize int) string {
+	return fmt.Sprintf(`
+data "ssh_file_info" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path          = %q
+  max_read_size = %d
+}
+`, path, maxReadSize)
+}
+
+func testAccFileDataSourceSplitLinesConfig(path string) string {
+	return fmt.Sprintf(`
+data "ssh_file_info" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path        = %q
+  split_lines = true
+}
+`, path)
+}
+
+func TestAccFileDataSourceDecodeJSON(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), ssh.SSHConfig{
+		Host:     "localhost",
+		Port:     2222,
+		Username: "testuser",
+		Password: "testpass",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	validPath := "/home/testuser/decode_" + rand.Text() + ".json"
+	invalidPath := "/home/testuser/decode_invalid_" + rand.Text() + ".json"
+	require.NoError(t, client.CreateFile(context.Background(), validPath, `{"server": {"port": 8080, "hosts": ["a", "b"]}, "debug": true}`, 0644))
+	require.NoError(t, client.CreateFile(context.Background(), invalidPath, `{"server": `, 0644))
+	defer client.DeleteFile(context.Background(), validPath)
+	defer client.DeleteFile(context.Background(), invalidPath)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileDataSourceDecodeConfig(validPath),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckOutput("port", "8080"),
+					resource.TestCheckOutput("host", "b"),
+					resource.TestCheckOutput("debug", "true"),
+				),
+			},
+			// Malformed content fails the read
+			{
+				Config:      testAccFileDataSourceDecodeConfig(invalidPath),
+				ExpectError: regexp.MustCompile(`Could not parse .* as JSON`),
+			},
+		},
+	})
+}
+
+func testAccFileDataSourceDecodeConfig(path string) string {
+	return fmt.Sprintf(`
+data "ssh_file_info" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path   = %q
+  decode = "json"
+}
+
+output "port" {
+  value = tostring(data.ssh_file_info.test.decoded.server.port)
+}
+
+output "host" {
+  value = data.ssh_file_info.test.decoded.server.hosts[1]
+}
+
+output "debug" {
+  value = tostring(data.ssh_file_info.test.decoded.debug)
+}
+`, path)
+}
+
+func TestAccFileDataSourceSymlink(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), ssh.SSHConfig{
+		Host:     "localhost",
+		Port:     2222,
+		Username: "testuser",
+		Password: "testpass",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	dir := "/home/testuser/symlink_" + rand.Text()
+	require.NoError(t, client.CreateDirectory(context.Background(), dir, 0755))
+	defer client.DeleteDirectory(context.Background(), dir)
+	require.NoError(t, client.CreateFile(context.Background(), dir+"/target", "target content", 0600))
+	require.NoError(t, client.SftpClient.Symlink("target", dir+"/link"))
+	require.NoError(t, client.SftpClient.Symlink("missing", dir+"/dangling"))
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The link is followed by default
+			{
+				Config: testAccFileDataSourceConfig(dir + "/link"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "is_symlink", "true"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "symlink_target", "target"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "content", "target content"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "permissions", "0600"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "exists", "true"),
+				),
+			},
+			// Without following, the link itself is described
+			{
+				Config: testAccFileDataSourceNoFollowConfig(dir + "/link"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "is_symlink", "true"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "symlink_target", "target"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "permissions", "0777"),
+					resource.TestCheckNoResourceAttr("data.ssh_file_info.test", "content"),
+				),
+			},
+			// A link to a missing target doesn't exist when followed
+			{
+				Config: testAccFileDataSourceConfig(dir + "/dangling"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "is_symlink", "true"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "symlink_target", "missing"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "exists", "false"),
+				),
+			},
+			// Regular files are no links
+			{
+				Config: testAccFileDataSourceConfig(dir + "/target"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "is_symlink", "false"),
+					resource.TestCheckNoResourceAttr("data.ssh_file_info.test", "symlink_target"),
+				),
+			},
+		},
+	})
+}
+
+func testAccFileDataSourceNoFollowConfig(path string) string {
+	return fmt.Sprintf(`
+data "ssh_file_info" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path            = %q
+  follow_symlinks = false
+}
+`, path)
+}
+
+func TestAccFileDataSourceMetadataOnly(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), ssh.SSHConfig{
+		Host:     "localhost",
+		Port:     2222,
+		Username: "testuser",
+		Password: "testpass",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	testFilePath := "/home/testuser/metadata_" + rand.Text()
+	require.NoError(t, client.CreateFile(context.Background(), testFilePath, "0123456789", 0640))
+	defer client.DeleteFile(context.Background(), testFilePath)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Only the stat is read
+			{
+				Config: testAccFileDataSourceMetadataOnlyConfig(testFilePath, ""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "exists", "true"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "size", "10"),
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "permissions", "0640"),
+					resource.TestCheckResourceAttrSet("data.ssh_file_info.test", "mod_time"),
+					resource.TestCheckNoResourceAttr("data.ssh_file_info.test", "content"),
+					resource.TestCheckNoResourceAttr("data.ssh_file_info.test", "owner"),
+					resource.TestCheckNoResourceAttr("data.ssh_file_info.test", "immutable"),
+				),
+			},
+			// Missing files are reported as usual
+			{
+				Config: testAccFileDataSourceMetadataOnlyConfig(testFilePath+"_missing", ""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_info.test", "exists", "false"),
+					resource.TestCheckNoResourceAttr("data.ssh_file_info.test", "size"),
+				),
+			},
+			// Nothing can be derived from content that isn't read
+			{
+				Config:      testAccFileDataSourceMetadataOnlyConfig(testFilePath, `decode = "json"`),
+				ExpectError: regexp.MustCompile("Invalid attribute combination"),
+			},
+		},
+	})
+}
+
+func testAccFileDataSourceMetadataOnlyConfig(path string, extra string) string {
+	return fmt.Sprintf(`
+data "ssh_file_info" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path          = %q
+  metadata_only = true
+  %s
+}
+`, path, extra)
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccFileSetDataSource(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), ssh.SSHConfig{
+		Host:     "localhost",
+		Port:     2222,
+		Username: "testuser",
+		Password: "testpass",
+	})
+	require.NoError(t, err)
+	defer client.Close()
+
+	dir := "/home/testuser/file_set_" + rand.Text()
+	require.NoError(t, client.CreateDirectory(context.Background(), dir+"/a/conf.d", 0755))
+	require.NoError(t, client.CreateDirectory(context.Background(), dir+"/b/conf.d/extra.conf", 0755))
+	defer client.DeleteDirectory(context.Background(), dir)
+	require.NoError(t, client.CreateFile(context.Background(), dir+"/b/conf.d/site.conf", "server {}", 0640))
+	require.NoError(t, client.CreateFile(context.Background(), dir+"/a/conf.d/default.conf", "", 0644))
+	require.NoError(t, client.CreateFile(context.Background(), dir+"/a/conf.d/README", "", 0644))
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Wildcards match in every segment, directories are left out
+			{
+				Config: testAccFileSetDataSourceConfig(dir+"/*/conf.d/*.conf", false),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "count", "2"),
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "paths.0", dir+"/a/conf.d/default.conf"),
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "paths.1", dir+"/b/conf.d/site.conf"),
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "files.1.name", "site.conf"),
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "files.1.size", "9"),
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "files.1.permissions", "0640"),
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "files.1.is_dir", "false"),
+				),
+			},
+			{
+				Config: testAccFileSetDataSourceConfig(dir+"/*/conf.d/*.conf", true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "count", "3"),
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "paths.1", dir+"/b/conf.d/extra.conf"),
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "files.1.is_dir", "true"),
+				),
+			},
+			// No match is an empty set
+			{
+				Config: testAccFileSetDataSourceConfig(dir+"/*/*.yaml", false),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "count", "0"),
+					resource.TestCheckResourceAttr("data.ssh_file_set.test", "paths.#", "0"),
+				),
+			},
+			{
+				Config:      testAccFileSetDataSourceConfig(dir+"/[a-", false),
+				ExpectError: regexp.MustCompile(`Invalid glob pattern`),
+			},
+		},
+	})
+}
+
+func testAccFileSetDataSourceConfig(pattern string, includeDirectories bool) string {
+	return fmt.Sprintf(`
+data "ssh_file_set" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  pattern             = %q
+  include_directories = %t
+}
+`, pattern, includeDirectories)
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+)
+
+func TestAccHostInfoDataSource(t *testing.T) {
+	t.Parallel()
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccHostInfoDataSourceConfig(),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_host_info.test", "home_dir", "/home/testuser"),
+					resource.TestCheckResourceAttr("data.ssh_host_info.test", "platform", "linux"),
+					resource.TestCheckResourceAttr("data.ssh_host_info.test", "id", "localhost"),
+					resource.TestCheckResourceAttr("data.ssh_host_info.test", "detected_sftp_root", ""),
+					resource.TestMatchResourceAttr("data.ssh_host_info.test", "server_version", regexp.MustCompile(`^SSH-2\.0-`)),
+					resource.TestCheckResourceAttrSet("data.ssh_host_info.test", "kex_algorithm"),
+					resource.TestCheckResourceAttrSet("data.ssh_host_info.test", "host_key_algorithm"),
+					resource.TestCheckResourceAttrSet("data.ssh_host_info.test", "cipher_client_to_server"),
+					resource.TestCheckResourceAttrSet("data.ssh_host_info.test", "cipher_server_to_client"),
+				),
+			},
+		},
+	})
+}
+
+func testAccHostInfoDataSourceConfig() string {
+	return `
+data "ssh_host_info" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+}
+`
+}
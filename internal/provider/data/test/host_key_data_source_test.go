@@ -0,0 +1,54 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccHostKeyDataSource(t *testing.T) {
+	t.Parallel()
+
+	// Setup SSH client to look up the expected fingerprint
+	sshConfig := ssh.SSHConfig{
+		Host:     "localhost",
+		Port:     2222,
+		Username: "testuser",
+		Password: "testpass",
+	}
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccHostKeyDataSourceConfig(),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("data.ssh_host_key_info.test", "fingerprint", client.HostKeyFingerprint()),
+					resource.TestCheckResourceAttr("data.ssh_host_key_info.test", "key_type", client.HostKey().Type()),
+					resource.TestCheckResourceAttrSet("data.ssh_host_key_info.test", "public_key"),
+				),
+			},
+		},
+	})
+}
+
+func testAccHostKeyDataSourceConfig() string {
+	return `
+data "ssh_host_key_info" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+}
+`
+}
@@ -0,0 +1,241 @@
+package ephemeral
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ ephemeral.EphemeralResource                     = &TunnelEphemeralResource{}
+	_ ephemeral.EphemeralResourceWithClose            = &TunnelEphemeralResource{}
+	_ ephemeral.EphemeralResourceWithConfigure        = &TunnelEphemeralResource{}
+	_ ephemeral.EphemeralResourceWithConfigValidators = &TunnelEphemeralResource{}
+)
+
+// openTunnels holds the tunnels opened by this provider process, keyed by the ID kept in private data
+var openTunnels sync.Map
+
+// openTunnel is a tunnel together with the connection dedicated to it
+type openTunnel struct {
+	client *ssh.SSHClient
+	tunnel *ssh.Tunnel
+}
+
+// tunnelPrivateData is stored in the private data between Open and Close
+type tunnelPrivateData struct {
+	ID string `json:"id"`
+}
+
+// TunnelEphemeralResource defines the ephemeral resource implementation.
+type TunnelEphemeralResource struct {
+	connections ssh.Connections
+}
+
+// TunnelEphemeralResourceModel describes the ephemeral resource data model.
+type TunnelEphemeralResourceModel struct {
+	SSH          *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection   types.String       `tfsdk:"connection"`
+	RemoteHost   types.String       `tfsdk:"remote_host"`
+	RemotePort   types.Int64        `tfsdk:"remote_port"`
+	LocalPort    types.Int64        `tfsdk:"local_port"`
+	LocalHost    types.String       `tfsdk:"local_host"`
+	LocalAddress types.String       `tfsdk:"local_address"`
+}
+
+// NewTunnelEphemeralResource creates a new ephemeral resource implementation.
+func NewTunnelEphemeralResource() ephemeral.EphemeralResource {
+	return &TunnelEphemeralResource{}
+}
+
+// Metadata returns the ephemeral resource type name.
+func (r *TunnelEphemeralResource) Metadata(_ context.Context, req ephemeral.MetadataRequest, resp *ephemeral.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_tunnel"
+}
+
+// ConfigValidators returns the validators of the ephemeral resource configuration.
+func (r *TunnelEphemeralResource) ConfigValidators(_ context.Context) []ephemeral.ConfigValidator {
+	return []ephemeral.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the ephemeral resource.
+func (r *TunnelEphemeralResource) Schema(_ context.Context, _ ephemeral.SchemaRequest, resp *ephemeral.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Forwards a local port to an address reachable from a remote server via SSH, for the duration of a Terraform run.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockEphemeralSchema(),
+			},
+			"remote_host": schema.StringAttribute{
+				Description: "The host to forward to, as seen from the remote server (e.g., 'localhost' or an internal hostname).",
+				Required:    true,
+			},
+			"remote_port": schema.Int64Attribute{
+				Description: "The port to forward to on the remote host.",
+				Required:    true,
+			},
+			"local_port": schema.Int64Attribute{
+				Description: "The local port to listen on. A free port is chosen if not set.",
+				Optional:    true,
+				Computed:    true,
+			},
+			"local_host": schema.StringAttribute{
+				Description: "The local host the tunnel listens on.",
+				Computed:    true,
+			},
+			"local_address": schema.StringAttribute{
+				Description: "The local address the tunnel listens on, in host:port format.",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+// Configure stores the named connections of the provider.
+func (r *TunnelEphemeralResource) Configure(_ context.Context, req ephemeral.ConfigureRequest, resp *ephemeral.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	r.connections = providerData.Connections
+}
+
+// Open opens the tunnel and keeps it running until Close is called.
+func (r *TunnelEphemeralResource) Open(ctx context.Context, req ephemeral.OpenRequest, resp *ephemeral.OpenResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "TunnelEphemeralResource.Open")
+	defer span.End()
+
+	var data TunnelEphemeralResourceModel
+	diags := req.Config.Get(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	config, err := r.connections.Config(data.SSH, data.Connection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+
+	// The tunnel gets its own connection, so it is not closed by the pool's idle cleanup
+	client, err := ssh.NewSSHClient(ctx, config)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+
+	localAddr := net.JoinHostPort("127.0.0.1", strconv.FormatInt(data.LocalPort.ValueInt64(), 10))
+	remoteAddr := net.JoinHostPort(data.RemoteHost.ValueString(), strconv.FormatInt(data.RemotePort.ValueInt64(), 10))
+
+	tunnel, err := client.OpenTunnel(ctx, localAddr, remoteAddr)
+	if err != nil {
+		client.Close()
+		resp.Diagnostics.AddError(
+			"Error opening tunnel",
+			fmt.Sprintf("Could not open tunnel to %s: %s", remoteAddr, err),
+		)
+		return
+	}
+
+	id := rand.Text()
+	openTunnels.Store(id, &openTunnel{client: client, tunnel: tunnel})
+
+	privateData, err := json.Marshal(tunnelPrivateData{ID: id})
+	if err == nil {
+		resp.Diagnostics.Append(resp.Private.SetKey(ctx, "tunnel", privateData)...)
+	}
+	if err != nil || resp.Diagnostics.HasError() {
+		closeTunnel(id)
+		resp.Diagnostics.AddError(
+			"Error opening tunnel",
+			fmt.Sprintf("Could not store tunnel reference: %v", err),
+		)
+		return
+	}
+
+	host, port, _ := net.SplitHostPort(tunnel.LocalAddr())
+	localPort, _ := strconv.ParseInt(port, 10, 64)
+	data.LocalHost = types.StringValue(host)
+	data.LocalPort = types.Int64Value(localPort)
+	data.LocalAddress = types.StringValue(tunnel.LocalAddr())
+
+	diags = resp.Result.Set(ctx, &data)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Close tears down the tunnel and its connection.
+func (r *TunnelEphemeralResource) Close(ctx context.Context, req ephemeral.CloseRequest, resp *ephemeral.CloseResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "TunnelEphemeralResource.Close")
+	defer span.End()
+
+	privateData, diags := req.Private.GetKey(ctx, "tunnel")
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() || privateData == nil {
+		return
+	}
+
+	var data tunnelPrivateData
+	if err := json.Unmarshal(privateData, &data); err != nil {
+		resp.Diagnostics.AddError(
+			"Error closing tunnel",
+			fmt.Sprintf("Could not read tunnel reference: %s", err),
+		)
+		return
+	}
+
+	if err := closeTunnel(data.ID); err != nil {
+		resp.Diagnostics.AddWarning(
+			"Error closing tunnel",
+			fmt.Sprintf("Could not close tunnel cleanly: %s", err),
+		)
+	}
+}
+
+// closeTunnel closes a tunnel opened by this process. Unknown IDs are ignored.
+func closeTunnel(id string) error {
+	value, ok := openTunnels.LoadAndDelete(id)
+	if !ok {
+		return nil
+	}
+
+	t := value.(*openTunnel)
+	if err := t.tunnel.Close(); err != nil {
+		t.client.Close()
+		return err
+	}
+	return t.client.Close()
+}
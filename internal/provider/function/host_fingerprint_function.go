@@ -0,0 +1,59 @@
+package function
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+var _ function.Function = &HostFingerprintFunction{}
+
+// HostFingerprintFunction computes the SHA256 fingerprint of a public key.
+type HostFingerprintFunction struct{}
+
+// NewHostFingerprintFunction creates a new function implementation.
+func NewHostFingerprintFunction() function.Function {
+	return &HostFingerprintFunction{}
+}
+
+// Metadata returns the function name.
+func (f *HostFingerprintFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "host_fingerprint"
+}
+
+// Definition defines the parameters and return type of the function.
+func (f *HostFingerprintFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary: "Computes the SHA256 fingerprint of a public key.",
+		Description: "Computes the SHA256 fingerprint of a public key in authorized_keys format (e.g., 'ssh-ed25519 AAAA...') " +
+			"or of a known_hosts line (e.g., 'example.com ssh-ed25519 AAAA...'), in the same format as the fingerprint " +
+			"attribute of the ssh_host_key_info data source (e.g., 'SHA256:...').",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "public_key",
+				Description: "The public key in authorized_keys format or a known_hosts line.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run computes the fingerprint.
+func (f *HostFingerprintFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var publicKey string
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &publicKey))
+	if resp.Error != nil {
+		return
+	}
+
+	fingerprint, err := ssh.PublicKeyFingerprint(publicKey)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Could not compute fingerprint: %s", err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, fingerprint))
+}
@@ -0,0 +1,59 @@
+package function
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+var _ function.Function = &ParsePermissionsFunction{}
+
+// ParsePermissionsFunction converts octal or symbolic file modes to the octal format of the permissions attributes.
+type ParsePermissionsFunction struct{}
+
+// NewParsePermissionsFunction creates a new function implementation.
+func NewParsePermissionsFunction() function.Function {
+	return &ParsePermissionsFunction{}
+}
+
+// Metadata returns the function name.
+func (f *ParsePermissionsFunction) Metadata(_ context.Context, _ function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "parse_permissions"
+}
+
+// Definition defines the parameters and return type of the function.
+func (f *ParsePermissionsFunction) Definition(_ context.Context, _ function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary: "Converts a symbolic or octal file mode to octal permissions.",
+		Description: "Converts a file mode in symbolic notation like chmod accepts (e.g., 'u=rwx,g=rx,o=') or octal notation " +
+			"(e.g., '750') to the four digit octal format used by the permissions attributes (e.g., '0750'). " +
+			"Symbolic clauses are applied to an empty mode.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "mode",
+				Description: "The file mode in symbolic or octal notation.",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+// Run converts the mode.
+func (f *ParsePermissionsFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var mode string
+	resp.Error = function.ConcatFuncErrors(req.Arguments.Get(ctx, &mode))
+	if resp.Error != nil {
+		return
+	}
+
+	perms, err := ssh.ParseMode(mode)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, fmt.Sprintf("Could not parse mode: %s", err))
+		return
+	}
+
+	resp.Error = function.ConcatFuncErrors(resp.Result.Set(ctx, fmt.Sprintf("%04o", perms)))
+}
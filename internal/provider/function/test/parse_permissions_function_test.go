@@ -0,0 +1,45 @@
+package test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
+)
+
+func TestAccParsePermissionsFunction(t *testing.T) {
+	t.Parallel()
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(tfversion.Version1_8_0),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: `
+output "symbolic" {
+  value = provider::ssh::parse_permissions("u=rwx,g=rx,o=")
+}
+
+output "octal" {
+  value = provider::ssh::parse_permissions("644")
+}
+`,
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckOutput("symbolic", "0750"),
+					resource.TestCheckOutput("octal", "0644"),
+				),
+			},
+			{
+				Config: `
+output "invalid" {
+  value = provider::ssh::parse_permissions("u=rwq")
+}
+`,
+				ExpectError: regexp.MustCompile("invalid permission"),
+			},
+		},
+	})
+}
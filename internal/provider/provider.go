@@ -2,21 +2,30 @@ package provider
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/askrella/askrella-ssh-provider/internal/provider/data"
+	ephemeral2 "github.com/askrella/askrella-ssh-provider/internal/provider/ephemeral"
+	function2 "github.com/askrella/askrella-ssh-provider/internal/provider/function"
 	resource2 "github.com/askrella/askrella-ssh-provider/internal/provider/resource"
 	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/sirupsen/logrus"
 )
 
 // Ensure the implementation satisfies the expected interfaces
 var (
-	_ provider.Provider = &SSHProvider{}
+	_ provider.Provider                       = &SSHProvider{}
+	_ provider.ProviderWithEphemeralResources = &SSHProvider{}
+	_ provider.ProviderWithFunctions          = &SSHProvider{}
 )
 
 // SSHProvider is the provider implementation.
@@ -28,6 +37,20 @@ type SSHProvider struct {
 	pool    *ssh.SSHPool
 }
 
+// SSHProviderModel describes the provider data model.
+type SSHProviderModel struct {
+	Connections     map[string]ssh.SSHBlockModel `tfsdk:"connections"`
+	MaxConnsTotal   types.Int64                  `tfsdk:"max_conns_total"`
+	MaxConnsPerHost types.Int64                  `tfsdk:"max_conns_per_host"`
+}
+
+// defaultMaxConnsTotal is the number of pooled connections if max_conns_total is not set
+const defaultMaxConnsTotal = 10
+
+// acquireRetries is how often an operation retries to get a pooled connection while the connection limits
+// are reached. With the doubling backoff of the pool, this waits for about a minute and a half.
+const acquireRetries = 24
+
 // New creates a new provider instance
 func New(version string) func() provider.Provider {
 	return func() provider.Provider {
@@ -45,15 +68,76 @@ func (p *SSHProvider) Metadata(_ context.Context, _ provider.MetadataRequest, re
 
 // Schema defines the provider-level schema for configuration data.
 func (p *SSHProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
-	resp.Schema = schema.Schema{}
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"connections": schema.MapNestedAttribute{
+				Description: "Named SSH connections that resources, data sources and ephemeral resources can reference with their connection attribute instead of an ssh block.",
+				Optional:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: ssh.SSHBlockProviderSchema(),
+				},
+			},
+			"max_conns_total": schema.Int64Attribute{
+				Description: "The maximum number of SSH connections the provider keeps open at once, across all hosts. Defaults to 10.",
+				Optional:    true,
+			},
+			"max_conns_per_host": schema.Int64Attribute{
+				Description: "The maximum number of SSH connections the provider keeps open to a single host and port, across all SSH configurations for it. Defaults to no limit besides max_conns_total.",
+				Optional:    true,
+			},
+		},
+	}
 }
 
-// Configure prepares a HashiCups API client for data sources and resources.
+// Configure prepares the SSH connection pool and the named connections for data sources and resources.
 func (p *SSHProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
-	// Initialize the SSH connection pool
-	p.pool = ssh.NewSSHPool(ssh.PoolConfig{
-		Logger: logrus.New(),
-	})
+	var config SSHProviderModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	maxConnsTotal := int64(defaultMaxConnsTotal)
+	if !config.MaxConnsTotal.IsNull() && !config.MaxConnsTotal.IsUnknown() {
+		maxConnsTotal = config.MaxConnsTotal.ValueInt64()
+		if maxConnsTotal < 1 {
+			resp.Diagnostics.AddAttributeError(path.Root("max_conns_total"), "Invalid connection limit",
+				fmt.Sprintf("max_conns_total must be at least 1, got %d.", maxConnsTotal))
+		}
+	}
+	var maxConnsPerHost int64
+	if !config.MaxConnsPerHost.IsNull() && !config.MaxConnsPerHost.IsUnknown() {
+		maxConnsPerHost = config.MaxConnsPerHost.ValueInt64()
+		if maxConnsPerHost < 1 {
+			resp.Diagnostics.AddAttributeError(path.Root("max_conns_per_host"), "Invalid connection limit",
+				fmt.Sprintf("max_conns_per_host must be at least 1, got %d.", maxConnsPerHost))
+		}
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Keep the existing pool if the provider is configured again, it would leak otherwise
+	if p.pool == nil {
+		// Initialize the SSH connection pool. It's closed by Close or, if Terraform stops the
+		// plugin without closing the provider, by ssh.CloseAllPools in main. The provider process
+		// only lives for a single Terraform run, so idle connections are kept until then.
+		// Operations wait for a connection while the connection limits are reached.
+		p.pool = ssh.NewSSHPool(ssh.PoolConfig{
+			Logger:          logrus.New(),
+			DisableCleanup:  true,
+			MaxConns:        int(maxConnsTotal),
+			MaxConnsPerHost: int(maxConnsPerHost),
+			AcquireRetries:  acquireRetries,
+		})
+	}
+
+	providerData := &ssh.ProviderData{
+		Connections: ssh.Connections(config.Connections),
+	}
+	resp.DataSourceData = providerData
+	resp.ResourceData = providerData
+	resp.EphemeralResourceData = providerData
 }
 
 // DataSources defines the data sources implemented in the provider.
@@ -65,6 +149,27 @@ func (p *SSHProvider) DataSources(_ context.Context) []func() datasource.DataSou
 		func() datasource.DataSource {
 			return data.NewDirectoryDataSource(p.pool)
 		},
+		func() datasource.DataSource {
+			return data.NewHostKeyDataSource(p.pool)
+		},
+		func() datasource.DataSource {
+			return data.NewFileChecksumDataSource(p.pool)
+		},
+		func() datasource.DataSource {
+			return data.NewFileSetDataSource(p.pool)
+		},
+		func() datasource.DataSource {
+			return data.NewHostInfoDataSource(p.pool)
+		},
+		func() datasource.DataSource {
+			return data.NewConnectionTestDataSource()
+		},
+		func() datasource.DataSource {
+			return data.NewAuthorizedKeysDataSource(p.pool)
+		},
+		func() datasource.DataSource {
+			return data.NewEnvironmentDataSource(p.pool)
+		},
 	}
 }
 
@@ -74,9 +179,40 @@ func (p *SSHProvider) Resources(_ context.Context) []func() resource.Resource {
 		func() resource.Resource {
 			return resource2.NewFileResource(p.pool)
 		},
+		func() resource.Resource {
+			return resource2.NewFilesResource(p.pool)
+		},
 		func() resource.Resource {
 			return resource2.NewDirectoryResource(p.pool)
 		},
+		func() resource.Resource {
+			return resource2.NewFileUploadResource(p.pool)
+		},
+		func() resource.Resource {
+			return resource2.NewUserResource(p.pool)
+		},
+		func() resource.Resource {
+			return resource2.NewGroupResource(p.pool)
+		},
+		func() resource.Resource {
+			return resource2.NewAuthorizedKeyResource(p.pool)
+		},
+		resource2.NewKeygenResource,
+	}
+}
+
+// EphemeralResources defines the ephemeral resources implemented in the provider.
+func (p *SSHProvider) EphemeralResources(_ context.Context) []func() ephemeral.EphemeralResource {
+	return []func() ephemeral.EphemeralResource{
+		ephemeral2.NewTunnelEphemeralResource,
+	}
+}
+
+// Functions defines the provider-defined functions implemented in the provider.
+func (p *SSHProvider) Functions(_ context.Context) []func() function.Function {
+	return []func() function.Function{
+		function2.NewParsePermissionsFunction,
+		function2.NewHostFingerprintFunction,
 	}
 }
 
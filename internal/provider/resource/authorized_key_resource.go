@@ -0,0 +1,570 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"go.opentelemetry.io/otel"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+var (
+	_ resource.Resource                     = &AuthorizedKeyResource{}
+	_ resource.ResourceWithConfigure        = &AuthorizedKeyResource{}
+	_ resource.ResourceWithValidateConfig   = &AuthorizedKeyResource{}
+	_ resource.ResourceWithConfigValidators = &AuthorizedKeyResource{}
+)
+
+// authorizedKeysMu serializes changes of authorized_keys files, so resources managing different keys
+// in the same file don't overwrite each other's changes
+var authorizedKeysMu sync.Mutex
+
+// Values of the state attribute of ssh_authorized_key
+const (
+	authorizedKeyPresent = "present"
+	authorizedKeyAbsent  = "absent"
+)
+
+// AuthorizedKeyResource defines the resource implementation.
+type AuthorizedKeyResource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// AuthorizedKeyResourceModel describes the resource data model.
+type AuthorizedKeyResourceModel struct {
+	SSH                 *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection          types.String       `tfsdk:"connection"`
+	DedicatedConnection types.Bool         `tfsdk:"dedicated_connection"`
+	Path                types.String       `tfsdk:"path"`
+	PublicKey           types.String       `tfsdk:"public_key"`
+	Options             types.List         `tfsdk:"options"`
+	Comment             types.String       `tfsdk:"comment"`
+	State               types.String       `tfsdk:"state"`
+	Owner               types.String       `tfsdk:"owner"`
+	Group               types.String       `tfsdk:"group"`
+	UseSudo             types.Bool         `tfsdk:"use_sudo"`
+	Fingerprint         types.String       `tfsdk:"fingerprint"`
+	ID                  types.String       `tfsdk:"id"`
+}
+
+// NewAuthorizedKeyResource creates a new resource implementation.
+func NewAuthorizedKeyResource(pool *ssh.SSHPool) resource.Resource {
+	return &AuthorizedKeyResource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the resource type name.
+func (r *AuthorizedKeyResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_authorized_key"
+}
+
+// ConfigValidators returns the validators of the resource configuration.
+func (r *AuthorizedKeyResource) ConfigValidators(_ context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the resource.
+func (r *AuthorizedKeyResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages a single entry of an authorized_keys file on a remote server via SSH, leaving the other entries alone.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"dedicated_connection": schema.BoolAttribute{
+				Description: "If true, every operation of this resource opens its own SSH connection instead of using the shared connection pool and closes it when the operation finishes.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockSchema(),
+			},
+			"path": schema.StringAttribute{
+				Description: "The path of the authorized_keys file on the remote server. Defaults to '" + ssh.DefaultAuthorizedKeysPath + "', the file of the SSH user.",
+				Optional:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"public_key": schema.StringAttribute{
+				Description: "The public key in authorized_keys format (e.g., 'ssh-ed25519 AAAA... deploy@example.com'). Entries are matched by the key material, so a comment in it doesn't have to match.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"options": schema.ListAttribute{
+				Description: "The options of the entry (e.g., 'from=\"10.0.0.0/8\"' or 'no-pty'). The options of an existing entry are kept if not set.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"comment": schema.StringAttribute{
+				Description: "The comment of the entry. Defaults to the comment in public_key, or the comment of an existing entry if public_key has none.",
+				Optional:    true,
+			},
+			"state": schema.StringAttribute{
+				Description: "Whether the key must be 'present' or 'absent'. An absent key is removed from the file when the resource is created. Defaults to 'present'.",
+				Optional:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"owner": schema.StringAttribute{
+				Description: "The owner of the authorized_keys file and of a directory created for it. Defaults to the owner of the existing file, or of the nearest existing parent directory for a new file.",
+				Optional:    true,
+			},
+			"group": schema.StringAttribute{
+				Description: "The group of the authorized_keys file and of a directory created for it. Defaults to the group of the existing file, or of the nearest existing parent directory for a new file.",
+				Optional:    true,
+			},
+			"use_sudo": schema.BoolAttribute{
+				Description: "If true, the authorized_keys file is read and written through sudo, e.g. to manage the file of another user.",
+				Optional:    true,
+			},
+			"fingerprint": schema.StringAttribute{
+				Description: "The SHA256 fingerprint of the key (e.g., 'SHA256:...').",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+// Create creates the resource and sets the initial Terraform state.
+func (r *AuthorizedKeyResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "AuthorizedKeyResource.Create")
+	defer span.End()
+
+	var plan AuthorizedKeyResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	if !writeAuthorizedKey(ctx, client, plan, authorizedKeyState(plan) == authorizedKeyPresent, &resp.Diagnostics) {
+		return
+	}
+
+	publicKey, _, _ := parsePublicKey(plan.PublicKey.ValueString())
+	plan.Fingerprint = basetypes.NewStringValue(gossh.FingerprintSHA256(publicKey))
+	plan.ID = basetypes.NewStringValue(authorizedKeysPath(plan) + ":" + plan.Fingerprint.ValueString())
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (r *AuthorizedKeyResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "AuthorizedKeyResource.Read")
+	defer span.End()
+
+	var state AuthorizedKeyResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	entry, err := findAuthorizedKey(ctx, client, state)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading authorized keys",
+			fmt.Sprintf("Could not read authorized_keys file: %s", err),
+		)
+		return
+	}
+
+	// A key that was removed or added outside of Terraform is restored on the next apply
+	present := authorizedKeyState(state) == authorizedKeyPresent
+	if (entry != nil) != present {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	if entry != nil {
+		if !state.Options.IsNull() {
+			options, diags := types.ListValueFrom(ctx, types.StringType, entry.Options)
+			resp.Diagnostics.Append(diags...)
+			state.Options = options
+		}
+		if !state.Comment.IsNull() {
+			state.Comment = basetypes.NewStringValue(entry.Comment)
+		}
+	}
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Update updates the resource and sets the updated Terraform state on success.
+func (r *AuthorizedKeyResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "AuthorizedKeyResource.Update")
+	defer span.End()
+
+	var plan AuthorizedKeyResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	// Only the options and comment can change in place
+	if !writeAuthorizedKey(ctx, client, plan, authorizedKeyState(plan) == authorizedKeyPresent, &resp.Diagnostics) {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Delete deletes the resource and removes the Terraform state on success.
+func (r *AuthorizedKeyResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "AuthorizedKeyResource.Delete")
+	defer span.End()
+
+	var state AuthorizedKeyResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Destroying an absent key doesn't add it back
+	if authorizedKeyState(state) == authorizedKeyAbsent {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	writeAuthorizedKey(ctx, client, state, false, &resp.Diagnostics)
+}
+
+// ValidateConfig validates the public key and the state.
+func (r *AuthorizedKeyResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var config AuthorizedKeyResourceModel
+	diags := req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !config.PublicKey.IsUnknown() {
+		if _, _, err := parsePublicKey(config.PublicKey.ValueString()); err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("public_key"),
+				"Invalid public key",
+				fmt.Sprintf("Could not parse public key: %s", err),
+			)
+		}
+	}
+
+	if config.State.IsUnknown() {
+		return
+	}
+	switch authorizedKeyState(config) {
+	case authorizedKeyPresent:
+	case authorizedKeyAbsent:
+		conflicting := map[string]attr.Value{
+			"options": config.Options,
+			"comment": config.Comment,
+		}
+		for _, name := range slices.Sorted(maps.Keys(conflicting)) {
+			if !conflicting[name].IsNull() {
+				resp.Diagnostics.AddAttributeError(
+					path.Root(name),
+					"Conflicting attributes",
+					fmt.Sprintf("%s can't be set if state is %q.", name, authorizedKeyAbsent),
+				)
+			}
+		}
+	default:
+		resp.Diagnostics.AddAttributeError(
+			path.Root("state"),
+			"Invalid state",
+			fmt.Sprintf("Expected %q or %q, got %q.", authorizedKeyPresent, authorizedKeyAbsent, config.State.ValueString()),
+		)
+	}
+}
+
+func (r *AuthorizedKeyResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	r.connections = providerData.Connections
+}
+
+// parsePublicKey parses a public key in authorized_keys format and returns it with its comment. Options
+// are rejected, as they are set with the options attribute.
+func parsePublicKey(value string) (gossh.PublicKey, string, error) {
+	publicKey, comment, options, _, err := gossh.ParseAuthorizedKey([]byte(value))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(options) > 0 {
+		return nil, "", fmt.Errorf("options must be set with the options attribute, not in the public key")
+	}
+	return publicKey, comment, nil
+}
+
+// authorizedKeysPath returns the configured authorized_keys path, or the file of the SSH user if it's not set
+func authorizedKeysPath(model AuthorizedKeyResourceModel) string {
+	if model.Path.IsNull() {
+		return ssh.DefaultAuthorizedKeysPath
+	}
+	return model.Path.ValueString()
+}
+
+// authorizedKeyState returns the configured state, or present if it's not set
+func authorizedKeyState(model AuthorizedKeyResourceModel) string {
+	if model.State.IsNull() {
+		return authorizedKeyPresent
+	}
+	return model.State.ValueString()
+}
+
+// readAuthorizedKeys reads the authorized_keys file, which is empty if it doesn't exist
+func readAuthorizedKeys(ctx context.Context, client ssh.FileOps, remotePath string) (string, error) {
+	exists, err := client.Exists(ctx, remotePath)
+	if err != nil || !exists {
+		return "", err
+	}
+	return client.ReadFileLimited(ctx, remotePath, ssh.DefaultMaxReadSize)
+}
+
+// findAuthorizedKey returns the first entry of the key in the authorized_keys file, or nil if it has none
+func findAuthorizedKey(ctx context.Context, client ssh.FileOps, model AuthorizedKeyResourceModel) (*ssh.AuthorizedKey, error) {
+	publicKey, _, err := parsePublicKey(model.PublicKey.ValueString())
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := readAuthorizedKeys(ctx, client, authorizedKeysPath(model))
+	if err != nil {
+		return nil, err
+	}
+
+	keys, _ := ssh.ParseAuthorizedKeys(content)
+	for _, key := range keys {
+		if string(key.PublicKey.Marshal()) == string(publicKey.Marshal()) {
+			return &key, nil
+		}
+	}
+	return nil, nil
+}
+
+// writeAuthorizedKey adds or updates the entry of the key in the authorized_keys file if present is set,
+// and removes it otherwise. The file is only written if it changes, and is created with its directory
+// if it doesn't exist.
+func writeAuthorizedKey(ctx context.Context, client ssh.FileOps, model AuthorizedKeyResourceModel, present bool, diags *diag.Diagnostics) bool {
+	authorizedKeysMu.Lock()
+	defer authorizedKeysMu.Unlock()
+
+	remotePath := authorizedKeysPath(model)
+	publicKey, comment, err := parsePublicKey(model.PublicKey.ValueString())
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("public_key"),
+			"Invalid public key",
+			fmt.Sprintf("Could not parse public key: %s", err),
+		)
+		return false
+	}
+
+	content, err := readAuthorizedKeys(ctx, client, remotePath)
+	if err != nil {
+		diags.AddError(
+			"Error reading authorized keys",
+			fmt.Sprintf("Could not read authorized_keys file: %s", err),
+		)
+		return false
+	}
+
+	line := ""
+	if present {
+		// Options and comment that aren't configured are taken from the existing entry
+		var options []string
+		keys, _ := ssh.ParseAuthorizedKeys(content)
+		for _, key := range keys {
+			if string(key.PublicKey.Marshal()) == string(publicKey.Marshal()) {
+				options = key.Options
+				if comment == "" {
+					comment = key.Comment
+				}
+				break
+			}
+		}
+		if !model.Options.IsNull() {
+			diags.Append(model.Options.ElementsAs(ctx, &options, false)...)
+			if diags.HasError() {
+				return false
+			}
+		}
+		if !model.Comment.IsNull() {
+			comment = model.Comment.ValueString()
+		}
+		line = ssh.AuthorizedKeyLine(options, publicKey, comment)
+	}
+
+	updated, changed := ssh.SetAuthorizedKey(content, publicKey, line)
+	if !changed {
+		return true
+	}
+
+	ownership, err := authorizedKeysOwnership(ctx, client, model, remotePath)
+	if err != nil {
+		diags.AddError(
+			"Error reading authorized keys",
+			fmt.Sprintf("Could not read the owner of the authorized_keys file: %s", err),
+		)
+		return false
+	}
+
+	// sshd ignores authorized_keys files and directories that are writable by others
+	opts := ssh.DefaultCreateFileOptions()
+	opts.ParentPermissions = 0700
+	opts.Owner, opts.Group = ownership.User, ownership.Group
+	opts.ParentOwner, opts.ParentGroup = ownership.User, ownership.Group
+	if err := client.CreateFileWithOptions(ctx, remotePath, updated, os.FileMode(0600), opts); err != nil {
+		diags.AddError(
+			"Error writing authorized keys",
+			fmt.Sprintf("Could not write authorized_keys file: %s", err),
+		)
+		return false
+	}
+	if err := client.SetFileOwnership(ctx, remotePath, ownership); err != nil {
+		diags.AddError(
+			"Error writing authorized keys",
+			fmt.Sprintf("Could not set the owner of the authorized_keys file: %s", err),
+		)
+		return false
+	}
+	return true
+}
+
+// authorizedKeysOwnership returns the owner and group of the authorized_keys file. Those that aren't
+// configured are taken from the existing file, or from the nearest existing parent directory of a new
+// one, so a file written as another user, e.g. root, keeps belonging to the user it's for.
+func authorizedKeysOwnership(ctx context.Context, client ssh.FileOps, model AuthorizedKeyResourceModel, remotePath string) (*ssh.FileOwnership, error) {
+	ownership := &ssh.FileOwnership{User: model.Owner.ValueString(), Group: model.Group.ValueString()}
+	if ownership.User != "" && ownership.Group != "" {
+		return ownership, nil
+	}
+
+	existing, err := ssh.NearestExisting(ctx, client, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	// Numeric IDs keep the ownership exactly, even if the user or group has no name
+	current, err := client.GetFileOwnershipAs(ctx, existing, &ssh.FileOwnership{User: "0", Group: "0"})
+	if err != nil {
+		return nil, err
+	}
+	if ownership.User == "" {
+		ownership.User = current.User
+	}
+	if ownership.Group == "" {
+		ownership.Group = current.Group
+	}
+	return ownership, nil
+}
+
+func (r *AuthorizedKeyResource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String, dedicated types.Bool) (ssh.FileOps, func(), error) {
+	config, err := r.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.pool.GetFileOps(ctx, config, dedicated.ValueBool())
+}
@@ -3,12 +3,15 @@ package resource
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
 
 	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
 
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
@@ -17,31 +20,55 @@ import (
 )
 
 var (
-	_ resource.Resource              = &DirectoryResource{}
-	_ resource.ResourceWithConfigure = &DirectoryResource{}
+	_ resource.Resource                     = &DirectoryResource{}
+	_ resource.ResourceWithConfigure        = &DirectoryResource{}
+	_ resource.ResourceWithValidateConfig   = &DirectoryResource{}
+	_ resource.ResourceWithConfigValidators = &DirectoryResource{}
 )
 
 // DirectoryResource defines the resource implementation.
 type DirectoryResource struct {
-	pool *ssh.SSHPool
+	pool        *ssh.SSHPool
+	connections ssh.Connections
 }
 
 // DirectoryResourceModel describes the resource data model.
 type DirectoryResourceModel struct {
-	SSH         *ssh.SSHBlockModel `tfsdk:"ssh"`
-	Path        types.String       `tfsdk:"path"`
-	Permissions types.String       `tfsdk:"permissions"`
-	Owner       types.String       `tfsdk:"owner"`
-	Group       types.String       `tfsdk:"group"`
-	Immutable   types.Bool         `tfsdk:"immutable"`
-	AppendOnly  types.Bool         `tfsdk:"append_only"`
-	NoDump      types.Bool         `tfsdk:"no_dump"`
-	Synchronous types.Bool         `tfsdk:"synchronous"`
-	NoAtime     types.Bool         `tfsdk:"no_atime"`
-	Compressed  types.Bool         `tfsdk:"compressed"`
-	NoCoW       types.Bool         `tfsdk:"no_cow"`
-	Undeletable types.Bool         `tfsdk:"undeletable"`
-	ID          types.String       `tfsdk:"id"`
+	SSH                         *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection                  types.String       `tfsdk:"connection"`
+	DedicatedConnection         types.Bool         `tfsdk:"dedicated_connection"`
+	Path                        types.String       `tfsdk:"path"`
+	Permissions                 types.String       `tfsdk:"permissions"`
+	Recursive                   types.Bool         `tfsdk:"recursive"`
+	FilePermissions             types.String       `tfsdk:"file_permissions"`
+	DirPermissions              types.String       `tfsdk:"dir_permissions"`
+	Owner                       types.String       `tfsdk:"owner"`
+	Group                       types.String       `tfsdk:"group"`
+	Immutable                   types.Bool         `tfsdk:"immutable"`
+	AppendOnly                  types.Bool         `tfsdk:"append_only"`
+	NoDump                      types.Bool         `tfsdk:"no_dump"`
+	Synchronous                 types.Bool         `tfsdk:"synchronous"`
+	NoAtime                     types.Bool         `tfsdk:"no_atime"`
+	Compressed                  types.Bool         `tfsdk:"compressed"`
+	NoCoW                       types.Bool         `tfsdk:"no_cow"`
+	Undeletable                 types.Bool         `tfsdk:"undeletable"`
+	DataJournaling              types.Bool         `tfsdk:"data_journaling"`
+	NoTailMerge                 types.Bool         `tfsdk:"no_tail_merge"`
+	TopDir                      types.Bool         `tfsdk:"top_dir"`
+	DirSync                     types.Bool         `tfsdk:"dir_sync"`
+	IgnoreUnsupportedAttributes types.Bool         `tfsdk:"ignore_unsupported_attributes"`
+	Triggers                    types.Map          `tfsdk:"triggers"`
+	PreCommand                  types.String       `tfsdk:"pre_command"`
+	PreCommandOnFailure         types.String       `tfsdk:"pre_command_on_failure"`
+	PostCommand                 types.String       `tfsdk:"post_command"`
+	PostCommandOnFailure        types.String       `tfsdk:"post_command_on_failure"`
+	CommandEnvironment          types.Map          `tfsdk:"command_environment"`
+	CommandPTY                  types.Bool         `tfsdk:"command_pty"`
+	CommandTimeout              types.String       `tfsdk:"command_timeout"`
+	CommandShell                types.String       `tfsdk:"command_shell"`
+	OnlyOnOS                    types.List         `tfsdk:"only_on_os"`
+	Skipped                     types.Bool         `tfsdk:"skipped"`
+	ID                          types.String       `tfsdk:"id"`
 }
 
 // NewDirectoryResource creates a new resource implementation.
@@ -56,14 +83,29 @@ func (r *DirectoryResource) Metadata(_ context.Context, req resource.MetadataReq
 	resp.TypeName = req.ProviderTypeName + "_directory"
 }
 
+// ConfigValidators returns the validators of the resource configuration.
+func (r *DirectoryResource) ConfigValidators(_ context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
 // Schema defines the schema for the resource.
 func (r *DirectoryResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Description: "Manages a directory on a remote server via SSH.",
 		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"dedicated_connection": schema.BoolAttribute{
+				Description: "If true, every operation of this resource opens its own SSH connection instead of using the shared connection pool and closes it when the operation finishes.",
+				Optional:    true,
+			},
 			"ssh": schema.SingleNestedAttribute{
-				Description: "SSH connection configuration.",
-				Required:    true,
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
 				Attributes:  ssh.SSHBlockSchema(),
 			},
 			"path": schema.StringAttribute{
@@ -77,6 +119,18 @@ func (r *DirectoryResource) Schema(_ context.Context, _ resource.SchemaRequest,
 				Description: "The directory permissions in octal format (e.g., '0755').",
 				Optional:    true,
 			},
+			"recursive": schema.BoolAttribute{
+				Description: "If true, file_permissions and dir_permissions are applied to all files and subdirectories below the directory.",
+				Optional:    true,
+			},
+			"file_permissions": schema.StringAttribute{
+				Description: "The permissions of all files below the directory in octal format (e.g., '0644'). Requires recursive.",
+				Optional:    true,
+			},
+			"dir_permissions": schema.StringAttribute{
+				Description: "The permissions of all subdirectories below the directory in octal format (e.g., '0755'). Requires recursive.",
+				Optional:    true,
+			},
 			"owner": schema.StringAttribute{
 				Description: "The user owner of the directory.",
 				Optional:    true,
@@ -117,6 +171,34 @@ func (r *DirectoryResource) Schema(_ context.Context, _ resource.SchemaRequest,
 				Description: "If true, content is saved when deleted.",
 				Optional:    true,
 			},
+			"data_journaling": schema.BoolAttribute{
+				Description: "If true, data is written to the journal before it is written to the file.",
+				Optional:    true,
+			},
+			"no_tail_merge": schema.BoolAttribute{
+				Description: "If true, files in the directory are not tail-merged with other files.",
+				Optional:    true,
+			},
+			"top_dir": schema.BoolAttribute{
+				Description: "If true, the directory is treated as the top of a directory hierarchy by the block allocator.",
+				Optional:    true,
+			},
+			"dir_sync": schema.BoolAttribute{
+				Description: "If true, changes to the directory are written synchronously to disk.",
+				Optional:    true,
+			},
+			"ignore_unsupported_attributes": schema.BoolAttribute{
+				Description: "If true, attributes the filesystem does not support are reported as a warning instead of failing. The remaining attributes are still applied.",
+				Optional:    true,
+			},
+			"triggers": schema.MapAttribute{
+				Description: "Arbitrary values that force the directory to be recreated when any of them changes.",
+				ElementType: types.StringType,
+				Optional:    true,
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
 			"id": schema.StringAttribute{
 				Computed: true,
 				PlanModifiers: []planmodifier.String{
@@ -125,6 +207,8 @@ func (r *DirectoryResource) Schema(_ context.Context, _ resource.SchemaRequest,
 			},
 		},
 	}
+	maps.Copy(resp.Schema.Attributes, commandHookAttributes("directory"))
+	maps.Copy(resp.Schema.Attributes, onlyOnOSAttributes("directory"))
 }
 
 // Create creates the resource and sets the initial Terraform state.
@@ -139,19 +223,41 @@ func (r *DirectoryResource) Create(ctx context.Context, req resource.CreateReque
 		return
 	}
 
-	client, err := r.getClient(ctx, plan.SSH)
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			ssh.ConnectionErrorDetail(err),
 		)
 		return
 	}
-	defer client.Close()
+	defer release()
+
+	skipped, ok := skippedOnPlatform(ctx, client, plan.OnlyOnOS, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	plan.Skipped = types.BoolValue(skipped)
+	plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
+	if skipped {
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
+
+	if !checkPathType(ctx, client, plan.Path.ValueString(), true, &resp.Diagnostics) {
+		return
+	}
+
+	commandCtx := commandContext(ctx, plan.CommandEnvironment, plan.CommandPTY, plan.CommandTimeout, plan.CommandShell, &resp.Diagnostics)
+	if !runCommandHook(commandCtx, client, "pre_command", plan.PreCommand, plan.PreCommandOnFailure, &resp.Diagnostics) {
+		return
+	}
 
 	permissions := ssh.ParsePermissions(plan.Permissions.ValueString())
 
-	if exists, _ := client.Exists(ctx, plan.Path.ValueString()); !exists {
+	exists, _ := client.Exists(ctx, plan.Path.ValueString())
+	if !exists {
 		err = client.CreateDirectory(ctx, plan.Path.ValueString(), os.FileMode(permissions))
 		if err != nil {
 			resp.Diagnostics.AddError(
@@ -177,21 +283,35 @@ func (r *DirectoryResource) Create(ctx context.Context, req resource.CreateReque
 		}
 	}
 
+	if err := setChildrenModes(ctx, client, plan); err != nil {
+		resp.Diagnostics.AddError(
+			"Error setting permissions of directory contents",
+			fmt.Sprintf("Could not set permissions of directory contents: %s", err),
+		)
+		return
+	}
+
 	// Set attributes if any are specified
 	if !plan.Immutable.IsNull() || !plan.AppendOnly.IsNull() || !plan.NoDump.IsNull() ||
 		!plan.Synchronous.IsNull() || !plan.NoAtime.IsNull() || !plan.Compressed.IsNull() ||
-		!plan.NoCoW.IsNull() || !plan.Undeletable.IsNull() {
-		err = client.SetFileAttributes(ctx, plan.Path.ValueString(), &ssh.FileAttributes{
-			Immutable:   plan.Immutable.ValueBool(),
-			AppendOnly:  plan.AppendOnly.ValueBool(),
-			NoDump:      plan.NoDump.ValueBool(),
-			Synchronous: plan.Synchronous.ValueBool(),
-			NoAtime:     plan.NoAtime.ValueBool(),
-			Compressed:  plan.Compressed.ValueBool(),
-			NoCoW:       plan.NoCoW.ValueBool(),
-			Undeletable: plan.Undeletable.ValueBool(),
+		!plan.NoCoW.IsNull() || !plan.Undeletable.IsNull() ||
+		!plan.DataJournaling.IsNull() || !plan.NoTailMerge.IsNull() ||
+		!plan.TopDir.IsNull() || !plan.DirSync.IsNull() {
+		err = client.SetFileAttributes(ctx, plan.Path.ValueString(), &ssh.FileAttributesUpdate{
+			Immutable:      plan.Immutable.ValueBoolPointer(),
+			AppendOnly:     plan.AppendOnly.ValueBoolPointer(),
+			NoDump:         plan.NoDump.ValueBoolPointer(),
+			Synchronous:    plan.Synchronous.ValueBoolPointer(),
+			NoAtime:        plan.NoAtime.ValueBoolPointer(),
+			Compressed:     plan.Compressed.ValueBoolPointer(),
+			NoCoW:          plan.NoCoW.ValueBoolPointer(),
+			Undeletable:    plan.Undeletable.ValueBoolPointer(),
+			DataJournaling: plan.DataJournaling.ValueBoolPointer(),
+			NoTailMerge:    plan.NoTailMerge.ValueBoolPointer(),
+			TopDir:         plan.TopDir.ValueBoolPointer(),
+			DirSync:        plan.DirSync.ValueBoolPointer(),
 		})
-		if err != nil {
+		if err != nil && !warnUnsupportedAttributes(err, "directory", plan.IgnoreUnsupportedAttributes, &resp.Diagnostics) {
 			resp.Diagnostics.AddError(
 				"Error setting directory attributes",
 				fmt.Sprintf("Could not set directory attributes: %s", err),
@@ -200,10 +320,14 @@ func (r *DirectoryResource) Create(ctx context.Context, req resource.CreateReque
 		}
 	}
 
-	plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
-
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
+
+	// The state is saved first, a failing post_command doesn't undo the change. An existing directory is
+	// adopted, which doesn't count as a change.
+	if !exists {
+		runCommandHook(commandCtx, client, "post_command", plan.PostCommand, plan.PostCommandOnFailure, &resp.Diagnostics)
+	}
 }
 
 // Read refreshes the Terraform state with the latest data.
@@ -218,15 +342,32 @@ func (r *DirectoryResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
-	client, err := r.getClient(ctx, state.SSH)
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			ssh.ConnectionErrorDetail(err),
 		)
 		return
 	}
-	defer client.Close()
+	defer release()
+
+	skipped, ok := skippedOnPlatform(ctx, client, state.OnlyOnOS, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	// A skipped directory is created once the host's operating system is in only_on_os, e.g. after the host was
+	// replaced
+	if state.Skipped.ValueBool() && !skipped {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	state.Skipped = types.BoolValue(skipped)
+	if skipped {
+		diags = resp.State.Set(ctx, &state)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
 
 	exists, err := client.Exists(ctx, state.Path.ValueString())
 	if err != nil {
@@ -241,20 +382,55 @@ func (r *DirectoryResource) Read(ctx context.Context, req resource.ReadRequest,
 		return
 	}
 
-	// Get directory mode
-	mode, err := client.GetFileMode(ctx, state.Path.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error reading directory mode",
-			fmt.Sprintf("Could not read directory mode: %s", err),
-		)
-		return
+	// Get directory mode if it was specified
+	if !state.Permissions.IsNull() {
+		mode, err := client.GetFileMode(ctx, state.Path.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error reading directory mode",
+				fmt.Sprintf("Could not read directory mode: %s", err),
+			)
+			return
+		}
+		// Keep the configured notation (e.g. "644" vs "0644") when the mode matches
+		if os.FileMode(ssh.ParsePermissions(state.Permissions.ValueString())) != mode {
+			state.Permissions = basetypes.NewStringValue(fmt.Sprintf("%04o", mode))
+		}
+	}
+
+	// Check the permissions of the directory contents if they are managed
+	if state.Recursive.ValueBool() {
+		if !state.FilePermissions.IsNull() {
+			mode, err := readChildrenMode(ctx, client, state.Path.ValueString(), ssh.ChildFiles, state.FilePermissions.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"Error reading permissions of directory contents",
+					fmt.Sprintf("Could not read permissions of directory contents: %s", err),
+				)
+				return
+			}
+			state.FilePermissions = mode
+		}
+		if !state.DirPermissions.IsNull() {
+			mode, err := readChildrenMode(ctx, client, state.Path.ValueString(), ssh.ChildDirectories, state.DirPermissions.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"Error reading permissions of directory contents",
+					fmt.Sprintf("Could not read permissions of directory contents: %s", err),
+				)
+				return
+			}
+			state.DirPermissions = mode
+		}
 	}
-	state.Permissions = basetypes.NewStringValue(fmt.Sprintf("%04o", mode))
 
 	// Get ownership if it was specified
 	if !state.Owner.IsNull() || !state.Group.IsNull() {
-		ownership, err := client.GetFileOwnership(ctx, state.Path.ValueString())
+		// Numeric IDs are kept as configured instead of being replaced by names
+		ownership, err := client.GetFileOwnershipAs(ctx, state.Path.ValueString(), &ssh.FileOwnership{
+			User:  state.Owner.ValueString(),
+			Group: state.Group.ValueString(),
+		})
 		if err != nil {
 			resp.Diagnostics.AddError(
 				"Error reading directory ownership",
@@ -273,7 +449,9 @@ func (r *DirectoryResource) Read(ctx context.Context, req resource.ReadRequest,
 	// Get attributes if any were specified
 	if !state.Immutable.IsNull() || !state.AppendOnly.IsNull() || !state.NoDump.IsNull() ||
 		!state.Synchronous.IsNull() || !state.NoAtime.IsNull() || !state.Compressed.IsNull() ||
-		!state.NoCoW.IsNull() || !state.Undeletable.IsNull() {
+		!state.NoCoW.IsNull() || !state.Undeletable.IsNull() ||
+		!state.DataJournaling.IsNull() || !state.NoTailMerge.IsNull() ||
+		!state.TopDir.IsNull() || !state.DirSync.IsNull() {
 		attrs, err := client.GetFileAttributes(ctx, state.Path.ValueString())
 		if err != nil {
 			resp.Diagnostics.AddError(
@@ -306,6 +484,18 @@ func (r *DirectoryResource) Read(ctx context.Context, req resource.ReadRequest,
 		if !state.Undeletable.IsNull() {
 			state.Undeletable = types.BoolValue(attrs.Undeletable)
 		}
+		if !state.DataJournaling.IsNull() {
+			state.DataJournaling = types.BoolValue(attrs.DataJournaling)
+		}
+		if !state.NoTailMerge.IsNull() {
+			state.NoTailMerge = types.BoolValue(attrs.NoTailMerge)
+		}
+		if !state.TopDir.IsNull() {
+			state.TopDir = types.BoolValue(attrs.TopDir)
+		}
+		if !state.DirSync.IsNull() {
+			state.DirSync = types.BoolValue(attrs.DirSync)
+		}
 	}
 
 	diags = resp.State.Set(ctx, &state)
@@ -317,27 +507,53 @@ func (r *DirectoryResource) Update(ctx context.Context, req resource.UpdateReque
 	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "DirectoryResource.Update")
 	defer span.End()
 
-	var plan DirectoryResourceModel
+	var plan, state DirectoryResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	client, err := r.getClient(ctx, plan.SSH)
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			ssh.ConnectionErrorDetail(err),
 		)
 		return
 	}
-	defer client.Close()
+	defer release()
+
+	skipped, ok := skippedOnPlatform(ctx, client, plan.OnlyOnOS, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	plan.Skipped = types.BoolValue(skipped)
+	if skipped {
+		warnNoLongerManaged("directory", plan.Path, state.Skipped, &resp.Diagnostics)
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
+
+	if !checkPathType(ctx, client, plan.Path.ValueString(), true, &resp.Diagnostics) {
+		return
+	}
+
+	commandCtx := commandContext(ctx, plan.CommandEnvironment, plan.CommandPTY, plan.CommandTimeout, plan.CommandShell, &resp.Diagnostics)
+	if !runCommandHook(commandCtx, client, "pre_command", plan.PreCommand, plan.PreCommandOnFailure, &resp.Diagnostics) {
+		return
+	}
 
 	permissions := ssh.ParsePermissions(plan.Permissions.ValueString())
 	wantedFileMode := os.FileMode(permissions)
 
-	if exists, _ := client.Exists(ctx, plan.Path.ValueString()); !exists {
+	// changed is whether anything on the directory was changed, which triggers post_command
+	exists, _ := client.Exists(ctx, plan.Path.ValueString())
+	changed := !exists
+	if !exists {
 		err = client.CreateDirectory(ctx, plan.Path.ValueString(), wantedFileMode)
 		if err != nil {
 			resp.Diagnostics.AddError(
@@ -356,6 +572,7 @@ func (r *DirectoryResource) Update(ctx context.Context, req resource.UpdateReque
 		)
 	}
 	if fileMode != wantedFileMode {
+		changed = true
 		err := client.SetFileMode(ctx, plan.Path.ValueString(), wantedFileMode)
 		if err != nil {
 			resp.Diagnostics.AddError(
@@ -366,8 +583,33 @@ func (r *DirectoryResource) Update(ctx context.Context, req resource.UpdateReque
 		}
 	}
 
-	// Set ownership if specified
-	if !plan.Owner.IsNull() || !plan.Group.IsNull() {
+	// Read reports the mode of a child that differs, so a difference to the state means children are changed
+	if !plan.Recursive.Equal(state.Recursive) || !plan.DirPermissions.Equal(state.DirPermissions) ||
+		!plan.FilePermissions.Equal(state.FilePermissions) {
+		changed = changed || plan.Recursive.ValueBool()
+	}
+	if err := setChildrenModes(ctx, client, plan); err != nil {
+		resp.Diagnostics.AddError(
+			"Error setting permissions of directory contents",
+			fmt.Sprintf("Could not set permissions of directory contents: %s", err),
+		)
+		return
+	}
+
+	// State was refreshed by Read, so unchanged values already match the directory.
+	// A recreated directory has to be reconciled regardless.
+	ownershipChanged := !exists || !plan.Owner.Equal(state.Owner) || !plan.Group.Equal(state.Group)
+	attributesChanged := !exists || !plan.Immutable.Equal(state.Immutable) || !plan.AppendOnly.Equal(state.AppendOnly) ||
+		!plan.NoDump.Equal(state.NoDump) || !plan.Synchronous.Equal(state.Synchronous) ||
+		!plan.NoAtime.Equal(state.NoAtime) || !plan.Compressed.Equal(state.Compressed) ||
+		!plan.NoCoW.Equal(state.NoCoW) || !plan.Undeletable.Equal(state.Undeletable) ||
+		!plan.DataJournaling.Equal(state.DataJournaling) || !plan.NoTailMerge.Equal(state.NoTailMerge) ||
+		!plan.TopDir.Equal(state.TopDir) || !plan.DirSync.Equal(state.DirSync)
+
+	// Set ownership if specified and changed. SetFileOwnership only runs chown if the ownership
+	// differs from the current one.
+	if ownershipChanged && (!plan.Owner.IsNull() || !plan.Group.IsNull()) {
+		changed = true
 		err = client.SetFileOwnership(ctx, plan.Path.ValueString(), &ssh.FileOwnership{
 			User:  plan.Owner.ValueString(),
 			Group: plan.Group.ValueString(),
@@ -381,21 +623,29 @@ func (r *DirectoryResource) Update(ctx context.Context, req resource.UpdateReque
 		}
 	}
 
-	// Set attributes if any are specified
-	if !plan.Immutable.IsNull() || !plan.AppendOnly.IsNull() || !plan.NoDump.IsNull() ||
+	// Set attributes if any are specified and changed. SetFileAttributes only runs chattr for
+	// attributes that differ from the current ones.
+	if attributesChanged && (!plan.Immutable.IsNull() || !plan.AppendOnly.IsNull() || !plan.NoDump.IsNull() ||
 		!plan.Synchronous.IsNull() || !plan.NoAtime.IsNull() || !plan.Compressed.IsNull() ||
-		!plan.NoCoW.IsNull() || !plan.Undeletable.IsNull() {
-		err = client.SetFileAttributes(ctx, plan.Path.ValueString(), &ssh.FileAttributes{
-			Immutable:   plan.Immutable.ValueBool(),
-			AppendOnly:  plan.AppendOnly.ValueBool(),
-			NoDump:      plan.NoDump.ValueBool(),
-			Synchronous: plan.Synchronous.ValueBool(),
-			NoAtime:     plan.NoAtime.ValueBool(),
-			Compressed:  plan.Compressed.ValueBool(),
-			NoCoW:       plan.NoCoW.ValueBool(),
-			Undeletable: plan.Undeletable.ValueBool(),
+		!plan.NoCoW.IsNull() || !plan.Undeletable.IsNull() ||
+		!plan.DataJournaling.IsNull() || !plan.NoTailMerge.IsNull() ||
+		!plan.TopDir.IsNull() || !plan.DirSync.IsNull()) {
+		changed = true
+		err = client.SetFileAttributes(ctx, plan.Path.ValueString(), &ssh.FileAttributesUpdate{
+			Immutable:      plan.Immutable.ValueBoolPointer(),
+			AppendOnly:     plan.AppendOnly.ValueBoolPointer(),
+			NoDump:         plan.NoDump.ValueBoolPointer(),
+			Synchronous:    plan.Synchronous.ValueBoolPointer(),
+			NoAtime:        plan.NoAtime.ValueBoolPointer(),
+			Compressed:     plan.Compressed.ValueBoolPointer(),
+			NoCoW:          plan.NoCoW.ValueBoolPointer(),
+			Undeletable:    plan.Undeletable.ValueBoolPointer(),
+			DataJournaling: plan.DataJournaling.ValueBoolPointer(),
+			NoTailMerge:    plan.NoTailMerge.ValueBoolPointer(),
+			TopDir:         plan.TopDir.ValueBoolPointer(),
+			DirSync:        plan.DirSync.ValueBoolPointer(),
 		})
-		if err != nil {
+		if err != nil && !warnUnsupportedAttributes(err, "directory", plan.IgnoreUnsupportedAttributes, &resp.Diagnostics) {
 			resp.Diagnostics.AddError(
 				"Error setting directory attributes",
 				fmt.Sprintf("Could not set directory attributes: %s", err),
@@ -406,6 +656,11 @@ func (r *DirectoryResource) Update(ctx context.Context, req resource.UpdateReque
 
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
+
+	// The state is saved first, a failing post_command doesn't undo the change
+	if changed {
+		runCommandHook(commandCtx, client, "post_command", plan.PostCommand, plan.PostCommandOnFailure, &resp.Diagnostics)
+	}
 }
 
 // Delete deletes the resource and removes the Terraform state on success.
@@ -420,22 +675,23 @@ func (r *DirectoryResource) Delete(ctx context.Context, req resource.DeleteReque
 		return
 	}
 
-	client, err := r.getClient(ctx, state.SSH)
+	// A skipped directory was never created
+	if state.Skipped.ValueBool() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			ssh.ConnectionErrorDetail(err),
 		)
 		return
 	}
-	defer client.Close()
+	defer release()
 
 	err = client.DeleteDirectory(ctx, state.Path.ValueString())
 	if err != nil {
-		if os.IsNotExist(err) {
-			// If the directory is already gone, that's fine
-			return
-		}
 		resp.Diagnostics.AddError(
 			"Error deleting directory",
 			fmt.Sprintf("Could not delete directory: %s", err),
@@ -444,36 +700,102 @@ func (r *DirectoryResource) Delete(ctx context.Context, req resource.DeleteReque
 	}
 }
 
-func (r *DirectoryResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
-	if req.ProviderData == nil {
+// ValidateConfig validates the resource configuration.
+func (r *DirectoryResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var config DirectoryResourceModel
+	diags := req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
 		return
 	}
-}
 
-func (r *DirectoryResource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel) (*ssh.SSHClient, error) {
-	port := int(sshBlock.Port.ValueInt64())
-	if port == 0 {
-		port = 22
+	validateOwnership(config.Owner, path.Root("owner"), &resp.Diagnostics)
+	validateOwnership(config.Group, path.Root("group"), &resp.Diagnostics)
+	validateCommandFailure(config.PreCommandOnFailure, "pre_command_on_failure", &resp.Diagnostics)
+	validateCommandFailure(config.PostCommandOnFailure, "post_command_on_failure", &resp.Diagnostics)
+	validateCommandEnvironment(config.CommandEnvironment, &resp.Diagnostics)
+	validateCommandTimeout(config.CommandTimeout, &resp.Diagnostics)
+	validateCommandShell(config.CommandShell, &resp.Diagnostics)
+	validateOnlyOnOS(config.OnlyOnOS, &resp.Diagnostics)
+
+	if config.Recursive.IsUnknown() || config.Recursive.ValueBool() {
+		return
+	}
+	childModes := map[string]types.String{
+		"file_permissions": config.FilePermissions,
+		"dir_permissions":  config.DirPermissions,
 	}
+	for attr, value := range childModes {
+		if !value.IsNull() {
+			resp.Diagnostics.AddAttributeError(
+				path.Root(attr),
+				"Missing recursive",
+				fmt.Sprintf("%s only applies to the directory contents and requires recursive = true.", attr),
+			)
+		}
+	}
+}
 
-	config := ssh.SSHConfig{
-		Host:       sshBlock.Host.ValueString(),
-		Port:       port,
-		Username:   sshBlock.Username.ValueString(),
-		Password:   sshBlock.Password.ValueString(),
-		PrivateKey: sshBlock.PrivateKey.ValueString(),
+func (r *DirectoryResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
 	}
+	if providerData == nil {
+		return
+	}
+
+	r.connections = providerData.Connections
+}
 
-	client, err := r.pool.GetClient(ctx, config)
+func (r *DirectoryResource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String, dedicated types.Bool) (ssh.FileOps, func(), error) {
+	config, err := r.connections.Config(sshBlock, connection)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Release the client when the context is done
-	go func() {
-		<-ctx.Done()
-		r.pool.ReleaseClient(config)
-	}()
+	return r.pool.GetFileOps(ctx, config, dedicated.ValueBool())
+}
 
-	return client, nil
+// setChildrenModes applies file_permissions and dir_permissions to the directory contents if recursive is set
+func setChildrenModes(ctx context.Context, client ssh.FileOps, plan DirectoryResourceModel) error {
+	if !plan.Recursive.ValueBool() {
+		return nil
+	}
+	if !plan.DirPermissions.IsNull() {
+		mode := os.FileMode(ssh.ParsePermissions(plan.DirPermissions.ValueString()))
+		if err := client.SetChildrenMode(ctx, plan.Path.ValueString(), ssh.ChildDirectories, mode); err != nil {
+			return err
+		}
+	}
+	if !plan.FilePermissions.IsNull() {
+		mode := os.FileMode(ssh.ParsePermissions(plan.FilePermissions.ValueString()))
+		if err := client.SetChildrenMode(ctx, plan.Path.ValueString(), ssh.ChildFiles, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readChildrenMode returns the configured mode if all children of the given type have it, or the mode
+// of the first child that differs, so the drift shows up in the plan
+func readChildrenMode(ctx context.Context, client ssh.FileOps, dirPath string, childType ssh.ChildType, configured string) (types.String, error) {
+	mode := os.FileMode(ssh.ParsePermissions(configured))
+	child, err := client.FindChildWithOtherMode(ctx, dirPath, childType, mode)
+	if err != nil {
+		return types.StringNull(), err
+	}
+	if child == "" {
+		return types.StringValue(configured), nil
+	}
+
+	childMode, err := client.GetFileMode(ctx, child)
+	if err != nil {
+		return types.StringNull(), err
+	}
+	return types.StringValue(fmt.Sprintf("%04o", childMode)), nil
 }
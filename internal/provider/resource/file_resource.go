@@ -2,47 +2,122 @@ package resource
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
 	"go.opentelemetry.io/otel"
+	"maps"
 	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 )
 
 var (
-	_ resource.Resource              = &FileResource{}
-	_ resource.ResourceWithConfigure = &FileResource{}
+	_ resource.Resource                     = &FileResource{}
+	_ resource.ResourceWithConfigure        = &FileResource{}
+	_ resource.ResourceWithValidateConfig   = &FileResource{}
+	_ resource.ResourceWithModifyPlan       = &FileResource{}
+	_ resource.ResourceWithConfigValidators = &FileResource{}
+)
+
+const (
+	pathChangeStrategyReplace = "replace"
+	pathChangeStrategyMove    = "move"
+
+	appendOnlyStrategyAppend  = "append"
+	appendOnlyStrategyRewrite = "rewrite"
+
+	commandFailureFail = "fail"
+	commandFailureWarn = "warn"
+
+	onTooLargeError = "error"
+	onTooLargeWarn  = "warn"
+
+	defaultBackupSuffix = ".bak"
 )
 
 var _ = resource.Resource(&FileResource{})
 
 // FileResource defines the resource implementation.
 type FileResource struct {
-	pool *ssh.SSHPool
+	pool        *ssh.SSHPool
+	connections ssh.Connections
 }
 
 // FileResourceModel describes the resource data model.
 type FileResourceModel struct {
-	SSH         *ssh.SSHBlockModel `tfsdk:"ssh"`
-	Path        types.String       `tfsdk:"path"`
-	Content     types.String       `tfsdk:"content"`
-	Permissions types.String       `tfsdk:"permissions"`
-	Owner       types.String       `tfsdk:"owner"`
-	Group       types.String       `tfsdk:"group"`
-	Immutable   types.Bool         `tfsdk:"immutable"`
-	AppendOnly  types.Bool         `tfsdk:"append_only"`
-	NoDump      types.Bool         `tfsdk:"no_dump"`
-	Synchronous types.Bool         `tfsdk:"synchronous"`
-	NoAtime     types.Bool         `tfsdk:"no_atime"`
-	Compressed  types.Bool         `tfsdk:"compressed"`
-	NoCoW       types.Bool         `tfsdk:"no_cow"`
-	Undeletable types.Bool         `tfsdk:"undeletable"`
-	ID          types.String       `tfsdk:"id"`
+	SSH                         *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection                  types.String       `tfsdk:"connection"`
+	DedicatedConnection         types.Bool         `tfsdk:"dedicated_connection"`
+	Path                        types.String       `tfsdk:"path"`
+	Content                     types.String       `tfsdk:"content"`
+	SensitiveContent            types.String       `tfsdk:"sensitive_content"`
+	ContentWO                   types.String       `tfsdk:"content_wo"`
+	ContentWOVersion            types.Int64        `tfsdk:"content_wo_version"`
+	MaxReadSize                 types.Int64        `tfsdk:"max_read_size"`
+	OnTooLarge                  types.String       `tfsdk:"on_too_large"`
+	Permissions                 types.String       `tfsdk:"permissions"`
+	Owner                       types.String       `tfsdk:"owner"`
+	Group                       types.String       `tfsdk:"group"`
+	UseSudo                     types.Bool         `tfsdk:"use_sudo"`
+	CreateParents               types.Bool         `tfsdk:"create_parents"`
+	ParentPermissions           types.String       `tfsdk:"parent_permissions"`
+	ParentOwner                 types.String       `tfsdk:"parent_owner"`
+	ParentGroup                 types.String       `tfsdk:"parent_group"`
+	PathChangeStrategy          types.String       `tfsdk:"path_change_strategy"`
+	ResolveSymlinks             types.Bool         `tfsdk:"resolve_symlinks"`
+	Immutable                   types.Bool         `tfsdk:"immutable"`
+	AppendOnly                  types.Bool         `tfsdk:"append_only"`
+	AppendOnlyStrategy          types.String       `tfsdk:"append_only_strategy"`
+	NoDump                      types.Bool         `tfsdk:"no_dump"`
+	Synchronous                 types.Bool         `tfsdk:"synchronous"`
+	NoAtime                     types.Bool         `tfsdk:"no_atime"`
+	Compressed                  types.Bool         `tfsdk:"compressed"`
+	NoCoW                       types.Bool         `tfsdk:"no_cow"`
+	Undeletable                 types.Bool         `tfsdk:"undeletable"`
+	DataJournaling              types.Bool         `tfsdk:"data_journaling"`
+	NoTailMerge                 types.Bool         `tfsdk:"no_tail_merge"`
+	Capabilities                types.String       `tfsdk:"capabilities"`
+	IgnoreUnsupportedAttributes types.Bool         `tfsdk:"ignore_unsupported_attributes"`
+	Triggers                    types.Map          `tfsdk:"triggers"`
+	CreateOnly                  types.Bool         `tfsdk:"create_only"`
+	PseudoFile                  types.Bool         `tfsdk:"pseudo_file"`
+	PreserveExisting            types.Bool         `tfsdk:"preserve_existing"`
+	Backup                      types.Bool         `tfsdk:"backup"`
+	BackupSuffix                types.String       `tfsdk:"backup_suffix"`
+	DeleteBackupOnDestroy       types.Bool         `tfsdk:"delete_backup_on_destroy"`
+	BackupPath                  types.String       `tfsdk:"backup_path"`
+	ContentValidation           types.String       `tfsdk:"content_validation"`
+	ValidateCommand             types.String       `tfsdk:"validate_command"`
+	VerifyCommand               types.String       `tfsdk:"verify_command"`
+	RolledBack                  types.Bool         `tfsdk:"rolled_back"`
+	PreCommand                  types.String       `tfsdk:"pre_command"`
+	PreCommandOnFailure         types.String       `tfsdk:"pre_command_on_failure"`
+	PostCommand                 types.String       `tfsdk:"post_command"`
+	PostCommandOnFailure        types.String       `tfsdk:"post_command_on_failure"`
+	CommandEnvironment          types.Map          `tfsdk:"command_environment"`
+	CommandPTY                  types.Bool         `tfsdk:"command_pty"`
+	CommandTimeout              types.String       `tfsdk:"command_timeout"`
+	CommandShell                types.String       `tfsdk:"command_shell"`
+	OnlyOnOS                    types.List         `tfsdk:"only_on_os"`
+	Skipped                     types.Bool         `tfsdk:"skipped"`
+	Checksum                    types.String       `tfsdk:"checksum"`
+	Drifted                     types.Bool         `tfsdk:"drifted"`
+	ID                          types.String       `tfsdk:"id"`
 }
 
 // NewFileResource creates a new resource implementation.
@@ -57,26 +132,81 @@ func (r *FileResource) Metadata(_ context.Context, req resource.MetadataRequest,
 	resp.TypeName = req.ProviderTypeName + "_file"
 }
 
+// ConfigValidators returns the validators of the resource configuration.
+func (r *FileResource) ConfigValidators(_ context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
 // Schema defines the schema for the resource.
 func (r *FileResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
 	resp.Schema = schema.Schema{
 		Description: "Manages a file on a remote server via SSH.",
 		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"dedicated_connection": schema.BoolAttribute{
+				Description: "If true, every operation of this resource opens its own SSH connection instead of using the shared connection pool and closes it when the operation finishes.",
+				Optional:    true,
+			},
 			"ssh": schema.SingleNestedAttribute{
-				Description: "SSH connection configuration.",
-				Required:    true,
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
 				Attributes:  ssh.SSHBlockSchema(),
 			},
 			"path": schema.StringAttribute{
 				Description: "The path where the file should be created on the remote server.",
 				Required:    true,
 				PlanModifiers: []planmodifier.String{
-					stringplanmodifier.RequiresReplace(),
+					requiresReplaceUnlessMoved(),
 				},
 			},
+			"path_change_strategy": schema.StringAttribute{
+				Description: "How a change of path is applied: 'replace' destroys the old file and creates a new one, " +
+					"'move' renames the existing file on the remote server. Defaults to 'replace'.",
+				Optional: true,
+			},
+			"resolve_symlinks": schema.BoolAttribute{
+				Description: "If true and path is a symbolic link, the file the link finally points to is managed, and the link is left " +
+					"in place. If false, a symbolic link at path is replaced by the file, and deleting the file deletes the link, " +
+					"never its target. Defaults to false.",
+				Optional: true,
+			},
 			"content": schema.StringAttribute{
-				Description: "The content of the file.",
-				Required:    true,
+				Description: "The content of the file. Exactly one of content, sensitive_content and content_wo must be set.",
+				Optional:    true,
+			},
+			"sensitive_content": schema.StringAttribute{
+				Description: "The content of the file, redacted in plan output. Use it instead of content for secrets. " +
+					"Exactly one of content, sensitive_content and content_wo must be set.",
+				Optional:  true,
+				Sensitive: true,
+			},
+			"content_wo": schema.StringAttribute{
+				Description: "The content of the file, which is never stored in the plan or state. Requires Terraform 1.11 or later. " +
+					"Exactly one of content, sensitive_content and content_wo must be set.",
+				Optional:  true,
+				Sensitive: true,
+				WriteOnly: true,
+			},
+			"content_wo_version": schema.Int64Attribute{
+				Description: "A version of content_wo. Since changes of content_wo can't be detected, " +
+					"the file is only rewritten when this version changes.",
+				Optional: true,
+			},
+			"max_read_size": schema.Int64Attribute{
+				Description: "The remote file is read to detect changes of its content. Files larger than this many bytes are not read, to avoid loading huge files into memory and state. Defaults to 10 MiB (10485760), 0 disables the limit.",
+				Optional:    true,
+			},
+			"on_too_large": schema.StringAttribute{
+				Description: "What happens on refresh if the remote file is larger than max_read_size. With \"error\", refreshing fails. " +
+					"With \"warn\", content keeps the last known value instead, changes are detected by the checksum and reported in " +
+					"drifted, and a warning is shown, which is useful for files that grow large after they are created, like logs. " +
+					"Defaults to \"error\".",
+				Optional: true,
 			},
 			"permissions": schema.StringAttribute{
 				Description: "The file permissions in octal format (e.g., '0644').",
@@ -90,6 +220,27 @@ func (r *FileResource) Schema(_ context.Context, _ resource.SchemaRequest, resp
 				Description: "The group owner of the file.",
 				Optional:    true,
 			},
+			"use_sudo": schema.BoolAttribute{
+				Description: "If true, the file is uploaded to a temporary file and installed to its path through sudo, and all " +
+					"other operations on the file run through sudo. Requires passwordless sudo for the SSH user.",
+				Optional: true,
+			},
+			"create_parents": schema.BoolAttribute{
+				Description: "Whether missing parent directories are created. If false, a missing parent directory is an error. Defaults to true.",
+				Optional:    true,
+			},
+			"parent_permissions": schema.StringAttribute{
+				Description: "The permissions in octal format used for parent directories created for the file (e.g., '0700'). Defaults to '0755'.",
+				Optional:    true,
+			},
+			"parent_owner": schema.StringAttribute{
+				Description: "The user owner of parent directories created for the file. Defaults to owner, or the SSH user if owner is not set.",
+				Optional:    true,
+			},
+			"parent_group": schema.StringAttribute{
+				Description: "The group owner of parent directories created for the file. Defaults to group, or the SSH user's group if group is not set.",
+				Optional:    true,
+			},
 			"immutable": schema.BoolAttribute{
 				Description: "If true, the file cannot be modified/deleted/renamed.",
 				Optional:    true,
@@ -98,6 +249,12 @@ func (r *FileResource) Schema(_ context.Context, _ resource.SchemaRequest, resp
 				Description: "If true, the file can only be opened in append mode for writing.",
 				Optional:    true,
 			},
+			"append_only_strategy": schema.StringAttribute{
+				Description: "How content changes are applied to a file with the append-only attribute: 'append' writes only the " +
+					"content added to the end, and fails if the new content doesn't start with the previous content; 'rewrite' " +
+					"clears the attribute, rewrites the file and restores the attribute. Defaults to 'append'.",
+				Optional: true,
+			},
 			"no_dump": schema.BoolAttribute{
 				Description: "If true, the file is not included in backups.",
 				Optional:    true,
@@ -122,6 +279,101 @@ func (r *FileResource) Schema(_ context.Context, _ resource.SchemaRequest, resp
 				Description: "If true, content is saved when deleted.",
 				Optional:    true,
 			},
+			"data_journaling": schema.BoolAttribute{
+				Description: "If true, data is written to the journal before it is written to the file.",
+				Optional:    true,
+			},
+			"no_tail_merge": schema.BoolAttribute{
+				Description: "If true, the file is not tail-merged with other files.",
+				Optional:    true,
+			},
+			"capabilities": schema.StringAttribute{
+				Description: "The file capabilities in getcap/setcap format (e.g., 'cap_net_bind_service=ep'). An empty string removes all capabilities. Ignored if libcap tools are not installed.",
+				Optional:    true,
+			},
+			"ignore_unsupported_attributes": schema.BoolAttribute{
+				Description: "If true, attributes the filesystem does not support are reported as a warning instead of failing. The remaining attributes are still applied.",
+				Optional:    true,
+			},
+			"triggers": schema.MapAttribute{
+				Description: "Arbitrary values that force the file to be recreated when any of them changes.",
+				ElementType: types.StringType,
+				Optional:    true,
+				PlanModifiers: []planmodifier.Map{
+					mapplanmodifier.RequiresReplace(),
+				},
+			},
+			"create_only": schema.BoolAttribute{
+				Description: "If true, the content is only written when the file does not exist yet. Afterwards, content changes on the remote server or in the configuration are ignored.",
+				Optional:    true,
+			},
+			"pseudo_file": schema.BoolAttribute{
+				Description: "If true, path is a pseudo file like /proc/sys/vm/swappiness or a file in /sys, e.g. a kernel tunable. " +
+					"The content is written in place with a single write, only if the current value differs, and the file is left " +
+					"alone on destroy. Values written this way don't persist across reboots.",
+				Optional: true,
+			},
+			"preserve_existing": schema.BoolAttribute{
+				Description: "If true, an existing file that is overwritten keeps its permissions, owner and group unless " +
+					"they are set in the configuration, instead of getting the default permissions '0644' and the SSH user " +
+					"as owner. Useful for files of which only the content is managed.",
+				Optional: true,
+			},
+			"backup": schema.BoolAttribute{
+				Description: "If true, an existing file is copied to the path with backup_suffix appended before its content " +
+					"is overwritten, keeping its permissions and ownership. Each write replaces the previous backup.",
+				Optional: true,
+			},
+			"backup_suffix": schema.StringAttribute{
+				Description: "The suffix appended to the path of the backup. Defaults to '.bak'.",
+				Optional:    true,
+			},
+			"delete_backup_on_destroy": schema.BoolAttribute{
+				Description: "If true, the backup is deleted together with the file. By default it is kept.",
+				Optional:    true,
+			},
+			"backup_path": schema.StringAttribute{
+				Description: "The path of the last backup made, null if none was made.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"content_validation": schema.StringAttribute{
+				Description: "A regular expression (RE2 syntax) the content must match. Checked during plan if the content is known, " +
+					"and before the file is written.",
+				Optional: true,
+			},
+			"validate_command": schema.StringAttribute{
+				Description: "A shell command run on the remote server to validate the content before the file is written, " +
+					"e.g. 'nginx -t -c %s'. The content is staged in a temporary file and every '%s' is replaced by its path. " +
+					"If the command fails, the file is not changed.",
+				Optional: true,
+			},
+			"verify_command": schema.StringAttribute{
+				Description: "A shell command run on the remote server after the content was written, e.g. 'sshd -t'. Requires backup. " +
+					"If it fails, the previous content is restored from the backup, or a file that didn't exist before is deleted, " +
+					"and the apply fails with the error output of the command.",
+				Optional: true,
+			},
+			"rolled_back": schema.BoolAttribute{
+				Description: "Whether the last update restored the previous content because verify_command failed.",
+				Computed:    true,
+			},
+			"checksum": schema.StringAttribute{
+				Description: "The SHA-256 checksum of the content written by Terraform.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"drifted": schema.BoolAttribute{
+				Description: "Whether the file content on the remote server was modified outside of Terraform since the last apply.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.UseStateForUnknown(),
+				},
+			},
 			"id": schema.StringAttribute{
 				Computed: true,
 				PlanModifiers: []planmodifier.String{
@@ -130,6 +382,8 @@ func (r *FileResource) Schema(_ context.Context, _ resource.SchemaRequest, resp
 			},
 		},
 	}
+	maps.Copy(resp.Schema.Attributes, commandHookAttributes("file"))
+	maps.Copy(resp.Schema.Attributes, onlyOnOSAttributes("file"))
 }
 
 // Create creates the resource and sets the initial Terraform state.
@@ -140,19 +394,98 @@ func (r *FileResource) Create(ctx context.Context, req resource.CreateRequest, r
 	var plan FileResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
+	// Write-only content is only available in the configuration
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("content_wo"), &plan.ContentWO)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	client, err := r.getClient(ctx, plan.SSH)
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	// Only a file that is overwritten is backed up
+	plan.BackupPath = types.StringNull()
+
+	skipped, ok := skippedOnPlatform(ctx, client, plan.OnlyOnOS, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	plan.Skipped = types.BoolValue(skipped)
+	if skipped {
+		plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
+		plan.Checksum = types.StringNull()
+		plan.Drifted = basetypes.NewBoolValue(false)
+		plan.RolledBack = basetypes.NewBoolValue(false)
+
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	// The operations apply to the target of a resolved symbolic link, the state keeps the configured path
+	configuredPath := plan.Path
+	if !resolveSymlinks(ctx, client, &plan, &resp.Diagnostics) {
+		return
+	}
+
+	if !checkPathType(ctx, client, plan.Path.ValueString(), false, &resp.Diagnostics) {
+		return
+	}
+
+	commandCtx := commandContext(ctx, plan.CommandEnvironment, plan.CommandPTY, plan.CommandTimeout, plan.CommandShell, &resp.Diagnostics)
+	if !validateFileContent(commandCtx, client, plan, &resp.Diagnostics) {
+		return
+	}
+
+	if !runCommandHook(commandCtx, client, "pre_command", plan.PreCommand, plan.PreCommandOnFailure, &resp.Diagnostics) {
+		return
+	}
+
+	if plan.PseudoFile.ValueBool() {
+		written, ok := writePseudoFile(ctx, client, plan, &resp.Diagnostics)
+		if !ok {
+			return
+		}
+
+		plan.Path = configuredPath
+		plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
+		plan.Checksum = contentChecksum(plan)
+		plan.Drifted = basetypes.NewBoolValue(false)
+		plan.RolledBack = basetypes.NewBoolValue(false)
+
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+
+		if written {
+			runCommandHook(commandCtx, client, "post_command", plan.PostCommand, plan.PostCommandOnFailure, &resp.Diagnostics)
+		}
+		return
+	}
+
+	if _, ok := removeSymlink(ctx, client, plan, &resp.Diagnostics); !ok {
+		return
+	}
+
+	// The file is written with the mode and ownership it already has, the state keeps them unmanaged
+	write, err := preserveExisting(ctx, client, plan)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading existing file",
+			fmt.Sprintf("Could not read the permissions and ownership of the existing file: %s", err),
 		)
 		return
 	}
-	defer client.Close()
 
 	exists, err := client.Exists(ctx, plan.Path.ValueString())
 	if err != nil {
@@ -162,23 +495,24 @@ func (r *FileResource) Create(ctx context.Context, req resource.CreateRequest, r
 		)
 		return
 	}
-	if exists {
-		content, err := client.ReadFile(ctx, plan.Path.ValueString())
+	existed := exists
+	// An existing file is kept as-is if it's only to be created when absent
+	if exists && !plan.CreateOnly.ValueBool() {
+		unchanged, err := contentUnchanged(ctx, client, plan)
 		if err != nil {
 			resp.Diagnostics.AddError(
 				"Error checking file content",
-				fmt.Sprintf("Could not read file content: %s", err),
+				fmt.Sprintf("Could not compare the file content: %s", err),
 			)
 			return
 		}
 
-		// When content does not match the desired state, delete the file and pretend it doesn't exist (anymore)
-		if content != plan.Content.ValueString() {
-			err := client.DeleteFile(ctx, plan.Path.ValueString())
-			if err != nil {
+		// When content does not match the desired state, the file is written as if it didn't exist
+		if !unchanged {
+			if plan.BackupPath, err = backupFile(ctx, client, plan, plan.BackupPath); err != nil {
 				resp.Diagnostics.AddError(
-					"Error recreating file",
-					fmt.Sprintf("Could delete file after content mismatch: %s", err),
+					"Error backing up file",
+					fmt.Sprintf("Could not back up file before overwriting it: %s", err),
 				)
 				return
 			}
@@ -186,10 +520,10 @@ func (r *FileResource) Create(ctx context.Context, req resource.CreateRequest, r
 		}
 	}
 
-	permissions := ssh.ParsePermissions(plan.Permissions.ValueString())
+	permissions := ssh.ParsePermissions(write.Permissions.ValueString())
 
 	if !exists {
-		err = client.CreateFile(ctx, plan.Path.ValueString(), plan.Content.ValueString(), os.FileMode(permissions))
+		err = client.CreateFileWithOptions(ctx, plan.Path.ValueString(), fileContent(plan), os.FileMode(permissions), createFileOptions(write))
 		if err != nil {
 			resp.Diagnostics.AddError(
 				"Error creating file",
@@ -197,50 +531,38 @@ func (r *FileResource) Create(ctx context.Context, req resource.CreateRequest, r
 			)
 			return
 		}
-	}
 
-	// Set ownership if specified
-	if !plan.Owner.IsNull() || !plan.Group.IsNull() {
-		err = client.SetFileOwnership(ctx, plan.Path.ValueString(), &ssh.FileOwnership{
-			User:  plan.Owner.ValueString(),
-			Group: plan.Group.ValueString(),
-		})
-		if err != nil {
-			resp.Diagnostics.AddError(
-				"Error setting file ownership",
-				fmt.Sprintf("Could not set file ownership: %s", err),
-			)
+		// The file isn't recorded in the state if it's rolled back
+		if _, ok := verifyFile(ctx, commandCtx, client, plan, existed, existed && plan.Backup.ValueBool(), &resp.Diagnostics); !ok {
 			return
 		}
 	}
 
-	// Set attributes if any are specified
-	if !plan.Immutable.IsNull() || !plan.AppendOnly.IsNull() || !plan.NoDump.IsNull() ||
-		!plan.Synchronous.IsNull() || !plan.NoAtime.IsNull() || !plan.Compressed.IsNull() ||
-		!plan.NoCoW.IsNull() || !plan.Undeletable.IsNull() {
-		err = client.SetFileAttributes(ctx, plan.Path.ValueString(), &ssh.FileAttributes{
-			Immutable:   plan.Immutable.ValueBool(),
-			AppendOnly:  plan.AppendOnly.ValueBool(),
-			NoDump:      plan.NoDump.ValueBool(),
-			Synchronous: plan.Synchronous.ValueBool(),
-			NoAtime:     plan.NoAtime.ValueBool(),
-			Compressed:  plan.Compressed.ValueBool(),
-			NoCoW:       plan.NoCoW.ValueBool(),
-			Undeletable: plan.Undeletable.ValueBool(),
-		})
-		if err != nil {
-			resp.Diagnostics.AddError(
-				"Error setting file attributes",
-				fmt.Sprintf("Could not set file attributes: %s", err),
-			)
-			return
-		}
+	// The mode of an existing file that is only created when absent is kept. Otherwise it's applied even to a
+	// file that was just created with it, as changing the ownership clears setuid and setgid bits.
+	var mode *os.FileMode
+	if !exists || !plan.CreateOnly.ValueBool() {
+		wanted := os.FileMode(permissions)
+		mode = &wanted
+	}
+	if !applyFileMetadata(ctx, client, plan, write, mode, &resp.Diagnostics) {
+		return
 	}
 
+	plan.Path = configuredPath
 	plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
+	plan.Checksum = contentChecksum(plan)
+	plan.Drifted = basetypes.NewBoolValue(false)
+	plan.RolledBack = basetypes.NewBoolValue(false)
 
 	diags = resp.State.Set(ctx, plan)
 	resp.Diagnostics.Append(diags...)
+
+	// The state is saved first, a failing post_command doesn't undo the change. An existing file with the
+	// wanted content is not written, so it doesn't count as a change.
+	if !exists {
+		runCommandHook(commandCtx, client, "post_command", plan.PostCommand, plan.PostCommandOnFailure, &resp.Diagnostics)
+	}
 }
 
 // Read refreshes the Terraform state with the latest data.
@@ -255,15 +577,40 @@ func (r *FileResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
-	client, err := r.getClient(ctx, state.SSH)
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			ssh.ConnectionErrorDetail(err),
 		)
 		return
 	}
-	defer client.Close()
+	defer release()
+
+	skipped, ok := skippedOnPlatform(ctx, client, state.OnlyOnOS, &resp.Diagnostics)
+	if !ok {
+		return
+	}
+	// A skipped file is created once the host's operating system is in only_on_os, e.g. after the host was replaced
+	if state.Skipped.ValueBool() && !skipped {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	state.Skipped = types.BoolValue(skipped)
+	if skipped {
+		diags = resp.State.Set(ctx, &state)
+		resp.Diagnostics.Append(diags...)
+		return
+	}
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	configuredPath := state.Path
+	if !resolveSymlinks(ctx, client, &state, &resp.Diagnostics) {
+		return
+	}
 
 	exists, err := client.Exists(ctx, state.Path.ValueString())
 	if err != nil {
@@ -278,30 +625,123 @@ func (r *FileResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		return
 	}
 
-	content, err := client.ReadFile(ctx, state.Path.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error reading file",
-			fmt.Sprintf("Could not read file: %s", err),
-		)
-		return
+	// A symbolic link that isn't resolved is not the managed file, so the file is created in its place
+	if !state.ResolveSymlinks.ValueBool() && !state.PseudoFile.ValueBool() {
+		_, isSymlink, err := client.ReadSymlink(ctx, state.Path.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error reading file",
+				fmt.Sprintf("Could not check for a symbolic link: %s", err),
+			)
+			return
+		}
+		if isSymlink {
+			resp.State.RemoveResource(ctx)
+			return
+		}
 	}
-	state.Content = basetypes.NewStringValue(content)
 
-	// Get file mode
-	mode, err := client.GetFileMode(ctx, state.Path.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error reading file mode",
-			fmt.Sprintf("Could not read file mode: %s", err),
-		)
+	// A pseudo file only has its current value, and the kernel formats it on its own, so the value
+	// is compared instead of a checksum
+	if state.PseudoFile.ValueBool() {
+		state.Drifted = basetypes.NewBoolValue(false)
+		if !writeOnlyContent(state) {
+			content, err := client.ReadFileLimited(ctx, state.Path.ValueString(), maxReadSize(state))
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"Error reading file",
+					ssh.FileReadErrorDetail(err),
+				)
+				return
+			}
+			if pseudoFileValue(content) != pseudoFileValue(fileContent(state)) {
+				state.Drifted = basetypes.NewBoolValue(true)
+				if !state.SensitiveContent.IsNull() {
+					state.SensitiveContent = basetypes.NewStringValue(content)
+				} else {
+					state.Content = basetypes.NewStringValue(content)
+				}
+			}
+		}
+
+		state.Path = configuredPath
+		diags = resp.State.Set(ctx, &state)
+		resp.Diagnostics.Append(diags...)
 		return
 	}
-	state.Permissions = basetypes.NewStringValue(fmt.Sprintf("%04o", mode))
+
+	// The content of a create-only file is expected to change, so it's neither read nor checked for drift.
+	// Write-only content isn't known after apply, so it can't be compared either.
+	if state.CreateOnly.ValueBool() || writeOnlyContent(state) {
+		state.Drifted = basetypes.NewBoolValue(false)
+	} else {
+		// With on_too_large = "warn", the content of a file above max_read_size keeps its last known value and
+		// only the checksum tells about changes
+		content, err := client.ReadFileLimited(ctx, state.Path.ValueString(), maxReadSize(state))
+		tooLarge := state.OnTooLarge.ValueString() == onTooLargeWarn && errors.Is(err, ssh.ErrFileTooLarge)
+		if err != nil && !tooLarge {
+			resp.Diagnostics.AddError(
+				"Error reading file",
+				ssh.FileReadErrorDetail(err),
+			)
+			return
+		}
+
+		// Detect external modifications by comparing the remote checksum with the one recorded on apply. It's
+		// computed from the content that was read, only a file that is too large to read is checksummed remotely.
+		checksum := ssh.ContentChecksum(content)
+		if tooLarge {
+			resp.Diagnostics.AddWarning(
+				"File content not read",
+				fmt.Sprintf("%s is larger than max_read_size (%d bytes), so its content was not read. The content keeps "+
+					"its last known value, drifted reports whether the file changed.", state.Path.ValueString(), maxReadSize(state)),
+			)
+			checksum, err = client.FileChecksum(ctx, state.Path.ValueString())
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"Error reading file checksum",
+					fmt.Sprintf("Could not compute file checksum: %s", err),
+				)
+				return
+			}
+		}
+		if state.Checksum.IsNull() {
+			state.Checksum = basetypes.NewStringValue(checksum)
+		}
+		state.Drifted = basetypes.NewBoolValue(checksum != state.Checksum.ValueString())
+
+		switch {
+		case tooLarge:
+		case !state.SensitiveContent.IsNull():
+			state.SensitiveContent = basetypes.NewStringValue(content)
+		default:
+			state.Content = basetypes.NewStringValue(content)
+		}
+	}
+
+	// Get file mode if it was specified
+	if !state.Permissions.IsNull() {
+		mode, err := client.GetFileMode(ctx, state.Path.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error reading file mode",
+				fmt.Sprintf("Could not read file mode: %s", err),
+			)
+			return
+		}
+		// Keep the configured notation (e.g. "644" vs "0644") when the mode matches
+		if os.FileMode(ssh.ParsePermissions(state.Permissions.ValueString())) != mode {
+			state.Permissions = basetypes.NewStringValue(fmt.Sprintf("%04o", mode))
+		}
+	}
 
 	// Get ownership if it was specified
 	if !state.Owner.IsNull() || !state.Group.IsNull() {
-		ownership, err := client.GetFileOwnership(ctx, state.Path.ValueString())
+		// Numeric IDs are kept as configured instead of being replaced by names
+		ownership, err := client.GetFileOwnershipAs(ctx, state.Path.ValueString(), &ssh.FileOwnership{
+			User:  state.Owner.ValueString(),
+			Group: state.Group.ValueString(),
+		})
 		if err != nil {
 			resp.Diagnostics.AddError(
 				"Error reading file ownership",
@@ -317,10 +757,34 @@ func (r *FileResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		}
 	}
 
+	// Get capabilities if they were specified
+	if !state.Capabilities.IsNull() {
+		capabilities, err := client.GetCapabilities(ctx, state.Path.ValueString())
+		switch {
+		case errors.Is(err, ssh.ErrCapabilitiesUnsupported):
+			// Capabilities are ignored without the libcap tools, so the last known value is kept
+			resp.Diagnostics.AddAttributeWarning(
+				path.Root("capabilities"),
+				"File capabilities not checked",
+				fmt.Sprintf("Could not read file capabilities, the last known value is kept: %s", err),
+			)
+		case err != nil:
+			resp.Diagnostics.AddError(
+				"Error reading file capabilities",
+				fmt.Sprintf("Could not read file capabilities: %s", err),
+			)
+			return
+		case ssh.NormalizeCapabilities(capabilities) != ssh.NormalizeCapabilities(state.Capabilities.ValueString()):
+			// Keep the configured notation when it is equivalent to what getcap reports
+			state.Capabilities = basetypes.NewStringValue(capabilities)
+		}
+	}
+
 	// Get attributes if any were specified
 	if !state.Immutable.IsNull() || !state.AppendOnly.IsNull() || !state.NoDump.IsNull() ||
 		!state.Synchronous.IsNull() || !state.NoAtime.IsNull() || !state.Compressed.IsNull() ||
-		!state.NoCoW.IsNull() || !state.Undeletable.IsNull() {
+		!state.NoCoW.IsNull() || !state.Undeletable.IsNull() ||
+		!state.DataJournaling.IsNull() || !state.NoTailMerge.IsNull() {
 		attrs, err := client.GetFileAttributes(ctx, state.Path.ValueString())
 		if err != nil {
 			resp.Diagnostics.AddError(
@@ -353,8 +817,15 @@ func (r *FileResource) Read(ctx context.Context, req resource.ReadRequest, resp
 		if !state.Undeletable.IsNull() {
 			state.Undeletable = types.BoolValue(attrs.Undeletable)
 		}
+		if !state.DataJournaling.IsNull() {
+			state.DataJournaling = types.BoolValue(attrs.DataJournaling)
+		}
+		if !state.NoTailMerge.IsNull() {
+			state.NoTailMerge = types.BoolValue(attrs.NoTailMerge)
+		}
 	}
 
+	state.Path = configuredPath
 	diags = resp.State.Set(ctx, &state)
 	resp.Diagnostics.Append(diags...)
 }
@@ -364,172 +835,1276 @@ func (r *FileResource) Update(ctx context.Context, req resource.UpdateRequest, r
 	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FileResource.Update")
 	defer span.End()
 
-	var plan FileResourceModel
+	var plan, state FileResourceModel
 	diags := req.Plan.Get(ctx, &plan)
 	resp.Diagnostics.Append(diags...)
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	// Write-only content is only available in the configuration
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("content_wo"), &plan.ContentWO)...)
 	if resp.Diagnostics.HasError() {
 		return
 	}
 
-	client, err := r.getClient(ctx, plan.SSH)
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			ssh.ConnectionErrorDetail(err),
 		)
 		return
 	}
-	defer client.Close()
+	defer release()
 
-	exists, err := client.Exists(ctx, plan.Path.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error determining if file exists",
-			fmt.Sprintf("Could determine file existence: %s", err),
-		)
+	plan.BackupPath = state.BackupPath
+
+	skipped, ok := skippedOnPlatform(ctx, client, plan.OnlyOnOS, &resp.Diagnostics)
+	if !ok {
 		return
 	}
-	if exists {
-		if err := client.DeleteFile(ctx, plan.Path.ValueString()); err != nil {
-			resp.Diagnostics.AddError(
-				"Error updating file",
-				fmt.Sprintf("Could not recreate file: %s", err),
-			)
-		}
+	plan.Skipped = types.BoolValue(skipped)
+	if skipped {
+		warnNoLongerManaged("file", plan.Path, state.Skipped, &resp.Diagnostics)
+		plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
+		plan.Checksum = types.StringNull()
+		plan.Drifted = basetypes.NewBoolValue(false)
+		plan.RolledBack = basetypes.NewBoolValue(false)
+
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+		return
 	}
 
-	permissions := ssh.ParsePermissions(plan.Permissions.ValueString())
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
 
-	err = client.CreateFile(ctx, plan.Path.ValueString(), plan.Content.ValueString(), os.FileMode(permissions))
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error updating file",
-			fmt.Sprintf("Could not update file: %s", err),
-		)
+	// The operations apply to the target of a resolved symbolic link, the state keeps the configured path
+	configuredPath := plan.Path
+	if !resolveSymlinks(ctx, client, &plan, &resp.Diagnostics) {
 		return
 	}
 
-	// Set ownership if specified
-	if !plan.Owner.IsNull() || !plan.Group.IsNull() {
-		err = client.SetFileOwnership(ctx, plan.Path.ValueString(), &ssh.FileOwnership{
-			User:  plan.Owner.ValueString(),
-			Group: plan.Group.ValueString(),
-		})
-		if err != nil {
-			resp.Diagnostics.AddError(
-				"Error setting file ownership",
-				fmt.Sprintf("Could not set file ownership: %s", err),
-			)
+	if !checkPathType(ctx, client, plan.Path.ValueString(), false, &resp.Diagnostics) {
+		return
+	}
+
+	commandCtx := commandContext(ctx, plan.CommandEnvironment, plan.CommandPTY, plan.CommandTimeout, plan.CommandShell, &resp.Diagnostics)
+	if !validateFileContent(commandCtx, client, plan, &resp.Diagnostics) {
+		return
+	}
+
+	if !runCommandHook(commandCtx, client, "pre_command", plan.PreCommand, plan.PreCommandOnFailure, &resp.Diagnostics) {
+		return
+	}
+
+	if plan.PseudoFile.ValueBool() {
+		written, ok := writePseudoFile(ctx, client, plan, &resp.Diagnostics)
+		if !ok {
 			return
 		}
+
+		plan.Path = configuredPath
+		plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
+		plan.Checksum = contentChecksum(plan)
+		plan.Drifted = basetypes.NewBoolValue(false)
+		plan.RolledBack = basetypes.NewBoolValue(false)
+
+		diags = resp.State.Set(ctx, plan)
+		resp.Diagnostics.Append(diags...)
+
+		if written {
+			runCommandHook(commandCtx, client, "post_command", plan.PostCommand, plan.PostCommandOnFailure, &resp.Diagnostics)
+		}
+		return
 	}
 
-	// Set attributes if any are specified
-	if !plan.Immutable.IsNull() || !plan.AppendOnly.IsNull() || !plan.NoDump.IsNull() ||
-		!plan.Synchronous.IsNull() || !plan.NoAtime.IsNull() || !plan.Compressed.IsNull() ||
-		!plan.NoCoW.IsNull() || !plan.Undeletable.IsNull() {
-		err = client.SetFileAttributes(ctx, plan.Path.ValueString(), &ssh.FileAttributes{
-			Immutable:   plan.Immutable.ValueBool(),
-			AppendOnly:  plan.AppendOnly.ValueBool(),
-			NoDump:      plan.NoDump.ValueBool(),
-			Synchronous: plan.Synchronous.ValueBool(),
-			NoAtime:     plan.NoAtime.ValueBool(),
-			Compressed:  plan.Compressed.ValueBool(),
-			NoCoW:       plan.NoCoW.ValueBool(),
-			Undeletable: plan.Undeletable.ValueBool(),
-		})
+	// A path change only reaches Update when the "move" strategy is used, otherwise it forces replacement
+	moved := !configuredPath.Equal(state.Path)
+	if moved {
+		err = client.Move(ctx, state.Path.ValueString(), plan.Path.ValueString())
 		if err != nil {
 			resp.Diagnostics.AddError(
-				"Error setting file attributes",
-				fmt.Sprintf("Could not set file attributes: %s", err),
+				"Error moving file",
+				fmt.Sprintf("Could not move file from %s to %s: %s", state.Path.ValueString(), plan.Path.ValueString(), err),
 			)
 			return
 		}
 	}
 
-	diags = resp.State.Set(ctx, plan)
-	resp.Diagnostics.Append(diags...)
-}
-
-// Delete deletes the resource and removes the Terraform state on success.
-func (r *FileResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
-	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FileResource.Delete")
-	defer span.End()
-
-	var state FileResourceModel
-	diags := req.State.Get(ctx, &state)
-	resp.Diagnostics.Append(diags...)
-	if resp.Diagnostics.HasError() {
+	linkRemoved, ok := removeSymlink(ctx, client, plan, &resp.Diagnostics)
+	if !ok {
 		return
 	}
 
-	client, err := r.getClient(ctx, state.SSH)
+	write, err := preserveExisting(ctx, client, plan)
 	if err != nil {
 		resp.Diagnostics.AddError(
-			"Error creating SSH client",
-			fmt.Sprintf("Could not create SSH client: %s", err),
+			"Error reading existing file",
+			fmt.Sprintf("Could not read the permissions and ownership of the existing file: %s", err),
 		)
 		return
 	}
-	defer client.Close()
+	permissions := ssh.ParsePermissions(write.Permissions.ValueString())
 
-	exists, err := client.Exists(ctx, state.Path.ValueString())
-	if err != nil {
-		resp.Diagnostics.AddError(
-			"Error determining if file exists",
-			fmt.Sprintf("Could determine file existence: %s", err),
+	// changed is whether the file was moved or its content was written, which triggers post_command.
+	// Permissions, ownership and attributes alone don't count as a change of the file.
+	changed := moved
+	// written is whether the content was written, and existed and backedUp whether it replaced a file that was
+	// backed up, for verify_command
+	var written, existed, backedUp bool
+	// An existing file that is kept in place only gets its metadata applied below
+	if !((moved && fileContent(plan) == fileContent(state)) || plan.CreateOnly.ValueBool()) || linkRemoved {
+		exists, err := client.Exists(ctx, plan.Path.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error determining if file exists",
+				fmt.Sprintf("Could determine file existence: %s", err),
+			)
+			return
+		}
+
+		// A file that already has the wanted content is not written, which keeps its modification time
+		unchanged := false
+		if exists {
+			unchanged, err = contentUnchanged(ctx, client, plan)
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"Error checking file content",
+					fmt.Sprintf("Could not compare the file content: %s", err),
+				)
+				return
+			}
+		}
+
+		// The content in the state was refreshed from the remote file. Write-only content isn't in the state,
+		// so its version tells whether it changed.
+		if writeOnlyContent(plan) {
+			changed = changed || !exists || !plan.ContentWOVersion.Equal(state.ContentWOVersion)
+		} else {
+			changed = changed || !exists || fileContent(plan) != fileContent(state)
+		}
+		// Like in Create, a file that already has the wanted content doesn't count as a change
+		if unchanged {
+			changed = moved
+		}
+
+		// The backup is made before an append-only file is appended to as well
+		if exists && changed && !unchanged {
+			if plan.BackupPath, err = backupFile(ctx, client, plan, plan.BackupPath); err != nil {
+				resp.Diagnostics.AddError(
+					"Error backing up file",
+					fmt.Sprintf("Could not back up file before overwriting it: %s", err),
+				)
+				return
+			}
+			backedUp = plan.Backup.ValueBool()
+		}
+		written, existed = !unchanged, exists
+
+		// An append-only file can't be truncated or deleted, so it's either appended to or the attribute
+		// is cleared while the file is rewritten
+		var appended, restoreAppendOnly bool
+		if exists && !unchanged {
+			appended, restoreAppendOnly, err = prepareAppendOnlyUpdate(ctx, client, plan, state)
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"Error updating append-only file",
+					fmt.Sprintf("Could not update append-only file: %s", err),
+				)
+				return
+			}
+		}
+
+		if !unchanged && !appended {
+			err = client.CreateFileWithOptions(ctx, plan.Path.ValueString(), fileContent(plan), os.FileMode(permissions), createFileOptions(write))
+			if restoreAppendOnly {
+				if restoreErr := setAppendOnly(ctx, client, plan.Path.ValueString(), true); restoreErr != nil {
+					resp.Diagnostics.AddError(
+						"Error restoring append-only attribute",
+						fmt.Sprintf("Could not restore the append-only attribute: %s", restoreErr),
+					)
+				}
+			}
+			if err != nil {
+				resp.Diagnostics.AddError(
+					"Error updating file",
+					fmt.Sprintf("Could not update file: %s", err),
+				)
+				return
+			}
+			if resp.Diagnostics.HasError() {
+				return
+			}
+		}
+	}
+
+	if written {
+		rolledBack, ok := verifyFile(ctx, commandCtx, client, plan, existed, backedUp, &resp.Diagnostics)
+		// The file has its previous content again, which the state records together with the rollback
+		if rolledBack {
+			state.Path = configuredPath
+			state.ID = basetypes.NewStringValue(configuredPath.ValueString())
+			state.BackupPath = plan.BackupPath
+			state.RolledBack = basetypes.NewBoolValue(true)
+			resp.Diagnostics.Append(resp.State.Set(ctx, state)...)
+		}
+		if !ok {
+			return
+		}
+	}
+
+	// The mode is applied even to a file that was just written with it, as changing the ownership clears
+	// setuid and setgid bits
+	mode := os.FileMode(permissions)
+	if !applyFileMetadata(ctx, client, plan, write, &mode, &resp.Diagnostics) {
+		return
+	}
+
+	plan.Path = configuredPath
+	plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
+	plan.Checksum = contentChecksum(plan)
+	plan.Drifted = basetypes.NewBoolValue(false)
+	plan.RolledBack = basetypes.NewBoolValue(false)
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+
+	// The state is saved first, a failing post_command doesn't undo the change
+	if changed {
+		runCommandHook(commandCtx, client, "post_command", plan.PostCommand, plan.PostCommandOnFailure, &resp.Diagnostics)
+	}
+}
+
+// Delete deletes the resource and removes the Terraform state on success.
+func (r *FileResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FileResource.Delete")
+	defer span.End()
+
+	var state FileResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Pseudo files belong to the kernel and can't be deleted, the last value written stays in effect. A skipped
+	// file was never written.
+	if state.PseudoFile.ValueBool() || state.Skipped.ValueBool() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
 		)
 		return
 	}
-	if !exists {
-		resp.State.RemoveResource(ctx)
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	// The target of a resolved symbolic link is deleted, the link is left dangling
+	if !resolveSymlinks(ctx, client, &state, &resp.Diagnostics) {
 		return
 	}
 
-	err = client.DeleteFile(ctx, state.Path.ValueString())
+	exists, err := client.Exists(ctx, state.Path.ValueString())
 	if err != nil {
-		if os.IsNotExist(err) {
-			// If the file is already gone, that's fine
+		resp.Diagnostics.AddError(
+			"Error determining if file exists",
+			fmt.Sprintf("Could determine file existence: %s", err),
+		)
+		return
+	}
+	if exists {
+		err = client.DeleteFile(ctx, state.Path.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error deleting file",
+				fmt.Sprintf("Could not delete file: %s", err),
+			)
+			return
+		}
+	}
+
+	if state.DeleteBackupOnDestroy.ValueBool() && !state.BackupPath.IsNull() {
+		err = client.DeleteFile(ctx, state.BackupPath.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error deleting backup",
+				fmt.Sprintf("Could not delete backup %s: %s", state.BackupPath.ValueString(), err),
+			)
 			return
 		}
+	}
+}
+
+// ValidateConfig validates the resource configuration.
+func (r *FileResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var config FileResourceModel
+	diags := req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	contentCount := 0
+	for _, content := range []types.String{config.Content, config.SensitiveContent, config.ContentWO} {
+		if !content.IsNull() {
+			contentCount++
+		}
+	}
+	if contentCount > 1 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("content"),
+			"Conflicting content",
+			"Only one of content, sensitive_content and content_wo can be set.",
+		)
+	}
+	if contentCount == 0 {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("content"),
+			"Missing content",
+			"One of content, sensitive_content and content_wo must be set.",
+		)
+	}
+	if !config.ContentWOVersion.IsNull() && config.ContentWO.IsNull() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("content_wo_version"),
+			"Missing write-only content",
+			"content_wo_version can only be set together with content_wo.",
+		)
+	}
+
+	validateOwnership(config.Owner, path.Root("owner"), &resp.Diagnostics)
+	validateOwnership(config.Group, path.Root("group"), &resp.Diagnostics)
+	validateOwnership(config.ParentOwner, path.Root("parent_owner"), &resp.Diagnostics)
+	validateOwnership(config.ParentGroup, path.Root("parent_group"), &resp.Diagnostics)
+
+	if !config.PathChangeStrategy.IsNull() && !config.PathChangeStrategy.IsUnknown() {
+		switch config.PathChangeStrategy.ValueString() {
+		case pathChangeStrategyReplace, pathChangeStrategyMove:
+		default:
+			resp.Diagnostics.AddAttributeError(
+				path.Root("path_change_strategy"),
+				"Invalid path change strategy",
+				fmt.Sprintf("Expected %q or %q, got %q.", pathChangeStrategyReplace, pathChangeStrategyMove, config.PathChangeStrategy.ValueString()),
+			)
+		}
+	}
+
+	if !config.ContentValidation.IsNull() && !config.ContentValidation.IsUnknown() {
+		pattern, err := regexp.Compile(config.ContentValidation.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("content_validation"),
+				"Invalid content validation",
+				fmt.Sprintf("Could not parse the regular expression: %s", err),
+			)
+		} else if contentCount == 1 {
+			// Content that is only known during apply is checked before it's written
+			content, contentPath := configuredContent(config)
+			if !content.IsUnknown() && !pattern.MatchString(content.ValueString()) {
+				resp.Diagnostics.AddAttributeError(
+					contentPath,
+					"Invalid content",
+					fmt.Sprintf("The content doesn't match content_validation %q.", config.ContentValidation.ValueString()),
+				)
+			}
+		}
+	}
+	if !config.OnTooLarge.IsNull() && !config.OnTooLarge.IsUnknown() {
+		switch config.OnTooLarge.ValueString() {
+		case onTooLargeError, onTooLargeWarn:
+		default:
+			resp.Diagnostics.AddAttributeError(
+				path.Root("on_too_large"),
+				"Invalid on_too_large",
+				fmt.Sprintf("Expected %q or %q, got %q.", onTooLargeError, onTooLargeWarn, config.OnTooLarge.ValueString()),
+			)
+		}
+	}
+	if !config.ValidateCommand.IsNull() && !config.ValidateCommand.IsUnknown() && !strings.Contains(config.ValidateCommand.ValueString(), "%s") {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("validate_command"),
+			"Invalid validate command",
+			"validate_command must contain %s, which is replaced by the path of the staged content.",
+		)
+	}
+
+	if !config.VerifyCommand.IsNull() && !config.Backup.IsUnknown() && !config.Backup.ValueBool() {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("verify_command"),
+			"Missing backup",
+			"verify_command requires backup = true, as the previous content is restored from the backup if the command fails.",
+		)
+	}
+
+	if !config.BackupSuffix.IsNull() && !config.BackupSuffix.IsUnknown() {
+		suffix := config.BackupSuffix.ValueString()
+		if suffix == "" || strings.Contains(suffix, "/") {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("backup_suffix"),
+				"Invalid backup suffix",
+				fmt.Sprintf("The backup suffix must not be empty or contain '/', got %q.", suffix),
+			)
+		}
+	}
+
+	validateCommandFailure(config.PreCommandOnFailure, "pre_command_on_failure", &resp.Diagnostics)
+	validateCommandFailure(config.PostCommandOnFailure, "post_command_on_failure", &resp.Diagnostics)
+	validateCommandEnvironment(config.CommandEnvironment, &resp.Diagnostics)
+	validateCommandTimeout(config.CommandTimeout, &resp.Diagnostics)
+	validateCommandShell(config.CommandShell, &resp.Diagnostics)
+	validateOnlyOnOS(config.OnlyOnOS, &resp.Diagnostics)
+
+	if config.PseudoFile.ValueBool() {
+		validatePseudoFile(config, &resp.Diagnostics)
+	}
+	if config.ResolveSymlinks.ValueBool() && config.PathChangeStrategy.ValueString() == pathChangeStrategyMove {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("path_change_strategy"),
+			"Conflicting attributes",
+			fmt.Sprintf("path_change_strategy can't be %q if resolve_symlinks is true, as it's unclear whether the link or its target should move.", pathChangeStrategyMove),
+		)
+	}
+
+	if !config.AppendOnlyStrategy.IsNull() && !config.AppendOnlyStrategy.IsUnknown() {
+		switch config.AppendOnlyStrategy.ValueString() {
+		case appendOnlyStrategyAppend, appendOnlyStrategyRewrite:
+		default:
+			resp.Diagnostics.AddAttributeError(
+				path.Root("append_only_strategy"),
+				"Invalid append-only strategy",
+				fmt.Sprintf("Expected %q or %q, got %q.", appendOnlyStrategyAppend, appendOnlyStrategyRewrite, config.AppendOnlyStrategy.ValueString()),
+			)
+		}
+	}
+}
+
+// ModifyPlan marks the ID as unknown when a file is moved, since the ID follows the path, the backup
+// path when an update may overwrite a file that is backed up, and the checksum and drifted on updates.
+func (r *FileResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var planPath, statePath types.String
+	var backup types.Bool
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("path"), &planPath)...)
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("path"), &statePath)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("backup"), &backup)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !planPath.Equal(statePath) {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("id"), types.StringUnknown())...)
+	}
+	if backup.ValueBool() && !req.Plan.Raw.Equal(req.State.Raw) {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("backup_path"), types.StringUnknown())...)
+	}
+	// The checksum and drifted keep their values unless the file is updated, which sets them again
+	if !req.Plan.Raw.Equal(req.State.Raw) {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("checksum"), types.StringUnknown())...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("drifted"), types.BoolUnknown())...)
+	}
+}
+
+func (r *FileResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
 		resp.Diagnostics.AddError(
-			"Error deleting file",
-			fmt.Sprintf("Could not delete file: %s", err),
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
 		)
 		return
 	}
+	if providerData == nil {
+		return
+	}
+
+	r.connections = providerData.Connections
+}
+
+// requiresReplaceUnlessMoved forces replacement on a path change unless the "move" strategy is configured
+func requiresReplaceUnlessMoved() planmodifier.String {
+	return stringplanmodifier.RequiresReplaceIf(
+		func(ctx context.Context, req planmodifier.StringRequest, resp *stringplanmodifier.RequiresReplaceIfFuncResponse) {
+			var strategy types.String
+			resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("path_change_strategy"), &strategy)...)
+			resp.RequiresReplace = strategy.ValueString() != pathChangeStrategyMove
+		},
+		"Changing the path requires replacement unless path_change_strategy is \"move\".",
+		"Changing the path requires replacement unless `path_change_strategy` is `\"move\"`.",
+	)
+}
+
+// prepareAppendOnlyUpdate handles a content change of an existing file that has the append-only
+// attribute. With the "append" strategy, the added content is appended and appended is true. With the
+// "rewrite" strategy, or if append_only is set to false, the attribute is cleared so the file can be
+// rewritten, and restore reports whether it must be set again afterwards. Files without the attribute,
+// or on hosts without lsattr, are left to be rewritten as usual.
+func prepareAppendOnlyUpdate(ctx context.Context, client ssh.FileOps, plan FileResourceModel, state FileResourceModel) (appended bool, restore bool, err error) {
+	attrs, err := client.GetFileAttributes(ctx, plan.Path.ValueString())
+	if err != nil || !attrs.AppendOnly {
+		return false, false, nil
+	}
+
+	keepAppendOnly := plan.AppendOnly.IsNull() || plan.AppendOnly.ValueBool()
+	if keepAppendOnly && plan.AppendOnlyStrategy.ValueString() != appendOnlyStrategyRewrite {
+		if writeOnlyContent(state) {
+			return false, false, fmt.Errorf("the previous write-only content is unknown, so the new content can't be appended; " +
+				"set append_only_strategy to \"rewrite\" to replace the content")
+		}
+		previous := fileContent(state)
+		content := fileContent(plan)
+		if !strings.HasPrefix(content, previous) {
+			return false, false, fmt.Errorf("the new content doesn't start with the previous content, so it can't be appended; " +
+				"set append_only_strategy to \"rewrite\" to replace the content")
+		}
+		if err := client.AppendFile(ctx, plan.Path.ValueString(), strings.TrimPrefix(content, previous)); err != nil {
+			return false, false, err
+		}
+		return true, false, nil
+	}
+
+	if err := setAppendOnly(ctx, client, plan.Path.ValueString(), false); err != nil {
+		return false, false, fmt.Errorf("failed to clear append-only attribute: %w", err)
+	}
+	return false, keepAppendOnly, nil
+}
+
+// setAppendOnly sets or clears only the append-only attribute of a file
+func setAppendOnly(ctx context.Context, client ssh.FileOps, remotePath string, appendOnly bool) error {
+	return client.SetFileAttributes(ctx, remotePath, &ssh.FileAttributesUpdate{
+		AppendOnly: &appendOnly,
+	})
 }
 
-func (r *FileResource) Configure(_ context.Context, req resource.ConfigureRequest, _ *resource.ConfigureResponse) {
-	if req.ProviderData == nil {
+// validateOwnership checks that a configured user or group is a name or a numeric ID
+func validateOwnership(value types.String, attributePath path.Path, diags *diag.Diagnostics) {
+	if value.IsNull() || value.IsUnknown() {
 		return
 	}
+	if err := ssh.ValidateOwnerToken(value.ValueString()); err != nil {
+		diags.AddAttributeError(
+			attributePath,
+			"Invalid ownership",
+			fmt.Sprintf("Could not use the user or group: %s", err),
+		)
+	}
 }
 
-func (r *FileResource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel) (*ssh.SSHClient, error) {
-	port := int(sshBlock.Port.ValueInt64())
-	if port == 0 {
-		port = 22
+// commandHookAttributes returns the schema attributes of the commands run before and after a change of the
+// file or directory described by kind
+func commandHookAttributes(kind string) map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"pre_command": schema.StringAttribute{
+			Description: fmt.Sprintf("A shell command run on the remote server right before the %s is created or updated, e.g. to validate a "+
+				"configuration. If it fails, the %s is not changed unless pre_command_on_failure is 'warn'.", kind, kind),
+			Optional: true,
+		},
+		"pre_command_on_failure": schema.StringAttribute{
+			Description: "What happens if pre_command fails: 'fail' stops the apply, 'warn' reports a warning and continues. Defaults to 'fail'.",
+			Optional:    true,
+		},
+		"post_command": schema.StringAttribute{
+			Description: fmt.Sprintf("A shell command run on the remote server after the %s was changed, e.g. to reload a service.", kind),
+			Optional:    true,
+		},
+		"command_environment": schema.MapAttribute{
+			Description: "Environment variables set for the commands configured on this resource, such as pre_command and post_command. " +
+				"They are passed by prefixing the commands with env, so they don't depend on AcceptEnv in the server's sshd_config.",
+			ElementType: types.StringType,
+			Optional:    true,
+		},
+		"command_timeout": schema.StringAttribute{
+			Description: "The maximum duration of each command configured on this resource (e.g., '5m'). A command that runs longer " +
+				"is sent SIGTERM, then SIGKILL, and fails with the output it produced so far. Defaults to the operation_timeout of the connection.",
+			Optional: true,
+		},
+		"command_pty": schema.BoolAttribute{
+			Description: "If true, the commands configured on this resource run in a pseudo terminal, for commands that refuse to run " +
+				"without one. The terminal merges their error output into the standard output.",
+			Optional: true,
+		},
+		"command_shell": schema.StringAttribute{
+			Description: "The absolute path of the shell the commands configured on this resource run in, e.g. '/bin/bash'. Each command " +
+				"is passed to it with -c. Defaults to the login shell of the SSH user.",
+			Optional: true,
+		},
+		"post_command_on_failure": schema.StringAttribute{
+			Description: "What happens if post_command fails: 'fail' reports an error, 'warn' reports a warning. " +
+				"The change itself is kept in both cases. Defaults to 'fail'.",
+			Optional: true,
+		},
+	}
+}
+
+// runCommandHook runs a pre_command or post_command if it's set. A failure is reported as an error or as a
+// warning according to onFailure; false is returned if it was an error.
+func runCommandHook(ctx context.Context, client ssh.FileOps, attribute string, command types.String, onFailure types.String, diags *diag.Diagnostics) bool {
+	if command.IsNull() || command.ValueString() == "" {
+		return true
 	}
 
-	config := ssh.SSHConfig{
-		Host:       sshBlock.Host.ValueString(),
-		Port:       port,
-		Username:   sshBlock.Username.ValueString(),
-		Password:   sshBlock.Password.ValueString(),
-		PrivateKey: sshBlock.PrivateKey.ValueString(),
+	// The error includes the error output of the command
+	if _, err := client.RunCommand(ctx, command.ValueString()); err != nil {
+		if onFailure.ValueString() == commandFailureWarn {
+			diags.AddAttributeWarning(
+				path.Root(attribute),
+				fmt.Sprintf("Error running %s", attribute),
+				fmt.Sprintf("The command failed, continuing as %s_on_failure is %q: %s", attribute, commandFailureWarn, err),
+			)
+			return true
+		}
+		diags.AddAttributeError(
+			path.Root(attribute),
+			fmt.Sprintf("Error running %s", attribute),
+			fmt.Sprintf("Could not run %s: %s", attribute, err),
+		)
+		return false
 	}
+	return true
+}
 
-	client, err := r.pool.GetClient(ctx, config)
+// onlyOnOSAttributes returns the schema attributes that restrict the file or directory described by kind to
+// hosts with certain operating systems
+func onlyOnOSAttributes(kind string) map[string]schema.Attribute {
+	return map[string]schema.Attribute{
+		"only_on_os": schema.ListAttribute{
+			Description: fmt.Sprintf("The operating systems the %s is managed on, as reported by 'uname -s' in lower case (e.g., ['linux']). "+
+				"On hosts with another operating system the resource does nothing and skipped is true. By default, the %s is managed on all hosts.", kind, kind),
+			ElementType: types.StringType,
+			Optional:    true,
+			PlanModifiers: []planmodifier.List{
+				requiresReplaceIfSkipped(),
+			},
+		},
+		"skipped": schema.BoolAttribute{
+			Description: fmt.Sprintf("Whether the %s is not managed because the operating system of the host is not in only_on_os.", kind),
+			Computed:    true,
+			PlanModifiers: []planmodifier.Bool{
+				skippedPlanModifier{},
+			},
+		},
+	}
+}
+
+// skippedOnPlatform reports whether a resource is skipped because the operating system of the host isn't in
+// onlyOnOS. An unset onlyOnOS includes all operating systems. false is returned as ok if onlyOnOS can't be read
+// or the operating system can't be detected.
+func skippedOnPlatform(ctx context.Context, client ssh.FileOps, onlyOnOS types.List, diags *diag.Diagnostics) (skipped bool, ok bool) {
+	if onlyOnOS.IsNull() {
+		return false, true
+	}
+
+	var platforms []string
+	if d := onlyOnOS.ElementsAs(ctx, &platforms, false); d.HasError() {
+		diags.Append(d...)
+		return false, false
+	}
+	platform, err := client.LookupPlatform(ctx)
 	if err != nil {
-		return nil, err
+		diags.AddAttributeError(
+			path.Root("only_on_os"),
+			"Error detecting operating system",
+			fmt.Sprintf("Could not detect the operating system of the host for only_on_os: %s", err),
+		)
+		return false, false
+	}
+	return !slices.ContainsFunc(platforms, func(p string) bool { return strings.EqualFold(p, platform) }), true
+}
+
+// warnNoLongerManaged warns that a file or directory that was managed is left in place, as only_on_os no
+// longer includes the operating system of the host
+func warnNoLongerManaged(kind string, remotePath types.String, wasSkipped types.Bool, diags *diag.Diagnostics) {
+	if wasSkipped.ValueBool() {
+		return
+	}
+	diags.AddAttributeWarning(
+		path.Root("only_on_os"),
+		fmt.Sprintf("The %s is no longer managed", kind),
+		fmt.Sprintf("only_on_os doesn't include the operating system of the host anymore. %s was left in place and is not "+
+			"deleted when the resource is destroyed.", remotePath.ValueString()),
+	)
+}
+
+// validateOnlyOnOS checks that only_on_os lists at least one operating system
+func validateOnlyOnOS(onlyOnOS types.List, diags *diag.Diagnostics) {
+	if onlyOnOS.IsNull() || onlyOnOS.IsUnknown() {
+		return
+	}
+	if len(onlyOnOS.Elements()) == 0 {
+		diags.AddAttributeError(
+			path.Root("only_on_os"),
+			"Invalid only_on_os",
+			"only_on_os must list at least one operating system, e.g. [\"linux\"]. Remove it to manage the resource on all hosts.",
+		)
 	}
+}
+
+// requiresReplaceIfSkipped forces replacement on a change of only_on_os if the resource was skipped, so it's
+// created if the operating system of the host is included now
+func requiresReplaceIfSkipped() planmodifier.List {
+	return listplanmodifier.RequiresReplaceIf(
+		func(ctx context.Context, req planmodifier.ListRequest, resp *listplanmodifier.RequiresReplaceIfFuncResponse) {
+			var skipped types.Bool
+			resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("skipped"), &skipped)...)
+			resp.RequiresReplace = skipped.ValueBool()
+		},
+		"Changing only_on_os of a skipped resource requires replacement.",
+		"Changing `only_on_os` of a skipped resource requires replacement.",
+	)
+}
 
-	// Release the client when the context is done
-	go func() {
-		<-ctx.Done()
-		r.pool.ReleaseClient(config)
-	}()
+// skippedPlanModifier keeps skipped from the state unless only_on_os changes, which may change whether the
+// resource is skipped
+type skippedPlanModifier struct{}
+
+func (m skippedPlanModifier) Description(_ context.Context) string {
+	return "Keeps skipped from the state unless only_on_os changes."
+}
+
+func (m skippedPlanModifier) MarkdownDescription(ctx context.Context) string {
+	return m.Description(ctx)
+}
+
+func (m skippedPlanModifier) PlanModifyBool(ctx context.Context, req planmodifier.BoolRequest, resp *planmodifier.BoolResponse) {
+	if req.StateValue.IsNull() || !req.PlanValue.IsUnknown() {
+		return
+	}
+
+	var planOnlyOnOS, stateOnlyOnOS types.List
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("only_on_os"), &planOnlyOnOS)...)
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("only_on_os"), &stateOnlyOnOS)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if planOnlyOnOS.Equal(stateOnlyOnOS) {
+		resp.PlanValue = req.StateValue
+	}
+}
+
+// verifyFile runs verify_command after the content was written. If it fails, the previous content is restored
+// from the backup if the file existed, or the file is deleted if it didn't, and an error is reported. rolledBack
+// is whether the file was restored, ok is false if the command failed.
+func verifyFile(ctx context.Context, commandCtx context.Context, client ssh.FileOps, plan FileResourceModel, existed bool, backedUp bool, diags *diag.Diagnostics) (rolledBack bool, ok bool) {
+	if plan.VerifyCommand.IsNull() || plan.VerifyCommand.ValueString() == "" {
+		return false, true
+	}
+
+	// The error includes the error output of the command
+	_, verifyErr := client.RunCommand(commandCtx, plan.VerifyCommand.ValueString())
+	if verifyErr == nil {
+		return false, true
+	}
+
+	var err error
+	restored := "The file was deleted again"
+	switch {
+	case existed && backedUp:
+		err = client.CopyFile(ctx, plan.BackupPath.ValueString(), plan.Path.ValueString())
+		restored = fmt.Sprintf("The previous content was restored from %s", plan.BackupPath.ValueString())
+	case existed:
+		err = errors.New("no backup was made")
+	default:
+		err = client.DeleteFile(ctx, plan.Path.ValueString())
+	}
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("verify_command"),
+			"Error rolling back file",
+			fmt.Sprintf("verify_command failed and the previous state of %s could not be restored, so it keeps the new content: %s\n\n"+
+				"verify_command failed: %s", plan.Path.ValueString(), err, verifyErr),
+		)
+		return false, false
+	}
+
+	diags.AddAttributeError(
+		path.Root("verify_command"),
+		"Error verifying file",
+		fmt.Sprintf("%s after verify_command failed: %s", restored, verifyErr),
+	)
+	return true, false
+}
+
+// backupFile copies the file at the planned path to its backup path if backup is enabled. It returns the path
+// of the backup, or previous if no backup was made.
+func backupFile(ctx context.Context, client ssh.FileOps, plan FileResourceModel, previous types.String) (types.String, error) {
+	if !plan.Backup.ValueBool() {
+		return previous, nil
+	}
+
+	suffix := defaultBackupSuffix
+	if !plan.BackupSuffix.IsNull() {
+		suffix = plan.BackupSuffix.ValueString()
+	}
+
+	backupPath := plan.Path.ValueString() + suffix
+	if err := client.CopyFile(ctx, plan.Path.ValueString(), backupPath); err != nil {
+		return previous, err
+	}
+	return types.StringValue(backupPath), nil
+}
+
+// checkPathType reports an error on path if the path exists but is a directory where a file is managed, or
+// the other way round, before anything is changed on the server. false is returned in that case.
+func checkPathType(ctx context.Context, client ssh.FileOps, remotePath string, directory bool, diags *diag.Diagnostics) bool {
+	err := client.CheckPathType(ctx, remotePath, directory)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ssh.ErrIsDirectory):
+		diags.AddAttributeError(
+			path.Root("path"),
+			"Path is a directory",
+			fmt.Sprintf("%s exists and is a directory, cannot manage it as a file. Remove the directory or choose another path.", remotePath),
+		)
+	case errors.Is(err, ssh.ErrNotDirectory):
+		diags.AddAttributeError(
+			path.Root("path"),
+			"Path is not a directory",
+			fmt.Sprintf("%s exists and is not a directory, cannot manage it as a directory. Remove the file or choose another path.", remotePath),
+		)
+	default:
+		diags.AddError(
+			"Error checking path",
+			fmt.Sprintf("Could not check the type of %s: %s", remotePath, err),
+		)
+	}
+	return false
+}
+
+// commandContext returns a context in which commands run with the configured command_environment, command_pty,
+// command_timeout and command_shell. It is only used for the commands configured by the user, such as
+// pre_command, post_command and validate_command; the commands the provider runs itself use the plain context.
+func commandContext(ctx context.Context, env types.Map, pty types.Bool, timeout types.String, shell types.String, diags *diag.Diagnostics) context.Context {
+	if pty.ValueBool() {
+		ctx = ssh.WithPTY(ctx)
+	}
+	if !shell.IsNull() && !shell.IsUnknown() {
+		ctx = ssh.WithShell(ctx, shell.ValueString())
+	}
+	if !timeout.IsNull() && !timeout.IsUnknown() {
+		// The value was checked by ValidateConfig
+		if duration, err := time.ParseDuration(timeout.ValueString()); err == nil {
+			ctx = ssh.WithCommandTimeout(ctx, duration)
+		}
+	}
+	if env.IsNull() || env.IsUnknown() {
+		return ctx
+	}
+
+	vars := make(map[string]string, len(env.Elements()))
+	diags.Append(env.ElementsAs(ctx, &vars, false)...)
+	return ssh.WithEnvironment(ctx, vars)
+}
+
+// validateCommandTimeout checks that command_timeout is a positive duration
+func validateCommandTimeout(timeout types.String, diags *diag.Diagnostics) {
+	if timeout.IsNull() || timeout.IsUnknown() {
+		return
+	}
+	if duration, err := time.ParseDuration(timeout.ValueString()); err != nil || duration <= 0 {
+		diags.AddAttributeError(
+			path.Root("command_timeout"),
+			"Invalid command timeout",
+			fmt.Sprintf("Expected a positive duration such as \"30s\" or \"5m\", got %q.", timeout.ValueString()),
+		)
+	}
+}
+
+// validateCommandShell checks that command_shell is an absolute path
+func validateCommandShell(shell types.String, diags *diag.Diagnostics) {
+	if shell.IsNull() || shell.IsUnknown() {
+		return
+	}
+	if err := ssh.ValidateShell(shell.ValueString()); err != nil {
+		diags.AddAttributeError(
+			path.Root("command_shell"),
+			"Invalid command shell",
+			fmt.Sprintf("Could not use the shell: %s", err),
+		)
+	}
+}
+
+// validateCommandEnvironment checks that the names of command_environment can be set in a shell
+func validateCommandEnvironment(env types.Map, diags *diag.Diagnostics) {
+	if env.IsNull() || env.IsUnknown() {
+		return
+	}
+	for name := range env.Elements() {
+		if err := ssh.ValidateEnvName(name); err != nil {
+			diags.AddAttributeError(
+				path.Root("command_environment").AtMapKey(name),
+				"Invalid environment variable",
+				fmt.Sprintf("Could not use the environment variable: %s", err),
+			)
+		}
+	}
+}
+
+// validateCommandFailure checks the value of pre_command_on_failure or post_command_on_failure
+func validateCommandFailure(value types.String, attribute string, diags *diag.Diagnostics) {
+	if value.IsNull() || value.IsUnknown() {
+		return
+	}
+	switch value.ValueString() {
+	case commandFailureFail, commandFailureWarn:
+	default:
+		diags.AddAttributeError(
+			path.Root(attribute),
+			fmt.Sprintf("Invalid %s", attribute),
+			fmt.Sprintf("Expected %q or %q, got %q.", commandFailureFail, commandFailureWarn, value.ValueString()),
+		)
+	}
+}
+
+// validateFileContent checks the content against content_validation and validate_command before it's
+// written. It returns false if the content is invalid.
+func validateFileContent(ctx context.Context, client ssh.FileOps, plan FileResourceModel, diags *diag.Diagnostics) bool {
+	_, contentPath := configuredContent(plan)
+
+	if !plan.ContentValidation.IsNull() {
+		pattern, err := regexp.Compile(plan.ContentValidation.ValueString())
+		if err != nil {
+			diags.AddAttributeError(
+				path.Root("content_validation"),
+				"Invalid content validation",
+				fmt.Sprintf("Could not parse the regular expression: %s", err),
+			)
+			return false
+		}
+		if !pattern.MatchString(fileContent(plan)) {
+			diags.AddAttributeError(
+				contentPath,
+				"Invalid content",
+				fmt.Sprintf("The content doesn't match content_validation %q.", plan.ContentValidation.ValueString()),
+			)
+			return false
+		}
+	}
+
+	if !plan.ValidateCommand.IsNull() {
+		if err := client.ValidateContent(ctx, fileContent(plan), plan.ValidateCommand.ValueString()); err != nil {
+			if errors.Is(err, ssh.ErrContentInvalid) {
+				diags.AddAttributeError(
+					contentPath,
+					"Invalid content",
+					fmt.Sprintf("The content was rejected by validate_command, the file was not changed: %s", err),
+				)
+			} else {
+				diags.AddError(
+					"Error validating content",
+					fmt.Sprintf("Could not validate content: %s", err),
+				)
+			}
+			return false
+		}
+	}
+
+	return true
+}
+
+// configuredContent returns the value and path of whichever of content, sensitive_content and content_wo is set
+func configuredContent(model FileResourceModel) (types.String, path.Path) {
+	if !model.SensitiveContent.IsNull() {
+		return model.SensitiveContent, path.Root("sensitive_content")
+	}
+	if !model.ContentWO.IsNull() {
+		return model.ContentWO, path.Root("content_wo")
+	}
+	return model.Content, path.Root("content")
+}
+
+// fileContent returns the content to write, which is set in one of content, sensitive_content and content_wo
+func fileContent(model FileResourceModel) string {
+	content, _ := configuredContent(model)
+	return content.ValueString()
+}
+
+// writeOnlyContent reports whether the file content is set through content_wo, which is never part of the
+// plan or state
+func writeOnlyContent(model FileResourceModel) bool {
+	return model.Content.IsNull() && model.SensitiveContent.IsNull()
+}
+
+// contentChecksum returns the checksum recorded for drift detection. It's null for write-only content,
+// since even a checksum would reveal information about it.
+func contentChecksum(model FileResourceModel) types.String {
+	if writeOnlyContent(model) {
+		return types.StringNull()
+	}
+	return basetypes.NewStringValue(ssh.ContentChecksum(fileContent(model)))
+}
+
+// resolveSymlinks replaces the path of model with the final target of a symbolic link at path if
+// resolve_symlinks is set
+func resolveSymlinks(ctx context.Context, client ssh.FileOps, model *FileResourceModel, diags *diag.Diagnostics) bool {
+	if !model.ResolveSymlinks.ValueBool() {
+		return true
+	}
+
+	target, err := ssh.ResolveSymlinks(ctx, client, model.Path.ValueString())
+	if err != nil {
+		diags.AddError(
+			"Error resolving symbolic link",
+			fmt.Sprintf("Could not resolve the symbolic links at %s: %s", model.Path.ValueString(), err),
+		)
+		return false
+	}
+	model.Path = types.StringValue(target)
+	return true
+}
+
+// removeSymlink deletes a symbolic link at the path of a file that doesn't resolve symbolic links, so the
+// file is written in its place instead of through it, and reports whether there was one. The target of the
+// link is left untouched. Pseudo files are always written through links, as /sys consists of them.
+func removeSymlink(ctx context.Context, client ssh.FileOps, plan FileResourceModel, diags *diag.Diagnostics) (removed bool, ok bool) {
+	if plan.ResolveSymlinks.ValueBool() || plan.PseudoFile.ValueBool() {
+		return false, true
+	}
+
+	_, isSymlink, err := client.ReadSymlink(ctx, plan.Path.ValueString())
+	if err == nil && isSymlink {
+		err = client.DeleteFile(ctx, plan.Path.ValueString())
+	}
+	if err != nil {
+		diags.AddError(
+			"Error replacing symbolic link",
+			fmt.Sprintf("Could not replace the symbolic link at %s: %s", plan.Path.ValueString(), err),
+		)
+		return false, false
+	}
+	return isSymlink, true
+}
+
+// validatePseudoFile rejects the attributes that don't apply to a pseudo file, which is only written in place
+func validatePseudoFile(config FileResourceModel, diags *diag.Diagnostics) {
+	conflicting := map[string]attr.Value{
+		"permissions":          config.Permissions,
+		"owner":                config.Owner,
+		"group":                config.Group,
+		"create_parents":       config.CreateParents,
+		"parent_permissions":   config.ParentPermissions,
+		"parent_owner":         config.ParentOwner,
+		"parent_group":         config.ParentGroup,
+		"path_change_strategy": config.PathChangeStrategy,
+		"immutable":            config.Immutable,
+		"append_only":          config.AppendOnly,
+		"append_only_strategy": config.AppendOnlyStrategy,
+		"no_dump":              config.NoDump,
+		"synchronous":          config.Synchronous,
+		"no_atime":             config.NoAtime,
+		"compressed":           config.Compressed,
+		"no_cow":               config.NoCoW,
+		"undeletable":          config.Undeletable,
+		"data_journaling":      config.DataJournaling,
+		"no_tail_merge":        config.NoTailMerge,
+		"capabilities":         config.Capabilities,
+		"create_only":          config.CreateOnly,
+		"preserve_existing":    config.PreserveExisting,
+		"backup":               config.Backup,
+		"backup_suffix":        config.BackupSuffix,
+		"verify_command":       config.VerifyCommand,
+	}
+	for _, name := range slices.Sorted(maps.Keys(conflicting)) {
+		if !conflicting[name].IsNull() {
+			diags.AddAttributeError(
+				path.Root(name),
+				"Conflicting attributes",
+				fmt.Sprintf("%s can't be set if pseudo_file is true, because a pseudo file is only written in place.", name),
+			)
+		}
+	}
+}
+
+// writePseudoFile writes the content to a pseudo file if its current value differs and reports whether it
+// was written. The value of a missing pseudo file, e.g. of a kernel module that isn't loaded, can't be set.
+func writePseudoFile(ctx context.Context, client ssh.FileOps, plan FileResourceModel, diags *diag.Diagnostics) (bool, bool) {
+	current, err := client.ReadFileLimited(ctx, plan.Path.ValueString(), maxReadSize(plan))
+	if err != nil {
+		diags.AddError(
+			"Error reading pseudo file",
+			ssh.FileReadErrorDetail(err),
+		)
+		return false, false
+	}
+	if pseudoFileValue(current) == pseudoFileValue(fileContent(plan)) {
+		return false, true
+	}
+
+	if err := client.WritePseudoFile(ctx, plan.Path.ValueString(), fileContent(plan)); err != nil {
+		diags.AddError(
+			"Error writing pseudo file",
+			fmt.Sprintf("Could not write %s: %s", plan.Path.ValueString(), err),
+		)
+		return false, false
+	}
+	return true, true
+}
+
+// pseudoFileValue normalizes the value of a pseudo file for comparison. The kernel reports values with
+// its own whitespace, e.g. "32768\t60999\n" after "32768 60999" was written to ip_local_port_range.
+func pseudoFileValue(content string) string {
+	return strings.Join(strings.Fields(content), " ")
+}
+
+// contentUnchanged reports whether the remote file already has the content to write. Only the checksums are
+// compared, so the remote file is not read into memory.
+func contentUnchanged(ctx context.Context, client ssh.FileOps, plan FileResourceModel) (bool, error) {
+	checksum, err := client.FileChecksum(ctx, plan.Path.ValueString())
+	if err != nil {
+		return false, err
+	}
+	return checksum == ssh.ContentChecksum(fileContent(plan)), nil
+}
+
+// applyFileMetadata applies the ownership, capabilities and attributes of the plan and, if mode is set, the mode
+// to the file with a single batch of commands. write is the plan with the preserved ownership of an overwritten
+// file. It reports whether the metadata was applied; unsupported attributes only fail if they aren't ignored.
+func applyFileMetadata(ctx context.Context, client ssh.FileOps, plan FileResourceModel, write FileResourceModel, mode *os.FileMode, diags *diag.Diagnostics) bool {
+	metadata := &ssh.FileMetadata{Mode: mode}
+	if !write.Owner.IsNull() || !write.Group.IsNull() {
+		metadata.Ownership = &ssh.FileOwnership{
+			User:  write.Owner.ValueString(),
+			Group: write.Group.ValueString(),
+		}
+	}
+	if !plan.Capabilities.IsNull() {
+		metadata.Capabilities = plan.Capabilities.ValueStringPointer()
+	}
+	if !plan.Immutable.IsNull() || !plan.AppendOnly.IsNull() || !plan.NoDump.IsNull() ||
+		!plan.Synchronous.IsNull() || !plan.NoAtime.IsNull() || !plan.Compressed.IsNull() ||
+		!plan.NoCoW.IsNull() || !plan.Undeletable.IsNull() ||
+		!plan.DataJournaling.IsNull() || !plan.NoTailMerge.IsNull() {
+		metadata.Attributes = &ssh.FileAttributesUpdate{
+			Immutable:      plan.Immutable.ValueBoolPointer(),
+			AppendOnly:     plan.AppendOnly.ValueBoolPointer(),
+			NoDump:         plan.NoDump.ValueBoolPointer(),
+			Synchronous:    plan.Synchronous.ValueBoolPointer(),
+			NoAtime:        plan.NoAtime.ValueBoolPointer(),
+			Compressed:     plan.Compressed.ValueBoolPointer(),
+			NoCoW:          plan.NoCoW.ValueBoolPointer(),
+			Undeletable:    plan.Undeletable.ValueBoolPointer(),
+			DataJournaling: plan.DataJournaling.ValueBoolPointer(),
+			NoTailMerge:    plan.NoTailMerge.ValueBoolPointer(),
+		}
+	}
+
+	err := client.ApplyFileMetadata(ctx, plan.Path.ValueString(), metadata)
+	if err != nil {
+		if warnUnsupportedAttributes(err, "file", plan.IgnoreUnsupportedAttributes, diags) {
+			return true
+		}
+		diags.AddError(
+			"Error setting file metadata",
+			fmt.Sprintf("Could not set the ownership, permissions, capabilities or attributes of the file: %s", err),
+		)
+		return false
+	}
+	return true
+}
+
+// warnUnsupportedAttributes reports whether err only failed on attributes that the filesystem doesn't support
+// and ignore_unsupported_attributes is set. In that case it adds a warning instead of an error, so the caller
+// carries on. kind is "file" or "directory".
+func warnUnsupportedAttributes(err error, kind string, ignore types.Bool, diags *diag.Diagnostics) bool {
+	var unsupportedErr *ssh.UnsupportedAttributesError
+	if !errors.As(err, &unsupportedErr) || !ignore.ValueBool() {
+		return false
+	}
+	diags.AddWarning(
+		fmt.Sprintf("Unsupported %s attributes", kind),
+		fmt.Sprintf("Some %s attributes were not set: %s", kind, err),
+	)
+	return true
+}
+
+// preserveExisting returns the plan with the permissions, owner and group that aren't configured taken
+// from the existing file if preserve_existing is set, so that overwriting the file doesn't reset them
+func preserveExisting(ctx context.Context, client ssh.FileOps, plan FileResourceModel) (FileResourceModel, error) {
+	if !plan.PreserveExisting.ValueBool() || (!plan.Permissions.IsNull() && !plan.Owner.IsNull() && !plan.Group.IsNull()) {
+		return plan, nil
+	}
+
+	exists, err := client.Exists(ctx, plan.Path.ValueString())
+	if err != nil || !exists {
+		return plan, err
+	}
+
+	if plan.Permissions.IsNull() {
+		mode, err := client.GetFileMode(ctx, plan.Path.ValueString())
+		if err != nil {
+			return plan, err
+		}
+		plan.Permissions = types.StringValue(fmt.Sprintf("%04o", mode))
+	}
+
+	if plan.Owner.IsNull() || plan.Group.IsNull() {
+		// Numeric IDs restore the ownership exactly, even if the user or group has no name
+		ownership, err := client.GetFileOwnershipAs(ctx, plan.Path.ValueString(), &ssh.FileOwnership{User: "0", Group: "0"})
+		if err != nil {
+			return plan, err
+		}
+		if plan.Owner.IsNull() {
+			plan.Owner = types.StringValue(ownership.User)
+		}
+		if plan.Group.IsNull() {
+			plan.Group = types.StringValue(ownership.Group)
+		}
+	}
+	return plan, nil
+}
+
+// maxReadSize returns the size limit for reading the remote file
+func maxReadSize(model FileResourceModel) int64 {
+	if model.MaxReadSize.IsNull() {
+		return ssh.DefaultMaxReadSize
+	}
+	return model.MaxReadSize.ValueInt64()
+}
+
+// createFileOptions derives the parent directory handling from the plan
+func createFileOptions(plan FileResourceModel) ssh.CreateFileOptions {
+	opts := ssh.DefaultCreateFileOptions()
+	if !plan.CreateParents.IsNull() {
+		opts.CreateParents = plan.CreateParents.ValueBool()
+	}
+	if !plan.ParentPermissions.IsNull() {
+		opts.ParentPermissions = os.FileMode(ssh.ParsePermissions(plan.ParentPermissions.ValueString()))
+	}
+
+	// Parent directories created for the file belong to the file's owner unless configured otherwise
+	opts.ParentOwner = plan.Owner.ValueString()
+	if !plan.ParentOwner.IsNull() {
+		opts.ParentOwner = plan.ParentOwner.ValueString()
+	}
+	opts.ParentGroup = plan.Group.ValueString()
+	if !plan.ParentGroup.IsNull() {
+		opts.ParentGroup = plan.ParentGroup.ValueString()
+	}
+
+	if plan.UseSudo.ValueBool() {
+		// Installing through sudo sets ownership right away, so the file is never owned by the SSH user
+		opts.Owner = plan.Owner.ValueString()
+		opts.Group = plan.Group.ValueString()
+	}
+	return opts
+}
+
+func (r *FileResource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String, dedicated types.Bool) (ssh.FileOps, func(), error) {
+	config, err := r.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return client, nil
+	return r.pool.GetFileOps(ctx, config, dedicated.ValueBool())
 }
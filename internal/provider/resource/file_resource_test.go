@@ -0,0 +1,148 @@
+package resource
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh/sshtest"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	. "github.com/onsi/gomega"
+)
+
+func TestFileResourceLifecycle(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	fs := sshtest.NewFileSystem()
+	r := newTestFileResource(t, fs)
+	resourceSchema := fileResourceSchema(r)
+
+	t.Log("Create the file with its parent directories")
+	plan := testFileResourceModel("port=80\n")
+	createResp := &resource.CreateResponse{State: emptyState(resourceSchema)}
+	r.Create(ctx, resource.CreateRequest{Config: configOf(resourceSchema, plan), Plan: planOf(resourceSchema, plan)}, createResp)
+	Expect(createResp.Diagnostics).To(BeEmpty())
+	entry, ok := fs.Get("/etc/app/config")
+	Expect(ok).To(BeTrue())
+	Expect(entry.Content).To(Equal("port=80\n"))
+	Expect(entry.Mode).To(Equal(os.FileMode(0640)))
+
+	var state FileResourceModel
+	Expect(createResp.State.Get(ctx, &state)).To(BeEmpty())
+	Expect(state.ID.ValueString()).To(Equal("/etc/app/config"))
+	Expect(state.Checksum.ValueString()).To(Equal(ssh.ContentChecksum("port=80\n")))
+
+	t.Log("Read an unchanged file")
+	readResp := &resource.ReadResponse{State: createResp.State}
+	r.Read(ctx, resource.ReadRequest{State: createResp.State}, readResp)
+	Expect(readResp.Diagnostics).To(BeEmpty())
+	Expect(readResp.State.Get(ctx, &state)).To(BeEmpty())
+	Expect(state.Content.ValueString()).To(Equal("port=80\n"))
+	Expect(state.Drifted.ValueBool()).To(BeFalse())
+
+	t.Log("Read a file changed outside of Terraform")
+	fs.Put("/etc/app/config", sshtest.Entry{Content: "port=8080\n", Mode: 0600, User: "0", Group: "0"})
+	readResp = &resource.ReadResponse{State: createResp.State}
+	r.Read(ctx, resource.ReadRequest{State: createResp.State}, readResp)
+	Expect(readResp.Diagnostics).To(BeEmpty())
+	Expect(readResp.State.Get(ctx, &state)).To(BeEmpty())
+	Expect(state.Content.ValueString()).To(Equal("port=8080\n"))
+	Expect(state.Permissions.ValueString()).To(Equal("0600"))
+	Expect(state.Drifted.ValueBool()).To(BeTrue())
+
+	t.Log("Update the file back to the configured content and permissions")
+	plan = testFileResourceModel("port=443\n")
+	plan.ID = state.ID
+	updateResp := &resource.UpdateResponse{State: readResp.State}
+	r.Update(ctx, resource.UpdateRequest{Config: configOf(resourceSchema, plan), Plan: planOf(resourceSchema, plan), State: readResp.State}, updateResp)
+	Expect(updateResp.Diagnostics).To(BeEmpty())
+	entry, _ = fs.Get("/etc/app/config")
+	Expect(entry.Content).To(Equal("port=443\n"))
+	Expect(entry.Mode).To(Equal(os.FileMode(0640)))
+	Expect(updateResp.State.Get(ctx, &state)).To(BeEmpty())
+	Expect(state.Checksum.ValueString()).To(Equal(ssh.ContentChecksum("port=443\n")))
+	Expect(state.Drifted.ValueBool()).To(BeFalse())
+
+	t.Log("Delete the file")
+	deleteResp := &resource.DeleteResponse{State: updateResp.State}
+	r.Delete(ctx, resource.DeleteRequest{State: updateResp.State}, deleteResp)
+	Expect(deleteResp.Diagnostics).To(BeEmpty())
+	Expect(fs.Exists(ctx, "/etc/app/config")).To(BeFalse())
+
+	t.Log("Remove a deleted file from the state")
+	readResp = &resource.ReadResponse{State: updateResp.State}
+	r.Read(ctx, resource.ReadRequest{State: updateResp.State}, readResp)
+	Expect(readResp.Diagnostics).To(BeEmpty())
+	Expect(readResp.State.Raw.IsNull()).To(BeTrue())
+}
+
+// newTestFileResource returns a FileResource whose connection "test" operates on fs
+func newTestFileResource(t *testing.T, fs *sshtest.FileSystem) *FileResource {
+	pool := ssh.NewSSHPool(ssh.PoolConfig{
+		DisableCleanup: true,
+		FileOps: func(ssh.SSHConfig) ssh.FileOps {
+			return fs
+		},
+	})
+	t.Cleanup(pool.Close)
+
+	return &FileResource{
+		pool:        pool,
+		connections: ssh.Connections{"test": ssh.SSHBlockModel{}},
+	}
+}
+
+func fileResourceSchema(r *FileResource) schema.Schema {
+	resp := &resource.SchemaResponse{}
+	r.Schema(context.Background(), resource.SchemaRequest{}, resp)
+	Expect(resp.Diagnostics).To(BeEmpty())
+	return resp.Schema
+}
+
+// testFileResourceModel is the plan of a file with the given content, with the computed attributes unknown
+func testFileResourceModel(content string) FileResourceModel {
+	return FileResourceModel{
+		Connection:         types.StringValue("test"),
+		Path:               types.StringValue("/etc/app/config"),
+		Content:            types.StringValue(content),
+		Permissions:        types.StringValue("0640"),
+		Triggers:           types.MapNull(types.StringType),
+		CommandEnvironment: types.MapNull(types.StringType),
+		OnlyOnOS:           types.ListNull(types.StringType),
+		BackupPath:         types.StringUnknown(),
+		RolledBack:         types.BoolUnknown(),
+		Skipped:            types.BoolUnknown(),
+		Checksum:           types.StringUnknown(),
+		Drifted:            types.BoolUnknown(),
+		ID:                 types.StringUnknown(),
+	}
+}
+
+func planOf(resourceSchema schema.Schema, model FileResourceModel) tfsdk.Plan {
+	plan := tfsdk.Plan{Schema: resourceSchema, Raw: tftypes.NewValue(resourceSchema.Type().TerraformType(context.Background()), nil)}
+	Expect(plan.Set(context.Background(), &model)).To(BeEmpty())
+	return plan
+}
+
+// configOf is the configuration of a planned model, which has no values for the computed attributes
+func configOf(resourceSchema schema.Schema, model FileResourceModel) tfsdk.Config {
+	model.BackupPath = types.StringNull()
+	model.RolledBack = types.BoolNull()
+	model.Skipped = types.BoolNull()
+	model.Checksum = types.StringNull()
+	model.Drifted = types.BoolNull()
+	model.ID = types.StringNull()
+
+	plan := planOf(resourceSchema, model)
+	return tfsdk.Config{Schema: resourceSchema, Raw: plan.Raw}
+}
+
+func emptyState(resourceSchema schema.Schema) tfsdk.State {
+	return tfsdk.State{Schema: resourceSchema, Raw: tftypes.NewValue(resourceSchema.Type().TerraformType(context.Background()), nil)}
+}
@@ -0,0 +1,421 @@
+package resource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ resource.Resource                     = &FileUploadResource{}
+	_ resource.ResourceWithConfigure        = &FileUploadResource{}
+	_ resource.ResourceWithModifyPlan       = &FileUploadResource{}
+	_ resource.ResourceWithConfigValidators = &FileUploadResource{}
+)
+
+// FileUploadResource defines the resource implementation.
+type FileUploadResource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// FileUploadResourceModel describes the resource data model.
+type FileUploadResourceModel struct {
+	SSH                 *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection          types.String       `tfsdk:"connection"`
+	DedicatedConnection types.Bool         `tfsdk:"dedicated_connection"`
+	Source              types.String       `tfsdk:"source"`
+	Path                types.String       `tfsdk:"path"`
+	Permissions         types.String       `tfsdk:"permissions"`
+	UseSudo             types.Bool         `tfsdk:"use_sudo"`
+	Resumable           types.Bool         `tfsdk:"resumable"`
+	Retries             types.Int64        `tfsdk:"retries"`
+	Checksum            types.String       `tfsdk:"checksum"`
+	ID                  types.String       `tfsdk:"id"`
+}
+
+// NewFileUploadResource creates a new resource implementation.
+func NewFileUploadResource(pool *ssh.SSHPool) resource.Resource {
+	return &FileUploadResource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the resource type name.
+func (r *FileUploadResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_file_upload"
+}
+
+// ConfigValidators returns the validators of the resource configuration.
+func (r *FileUploadResource) ConfigValidators(_ context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the resource.
+func (r *FileUploadResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Uploads a local file to a remote server via SSH, optionally resuming interrupted transfers.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"dedicated_connection": schema.BoolAttribute{
+				Description: "If true, every operation of this resource opens its own SSH connection instead of using the shared connection pool and closes it when the operation finishes.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockSchema(),
+			},
+			"source": schema.StringAttribute{
+				Description: "The path of the local file to upload.",
+				Required:    true,
+			},
+			"path": schema.StringAttribute{
+				Description: "The path of the file on the remote server.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"permissions": schema.StringAttribute{
+				Description: "The file permissions in octal format (e.g., '0644').",
+				Optional:    true,
+			},
+			"use_sudo": schema.BoolAttribute{
+				Description: "If true, the file is installed through sudo.",
+				Optional:    true,
+			},
+			"resumable": schema.BoolAttribute{
+				Description: "If true, an interrupted transfer keeps the uploaded data and continues where it stopped on the next attempt, including attempts of later runs, instead of starting over.",
+				Optional:    true,
+			},
+			"retries": schema.Int64Attribute{
+				Description: "How often a failed transfer is retried before the upload fails. Defaults to 0.",
+				Optional:    true,
+			},
+			"checksum": schema.StringAttribute{
+				Description: "The SHA-256 checksum of the uploaded file.",
+				Computed:    true,
+			},
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+// Create creates the resource and sets the initial Terraform state.
+func (r *FileUploadResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FileUploadResource.Create")
+	defer span.End()
+
+	var plan FileUploadResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	if !uploadFile(ctx, client, &plan, &resp.Diagnostics) {
+		return
+	}
+	plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (r *FileUploadResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FileUploadResource.Read")
+	defer span.End()
+
+	var state FileUploadResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	exists, err := client.Exists(ctx, state.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error checking file existence",
+			fmt.Sprintf("Could not check if file exists: %s", err),
+		)
+		return
+	}
+	if !exists {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	// A changed file shows up as a different checksum, which the next apply uploads again
+	checksum, err := client.FileDigest(ctx, state.Path.ValueString(), "sha256")
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading file checksum",
+			fmt.Sprintf("Could not compute file checksum: %s", err),
+		)
+		return
+	}
+	state.Checksum = basetypes.NewStringValue(checksum)
+
+	if !state.Permissions.IsNull() {
+		mode, err := client.GetFileMode(ctx, state.Path.ValueString())
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error reading file mode",
+				fmt.Sprintf("Could not read file mode: %s", err),
+			)
+			return
+		}
+		// Keep the configured notation (e.g. "644" vs "0644") when the mode matches
+		if os.FileMode(ssh.ParsePermissions(state.Permissions.ValueString())) != mode {
+			state.Permissions = basetypes.NewStringValue(fmt.Sprintf("%04o", mode))
+		}
+	}
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Update updates the resource and sets the updated Terraform state on success.
+func (r *FileUploadResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FileUploadResource.Update")
+	defer span.End()
+
+	var plan, state FileUploadResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	// The file is only uploaded again if its content changed, other changes are applied in place
+	if plan.Checksum.IsUnknown() || !plan.Checksum.Equal(state.Checksum) {
+		if !uploadFile(ctx, client, &plan, &resp.Diagnostics) {
+			return
+		}
+	} else if !plan.Permissions.Equal(state.Permissions) {
+		err := client.SetFileMode(ctx, plan.Path.ValueString(), os.FileMode(ssh.ParsePermissions(plan.Permissions.ValueString())))
+		if err != nil {
+			resp.Diagnostics.AddError(
+				"Error setting file mode",
+				fmt.Sprintf("Could not set file mode: %s", err),
+			)
+			return
+		}
+	}
+	plan.ID = basetypes.NewStringValue(plan.Path.ValueString())
+
+	diags := resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Delete deletes the resource and removes the Terraform state on success.
+func (r *FileUploadResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FileUploadResource.Delete")
+	defer span.End()
+
+	var state FileUploadResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	exists, err := client.Exists(ctx, state.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error checking file existence",
+			fmt.Sprintf("Could not check if file exists: %s", err),
+		)
+		return
+	}
+	if !exists {
+		return
+	}
+
+	if err := client.DeleteFile(ctx, state.Path.ValueString()); err != nil {
+		resp.Diagnostics.AddError(
+			"Error deleting file",
+			fmt.Sprintf("Could not delete file: %s", err),
+		)
+	}
+}
+
+// ModifyPlan plans the checksum of the local file, so changing it uploads the file again.
+func (r *FileUploadResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var plan FileUploadResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() || plan.Source.IsUnknown() {
+		return
+	}
+
+	checksum, err := localFileChecksum(plan.Source.ValueString())
+	// A file created by another resource during the apply doesn't exist yet, its checksum stays unknown
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("source"),
+			"Error reading source file",
+			fmt.Sprintf("Could not compute the checksum of the source file: %s", err),
+		)
+		return
+	}
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("checksum"), checksum)...)
+}
+
+func (r *FileUploadResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	r.connections = providerData.Connections
+}
+
+// uploadFile uploads the source file to the path of the plan and sets the checksum of the uploaded file
+func uploadFile(ctx context.Context, client *ssh.SSHClient, plan *FileUploadResourceModel, diags *diag.Diagnostics) bool {
+	checksum, err := localFileChecksum(plan.Source.ValueString())
+	if err != nil {
+		diags.AddAttributeError(
+			path.Root("source"),
+			"Error reading source file",
+			fmt.Sprintf("Could not compute the checksum of the source file: %s", err),
+		)
+		return false
+	}
+
+	err = client.UploadFile(ctx, plan.Source.ValueString(), plan.Path.ValueString(), os.FileMode(ssh.ParsePermissions(plan.Permissions.ValueString())), ssh.UploadOptions{
+		Resumable: plan.Resumable.ValueBool(),
+		Retries:   int(plan.Retries.ValueInt64()),
+	})
+	if err != nil {
+		diags.AddError(
+			"Error uploading file",
+			fmt.Sprintf("Could not upload file: %s", err),
+		)
+		return false
+	}
+
+	plan.Checksum = basetypes.NewStringValue(checksum)
+	return true
+}
+
+func (r *FileUploadResource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String, dedicated types.Bool) (*ssh.SSHClient, func(), error) {
+	config, err := r.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.pool.Acquire(ctx, config, dedicated.ValueBool())
+}
+
+// localFileChecksum returns the SHA-256 checksum of a local file
+func localFileChecksum(localPath string) (string, error) {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
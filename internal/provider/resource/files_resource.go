@@ -0,0 +1,546 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ resource.Resource                     = &FilesResource{}
+	_ resource.ResourceWithConfigure        = &FilesResource{}
+	_ resource.ResourceWithValidateConfig   = &FilesResource{}
+	_ resource.ResourceWithConfigValidators = &FilesResource{}
+)
+
+// defaultFilesConcurrency is the number of files written or read at the same time if concurrency is not set
+const defaultFilesConcurrency = 4
+
+// maxFilesConcurrency stays below OpenSSH's default MaxSessions of 10, since file operations with
+// ownership or sudo open a session per remote command
+const maxFilesConcurrency = 8
+
+// FilesResource defines the resource implementation.
+type FilesResource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// FilesResourceModel describes the resource data model.
+type FilesResourceModel struct {
+	SSH                 *ssh.SSHBlockModel        `tfsdk:"ssh"`
+	Connection          types.String              `tfsdk:"connection"`
+	DedicatedConnection types.Bool                `tfsdk:"dedicated_connection"`
+	Files               map[string]FileEntryModel `tfsdk:"files"`
+	UseSudo             types.Bool                `tfsdk:"use_sudo"`
+	CreateParents       types.Bool                `tfsdk:"create_parents"`
+	Concurrency         types.Int64               `tfsdk:"concurrency"`
+	ID                  types.String              `tfsdk:"id"`
+}
+
+// FileEntryModel describes a single file of the ssh_files resource.
+type FileEntryModel struct {
+	Content     types.String `tfsdk:"content"`
+	Permissions types.String `tfsdk:"permissions"`
+	Owner       types.String `tfsdk:"owner"`
+	Group       types.String `tfsdk:"group"`
+}
+
+// NewFilesResource creates a new resource implementation.
+func NewFilesResource(pool *ssh.SSHPool) resource.Resource {
+	return &FilesResource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the resource type name.
+func (r *FilesResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_files"
+}
+
+// ConfigValidators returns the validators of the resource configuration.
+func (r *FilesResource) ConfigValidators(_ context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the resource.
+func (r *FilesResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages many small files on a remote server via SSH over a single connection.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"dedicated_connection": schema.BoolAttribute{
+				Description: "If true, every operation of this resource opens its own SSH connection instead of using the shared connection pool and closes it when the operation finishes.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockSchema(),
+			},
+			"files": schema.MapNestedAttribute{
+				Description: "The files to manage, keyed by their path on the remote server.",
+				Required:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"content": schema.StringAttribute{
+							Description: "The content of the file.",
+							Required:    true,
+						},
+						"permissions": schema.StringAttribute{
+							Description: "The file permissions in octal format (e.g., '0644').",
+							Optional:    true,
+						},
+						"owner": schema.StringAttribute{
+							Description: "The user owner of the file, as name or numeric ID.",
+							Optional:    true,
+						},
+						"group": schema.StringAttribute{
+							Description: "The group owner of the file, as name or numeric ID.",
+							Optional:    true,
+						},
+					},
+				},
+			},
+			"use_sudo": schema.BoolAttribute{
+				Description: "If true, the files are installed to their paths through sudo, and all other operations on them " +
+					"run through sudo. Requires passwordless sudo for the SSH user.",
+				Optional: true,
+			},
+			"create_parents": schema.BoolAttribute{
+				Description: "Whether missing parent directories of the files are created. If false, a missing parent directory is an error. Defaults to true.",
+				Optional:    true,
+			},
+			"concurrency": schema.Int64Attribute{
+				Description: fmt.Sprintf("The number of files written or read at the same time over the connection. Defaults to %d, at most %d.",
+					defaultFilesConcurrency, maxFilesConcurrency),
+				Optional: true,
+			},
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+// Create creates the resource and sets the initial Terraform state.
+func (r *FilesResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FilesResource.Create")
+	defer span.End()
+
+	var plan FilesResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	errs := forEachFile(sortedPaths(plan.Files), filesConcurrency(plan), func(filePath string) error {
+		return writeFileEntry(ctx, client, filePath, plan.Files[filePath], plan)
+	})
+
+	// Only the files that were written are recorded, so a failed apply never deletes files it didn't create
+	state := plan
+	state.Files = make(map[string]FileEntryModel, len(plan.Files))
+	for filePath, entry := range plan.Files {
+		if err, failed := errs[filePath]; failed {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("files").AtMapKey(filePath),
+				"Error creating file",
+				fmt.Sprintf("Could not create file %s: %s", filePath, err),
+			)
+			continue
+		}
+		state.Files[filePath] = entry
+	}
+	state.ID = types.StringValue(filesID(plan.Files))
+
+	diags = resp.State.Set(ctx, state)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (r *FilesResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FilesResource.Read")
+	defer span.End()
+
+	var state FilesResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	var mu sync.Mutex
+	files := make(map[string]FileEntryModel, len(state.Files))
+	errs := forEachFile(sortedPaths(state.Files), filesConcurrency(state), func(filePath string) error {
+		entry, exists, err := readFileEntry(ctx, client, filePath, state.Files[filePath])
+		if err != nil || !exists {
+			return err
+		}
+		mu.Lock()
+		files[filePath] = entry
+		mu.Unlock()
+		return nil
+	})
+	for filePath, err := range errs {
+		resp.Diagnostics.AddAttributeError(
+			path.Root("files").AtMapKey(filePath),
+			"Error reading file",
+			fmt.Sprintf("Could not read file %s: %s", filePath, err),
+		)
+	}
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Files removed on the server are dropped from the state, so they are created again
+	state.Files = files
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Update updates the resource and sets the updated Terraform state on success.
+func (r *FilesResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FilesResource.Update")
+	defer span.End()
+
+	var plan, state FilesResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	diags = req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	// Files that are unchanged since the last refresh are left alone
+	var changed, removed []string
+	for filePath, entry := range plan.Files {
+		if current, ok := state.Files[filePath]; !ok || current != entry {
+			changed = append(changed, filePath)
+		}
+	}
+	for filePath := range state.Files {
+		if _, ok := plan.Files[filePath]; !ok {
+			removed = append(removed, filePath)
+		}
+	}
+	sort.Strings(changed)
+	sort.Strings(removed)
+
+	// The new state starts from the previous one and records each change that succeeded
+	files := make(map[string]FileEntryModel, len(state.Files))
+	for filePath, entry := range state.Files {
+		files[filePath] = entry
+	}
+
+	writeErrs := forEachFile(changed, filesConcurrency(plan), func(filePath string) error {
+		return writeFileEntry(ctx, client, filePath, plan.Files[filePath], plan)
+	})
+	for _, filePath := range changed {
+		if err, failed := writeErrs[filePath]; failed {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("files").AtMapKey(filePath),
+				"Error updating file",
+				fmt.Sprintf("Could not write file %s: %s", filePath, err),
+			)
+			continue
+		}
+		files[filePath] = plan.Files[filePath]
+	}
+
+	deleteErrs := forEachFile(removed, filesConcurrency(plan), func(filePath string) error {
+		return client.DeleteFile(ctx, filePath)
+	})
+	for _, filePath := range removed {
+		if err, failed := deleteErrs[filePath]; failed {
+			resp.Diagnostics.AddError(
+				"Error deleting file",
+				fmt.Sprintf("Could not delete file %s: %s", filePath, err),
+			)
+			continue
+		}
+		delete(files, filePath)
+	}
+
+	plan.Files = files
+	plan.ID = state.ID
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Delete deletes the resource and removes the Terraform state on success.
+func (r *FilesResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "FilesResource.Delete")
+	defer span.End()
+
+	var state FilesResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	// Only the files recorded in the state are deleted, the directories they are in are kept
+	paths := sortedPaths(state.Files)
+	errs := forEachFile(paths, filesConcurrency(state), func(filePath string) error {
+		return client.DeleteFile(ctx, filePath)
+	})
+	for _, filePath := range paths {
+		if err, failed := errs[filePath]; failed {
+			resp.Diagnostics.AddError(
+				"Error deleting file",
+				fmt.Sprintf("Could not delete file %s: %s", filePath, err),
+			)
+		}
+	}
+}
+
+// ValidateConfig validates the resource configuration.
+func (r *FilesResource) ValidateConfig(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	var config FilesResourceModel
+	diags := req.Config.Get(ctx, &config)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !config.Concurrency.IsNull() && !config.Concurrency.IsUnknown() {
+		if concurrency := config.Concurrency.ValueInt64(); concurrency < 1 || concurrency > maxFilesConcurrency {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("concurrency"),
+				"Invalid concurrency",
+				fmt.Sprintf("Expected a value between 1 and %d, got %d.", maxFilesConcurrency, concurrency),
+			)
+		}
+	}
+
+	for filePath, entry := range config.Files {
+		validateOwnership(entry.Owner, path.Root("files").AtMapKey(filePath).AtName("owner"), &resp.Diagnostics)
+		validateOwnership(entry.Group, path.Root("files").AtMapKey(filePath).AtName("group"), &resp.Diagnostics)
+	}
+}
+
+func (r *FilesResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	r.connections = providerData.Connections
+}
+
+// writeFileEntry writes a single file of the plan with its permissions and ownership
+func writeFileEntry(ctx context.Context, client ssh.FileOps, filePath string, entry FileEntryModel, plan FilesResourceModel) error {
+	opts := ssh.DefaultCreateFileOptions()
+	if !plan.CreateParents.IsNull() {
+		opts.CreateParents = plan.CreateParents.ValueBool()
+	}
+	if plan.UseSudo.ValueBool() {
+		// Installing through sudo sets ownership right away, so the file is never owned by the SSH user
+		opts.Owner = entry.Owner.ValueString()
+		opts.Group = entry.Group.ValueString()
+	}
+
+	permissions := ssh.ParsePermissions(entry.Permissions.ValueString())
+	if err := client.CreateFileWithOptions(ctx, filePath, entry.Content.ValueString(), os.FileMode(permissions), opts); err != nil {
+		return err
+	}
+
+	if entry.Owner.IsNull() && entry.Group.IsNull() {
+		return nil
+	}
+	return client.SetFileOwnership(ctx, filePath, &ssh.FileOwnership{
+		User:  entry.Owner.ValueString(),
+		Group: entry.Group.ValueString(),
+	})
+}
+
+// readFileEntry reads the current state of a single file. Permissions and ownership are only read if
+// they are managed, and keep their configured notation if they match.
+func readFileEntry(ctx context.Context, client ssh.FileOps, filePath string, entry FileEntryModel) (FileEntryModel, bool, error) {
+	exists, err := client.Exists(ctx, filePath)
+	if err != nil || !exists {
+		return entry, false, err
+	}
+
+	content, err := client.ReadFileLimited(ctx, filePath, ssh.DefaultMaxReadSize)
+	if err != nil {
+		return entry, true, err
+	}
+	entry.Content = types.StringValue(content)
+
+	if !entry.Permissions.IsNull() {
+		mode, err := client.GetFileMode(ctx, filePath)
+		if err != nil {
+			return entry, true, err
+		}
+		if os.FileMode(ssh.ParsePermissions(entry.Permissions.ValueString())) != mode {
+			entry.Permissions = types.StringValue(fmt.Sprintf("%04o", mode))
+		}
+	}
+
+	if !entry.Owner.IsNull() || !entry.Group.IsNull() {
+		ownership, err := client.GetFileOwnershipAs(ctx, filePath, &ssh.FileOwnership{
+			User:  entry.Owner.ValueString(),
+			Group: entry.Group.ValueString(),
+		})
+		if err != nil {
+			return entry, true, err
+		}
+		if !entry.Owner.IsNull() {
+			entry.Owner = types.StringValue(ownership.User)
+		}
+		if !entry.Group.IsNull() {
+			entry.Group = types.StringValue(ownership.Group)
+		}
+	}
+
+	return entry, true, nil
+}
+
+// forEachFile runs fn for each path with at most concurrency calls at a time and returns the errors by path
+func forEachFile(paths []string, concurrency int, fn func(filePath string) error) map[string]error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs = make(map[string]error)
+		sem  = make(chan struct{}, concurrency)
+	)
+
+	for _, filePath := range paths {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := fn(filePath); err != nil {
+				mu.Lock()
+				errs[filePath] = err
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// filesConcurrency returns the configured concurrency, or the default if it's not set
+func filesConcurrency(model FilesResourceModel) int {
+	if model.Concurrency.IsNull() || model.Concurrency.IsUnknown() {
+		return defaultFilesConcurrency
+	}
+	return int(min(max(model.Concurrency.ValueInt64(), 1), maxFilesConcurrency))
+}
+
+// sortedPaths returns the paths of the files in a stable order
+func sortedPaths(files map[string]FileEntryModel) []string {
+	paths := make([]string, 0, len(files))
+	for filePath := range files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// filesID derives the ID from the managed paths
+func filesID(files map[string]FileEntryModel) string {
+	return ssh.ContentChecksum(strings.Join(sortedPaths(files), "\n"))
+}
+
+func (r *FilesResource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String, dedicated types.Bool) (ssh.FileOps, func(), error) {
+	config, err := r.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.pool.GetFileOps(ctx, config, dedicated.ValueBool())
+}
@@ -0,0 +1,390 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ resource.Resource                     = &GroupResource{}
+	_ resource.ResourceWithConfigure        = &GroupResource{}
+	_ resource.ResourceWithImportState      = &GroupResource{}
+	_ resource.ResourceWithConfigValidators = &GroupResource{}
+)
+
+// GroupResource defines the resource implementation.
+type GroupResource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// GroupResourceModel describes the resource data model.
+type GroupResourceModel struct {
+	SSH                 *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection          types.String       `tfsdk:"connection"`
+	DedicatedConnection types.Bool         `tfsdk:"dedicated_connection"`
+	Name                types.String       `tfsdk:"name"`
+	GID                 types.Int64        `tfsdk:"gid"`
+	System              types.Bool         `tfsdk:"system"`
+	UseSudo             types.Bool         `tfsdk:"use_sudo"`
+	Adopted             types.Bool         `tfsdk:"adopted"`
+	ID                  types.String       `tfsdk:"id"`
+}
+
+// NewGroupResource creates a new resource implementation.
+func NewGroupResource(pool *ssh.SSHPool) resource.Resource {
+	return &GroupResource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the resource type name.
+func (r *GroupResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_group"
+}
+
+// ConfigValidators returns the validators of the resource configuration.
+func (r *GroupResource) ConfigValidators(_ context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the resource.
+func (r *GroupResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages a Linux group on a remote server via SSH.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"dedicated_connection": schema.BoolAttribute{
+				Description: "If true, every operation of this resource opens its own SSH connection instead of using the shared connection pool and closes it when the operation finishes.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockSchema(),
+			},
+			"name": schema.StringAttribute{
+				Description: "The name of the group.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"gid": schema.Int64Attribute{
+				Description: "The numeric group ID. Chosen by the system if not set.",
+				Optional:    true,
+				Computed:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"system": schema.BoolAttribute{
+				Description: "If true, the group is created as a system group.",
+				Optional:    true,
+				PlanModifiers: []planmodifier.Bool{
+					// An imported group has no value yet, which doesn't replace it
+					boolplanmodifier.RequiresReplaceIf(func(_ context.Context, req planmodifier.BoolRequest, resp *boolplanmodifier.RequiresReplaceIfFuncResponse) {
+						resp.RequiresReplace = !req.StateValue.IsNull()
+					}, "Changing system replaces the group.", "Changing `system` replaces the group."),
+				},
+			},
+			"use_sudo": schema.BoolAttribute{
+				Description: "If true, group management commands run through sudo. Required unless the SSH user is root.",
+				Optional:    true,
+			},
+			"adopted": schema.BoolAttribute{
+				Description: "Whether the group already existed when the resource was created. An adopted group is not deleted on destroy, it is only removed from the state.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+// Create creates the resource and sets the initial Terraform state.
+func (r *GroupResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "GroupResource.Create")
+	defer span.End()
+
+	var plan GroupResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	adopted, err := client.CreateGroup(ctx, plan.Name.ValueString(), plannedGID(plan), plan.System.ValueBool())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating group",
+			fmt.Sprintf("Could not create group: %s", err),
+		)
+		return
+	}
+	if adopted {
+		resp.Diagnostics.AddWarning(
+			"Existing group adopted",
+			fmt.Sprintf("Group %s already exists and is now managed by Terraform. It is not deleted when the resource is destroyed.", plan.Name.ValueString()),
+		)
+	}
+	plan.Adopted = basetypes.NewBoolValue(adopted)
+
+	group, err := client.GetGroup(ctx, plan.Name.ValueString())
+	if err != nil || group == nil {
+		resp.Diagnostics.AddError(
+			"Error reading group",
+			fmt.Sprintf("Could not read group after creating it: %v", err),
+		)
+		return
+	}
+
+	plan.GID = basetypes.NewInt64Value(group.GID)
+	plan.ID = basetypes.NewStringValue(plan.Name.ValueString())
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (r *GroupResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "GroupResource.Read")
+	defer span.End()
+
+	var state GroupResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	group, err := client.GetGroup(ctx, state.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading group",
+			fmt.Sprintf("Could not read group: %s", err),
+		)
+		return
+	}
+	if group == nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	state.GID = basetypes.NewInt64Value(group.GID)
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Update updates the resource and sets the updated Terraform state on success.
+func (r *GroupResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "GroupResource.Update")
+	defer span.End()
+
+	var plan GroupResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	err = client.UpdateGroup(ctx, plan.Name.ValueString(), plannedGID(plan))
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error updating group",
+			fmt.Sprintf("Could not update group: %s", err),
+		)
+		return
+	}
+
+	group, err := client.GetGroup(ctx, plan.Name.ValueString())
+	if err != nil || group == nil {
+		resp.Diagnostics.AddError(
+			"Error reading group",
+			fmt.Sprintf("Could not read group after updating it: %v", err),
+		)
+		return
+	}
+
+	plan.GID = basetypes.NewInt64Value(group.GID)
+	plan.ID = basetypes.NewStringValue(plan.Name.ValueString())
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Delete deletes the resource and removes the Terraform state on success.
+func (r *GroupResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "GroupResource.Delete")
+	defer span.End()
+
+	var state GroupResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	// A group that existed before is left on the server
+	if state.Adopted.ValueBool() {
+		return
+	}
+
+	err = client.DeleteGroup(ctx, state.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error deleting group",
+			fmt.Sprintf("Could not delete group: %s", err),
+		)
+		return
+	}
+}
+
+// ImportState imports an existing group by the name of a provider connection and the group name,
+// "<connection>/<name>". An imported group is deleted on destroy like one created by Terraform.
+func (r *GroupResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	connection, name, err := parseGroupImportID(req.ID)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf("Could not import group: %s", err),
+		)
+		return
+	}
+	if _, err := r.connections.Config(nil, types.StringValue(connection)); err != nil {
+		resp.Diagnostics.AddError(
+			"Invalid import ID",
+			fmt.Sprintf("Could not import group: %s", err),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("connection"), connection)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("name"), name)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("adopted"), false)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), name)...)
+}
+
+func (r *GroupResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	r.connections = providerData.Connections
+}
+
+// parseGroupImportID splits an import ID "<connection>/<name>" into the connection and the group name
+func parseGroupImportID(id string) (string, string, error) {
+	index := strings.LastIndex(id, "/")
+	if index <= 0 || index == len(id)-1 {
+		return "", "", fmt.Errorf("import ID %q must have the format <connection>/<name>, with a connection configured on the provider", id)
+	}
+	return id[:index], id[index+1:], nil
+}
+
+// plannedGID returns the configured GID, or nil if the system should choose one
+func plannedGID(plan GroupResourceModel) *int64 {
+	if plan.GID.IsUnknown() {
+		return nil
+	}
+	return plan.GID.ValueInt64Pointer()
+}
+
+func (r *GroupResource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String, dedicated types.Bool) (*ssh.SSHClient, func(), error) {
+	config, err := r.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.pool.Acquire(ctx, config, dedicated.ValueBool())
+}
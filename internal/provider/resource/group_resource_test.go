@@ -0,0 +1,114 @@
+package resource
+
+import (
+	"context"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+	. "github.com/onsi/gomega"
+)
+
+func TestParseGroupImportID(t *testing.T) {
+	RegisterTestingT(t)
+
+	connection, name, err := parseGroupImportID("web/deploy")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(connection).To(Equal("web"))
+	Expect(name).To(Equal("deploy"))
+
+	connection, name, err = parseGroupImportID("eu/web/deploy")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(connection).To(Equal("eu/web"))
+	Expect(name).To(Equal("deploy"))
+
+	for _, id := range []string{"deploy", "/deploy", "web/", ""} {
+		_, _, err := parseGroupImportID(id)
+		Expect(err).To(MatchError(ContainSubstring("<connection>/<name>")), "import ID %q", id)
+	}
+}
+
+func TestGroupResourceImportState(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	r := &GroupResource{connections: ssh.Connections{"web": ssh.SSHBlockModel{}}}
+	schemaResp := &resource.SchemaResponse{}
+	r.Schema(ctx, resource.SchemaRequest{}, schemaResp)
+	groupSchema := schemaResp.Schema
+
+	t.Log("Import a group with the connection it is read through")
+	resp := &resource.ImportStateResponse{State: emptyState(groupSchema)}
+	r.ImportState(ctx, resource.ImportStateRequest{ID: "web/deploy"}, resp)
+	Expect(resp.Diagnostics).To(BeEmpty())
+	var state GroupResourceModel
+	Expect(resp.State.Get(ctx, &state)).To(BeEmpty())
+	Expect(state.Connection.ValueString()).To(Equal("web"))
+	Expect(state.Name.ValueString()).To(Equal("deploy"))
+	Expect(state.ID.ValueString()).To(Equal("deploy"))
+	Expect(state.Adopted.ValueBool()).To(BeFalse())
+	Expect(state.System.IsNull()).To(BeTrue())
+
+	t.Log("Reject a connection that is not configured on the provider")
+	resp = &resource.ImportStateResponse{State: emptyState(groupSchema)}
+	r.ImportState(ctx, resource.ImportStateRequest{ID: "db/deploy"}, resp)
+	Expect(resp.Diagnostics.HasError()).To(BeTrue())
+	Expect(resp.Diagnostics[0].Detail()).To(ContainSubstring(`connection "db" is not configured`))
+
+	t.Log("Configuring system on an imported group doesn't replace it")
+	imported := groupState(groupSchema, GroupResourceModel{
+		Connection: types.StringValue("web"),
+		Name:       types.StringValue("deploy"),
+		GID:        types.Int64Value(2000),
+		System:     types.BoolNull(),
+		Adopted:    types.BoolValue(false),
+		ID:         types.StringValue("deploy"),
+	})
+	Expect(systemRequiresReplace(groupSchema, imported, types.BoolValue(true))).To(BeFalse())
+
+	t.Log("Changing system of a group with a known value replaces it")
+	created := groupState(groupSchema, GroupResourceModel{
+		Connection: types.StringValue("web"),
+		Name:       types.StringValue("deploy"),
+		GID:        types.Int64Value(2000),
+		System:     types.BoolValue(false),
+		Adopted:    types.BoolValue(false),
+		ID:         types.StringValue("deploy"),
+	})
+	Expect(systemRequiresReplace(groupSchema, created, types.BoolValue(true))).To(BeTrue())
+}
+
+func groupState(groupSchema schema.Schema, model GroupResourceModel) tfsdk.State {
+	state := tfsdk.State{Schema: groupSchema, Raw: tftypes.NewValue(groupSchema.Type().TerraformType(context.Background()), nil)}
+	Expect(state.Set(context.Background(), &model)).To(BeEmpty())
+	return state
+}
+
+// systemRequiresReplace runs the plan modifiers of system for a plan that changes it to planned
+func systemRequiresReplace(groupSchema schema.Schema, state tfsdk.State, planned types.Bool) bool {
+	ctx := context.Background()
+	var stateValue types.Bool
+	Expect(state.GetAttribute(ctx, path.Root("system"), &stateValue)).To(BeEmpty())
+	plan := tfsdk.Plan{Schema: groupSchema, Raw: state.Raw}
+	Expect(plan.SetAttribute(ctx, path.Root("system"), planned)).To(BeEmpty())
+
+	req := planmodifier.BoolRequest{
+		Path:       path.Root("system"),
+		PlanValue:  planned,
+		StateValue: stateValue,
+		State:      state,
+		Plan:       plan,
+	}
+	resp := &planmodifier.BoolResponse{PlanValue: planned}
+	for _, modifier := range groupSchema.Attributes["system"].(schema.BoolAttribute).PlanModifiers {
+		modifier.PlanModifyBool(ctx, req, resp)
+	}
+	Expect(resp.Diagnostics).To(BeEmpty())
+	return resp.RequiresReplace
+}
@@ -0,0 +1,165 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"go.opentelemetry.io/otel"
+)
+
+var _ resource.Resource = &KeygenResource{}
+
+// KeygenResource defines the resource implementation.
+type KeygenResource struct{}
+
+// KeygenResourceModel describes the resource data model.
+type KeygenResourceModel struct {
+	KeyType     types.String `tfsdk:"key_type"`
+	Bits        types.Int64  `tfsdk:"bits"`
+	Comment     types.String `tfsdk:"comment"`
+	PrivateKey  types.String `tfsdk:"private_key"`
+	PublicKey   types.String `tfsdk:"public_key"`
+	Fingerprint types.String `tfsdk:"fingerprint"`
+	ID          types.String `tfsdk:"id"`
+}
+
+// NewKeygenResource creates a new resource implementation.
+func NewKeygenResource() resource.Resource {
+	return &KeygenResource{}
+}
+
+// Metadata returns the resource type name.
+func (r *KeygenResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_keygen"
+}
+
+// Schema defines the schema for the resource.
+func (r *KeygenResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Generates an SSH key pair on the machine running Terraform and keeps it in the state. A new key pair " +
+			"is only generated when key_type, bits or comment change.",
+		Attributes: map[string]schema.Attribute{
+			"key_type": schema.StringAttribute{
+				Description: "The type of the key: 'ed25519', 'ecdsa' or 'rsa'. Defaults to 'ed25519'.",
+				Optional:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"bits": schema.Int64Attribute{
+				Description: "The size of the key: 256, 384 or 521 for ecdsa keys, and between 2048 and 8192 for rsa keys. " +
+					"Defaults to 256 for ecdsa and 3072 for rsa. Can't be set for ed25519 keys.",
+				Optional: true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.RequiresReplace(),
+				},
+			},
+			"comment": schema.StringAttribute{
+				Description: "A comment stored in the private key and appended to the public key (e.g., 'deploy@example.com').",
+				Optional:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"private_key": schema.StringAttribute{
+				Description: "The private key in OpenSSH format, as written by ssh-keygen.",
+				Computed:    true,
+				Sensitive:   true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"public_key": schema.StringAttribute{
+				Description: "The public key in authorized_keys format (e.g., 'ssh-ed25519 AAAA... deploy@example.com').",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"fingerprint": schema.StringAttribute{
+				Description: "The SHA256 fingerprint of the public key (e.g., 'SHA256:...').",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"id": schema.StringAttribute{
+				Description: "The fingerprint of the public key.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+// Create generates the key pair and stores it in the state.
+func (r *KeygenResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "KeygenResource.Create")
+	defer span.End()
+
+	var plan KeygenResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	keyType := ssh.KeyTypeEd25519
+	if !plan.KeyType.IsNull() {
+		keyType = plan.KeyType.ValueString()
+	}
+
+	keyPair, err := ssh.GenerateKeyPair(keyType, int(plan.Bits.ValueInt64()), plan.Comment.ValueString())
+	if err != nil {
+		attribute := path.Root("key_type")
+		switch keyType {
+		case ssh.KeyTypeEd25519, ssh.KeyTypeECDSA, ssh.KeyTypeRSA:
+			attribute = path.Root("bits")
+		}
+		resp.Diagnostics.AddAttributeError(
+			attribute,
+			"Error generating key pair",
+			fmt.Sprintf("Could not generate key pair: %s", err),
+		)
+		return
+	}
+
+	plan.PrivateKey = types.StringValue(keyPair.PrivateKey)
+	plan.PublicKey = types.StringValue(keyPair.PublicKey)
+	plan.Fingerprint = types.StringValue(keyPair.Fingerprint)
+	plan.ID = plan.Fingerprint
+
+	diags = resp.State.Set(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Read keeps the key pair in the state, as it only exists there.
+func (r *KeygenResource) Read(_ context.Context, _ resource.ReadRequest, _ *resource.ReadResponse) {
+}
+
+// Update is never called with changes to the key pair, since changing any argument replaces the resource.
+func (r *KeygenResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan KeygenResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Delete removes the key pair from the state.
+func (r *KeygenResource) Delete(_ context.Context, _ resource.DeleteRequest, _ *resource.DeleteResponse) {
+}
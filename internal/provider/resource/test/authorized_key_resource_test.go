@@ -0,0 +1,215 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccAuthorizedKeyResource(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	managed, err := ssh.GenerateKeyPair(ssh.KeyTypeEd25519, 0, "managed@example.com")
+	require.NoError(t, err)
+	other, err := ssh.GenerateKeyPair(ssh.KeyTypeEd25519, 0, "other@example.com")
+	require.NoError(t, err)
+
+	testDirPath := "/home/testuser/ssh_" + rand.Text()
+	testFilePath := testDirPath + "/authorized_keys"
+	defer client.DeleteDirectory(context.Background(), testDirPath)
+
+	checkContent := func(expected string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			content, err := client.ReadFile(context.Background(), testFilePath)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %v", err)
+			}
+			if content != expected {
+				return fmt.Errorf("unexpected content: got %q, want %q", content, expected)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The file is created with its directory
+			{
+				Config: testAccAuthorizedKeyResourceConfig(testFilePath, managed.PublicKey, ""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_authorized_key.test", "fingerprint", managed.Fingerprint),
+					checkContent(managed.PublicKey+"\n"),
+					func(s *terraform.State) error {
+						for filePath, expected := range map[string]os.FileMode{testDirPath: 0700, testFilePath: 0600} {
+							mode, err := client.GetFileMode(context.Background(), filePath)
+							if err != nil {
+								return fmt.Errorf("failed to get permissions of %s: %v", filePath, err)
+							}
+							if mode != expected {
+								return fmt.Errorf("unexpected permissions of %s: got %o, want %o", filePath, mode, expected)
+							}
+						}
+						return nil
+					},
+				),
+			},
+			// Other entries added in the meantime are kept when the options change
+			{
+				PreConfig: func() {
+					content, err := client.ReadFile(context.Background(), testFilePath)
+					require.NoError(t, err)
+					require.NoError(t, client.CreateFile(context.Background(), testFilePath, "# other keys\n"+other.PublicKey+"\n"+content, 0600))
+				},
+				Config: testAccAuthorizedKeyResourceConfig(testFilePath, managed.PublicKey, `options = ["no-pty", "from=\"10.0.0.0/8\""]`),
+				Check:  checkContent("# other keys\n" + other.PublicKey + "\n" + `no-pty,from="10.0.0.0/8" ` + managed.PublicKey + "\n"),
+			},
+			// A key removed outside of Terraform is added back
+			{
+				PreConfig: func() {
+					require.NoError(t, client.CreateFile(context.Background(), testFilePath, other.PublicKey+"\n", 0600))
+				},
+				Config: testAccAuthorizedKeyResourceConfig(testFilePath, managed.PublicKey, `options = ["no-pty", "from=\"10.0.0.0/8\""]`),
+				Check:  checkContent(other.PublicKey + "\n" + `no-pty,from="10.0.0.0/8" ` + managed.PublicKey + "\n"),
+			},
+			// Replacing the resource removes the managed key, and the absent key is removed as well
+			{
+				Config: testAccAuthorizedKeyResourceConfig(testFilePath, strings.TrimSuffix(other.PublicKey, " other@example.com"), `state = "absent"`),
+				Check:  checkContent(""),
+			},
+			{
+				Config:      testAccAuthorizedKeyResourceConfig(testFilePath, managed.PublicKey, `state = "absent"`+"\n  comment = \"x\""),
+				ExpectError: regexp.MustCompile("Conflicting attributes"),
+			},
+			{
+				Config:      testAccAuthorizedKeyResourceConfig(testFilePath, "not a key", ""),
+				ExpectError: regexp.MustCompile("Invalid public key"),
+			},
+		},
+	})
+}
+
+func TestAccAuthorizedKeyResourceDestroy(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	managed, err := ssh.GenerateKeyPair(ssh.KeyTypeECDSA, 0, "")
+	require.NoError(t, err)
+	other, err := ssh.GenerateKeyPair(ssh.KeyTypeEd25519, 0, "other@example.com")
+	require.NoError(t, err)
+
+	testFilePath := "/home/testuser/authorized_keys_" + rand.Text()
+	require.NoError(t, client.CreateFile(context.Background(), testFilePath, other.PublicKey+"\n", 0600))
+	defer client.DeleteFile(context.Background(), testFilePath)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccAuthorizedKeyResourceConfig(testFilePath, managed.PublicKey, `comment = "managed@example.com"`),
+			},
+		},
+		// Destroying the resource removes only its own entry
+		CheckDestroy: func(s *terraform.State) error {
+			content, err := client.ReadFile(context.Background(), testFilePath)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %v", err)
+			}
+			if content != other.PublicKey+"\n" {
+				return fmt.Errorf("unexpected content after destroy: %q", content)
+			}
+			return nil
+		},
+	})
+}
+
+func TestAccAuthorizedKeyResourceForeignUser(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+	sudoCtx := ssh.WithSudo(context.Background())
+
+	managed, err := ssh.GenerateKeyPair(ssh.KeyTypeEd25519, 0, "managed@example.com")
+	require.NoError(t, err)
+
+	// The file of keyowner is managed as testuser through sudo, like root managing the keys of other users
+	testDirPath := "/home/keyowner/.ssh_" + rand.Text()
+	testFilePath := testDirPath + "/authorized_keys"
+	defer client.DeleteDirectory(sudoCtx, testDirPath)
+
+	checkOwnership := func(s *terraform.State) error {
+		for _, filePath := range []string{testDirPath, testFilePath} {
+			ownership, err := client.GetFileOwnership(sudoCtx, filePath)
+			if err != nil {
+				return fmt.Errorf("failed to get ownership of %s: %v", filePath, err)
+			}
+			if ownership.User != "keyowner" || ownership.Group != "keyowner" {
+				return fmt.Errorf("unexpected ownership of %s: got %s:%s, want keyowner:keyowner", filePath, ownership.User, ownership.Group)
+			}
+		}
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The file and its directory belong to the user whose home they are in
+			{
+				Config: testAccAuthorizedKeyResourceConfig(testFilePath, managed.PublicKey, "use_sudo = true"),
+				Check:  checkOwnership,
+			},
+			// Rewriting the file keeps its owner
+			{
+				Config: testAccAuthorizedKeyResourceConfig(testFilePath, managed.PublicKey, "use_sudo = true\n  options = [\"no-pty\"]"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					checkOwnership,
+					func(s *terraform.State) error {
+						content, err := client.ReadFile(sudoCtx, testFilePath)
+						if err != nil {
+							return fmt.Errorf("failed to read file: %v", err)
+						}
+						if content != "no-pty "+managed.PublicKey+"\n" {
+							return fmt.Errorf("unexpected content: %q", content)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func testAccAuthorizedKeyResourceConfig(path string, publicKey string, arguments string) string {
+	return fmt.Sprintf(`
+resource "ssh_authorized_key" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path       = %q
+  public_key = %q
+  %s
+}
+`, path, publicKey, arguments)
+}
@@ -5,11 +5,13 @@ import (
 	"crypto/rand"
 	"fmt"
 	"os"
+	"regexp"
 	"testing"
 
 	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
 
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
 	"github.com/stretchr/testify/require"
 )
@@ -139,3 +141,296 @@ resource "ssh_directory" "test" {
 }
 `, name, permissions, owner, group)
 }
+
+func TestAccDirectoryResourcePermissionDrift(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	dirName := "testdir_drift_" + rand.Text()
+	testDirPath := "/home/testuser/" + dirName
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDirectoryResourceConfig(dirName, "0755", "testuser", "testuser"),
+				Check:  resource.TestCheckResourceAttr("ssh_directory.test", "permissions", "0755"),
+			},
+			// An unchanged configuration must not produce a plan
+			{
+				Config:   testAccDirectoryResourceConfig(dirName, "0755", "testuser", "testuser"),
+				PlanOnly: true,
+			},
+			// An out-of-band permission change must show up as a diff
+			{
+				PreConfig: func() {
+					require.NoError(t, client.SetFileMode(context.Background(), testDirPath, 0700))
+				},
+				Config:             testAccDirectoryResourceConfig(dirName, "0755", "testuser", "testuser"),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestAccDirectoryResourceStickyBit(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	dirName := "testdir_sticky_" + rand.Text()
+	testDirPath := "/home/testuser/" + dirName
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDirectoryResourceConfig(dirName, "1777", "testuser", "testuser"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_directory.test", "permissions", "1777"),
+					func(s *terraform.State) error {
+						mode, err := client.GetFileMode(context.Background(), testDirPath)
+						if err != nil {
+							return fmt.Errorf("failed to get directory permissions: %v", err)
+						}
+						if mode != os.FileMode(01777) {
+							return fmt.Errorf("unexpected permissions: got %04o, want 1777", mode)
+						}
+						return nil
+					},
+				),
+			},
+			// The sticky bit is read back, so there is no diff
+			{
+				Config:   testAccDirectoryResourceConfig(dirName, "1777", "testuser", "testuser"),
+				PlanOnly: true,
+			},
+			// Removing the sticky bit is applied
+			{
+				Config: testAccDirectoryResourceConfig(dirName, "0777", "testuser", "testuser"),
+				Check: func(s *terraform.State) error {
+					mode, err := client.GetFileMode(context.Background(), testDirPath)
+					if err != nil {
+						return fmt.Errorf("failed to get directory permissions: %v", err)
+					}
+					if mode != os.FileMode(0777) {
+						return fmt.Errorf("unexpected permissions: got %04o, want 0777", mode)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestAccDirectoryResourceTriggers(t *testing.T) {
+	t.Parallel()
+
+	dirName := "testdir_triggers_" + rand.Text()
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDirectoryResourceTriggersConfig(dirName, "1"),
+				Check:  resource.TestCheckResourceAttr("ssh_directory.test", "triggers.version", "1"),
+			},
+			// Changing a trigger recreates the directory
+			{
+				Config: testAccDirectoryResourceTriggersConfig(dirName, "2"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("ssh_directory.test", plancheck.ResourceActionReplace),
+					},
+				},
+				Check: resource.TestCheckResourceAttr("ssh_directory.test", "triggers.version", "2"),
+			},
+		},
+	})
+}
+
+func testAccDirectoryResourceTriggersConfig(name string, version string) string {
+	return fmt.Sprintf(`
+resource "ssh_directory" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path     = "/home/testuser/%s"
+  triggers = {
+    version = "%s"
+  }
+}
+`, name, version)
+}
+
+func TestAccDirectoryResourceRecursivePermissions(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	dirName := "testdir_recursive_" + rand.Text()
+	testDirPath := "/home/testuser/" + dirName
+
+	// Existing content with mixed permissions
+	require.NoError(t, client.CreateFile(context.Background(), testDirPath+"/sub/nested.txt", "nested", 0600))
+	require.NoError(t, client.CreateFile(context.Background(), testDirPath+"/top.txt", "top", 0777))
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccDirectoryResourceRecursiveConfig(dirName),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_directory.test", "file_permissions", "0644"),
+					resource.TestCheckResourceAttr("ssh_directory.test", "dir_permissions", "0750"),
+					func(s *terraform.State) error {
+						for filePath, wanted := range map[string]os.FileMode{
+							testDirPath + "/sub":            0750,
+							testDirPath + "/sub/nested.txt": 0644,
+							testDirPath + "/top.txt":        0644,
+						} {
+							mode, err := client.GetFileMode(context.Background(), filePath)
+							if err != nil {
+								return err
+							}
+							if mode != wanted {
+								return fmt.Errorf("expected mode %04o for %s, got %04o", wanted, filePath, mode)
+							}
+						}
+						return nil
+					},
+				),
+			},
+			// A changed file below the directory must show up as a diff
+			{
+				PreConfig: func() {
+					require.NoError(t, client.SetFileMode(context.Background(), testDirPath+"/sub/nested.txt", 0666))
+				},
+				Config:             testAccDirectoryResourceRecursiveConfig(dirName),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func testAccDirectoryResourceRecursiveConfig(name string) string {
+	return fmt.Sprintf(`
+resource "ssh_directory" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path             = "/home/testuser/%s"
+  permissions      = "0700"
+  recursive        = true
+  file_permissions = "0644"
+  dir_permissions  = "0750"
+}
+`, name)
+}
+
+func TestAccDirectoryResourcePathIsFile(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "dir_is_file_" + rand.Text()
+	require.NoError(t, client.CreateFile(context.Background(), "/home/testuser/"+fileName, "content", 0644))
+	defer client.DeleteFile(context.Background(), "/home/testuser/"+fileName)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccDirectoryResourceConfig(fileName, "0755", "testuser", "testuser"),
+				ExpectError: regexp.MustCompile(`exists and is not a directory, cannot manage it as a directory`),
+			},
+		},
+	})
+}
+
+func TestAccDirectoryResourceOnlyOnOS(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	dirName := "testdir_only_on_os_" + rand.Text()
+	testDirPath := "/home/testuser/" + dirName
+
+	checkExists := func(want bool) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			exists, err := client.Exists(context.Background(), testDirPath)
+			if err != nil {
+				return fmt.Errorf("failed to check directory: %v", err)
+			}
+			if exists != want {
+				return fmt.Errorf("directory exists: %t, want %t", exists, want)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The directory is skipped on a host with another operating system
+			{
+				Config: testAccDirectoryResourceOnlyOnOSConfig(dirName, `["darwin"]`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_directory.test", "skipped", "true"),
+					checkExists(false),
+				),
+			},
+			// Including the operating system replaces the skipped resource, which creates the directory
+			{
+				Config: testAccDirectoryResourceOnlyOnOSConfig(dirName, `["darwin", "linux"]`),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("ssh_directory.test", plancheck.ResourceActionReplace),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_directory.test", "skipped", "false"),
+					checkExists(true),
+				),
+			},
+		},
+		// A managed directory is deleted as usual
+		CheckDestroy: func(s *terraform.State) error {
+			return checkExists(false)(s)
+		},
+	})
+}
+
+func testAccDirectoryResourceOnlyOnOSConfig(name string, onlyOnOS string) string {
+	return fmt.Sprintf(`
+resource "ssh_directory" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path       = "/home/testuser/%s"
+  only_on_os = %s
+}
+`, name, onlyOnOS)
+}
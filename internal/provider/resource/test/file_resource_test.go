@@ -2,13 +2,20 @@ package test
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
+	"os"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
 
+	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
 	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/hashicorp/terraform-plugin-testing/tfversion"
 	"github.com/stretchr/testify/require"
 )
 
@@ -121,3 +128,1109 @@ resource "ssh_file" "test" {
 }
 `, name, content, permissions, owner, group)
 }
+
+func TestAccFileResourcePermissionDrift(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "drift_" + rand.Text() + ".txt"
+	testFilePath := "/home/testuser/" + fileName
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileResourceConfig(fileName, "Hello, World!", "0644", "testuser", "testuser"),
+				Check:  resource.TestCheckResourceAttr("ssh_file.test", "permissions", "0644"),
+			},
+			// An unchanged configuration must not produce a plan
+			{
+				Config:   testAccFileResourceConfig(fileName, "Hello, World!", "0644", "testuser", "testuser"),
+				PlanOnly: true,
+			},
+			// An out-of-band permission change must show up as a diff
+			{
+				PreConfig: func() {
+					require.NoError(t, client.SetFileMode(context.Background(), testFilePath, 0600))
+				},
+				Config:             testAccFileResourceConfig(fileName, "Hello, World!", "0644", "testuser", "testuser"),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			// Applying restores the configured permissions
+			{
+				Config: testAccFileResourceConfig(fileName, "Hello, World!", "0644", "testuser", "testuser"),
+				Check: func(s *terraform.State) error {
+					mode, err := client.GetFileMode(context.Background(), testFilePath)
+					if err != nil {
+						return fmt.Errorf("failed to get file permissions: %v", err)
+					}
+					if mode != os.FileMode(0644) {
+						return fmt.Errorf("unexpected permissions: got %o, want 0644", mode)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func TestAccFileResourceUnchangedContent(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "unchanged_" + rand.Text() + ".txt"
+	testFilePath := "/home/testuser/" + fileName
+
+	// The file exists with the wanted content and a modification time that any write would change
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, client.CreateFile(context.Background(), testFilePath, "Hello, World!", 0644))
+	require.NoError(t, client.SftpClient.Chtimes(testFilePath, modTime, modTime))
+
+	checkNotWritten := func(s *terraform.State) error {
+		info, err := client.SftpClient.Stat(testFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to stat file: %v", err)
+		}
+		if !info.ModTime().Equal(modTime) {
+			return fmt.Errorf("file was written: modification time is %s, want %s", info.ModTime(), modTime)
+		}
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The existing file is adopted without writing it
+			{
+				Config: testAccFileResourceConfig(fileName, "Hello, World!", "0644", "testuser", "testuser"),
+				Check:  checkNotWritten,
+			},
+			// Only the mode is changed, the content is not written again
+			{
+				Config: testAccFileResourceConfig(fileName, "Hello, World!", "0600", "testuser", "testuser"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					checkNotWritten,
+					func(s *terraform.State) error {
+						mode, err := client.GetFileMode(context.Background(), testFilePath)
+						if err != nil {
+							return fmt.Errorf("failed to get file permissions: %v", err)
+						}
+						if mode != os.FileMode(0600) {
+							return fmt.Errorf("unexpected permissions: got %o, want 0600", mode)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func TestAccFileResourcePseudoFile(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// A regular file stands in for a kernel tunable, which the test user can't write. Like the kernel,
+	// it reports the value with a trailing newline.
+	fileName := "pseudo_" + rand.Text()
+	testFilePath := "/home/testuser/" + fileName
+	require.NoError(t, client.CreateFile(context.Background(), testFilePath, "1\n", 0644))
+	defer client.DeleteFile(context.Background(), testFilePath)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The value is written in place
+			{
+				Config: testAccFileResourcePseudoFileConfig(fileName, ""),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "drifted", "false"),
+					func(s *terraform.State) error {
+						content, err := client.ReadFile(context.Background(), testFilePath)
+						if err != nil {
+							return fmt.Errorf("failed to read file: %v", err)
+						}
+						if content != "0\n" {
+							return fmt.Errorf("unexpected content: got %q, want %q", content, "0\n")
+						}
+						return nil
+					},
+				),
+			},
+			// The trailing newline reported for the value is no drift
+			{
+				Config:   testAccFileResourcePseudoFileConfig(fileName, ""),
+				PlanOnly: true,
+			},
+			// Pseudo files have no permissions to manage
+			{
+				Config:      testAccFileResourcePseudoFileConfig(fileName, `permissions = "0644"`),
+				ExpectError: regexp.MustCompile("Conflicting attributes"),
+			},
+		},
+		// Destroying the resource leaves the pseudo file in place
+		CheckDestroy: func(s *terraform.State) error {
+			exists, err := client.Exists(context.Background(), testFilePath)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return fmt.Errorf("pseudo file %s was deleted", testFilePath)
+			}
+			return nil
+		},
+	})
+}
+
+func testAccFileResourcePseudoFileConfig(name string, extra string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path        = "/home/testuser/%s"
+  content     = "0"
+  pseudo_file = true
+  %s
+}
+`, name, extra)
+}
+
+func TestAccFileResourceSymlink(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	dir := "/home/testuser/symlink_" + rand.Text()
+	require.NoError(t, client.CreateDirectory(context.Background(), dir, 0755))
+	defer client.DeleteDirectory(context.Background(), dir)
+	require.NoError(t, client.CreateFile(context.Background(), dir+"/resolved_target", "original", 0644))
+	require.NoError(t, client.SftpClient.Symlink("resolved_target", dir+"/resolved"))
+	require.NoError(t, client.CreateFile(context.Background(), dir+"/replaced_target", "original", 0644))
+	require.NoError(t, client.SftpClient.Symlink("replaced_target", dir+"/replaced"))
+
+	checkContent := func(path string, want string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			content, err := client.ReadFile(context.Background(), path)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %v", err)
+			}
+			if content != want {
+				return fmt.Errorf("unexpected content of %s: got %q, want %q", path, content, want)
+			}
+			return nil
+		}
+	}
+	checkSymlink := func(path string, want bool) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			_, isSymlink, err := client.ReadSymlink(context.Background(), path)
+			if err != nil {
+				return err
+			}
+			if isSymlink != want {
+				return fmt.Errorf("unexpected type of %s: symbolic link is %t, want %t", path, isSymlink, want)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileResourceSymlinkConfig(dir),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					// The target of a resolved link is written, the link stays
+					checkContent(dir+"/resolved_target", "resolved"),
+					checkSymlink(dir+"/resolved", true),
+					// A link that isn't resolved is replaced, its target stays
+					checkContent(dir+"/replaced", "replaced"),
+					checkSymlink(dir+"/replaced", false),
+					checkContent(dir+"/replaced_target", "original"),
+				),
+			},
+			// Both files are read back without changes
+			{
+				Config:   testAccFileResourceSymlinkConfig(dir),
+				PlanOnly: true,
+			},
+		},
+		// The target of the resolved link is deleted, not the link
+		CheckDestroy: resource.ComposeAggregateTestCheckFunc(
+			checkSymlink(dir+"/resolved", true),
+			func(s *terraform.State) error {
+				exists, err := client.Exists(context.Background(), dir+"/resolved_target")
+				if err != nil {
+					return err
+				}
+				if exists {
+					return fmt.Errorf("target %s/resolved_target was not deleted", dir)
+				}
+				return nil
+			},
+			checkContent(dir+"/replaced_target", "original"),
+		),
+	})
+}
+
+func testAccFileResourceSymlinkConfig(dir string) string {
+	return fmt.Sprintf(`
+locals {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+}
+
+resource "ssh_file" "resolved" {
+  ssh              = local.ssh
+  path             = "%[1]s/resolved"
+  content          = "resolved"
+  resolve_symlinks = true
+}
+
+resource "ssh_file" "replaced" {
+  ssh     = local.ssh
+  path    = "%[1]s/replaced"
+  content = "replaced"
+}
+`, dir)
+}
+
+func TestAccFileResourceOnTooLarge(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "on_too_large_" + rand.Text() + ".log"
+	testFilePath := "/home/testuser/" + fileName
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileResourceOnTooLargeConfig(fileName),
+				Check:  resource.TestCheckResourceAttr("ssh_file.test", "drifted", "false"),
+			},
+			// The grown file isn't read, the content keeps its value and the checksum reports the change
+			{
+				PreConfig: func() {
+					require.NoError(t, client.AppendFile(context.Background(), testFilePath, "a line the application appended\n"))
+				},
+				Config: testAccFileResourceOnTooLargeConfig(fileName),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "content", "# log\n"),
+					resource.TestCheckResourceAttr("ssh_file.test", "drifted", "true"),
+				),
+			},
+		},
+	})
+}
+
+func testAccFileResourceOnTooLargeConfig(name string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path          = "/home/testuser/%s"
+  content       = "# log\n"
+  max_read_size = 16
+  on_too_large  = "warn"
+}
+`, name)
+}
+
+func TestAccFileResourceCreateOnly(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "create_only_" + rand.Text() + ".txt"
+	testFilePath := "/home/testuser/" + fileName
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileResourceCreateOnlyConfig(fileName, "default"),
+				Check:  resource.TestCheckResourceAttr("ssh_file.test", "content", "default"),
+			},
+			// Content rewritten on the server is left alone
+			{
+				PreConfig: func() {
+					require.NoError(t, client.DeleteFile(context.Background(), testFilePath))
+					require.NoError(t, client.CreateFile(context.Background(), testFilePath, "rewritten", 0644))
+				},
+				Config:   testAccFileResourceCreateOnlyConfig(fileName, "default"),
+				PlanOnly: true,
+			},
+			// Changed content in the configuration is not written either
+			{
+				Config: testAccFileResourceCreateOnlyConfig(fileName, "changed"),
+				Check: func(s *terraform.State) error {
+					content, err := client.ReadFile(context.Background(), testFilePath)
+					if err != nil {
+						return fmt.Errorf("failed to read file: %v", err)
+					}
+					if content != "rewritten" {
+						return fmt.Errorf("unexpected content: got %q, want %q", content, "rewritten")
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func testAccFileResourceCreateOnlyConfig(name string, content string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path        = "/home/testuser/%s"
+  content     = %q
+  create_only = true
+}
+`, name, content)
+}
+
+func TestAccFileResourceNamedConnection(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "named_connection_" + rand.Text() + ".txt"
+	testFilePath := "/home/testuser/" + fileName
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccFileResourceNamedConnectionConfig(fileName, "missing"),
+				ExpectError: regexp.MustCompile(`connection "missing" is not configured`),
+			},
+			{
+				Config: testAccFileResourceNamedConnectionConfig(fileName, "test"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "connection", "test"),
+					resource.TestCheckNoResourceAttr("ssh_file.test", "ssh"),
+					func(s *terraform.State) error {
+						content, err := client.ReadFile(context.Background(), testFilePath)
+						if err != nil {
+							return fmt.Errorf("failed to read file: %v", err)
+						}
+						if content != "Hello, World!" {
+							return fmt.Errorf("unexpected content: got %q, want %q", content, "Hello, World!")
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func testAccFileResourceNamedConnectionConfig(name string, connection string) string {
+	return fmt.Sprintf(`
+provider "ssh" {
+  connections = {
+    test = {
+      host     = "localhost"
+      port     = 2222
+      username = "testuser"
+      password = "testpass"
+    }
+  }
+}
+
+resource "ssh_file" "test" {
+  connection = %q
+  path       = "/home/testuser/%s"
+  content    = "Hello, World!"
+}
+`, connection, name)
+}
+
+func TestAccFileResourceSensitiveContent(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "sensitive_" + rand.Text() + ".txt"
+	testFilePath := "/home/testuser/" + fileName
+
+	checkContent := func(expected string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			content, err := client.ReadFile(context.Background(), testFilePath)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %v", err)
+			}
+			if content != expected {
+				return fmt.Errorf("unexpected content: got %q, want %q", content, expected)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileResourceSensitiveContentConfig(fileName, "secret"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "sensitive_content", "secret"),
+					resource.TestCheckNoResourceAttr("ssh_file.test", "content"),
+					checkContent("secret"),
+				),
+			},
+			{
+				Config: testAccFileResourceSensitiveContentConfig(fileName, "rotated"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "sensitive_content", "rotated"),
+					checkContent("rotated"),
+				),
+			},
+		},
+	})
+}
+
+func testAccFileResourceSensitiveContentConfig(name string, content string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path              = "/home/testuser/%s"
+  sensitive_content = %q
+}
+`, name, content)
+}
+
+func TestAccFileResourceWriteOnlyContent(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "write_only_" + rand.Text() + ".txt"
+	testFilePath := "/home/testuser/" + fileName
+
+	checkContent := func(expected string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			content, err := client.ReadFile(context.Background(), testFilePath)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %v", err)
+			}
+			if content != expected {
+				return fmt.Errorf("unexpected content: got %q, want %q", content, expected)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		TerraformVersionChecks: []tfversion.TerraformVersionCheck{
+			tfversion.SkipBelow(version.Must(version.NewVersion("1.11.0"))),
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileResourceWriteOnlyContentConfig(fileName, "secret", 1),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckNoResourceAttr("ssh_file.test", "content_wo"),
+					resource.TestCheckNoResourceAttr("ssh_file.test", "content"),
+					resource.TestCheckNoResourceAttr("ssh_file.test", "checksum"),
+					resource.TestCheckResourceAttr("ssh_file.test", "content_wo_version", "1"),
+					checkContent("secret"),
+				),
+			},
+			// Without a new version, changed write-only content is not applied
+			{
+				Config: testAccFileResourceWriteOnlyContentConfig(fileName, "rotated", 1),
+				Check:  checkContent("secret"),
+			},
+			{
+				Config: testAccFileResourceWriteOnlyContentConfig(fileName, "rotated", 2),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "content_wo_version", "2"),
+					checkContent("rotated"),
+				),
+			},
+		},
+	})
+}
+
+func testAccFileResourceWriteOnlyContentConfig(name string, content string, contentVersion int) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path               = "/home/testuser/%s"
+  content_wo         = %q
+  content_wo_version = %d
+}
+`, name, content, contentVersion)
+}
+
+func TestAccFileResourceCommands(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "commands_" + rand.Text() + ".txt"
+	testFilePath := "/home/testuser/" + fileName
+	logPath := testFilePath + ".log"
+
+	// checkLog verifies how often post_command ran
+	checkLog := func(want string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			content, err := client.ReadFile(context.Background(), logPath)
+			if err != nil {
+				return fmt.Errorf("failed to read command log: %v", err)
+			}
+			if content != want {
+				return fmt.Errorf("unexpected command log: got %q, want %q", content, want)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileResourceCommandsConfig(fileName, "first", "0644", "true"),
+				Check:  checkLog("post\n"),
+			},
+			// A content change runs post_command again
+			{
+				Config: testAccFileResourceCommandsConfig(fileName, "second", "0644", "true"),
+				Check:  checkLog("post\npost\n"),
+			},
+			// A permission change alone doesn't
+			{
+				Config: testAccFileResourceCommandsConfig(fileName, "second", "0600", "true"),
+				Check:  checkLog("post\npost\n"),
+			},
+			// A failing pre_command leaves the file unchanged
+			{
+				Config:      testAccFileResourceCommandsConfig(fileName, "third", "0600", "echo invalid config >&2; false"),
+				ExpectError: regexp.MustCompile(`invalid config`),
+			},
+			{
+				Config:   testAccFileResourceCommandsConfig(fileName, "second", "0600", "true"),
+				PlanOnly: true,
+			},
+		},
+	})
+}
+
+func testAccFileResourceCommandsConfig(name string, content string, permissions string, preCommand string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path         = "/home/testuser/%[1]s"
+  content      = %[2]q
+  permissions  = %[3]q
+  pre_command  = %[4]q
+  post_command = "echo $MARKER >> /home/testuser/%[1]s.log"
+
+  command_environment = {
+    MARKER = "post"
+  }
+}
+`, name, content, permissions, preCommand)
+}
+
+func TestAccFileResourceContentValidation(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "validated_" + rand.Text() + ".conf"
+	testFilePath := "/home/testuser/" + fileName
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Content that doesn't match the expression is rejected during plan
+			{
+				Config:      testAccFileResourceContentValidationConfig(fileName, "port = 80"),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`doesn't match content_validation`),
+			},
+			{
+				Config: testAccFileResourceContentValidationConfig(fileName, "[server]\nport = 80\n"),
+				Check:  resource.TestCheckResourceAttr("ssh_file.test", "content", "[server]\nport = 80\n"),
+			},
+			// Content rejected by the validate command leaves the file unchanged
+			{
+				Config:      testAccFileResourceContentValidationConfig(fileName, "[server]\nport = invalid\n"),
+				ExpectError: regexp.MustCompile(`rejected by validate_command`),
+			},
+			{
+				Config: testAccFileResourceContentValidationConfig(fileName, "[server]\nport = 80\n"),
+				Check: func(s *terraform.State) error {
+					content, err := client.ReadFile(context.Background(), testFilePath)
+					if err != nil {
+						return fmt.Errorf("failed to read file: %v", err)
+					}
+					if content != "[server]\nport = 80\n" {
+						return fmt.Errorf("unexpected content: got %q", content)
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func testAccFileResourceContentValidationConfig(name string, content string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path               = "/home/testuser/%s"
+  content            = %q
+  content_validation = "^\\[server\\]"
+  validate_command   = "grep -Eq '^port = [0-9]+$' %%s"
+}
+`, name, content)
+}
+
+func TestAccFileResourceDedicatedConnection(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "dedicated_" + rand.Text() + ".txt"
+	testFilePath := "/home/testuser/" + fileName
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileResourceDedicatedConnectionConfig(fileName, "first"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "dedicated_connection", "true"),
+					resource.TestCheckResourceAttr("ssh_file.test", "content", "first"),
+				),
+			},
+			{
+				Config: testAccFileResourceDedicatedConnectionConfig(fileName, "second"),
+				Check: func(s *terraform.State) error {
+					content, err := client.ReadFile(context.Background(), testFilePath)
+					if err != nil {
+						return fmt.Errorf("failed to read file: %v", err)
+					}
+					if content != "second" {
+						return fmt.Errorf("unexpected content: got %q, want %q", content, "second")
+					}
+					return nil
+				},
+			},
+		},
+	})
+}
+
+func testAccFileResourceDedicatedConnectionConfig(name string, content string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  dedicated_connection = true
+  path                 = "/home/testuser/%s"
+  content              = %q
+}
+`, name, content)
+}
+
+func TestAccFileResourcePathIsDirectory(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	dirName := "file_is_dir_" + rand.Text()
+	require.NoError(t, client.CreateDirectory(context.Background(), "/home/testuser/"+dirName, 0755))
+	defer client.DeleteDirectory(context.Background(), "/home/testuser/"+dirName)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      testAccFileResourceConfig(dirName, "content", "0644", "testuser", "testuser"),
+				ExpectError: regexp.MustCompile(`exists and is a directory, cannot manage it as a file`),
+			},
+		},
+	})
+}
+
+func TestAccFileResourceBackup(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "backup_" + rand.Text() + ".conf"
+	backupPath := "/home/testuser/" + fileName + ".orig"
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		CheckDestroy: func(s *terraform.State) error {
+			exists, err := client.Exists(context.Background(), backupPath)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("backup %s still exists", backupPath)
+			}
+			return nil
+		},
+		Steps: []resource.TestStep{
+			// Nothing to back up when the file is created
+			{
+				Config: testAccFileResourceBackupConfig(fileName, "first"),
+				Check:  resource.TestCheckNoResourceAttr("ssh_file.test", "backup_path"),
+			},
+			{
+				Config: testAccFileResourceBackupConfig(fileName, "second"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "backup_path", backupPath),
+					func(s *terraform.State) error {
+						content, err := client.ReadFile(context.Background(), backupPath)
+						if err != nil {
+							return fmt.Errorf("failed to read backup: %v", err)
+						}
+						if content != "first" {
+							return fmt.Errorf("unexpected backup content: got %q, want %q", content, "first")
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func testAccFileResourceBackupConfig(name string, content string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path                     = "/home/testuser/%s"
+  content                  = %q
+  backup                   = true
+  backup_suffix            = ".orig"
+  delete_backup_on_destroy = true
+}
+`, name, content)
+}
+
+func TestAccFileResourcePreserveExisting(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "preserve_" + rand.Text() + ".txt"
+	testFilePath := "/home/testuser/" + fileName
+	require.NoError(t, client.CreateFile(context.Background(), testFilePath, "original", 0640))
+
+	checkMode := func(s *terraform.State) error {
+		mode, err := client.GetFileMode(context.Background(), testFilePath)
+		if err != nil {
+			return fmt.Errorf("failed to get file permissions: %v", err)
+		}
+		if mode != os.FileMode(0640) {
+			return fmt.Errorf("unexpected permissions: got %o, want 0640", mode)
+		}
+		return nil
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The existing file is overwritten with its permissions kept
+			{
+				Config: testAccFileResourcePreserveExistingConfig(fileName, "Hello, World!"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "content", "Hello, World!"),
+					resource.TestCheckNoResourceAttr("ssh_file.test", "permissions"),
+					checkMode,
+				),
+			},
+			// They are kept when the content changes as well
+			{
+				Config: testAccFileResourcePreserveExistingConfig(fileName, "Hello, Terraform!"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "content", "Hello, Terraform!"),
+					checkMode,
+					func(s *terraform.State) error {
+						content, err := client.ReadFile(context.Background(), testFilePath)
+						if err != nil {
+							return fmt.Errorf("failed to read file: %v", err)
+						}
+						if content != "Hello, Terraform!" {
+							return fmt.Errorf("unexpected content: %q", content)
+						}
+						return nil
+					},
+				),
+			},
+		},
+	})
+}
+
+func testAccFileResourcePreserveExistingConfig(name string, content string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path              = "/home/testuser/%s"
+  content           = %q
+  preserve_existing = true
+}
+`, name, content)
+}
+
+func TestAccFileResourceOnlyOnOS(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "only_on_os_" + rand.Text()
+	testFilePath := "/home/testuser/" + fileName
+	// The file is left in place once it's no longer managed
+	defer client.DeleteFile(context.Background(), testFilePath)
+
+	checkExists := func(want bool) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			exists, err := client.Exists(context.Background(), testFilePath)
+			if err != nil {
+				return fmt.Errorf("failed to check file: %v", err)
+			}
+			if exists != want {
+				return fmt.Errorf("file exists: %t, want %t", exists, want)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// The file is skipped on a host with another operating system
+			{
+				Config: testAccFileResourceOnlyOnOSConfig(fileName, `["darwin", "freebsd"]`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "skipped", "true"),
+					resource.TestCheckResourceAttr("ssh_file.test", "id", testFilePath),
+					checkExists(false),
+				),
+			},
+			// Including the operating system replaces the skipped resource, which creates the file
+			{
+				Config: testAccFileResourceOnlyOnOSConfig(fileName, `["linux"]`),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("ssh_file.test", plancheck.ResourceActionReplace),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "skipped", "false"),
+					checkExists(true),
+				),
+			},
+			// Excluding it again leaves the file in place
+			{
+				Config: testAccFileResourceOnlyOnOSConfig(fileName, `["darwin"]`),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("ssh_file.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "skipped", "true"),
+					checkExists(true),
+				),
+			},
+			{
+				Config:      testAccFileResourceOnlyOnOSConfig(fileName, `[]`),
+				ExpectError: regexp.MustCompile("only_on_os must list at least one operating system"),
+			},
+		},
+		// Destroying a skipped file doesn't delete it
+		CheckDestroy: func(s *terraform.State) error {
+			return checkExists(true)(s)
+		},
+	})
+}
+
+func testAccFileResourceOnlyOnOSConfig(name string, onlyOnOS string) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path       = "/home/testuser/%s"
+  content    = "Hello, World!"
+  only_on_os = %s
+}
+`, name, onlyOnOS)
+}
+
+func TestAccFileResourceVerifyCommand(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	fileName := "verify_" + rand.Text()
+	testFilePath := "/home/testuser/" + fileName
+
+	checkContent := func(want string) resource.TestCheckFunc {
+		return func(s *terraform.State) error {
+			content, err := client.ReadFile(context.Background(), testFilePath)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %v", err)
+			}
+			if content != want {
+				return fmt.Errorf("unexpected content: %q, want %q", content, want)
+			}
+			return nil
+		}
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileResourceVerifyCommandConfig(fileName, "valid: 1", true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "rolled_back", "false"),
+					checkContent("valid: 1"),
+				),
+			},
+			// Content the command rejects is rolled back
+			{
+				Config:      testAccFileResourceVerifyCommandConfig(fileName, "broken", true),
+				ExpectError: regexp.MustCompile("The previous content was restored"),
+			},
+			// The state keeps the previous content, so the configuration it was rolled back to has no changes
+			{
+				Config: testAccFileResourceVerifyCommandConfig(fileName, "valid: 1", true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "rolled_back", "true"),
+					resource.TestCheckResourceAttr("ssh_file.test", "backup_path", testFilePath+".bak"),
+					checkContent("valid: 1"),
+				),
+			},
+			{
+				Config: testAccFileResourceVerifyCommandConfig(fileName, "valid: 2", true),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file.test", "rolled_back", "false"),
+					checkContent("valid: 2"),
+				),
+			},
+			{
+				Config:      testAccFileResourceVerifyCommandConfig(fileName, "valid: 2", false),
+				ExpectError: regexp.MustCompile("verify_command requires backup = true"),
+			},
+		},
+	})
+}
+
+func testAccFileResourceVerifyCommandConfig(name string, content string, backup bool) string {
+	return fmt.Sprintf(`
+resource "ssh_file" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  path           = "/home/testuser/%[1]s"
+  content        = %[2]q
+  backup         = %[3]t
+  verify_command = "grep -q '^valid:' /home/testuser/%[1]s"
+}
+`, name, content, backup)
+}
@@ -0,0 +1,133 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccFileUploadResource(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	remotePath := "/home/testuser/upload_" + rand.Text()
+	source := filepath.Join(t.TempDir(), "artifact")
+	content := strings.Repeat("0123456789", 300*1024)
+	require.NoError(t, os.WriteFile(source, []byte(content), 0644))
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccFileUploadResourceConfig(source, remotePath, "0640"),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_file_upload.test", "checksum", ssh.ContentChecksum(content)),
+					testAccCheckUploadedFile(client, remotePath, content, 0640),
+				),
+			},
+			// An unchanged source file is not uploaded again
+			{
+				Config: testAccFileUploadResourceConfig(source, remotePath, "0640"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+			},
+			// A changed source file is uploaded again
+			{
+				PreConfig: func() {
+					content = strings.Repeat("9876543210", 300*1024)
+					require.NoError(t, os.WriteFile(source, []byte(content), 0644))
+				},
+				Config: testAccFileUploadResourceConfig(source, remotePath, "0640"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("ssh_file_upload.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				Check: testAccCheckUploadedFile(client, remotePath, content, 0640),
+			},
+			// A file changed on the server is uploaded again
+			{
+				PreConfig: func() {
+					require.NoError(t, client.CreateFile(context.Background(), remotePath, "changed", 0640))
+				},
+				Config: testAccFileUploadResourceConfig(source, remotePath, "0640"),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("ssh_file_upload.test", plancheck.ResourceActionUpdate),
+					},
+				},
+				Check: testAccCheckUploadedFile(client, remotePath, content, 0640),
+			},
+			// Changing the permissions doesn't need another upload
+			{
+				Config: testAccFileUploadResourceConfig(source, remotePath, "0600"),
+				Check:  testAccCheckUploadedFile(client, remotePath, content, 0600),
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			exists, err := client.Exists(context.Background(), remotePath)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return fmt.Errorf("file %s still exists", remotePath)
+			}
+			return nil
+		},
+	})
+}
+
+func testAccCheckUploadedFile(client *ssh.SSHClient, remotePath string, content string, mode os.FileMode) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		checksum, err := client.FileChecksum(context.Background(), remotePath)
+		if err != nil {
+			return fmt.Errorf("failed to read uploaded file: %v", err)
+		}
+		if checksum != ssh.ContentChecksum(content) {
+			return fmt.Errorf("uploaded file has checksum %s, want %s", checksum, ssh.ContentChecksum(content))
+		}
+
+		actual, err := client.GetFileMode(context.Background(), remotePath)
+		if err != nil {
+			return fmt.Errorf("failed to get file permissions: %v", err)
+		}
+		if actual != mode {
+			return fmt.Errorf("unexpected permissions: got %o, want %o", actual, mode)
+		}
+		return nil
+	}
+}
+
+func testAccFileUploadResourceConfig(source string, remotePath string, permissions string) string {
+	return fmt.Sprintf(`
+resource "ssh_file_upload" "test" {
+  ssh = {
+    host     = "localhost"
+    port     = 2222
+    username = "testuser"
+    password = "testpass"
+  }
+  source      = %q
+  path        = %q
+  permissions = %q
+  resumable   = true
+  retries     = 2
+}
+`, source, remotePath, permissions)
+}
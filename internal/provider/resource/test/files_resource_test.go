@@ -0,0 +1,176 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccFilesResource(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	prefix := "/home/testuser/testfiles_" + rand.Text()
+	first, second, third := prefix+"_a.txt", prefix+"_b.txt", prefix+"_c.txt"
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// Create and Read testing
+			{
+				Config: testAccFilesResourceConfig(map[string]string{first: "first", second: "second"}),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_files.test", "files.%", "2"),
+					resource.TestCheckResourceAttr("ssh_files.test", fmt.Sprintf("files.%s.content", first), "first"),
+					resource.TestCheckResourceAttr("ssh_files.test", fmt.Sprintf("files.%s.permissions", second), "0600"),
+					func(s *terraform.State) error {
+						for filePath, want := range map[string]string{first: "first", second: "second"} {
+							content, err := client.ReadFile(context.Background(), filePath)
+							if err != nil {
+								return fmt.Errorf("failed to read file: %v", err)
+							}
+							if content != want {
+								return fmt.Errorf("unexpected content of %s: got %q, want %q", filePath, content, want)
+							}
+
+							mode, err := client.GetFileMode(context.Background(), filePath)
+							if err != nil {
+								return fmt.Errorf("failed to get file permissions: %v", err)
+							}
+							if mode != os.FileMode(0600) {
+								return fmt.Errorf("unexpected permissions of %s: got %o, want 0600", filePath, mode)
+							}
+						}
+						return nil
+					},
+				),
+			},
+			// Update testing: one file changes, one is removed and one is added
+			{
+				Config: testAccFilesResourceConfig(map[string]string{first: "changed", third: "third"}),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestCheckResourceAttr("ssh_files.test", "files.%", "2"),
+					resource.TestCheckResourceAttr("ssh_files.test", fmt.Sprintf("files.%s.content", first), "changed"),
+					resource.TestCheckResourceAttr("ssh_files.test", fmt.Sprintf("files.%s.content", third), "third"),
+					func(s *terraform.State) error {
+						exists, err := client.Exists(context.Background(), second)
+						if err != nil {
+							return fmt.Errorf("failed to check file: %v", err)
+						}
+						if exists {
+							return fmt.Errorf("removed file still exists: %s", second)
+						}
+						return nil
+					},
+				),
+			},
+			// A file deleted on the server must show up as a diff
+			{
+				PreConfig: func() {
+					require.NoError(t, client.DeleteFile(context.Background(), third))
+				},
+				Config:             testAccFilesResourceConfig(map[string]string{first: "changed", third: "third"}),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+		CheckDestroy: func(s *terraform.State) error {
+			for _, filePath := range []string{first, second, third} {
+				exists, err := client.Exists(context.Background(), filePath)
+				if err != nil {
+					return fmt.Errorf("failed to check file: %v", err)
+				}
+				if exists {
+					return fmt.Errorf("file still exists after destroy: %s", filePath)
+				}
+			}
+			return nil
+		},
+	})
+}
+
+func TestAccFilesResourceCreateParents(t *testing.T) {
+	t.Parallel()
+
+	client, err := ssh.NewSSHClient(context.Background(), sshConfig)
+	require.NoError(t, err)
+	defer client.Close()
+
+	testDirPath := "/home/testuser/testfiles_" + rand.Text()
+	testFilePath := testDirPath + "/nested/file.txt"
+	defer client.DeleteDirectory(context.Background(), testDirPath)
+
+	config := fmt.Sprintf(`
+resource "ssh_files" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  files = {
+    %q = {
+      content = "content"
+    }
+  }
+  create_parents = false
+}
+`, testFilePath)
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			// A missing parent directory is an error instead of being created
+			{
+				Config:      config,
+				ExpectError: regexp.MustCompile("parent directory does not exist"),
+			},
+			{
+				PreConfig: func() {
+					exists, err := client.Exists(context.Background(), testDirPath)
+					require.NoError(t, err)
+					require.False(t, exists)
+					require.NoError(t, client.CreateDirectory(context.Background(), testDirPath+"/nested", 0755))
+				},
+				Config: config,
+				Check:  resource.TestCheckResourceAttr("ssh_files.test", "create_parents", "false"),
+			},
+		},
+	})
+}
+
+func testAccFilesResourceConfig(files map[string]string) string {
+	entries := ""
+	for filePath, content := range files {
+		entries += fmt.Sprintf(`
+    %q = {
+      content     = %q
+      permissions = "0600"
+    }`, filePath, content)
+	}
+
+	return fmt.Sprintf(`
+resource "ssh_files" "test" {
+  ssh = {
+    host        = "localhost"
+    port        = 2222
+    username    = "testuser"
+    password    = "testpass"
+  }
+  files = {%s
+  }
+}
+`, entries)
+}
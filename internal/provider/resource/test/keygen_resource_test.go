@@ -0,0 +1,108 @@
+package test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-testing/helper/resource"
+	"github.com/hashicorp/terraform-plugin-testing/plancheck"
+	"github.com/hashicorp/terraform-plugin-testing/terraform"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestAccKeygenResource(t *testing.T) {
+	t.Parallel()
+
+	var fingerprint string
+
+	resource.Test(t, resource.TestCase{
+		ProtoV6ProviderFactories: testAccProtoV6ProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: testAccKeygenResourceConfig(`comment = "deploy@example.com"`),
+				Check: resource.ComposeAggregateTestCheckFunc(
+					resource.TestMatchResourceAttr("ssh_keygen.test", "public_key", regexp.MustCompile(`^ssh-ed25519 \S+ deploy@example\.com$`)),
+					resource.TestCheckResourceAttrWith("ssh_keygen.test", "fingerprint", func(value string) error {
+						fingerprint = value
+						return nil
+					}),
+					testAccCheckKeygenKeyPair("ssh_keygen.test", "ssh-ed25519"),
+				),
+			},
+			// The key pair is kept in the state instead of being generated again
+			{
+				Config: testAccKeygenResourceConfig(`comment = "deploy@example.com"`),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectEmptyPlan(),
+					},
+				},
+				Check: resource.TestCheckResourceAttrWith("ssh_keygen.test", "fingerprint", func(value string) error {
+					if value != fingerprint {
+						return fmt.Errorf("key pair was generated again: got %s, want %s", value, fingerprint)
+					}
+					return nil
+				}),
+			},
+			// Changing the key replaces it
+			{
+				Config: testAccKeygenResourceConfig(`
+  key_type = "ecdsa"
+  bits     = 384`),
+				ConfigPlanChecks: resource.ConfigPlanChecks{
+					PreApply: []plancheck.PlanCheck{
+						plancheck.ExpectResourceAction("ssh_keygen.test", plancheck.ResourceActionReplace),
+					},
+				},
+				Check: testAccCheckKeygenKeyPair("ssh_keygen.test", "ecdsa-sha2-nistp384"),
+			},
+			{
+				Config: testAccKeygenResourceConfig(`
+  key_type = "rsa"
+  bits     = 2048`),
+				Check: testAccCheckKeygenKeyPair("ssh_keygen.test", "ssh-rsa"),
+			},
+			{
+				Config: testAccKeygenResourceConfig(`
+  key_type = "ed25519"
+  bits     = 256`),
+				ExpectError: regexp.MustCompile("ed25519 keys have a fixed size"),
+			},
+		},
+	})
+}
+
+// testAccCheckKeygenKeyPair checks that the private key parses and belongs to the public key
+func testAccCheckKeygenKeyPair(name string, keyType string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[name]
+		if !ok {
+			return fmt.Errorf("resource %s not found", name)
+		}
+
+		signer, err := gossh.ParsePrivateKey([]byte(rs.Primary.Attributes["private_key"]))
+		if err != nil {
+			return fmt.Errorf("failed to parse private key: %v", err)
+		}
+		publicKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(rs.Primary.Attributes["public_key"]))
+		if err != nil {
+			return fmt.Errorf("failed to parse public key: %v", err)
+		}
+		if publicKey.Type() != keyType {
+			return fmt.Errorf("unexpected key type: got %s, want %s", publicKey.Type(), keyType)
+		}
+		if string(signer.PublicKey().Marshal()) != string(publicKey.Marshal()) {
+			return fmt.Errorf("private key doesn't belong to the public key")
+		}
+		return nil
+	}
+}
+
+func testAccKeygenResourceConfig(arguments string) string {
+	return fmt.Sprintf(`
+resource "ssh_keygen" "test" {
+  %s
+}
+`, arguments)
+}
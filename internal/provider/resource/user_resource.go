@@ -0,0 +1,422 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-framework/types/basetypes"
+	"go.opentelemetry.io/otel"
+)
+
+var (
+	_ resource.Resource                     = &UserResource{}
+	_ resource.ResourceWithConfigure        = &UserResource{}
+	_ resource.ResourceWithConfigValidators = &UserResource{}
+)
+
+// UserResource defines the resource implementation.
+type UserResource struct {
+	pool        *ssh.SSHPool
+	connections ssh.Connections
+}
+
+// UserResourceModel describes the resource data model.
+type UserResourceModel struct {
+	SSH                 *ssh.SSHBlockModel `tfsdk:"ssh"`
+	Connection          types.String       `tfsdk:"connection"`
+	DedicatedConnection types.Bool         `tfsdk:"dedicated_connection"`
+	Name                types.String       `tfsdk:"name"`
+	UID                 types.Int64        `tfsdk:"uid"`
+	GID                 types.Int64        `tfsdk:"gid"`
+	Home                types.String       `tfsdk:"home"`
+	Shell               types.String       `tfsdk:"shell"`
+	Groups              types.Set          `tfsdk:"groups"`
+	System              types.Bool         `tfsdk:"system"`
+	UseSudo             types.Bool         `tfsdk:"use_sudo"`
+	ID                  types.String       `tfsdk:"id"`
+}
+
+// NewUserResource creates a new resource implementation.
+func NewUserResource(pool *ssh.SSHPool) resource.Resource {
+	return &UserResource{
+		pool: pool,
+	}
+}
+
+// Metadata returns the resource type name.
+func (r *UserResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_user"
+}
+
+// ConfigValidators returns the validators of the resource configuration.
+func (r *UserResource) ConfigValidators(_ context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		ssh.ConnectionValidator{},
+	}
+}
+
+// Schema defines the schema for the resource.
+func (r *UserResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages a Linux user account on a remote server via SSH.",
+		Attributes: map[string]schema.Attribute{
+			"connection": schema.StringAttribute{
+				Description: "The name of a connection configured in the provider's connections attribute. Either ssh or connection must be set.",
+				Optional:    true,
+			},
+			"dedicated_connection": schema.BoolAttribute{
+				Description: "If true, every operation of this resource opens its own SSH connection instead of using the shared connection pool and closes it when the operation finishes.",
+				Optional:    true,
+			},
+			"ssh": schema.SingleNestedAttribute{
+				Description: "SSH connection configuration. Either ssh or connection must be set.",
+				Optional:    true,
+				Attributes:  ssh.SSHBlockSchema(),
+			},
+			"name": schema.StringAttribute{
+				Description: "The name of the user.",
+				Required:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.RequiresReplace(),
+				},
+			},
+			"uid": schema.Int64Attribute{
+				Description: "The numeric user ID. Chosen by the system if not set.",
+				Optional:    true,
+				Computed:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"gid": schema.Int64Attribute{
+				Description: "The numeric ID of the user's primary group.",
+				Computed:    true,
+				PlanModifiers: []planmodifier.Int64{
+					int64planmodifier.UseStateForUnknown(),
+				},
+			},
+			"home": schema.StringAttribute{
+				Description: "The home directory of the user. Chosen by the system if not set.",
+				Optional:    true,
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"shell": schema.StringAttribute{
+				Description: "The login shell of the user. Chosen by the system if not set.",
+				Optional:    true,
+				Computed:    true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+			"groups": schema.SetAttribute{
+				Description: "The supplementary groups of the user. If not set, group membership is not managed.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"system": schema.BoolAttribute{
+				Description: "If true, the user is created as a system account.",
+				Optional:    true,
+				PlanModifiers: []planmodifier.Bool{
+					boolplanmodifier.RequiresReplace(),
+				},
+			},
+			"use_sudo": schema.BoolAttribute{
+				Description: "If true, user management commands run through sudo. Required unless the SSH user is root.",
+				Optional:    true,
+			},
+			"id": schema.StringAttribute{
+				Computed: true,
+				PlanModifiers: []planmodifier.String{
+					stringplanmodifier.UseStateForUnknown(),
+				},
+			},
+		},
+	}
+}
+
+// Create creates the resource and sets the initial Terraform state.
+func (r *UserResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "UserResource.Create")
+	defer span.End()
+
+	var plan UserResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	spec, diags := userSpec(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	spec.System = plan.System.ValueBool()
+
+	err = client.CreateUser(ctx, spec)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating user",
+			fmt.Sprintf("Could not create user: %s", err),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(r.readUser(ctx, client, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	plan.ID = basetypes.NewStringValue(plan.Name.ValueString())
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Read refreshes the Terraform state with the latest data.
+func (r *UserResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "UserResource.Read")
+	defer span.End()
+
+	var state UserResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	user, err := client.GetUser(ctx, state.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error reading user",
+			fmt.Sprintf("Could not read user: %s", err),
+		)
+		return
+	}
+	if user == nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	resp.Diagnostics.Append(setUserState(ctx, user, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Update updates the resource and sets the updated Terraform state on success.
+func (r *UserResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "UserResource.Update")
+	defer span.End()
+
+	var plan UserResourceModel
+	diags := req.Plan.Get(ctx, &plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, plan.SSH, plan.Connection, plan.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if plan.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	spec, diags := userSpec(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	err = client.UpdateUser(ctx, spec)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error updating user",
+			fmt.Sprintf("Could not update user: %s", err),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(r.readUser(ctx, client, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	diags = resp.State.Set(ctx, plan)
+	resp.Diagnostics.Append(diags...)
+}
+
+// Delete deletes the resource and removes the Terraform state on success.
+func (r *UserResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "UserResource.Delete")
+	defer span.End()
+
+	var state UserResourceModel
+	diags := req.State.Get(ctx, &state)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	client, release, err := r.getClient(ctx, state.SSH, state.Connection, state.DedicatedConnection)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error creating SSH client",
+			ssh.ConnectionErrorDetail(err),
+		)
+		return
+	}
+	defer release()
+
+	if state.UseSudo.ValueBool() {
+		ctx = ssh.WithSudo(ctx)
+	}
+
+	err = client.DeleteUser(ctx, state.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Error deleting user",
+			fmt.Sprintf("Could not delete user: %s", err),
+		)
+		return
+	}
+}
+
+func (r *UserResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	providerData, err := ssh.ProviderDataFrom(req.ProviderData)
+	if err != nil {
+		resp.Diagnostics.AddError(
+			"Unexpected provider data",
+			fmt.Sprintf("Could not configure with the provider data: %s", err),
+		)
+		return
+	}
+	if providerData == nil {
+		return
+	}
+
+	r.connections = providerData.Connections
+}
+
+// readUser fills the computed attributes of model from the remote user account
+func (r *UserResource) readUser(ctx context.Context, client *ssh.SSHClient, model *UserResourceModel) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	user, err := client.GetUser(ctx, model.Name.ValueString())
+	if err != nil {
+		diags.AddError(
+			"Error reading user",
+			fmt.Sprintf("Could not read user: %s", err),
+		)
+		return diags
+	}
+	if user == nil {
+		diags.AddError(
+			"Error reading user",
+			fmt.Sprintf("User %s does not exist after applying changes", model.Name.ValueString()),
+		)
+		return diags
+	}
+
+	return setUserState(ctx, user, model)
+}
+
+// userSpec converts the planned attributes into a user specification
+func userSpec(ctx context.Context, plan UserResourceModel) (ssh.UserSpec, diag.Diagnostics) {
+	// Unknown values are left to the system; their ValueX accessors would return zero values
+	spec := ssh.UserSpec{
+		Name: plan.Name.ValueString(),
+	}
+	if !plan.UID.IsUnknown() {
+		spec.UID = plan.UID.ValueInt64Pointer()
+	}
+	if !plan.Home.IsUnknown() {
+		spec.Home = plan.Home.ValueString()
+	}
+	if !plan.Shell.IsUnknown() {
+		spec.Shell = plan.Shell.ValueString()
+	}
+
+	var diags diag.Diagnostics
+	if !plan.Groups.IsNull() && !plan.Groups.IsUnknown() {
+		spec.Groups = []string{}
+		diags = plan.Groups.ElementsAs(ctx, &spec.Groups, false)
+	}
+
+	return spec, diags
+}
+
+// setUserState copies the remote user account into the model
+func setUserState(ctx context.Context, user *ssh.User, model *UserResourceModel) diag.Diagnostics {
+	model.UID = basetypes.NewInt64Value(user.UID)
+	model.GID = basetypes.NewInt64Value(user.GID)
+	model.Home = basetypes.NewStringValue(user.Home)
+	model.Shell = basetypes.NewStringValue(user.Shell)
+
+	// Only track group membership if it is managed
+	if model.Groups.IsNull() {
+		return nil
+	}
+	groups, diags := types.SetValueFrom(ctx, types.StringType, user.Groups)
+	model.Groups = groups
+	return diags
+}
+
+func (r *UserResource) getClient(ctx context.Context, sshBlock *ssh.SSHBlockModel, connection types.String, dedicated types.Bool) (*ssh.SSHClient, func(), error) {
+	config, err := r.connections.Config(sshBlock, connection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return r.pool.Acquire(ctx, config, dedicated.ValueBool())
+}
@@ -0,0 +1,200 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+// msgKexInit is the SSH message number of SSH_MSG_KEXINIT (RFC 4253, section 7.1)
+const msgKexInit = 20
+
+// aeadCiphers don't use a separate MAC, so no MAC is negotiated for them
+var aeadCiphers = map[string]bool{
+	"aes128-gcm@openssh.com":        true,
+	"aes256-gcm@openssh.com":        true,
+	"chacha20-poly1305@openssh.com": true,
+}
+
+// NegotiatedAlgorithms are the algorithms agreed on during the initial key exchange of a connection
+type NegotiatedAlgorithms struct {
+	KeyExchange        string
+	HostKey            string
+	CipherClientServer string
+	CipherServerClient string
+	// MACClientServer and MACServerClient are empty if the cipher is an AEAD cipher with built-in integrity
+	MACClientServer string
+	MACServerClient string
+}
+
+// kexInit holds the algorithm name-lists of an SSH_MSG_KEXINIT message
+type kexInit struct {
+	kexAlgos            []string
+	hostKeyAlgos        []string
+	ciphersClientServer []string
+	ciphersServerClient []string
+	macsClientServer    []string
+	macsServerClient    []string
+}
+
+// kexInitRecorder wraps the connection an SSH client is created on and records the first
+// SSH_MSG_KEXINIT sent in each direction. The ssh package doesn't expose the negotiated algorithms,
+// but the initial key exchange is unencrypted, so they can be derived from both messages.
+type kexInitRecorder struct {
+	net.Conn
+
+	mu      sync.Mutex
+	read    kexInitStream
+	written kexInitStream
+}
+
+// kexInitStream collects the bytes of one direction until its SSH_MSG_KEXINIT is complete
+type kexInitStream struct {
+	buf  bytes.Buffer
+	msg  *kexInit
+	done bool
+}
+
+func newKexInitRecorder(conn net.Conn) *kexInitRecorder {
+	return &kexInitRecorder{Conn: conn}
+}
+
+func (r *kexInitRecorder) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	r.mu.Lock()
+	r.read.record(p[:n])
+	r.mu.Unlock()
+	return n, err
+}
+
+func (r *kexInitRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	r.written.record(p)
+	r.mu.Unlock()
+	return r.Conn.Write(p)
+}
+
+// algorithms returns the algorithms negotiated from the recorded messages, using the same rules
+// as the ssh package: the first algorithm of the client's list that the server supports is chosen
+func (r *kexInitRecorder) algorithms() (NegotiatedAlgorithms, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, server := r.written.msg, r.read.msg
+	if client == nil || server == nil {
+		return NegotiatedAlgorithms{}, errors.New("key exchange was not recorded")
+	}
+
+	algs := NegotiatedAlgorithms{
+		KeyExchange:        findCommonAlgorithm(client.kexAlgos, server.kexAlgos),
+		HostKey:            findCommonAlgorithm(client.hostKeyAlgos, server.hostKeyAlgos),
+		CipherClientServer: findCommonAlgorithm(client.ciphersClientServer, server.ciphersClientServer),
+		CipherServerClient: findCommonAlgorithm(client.ciphersServerClient, server.ciphersServerClient),
+	}
+	if !aeadCiphers[algs.CipherClientServer] {
+		algs.MACClientServer = findCommonAlgorithm(client.macsClientServer, server.macsClientServer)
+	}
+	if !aeadCiphers[algs.CipherServerClient] {
+		algs.MACServerClient = findCommonAlgorithm(client.macsServerClient, server.macsServerClient)
+	}
+	return algs, nil
+}
+
+// record appends data until the first SSH_MSG_KEXINIT has been parsed. Parse errors stop recording,
+// they must not affect the connection itself.
+func (s *kexInitStream) record(data []byte) {
+	if s.done || len(data) == 0 {
+		return
+	}
+
+	s.buf.Write(data)
+	msg, complete, err := parseKexInitStream(s.buf.Bytes())
+	if err != nil || complete {
+		s.msg = msg
+		s.done = true
+		s.buf = bytes.Buffer{}
+	}
+}
+
+// parseKexInitStream parses the start of an SSH byte stream: the identification line, optionally
+// preceded by other lines sent by a server, followed by the first binary packet, which is the
+// SSH_MSG_KEXINIT. complete is false if more data is needed.
+func parseKexInitStream(stream []byte) (msg *kexInit, complete bool, err error) {
+	// Skip lines up to and including the identification line
+	for {
+		end := bytes.IndexByte(stream, '\n')
+		if end < 0 {
+			return nil, false, nil
+		}
+		line := stream[:end]
+		stream = stream[end+1:]
+		if bytes.HasPrefix(line, []byte("SSH-")) {
+			break
+		}
+	}
+
+	if len(stream) < 5 {
+		return nil, false, nil
+	}
+	packetLength := binary.BigEndian.Uint32(stream)
+	paddingLength := uint32(stream[4])
+	if packetLength < paddingLength+1 || packetLength > 256*1024 {
+		return nil, false, errors.New("invalid packet length")
+	}
+	if uint32(len(stream)-4) < packetLength {
+		return nil, false, nil
+	}
+
+	payload := stream[5 : 4+packetLength-paddingLength]
+	msg, err = parseKexInit(payload)
+	return msg, true, err
+}
+
+// parseKexInit parses the payload of an SSH_MSG_KEXINIT (RFC 4253, section 7.1)
+func parseKexInit(payload []byte) (*kexInit, error) {
+	// Message number and 16 byte cookie
+	if len(payload) < 17 || payload[0] != msgKexInit {
+		return nil, errors.New("first packet is not a key exchange init message")
+	}
+	payload = payload[17:]
+
+	lists := make([][]string, 6)
+	for i := range lists {
+		if len(payload) < 4 {
+			return nil, errors.New("truncated name-list")
+		}
+		length := binary.BigEndian.Uint32(payload)
+		payload = payload[4:]
+		if uint32(len(payload)) < length {
+			return nil, errors.New("truncated name-list")
+		}
+		if length > 0 {
+			lists[i] = strings.Split(string(payload[:length]), ",")
+		}
+		payload = payload[length:]
+	}
+
+	return &kexInit{
+		kexAlgos:            lists[0],
+		hostKeyAlgos:        lists[1],
+		ciphersClientServer: lists[2],
+		ciphersServerClient: lists[3],
+		macsClientServer:    lists[4],
+		macsServerClient:    lists[5],
+	}, nil
+}
+
+// findCommonAlgorithm returns the first client algorithm that the server supports, or an empty string
+func findCommonAlgorithm(client []string, server []string) string {
+	for _, c := range client {
+		for _, s := range server {
+			if c == s {
+				return c
+			}
+		}
+	}
+	return ""
+}
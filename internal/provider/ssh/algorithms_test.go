@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestKexInitRecorderAlgorithms(t *testing.T) {
+	RegisterTestingT(t)
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	Expect(err).ToNot(HaveOccurred())
+
+	negotiate := func(cipher string) NegotiatedAlgorithms {
+		serverConfig := &ssh.ServerConfig{
+			NoClientAuth: true,
+			Config: ssh.Config{
+				Ciphers: []string{cipher},
+				MACs:    []string{"hmac-sha2-512"},
+			},
+		}
+		serverConfig.AddHostKey(signer)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer listener.Close()
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			_, _, _, _ = ssh.NewServerConn(conn, serverConfig)
+		}()
+
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		recorder := newKexInitRecorder(conn)
+		clientConn, _, _, err := ssh.NewClientConn(recorder, listener.Addr().String(), &ssh.ClientConfig{
+			User:            "test",
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer clientConn.Close()
+
+		algorithms, err := recorder.algorithms()
+		Expect(err).ToNot(HaveOccurred())
+		return algorithms
+	}
+
+	t.Log("Cipher with a separate MAC")
+	algorithms := negotiate("aes128-ctr")
+	Expect(algorithms.HostKey).To(Equal(ssh.KeyAlgoED25519))
+	Expect(algorithms.KeyExchange).ToNot(BeEmpty())
+	Expect(algorithms.CipherClientServer).To(Equal("aes128-ctr"))
+	Expect(algorithms.CipherServerClient).To(Equal("aes128-ctr"))
+	Expect(algorithms.MACClientServer).To(Equal("hmac-sha2-512"))
+	Expect(algorithms.MACServerClient).To(Equal("hmac-sha2-512"))
+
+	t.Log("AEAD cipher without MAC")
+	algorithms = negotiate("chacha20-poly1305@openssh.com")
+	Expect(algorithms.CipherClientServer).To(Equal("chacha20-poly1305@openssh.com"))
+	Expect(algorithms.MACClientServer).To(BeEmpty())
+	Expect(algorithms.MACServerClient).To(BeEmpty())
+}
+
+func TestParseKexInitStream(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Log("Incomplete streams need more data")
+	for _, stream := range []string{"", "SSH-2.0-OpenSSH_9.6", "SSH-2.0-OpenSSH_9.6\r\n", "banner\r\nSSH-2.0-OpenSSH_9.6\r\n\x00\x00\x01"} {
+		_, complete, err := parseKexInitStream([]byte(stream))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(complete).To(BeFalse(), "stream %q", stream)
+	}
+
+	t.Log("A first packet other than SSH_MSG_KEXINIT is an error")
+	_, _, err := parseKexInitStream([]byte("SSH-2.0-OpenSSH_9.6\r\n\x00\x00\x00\x0c\x0a\x05abcdefghijk"))
+	Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,139 @@
+package ssh
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// authTracker records the authentication methods the client configured and the ones the
+// server let it attempt. The method attempted last is the one the server accepted once the
+// handshake has succeeded.
+type authTracker struct {
+	mu         sync.Mutex
+	last       string
+	keys       int
+	configured []string
+	offered    []string
+}
+
+// configure records an authentication method the client is able to use, and for public key
+// authentication the number of keys it offers
+func (t *authTracker) configure(method string, keys int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.configured = append(t.configured, method)
+	t.keys += keys
+}
+
+// attempt records that the server let the client attempt an authentication method. method
+// is the name used in the SSH protocol, description is logged.
+func (t *authTracker) attempt(method string, description string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last = description
+	if !slices.Contains(t.offered, method) {
+		t.offered = append(t.offered, method)
+	}
+}
+
+func (t *authTracker) method() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+// authError wraps a failed authentication in an AuthError that describes what the client
+// offered and the server rejected
+func (t *authTracker) authError(username string, err error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &AuthError{
+		Username:        username,
+		Configured:      slices.Clone(t.configured),
+		Offered:         slices.Clone(t.offered),
+		Rejected:        rejectedAuthMethods(err),
+		Keys:            t.keys,
+		TooManyAttempts: strings.Contains(err.Error(), tooManyAuthFailures),
+		err:             err,
+	}
+}
+
+// authMethods builds the authentication methods in the order they are tried: public keys
+// (private_key, private_key_path or private_key_env, then private_keys, then the SSH agent's
+// keys) and then the password. The returned function releases the agent connection and must
+// be called once the connection has been established.
+func authMethods(config SSHConfig, tracker *authTracker) ([]ssh.AuthMethod, func(), error) {
+	var signers []ssh.Signer
+	release := func() {}
+
+	privateKey, err := loadPrivateKey(config)
+	if err != nil {
+		return nil, release, err
+	}
+	if privateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+		if err != nil {
+			return nil, release, fmt.Errorf("failed to parse private key: %w", err)
+		}
+		signers = append(signers, signer)
+	}
+
+	for i, key := range config.PrivateKeys {
+		signer, err := ssh.ParsePrivateKey([]byte(key))
+		if err != nil {
+			return nil, release, fmt.Errorf("failed to parse private key %d of private_keys: %w", i, err)
+		}
+		signers = append(signers, signer)
+	}
+
+	if config.UseAgent {
+		socket := os.Getenv("SSH_AUTH_SOCK")
+		if socket == "" {
+			return nil, release, fmt.Errorf("SSH agent requested but SSH_AUTH_SOCK is not set")
+		}
+		conn, err := net.Dial("unix", socket)
+		if err != nil {
+			return nil, release, fmt.Errorf("failed to connect to SSH agent: %w", err)
+		}
+		release = func() { conn.Close() }
+
+		agentSigners, err := agent.NewClient(conn).Signers()
+		if err != nil {
+			release()
+			return nil, func() {}, fmt.Errorf("failed to list SSH agent keys: %w", err)
+		}
+		signers = append(signers, agentSigners...)
+	}
+
+	// The SSH client tries each method type only once, so all keys have to be offered by a single method
+	var methods []ssh.AuthMethod
+	if len(signers) > 0 {
+		tracker.configure("publickey", len(signers))
+		methods = append(methods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+			tracker.attempt("publickey", fmt.Sprintf("public key (%d keys offered)", len(signers)))
+			return signers, nil
+		}))
+	}
+	if config.Password != "" {
+		tracker.configure("password", 0)
+		methods = append(methods, ssh.PasswordCallback(func() (string, error) {
+			tracker.attempt("password", "password")
+			return config.Password, nil
+		}))
+	}
+
+	if len(methods) == 0 {
+		release()
+		return nil, func() {}, fmt.Errorf("no authentication method provided")
+	}
+
+	return methods, release, nil
+}
@@ -0,0 +1,111 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultAuthorizedKeysPath is the authorized_keys file of the SSH user
+const DefaultAuthorizedKeysPath = "~/.ssh/authorized_keys"
+
+// AuthorizedKey is an entry of an authorized_keys file
+type AuthorizedKey struct {
+	Line        int      // Line number in the file, starting at 1
+	Type        string   // Key type, e.g. "ssh-ed25519"
+	Key         string   // Base64 encoded key material
+	Comment     string   // Comment after the key, usually user@host
+	Options     []string // Options before the key type, e.g. `from="10.0.0.0/8"` or "no-pty"
+	Fingerprint string   // SHA256 fingerprint of the key
+	PublicKey   ssh.PublicKey
+}
+
+// ParseAuthorizedKeys parses the entries of an authorized_keys file in the order they appear. Empty lines
+// and comments are skipped. Lines that can't be parsed are returned as errors with their line number,
+// so that the valid entries can still be used.
+func ParseAuthorizedKeys(content string) ([]AuthorizedKey, []error) {
+	var keys []AuthorizedKey
+	var errs []error
+	for i, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		publicKey, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(trimmed))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", i+1, err))
+			continue
+		}
+		keys = append(keys, AuthorizedKey{
+			Line:        i + 1,
+			Type:        publicKey.Type(),
+			Key:         base64.StdEncoding.EncodeToString(publicKey.Marshal()),
+			Comment:     comment,
+			Options:     options,
+			Fingerprint: ssh.FingerprintSHA256(publicKey),
+			PublicKey:   publicKey,
+		})
+	}
+	return keys, errs
+}
+
+// AuthorizedKeyLine formats an authorized_keys entry from its options, key and comment
+func AuthorizedKeyLine(options []string, publicKey ssh.PublicKey, comment string) string {
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+	if len(options) > 0 {
+		line = strings.Join(options, ",") + " " + line
+	}
+	if comment != "" {
+		line += " " + comment
+	}
+	return line
+}
+
+// SetAuthorizedKey returns the authorized_keys content with the entries of publicKey replaced by line.
+// The first entry of the key is replaced and further entries of the same key are removed; line is
+// appended if the key has no entry yet. An empty line removes all entries of the key. Other lines,
+// including comments, are kept as they are. changed reports whether any entry was changed.
+func SetAuthorizedKey(content string, publicKey ssh.PublicKey, line string) (updated string, changed bool) {
+	keyBytes := publicKey.Marshal()
+
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if content == "" {
+		lines = nil
+	}
+
+	result := make([]string, 0, len(lines)+1)
+	found := false
+	for _, existing := range lines {
+		trimmed := strings.TrimSpace(existing)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(trimmed))
+			if err == nil && bytes.Equal(key.Marshal(), keyBytes) {
+				if !found && line != "" {
+					result = append(result, line)
+					changed = changed || existing != line
+				} else {
+					changed = true
+				}
+				found = true
+				continue
+			}
+		}
+		result = append(result, existing)
+	}
+	if !found && line != "" {
+		result = append(result, line)
+		changed = true
+	}
+	if !changed {
+		return content, false
+	}
+
+	if len(result) > 0 {
+		updated = strings.Join(result, "\n") + "\n"
+	}
+	return updated, true
+}
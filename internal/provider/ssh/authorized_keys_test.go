@@ -0,0 +1,95 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParseAuthorizedKeys(t *testing.T) {
+	RegisterTestingT(t)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	key, err := ssh.NewPublicKey(pub)
+	Expect(err).ToNot(HaveOccurred())
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+
+	content := "# deploy keys\n" +
+		authorizedKey + " deploy@example.com\n" +
+		"\n" +
+		"not a key\n" +
+		`from="10.0.0.0/8",no-pty ` + authorizedKey + "\n"
+
+	keys, errs := ParseAuthorizedKeys(content)
+
+	t.Log("Comments and empty lines are skipped, keys keep their line number")
+	Expect(keys).To(HaveLen(2))
+	Expect(keys[0].Line).To(Equal(2))
+	Expect(keys[0].Type).To(Equal(ssh.KeyAlgoED25519))
+	Expect(keys[0].Type + " " + keys[0].Key).To(Equal(authorizedKey))
+	Expect(keys[0].Comment).To(Equal("deploy@example.com"))
+	Expect(keys[0].Options).To(BeEmpty())
+	Expect(keys[0].Fingerprint).To(Equal(ssh.FingerprintSHA256(key)))
+
+	t.Log("Options are parsed")
+	Expect(keys[1].Line).To(Equal(5))
+	Expect(keys[1].Options).To(Equal([]string{`from="10.0.0.0/8"`, "no-pty"}))
+	Expect(keys[1].Comment).To(BeEmpty())
+
+	t.Log("Invalid lines are reported with their line number")
+	Expect(errs).To(HaveLen(1))
+	Expect(errs[0]).To(MatchError(ContainSubstring("line 4")))
+}
+
+func TestSetAuthorizedKey(t *testing.T) {
+	RegisterTestingT(t)
+
+	newKey := func() ssh.PublicKey {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).ToNot(HaveOccurred())
+		key, err := ssh.NewPublicKey(pub)
+		Expect(err).ToNot(HaveOccurred())
+		return key
+	}
+	key, other := newKey(), newKey()
+	line := AuthorizedKeyLine([]string{"no-pty"}, key, "deploy@example.com")
+	otherLine := AuthorizedKeyLine(nil, other, "other@example.com")
+	Expect(line).To(HavePrefix("no-pty ssh-ed25519 "))
+	Expect(line).To(HaveSuffix(" deploy@example.com"))
+
+	t.Log("A missing key is appended")
+	updated, changed := SetAuthorizedKey("", key, line)
+	Expect(changed).To(BeTrue())
+	Expect(updated).To(Equal(line + "\n"))
+	updated, changed = SetAuthorizedKey("# keys\n"+otherLine, key, line)
+	Expect(changed).To(BeTrue())
+	Expect(updated).To(Equal("# keys\n" + otherLine + "\n" + line + "\n"))
+
+	t.Log("A present key is left alone")
+	content := otherLine + "\n" + line
+	updated, changed = SetAuthorizedKey(content, key, line)
+	Expect(changed).To(BeFalse())
+	Expect(updated).To(Equal(content))
+
+	t.Log("The first entry of the key is replaced in place and duplicates are removed")
+	plain := AuthorizedKeyLine(nil, key, "")
+	updated, changed = SetAuthorizedKey(plain+"\n"+otherLine+"\n"+line+"\n", key, line)
+	Expect(changed).To(BeTrue())
+	Expect(updated).To(Equal(line + "\n" + otherLine + "\n"))
+
+	t.Log("An empty line removes all entries of the key")
+	updated, changed = SetAuthorizedKey(plain+"\n"+otherLine+"\n"+line+"\n", key, "")
+	Expect(changed).To(BeTrue())
+	Expect(updated).To(Equal(otherLine + "\n"))
+	updated, changed = SetAuthorizedKey(otherLine, key, "")
+	Expect(changed).To(BeFalse())
+	Expect(updated).To(Equal(otherLine))
+	updated, changed = SetAuthorizedKey(line+"\n", key, "")
+	Expect(changed).To(BeTrue())
+	Expect(updated).To(BeEmpty())
+}
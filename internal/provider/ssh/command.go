@@ -0,0 +1,235 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/ssh"
+)
+
+type sudoContextKey struct{}
+
+// WithSudo returns a context in which remote commands and privileged file operations run through sudo.
+// The remote user must be allowed to run sudo without a password prompt.
+func WithSudo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sudoContextKey{}, true)
+}
+
+// usesSudo reports whether the context requests privileged operations
+func usesSudo(ctx context.Context) bool {
+	sudo, _ := ctx.Value(sudoContextKey{}).(bool)
+	return sudo
+}
+
+type environmentContextKey struct{}
+
+// WithEnvironment returns a context in which remote commands run with the given environment variables.
+// Many servers only accept a few variables through AcceptEnv in sshd_config, so they are passed by
+// prefixing the command with env instead of being sent with the session.
+func WithEnvironment(ctx context.Context, env map[string]string) context.Context {
+	return context.WithValue(ctx, environmentContextKey{}, env)
+}
+
+// commandEnvironment returns the environment variables requested by the context
+func commandEnvironment(ctx context.Context) map[string]string {
+	env, _ := ctx.Value(environmentContextKey{}).(map[string]string)
+	return env
+}
+
+type shellContextKey struct{}
+
+// WithShell returns a context in which remote commands run in the given shell, e.g. "/bin/bash", instead of
+// the login shell of the remote user. The command is passed to the shell with -c, so commands behave the
+// same on hosts with different login shells. The shell must be an absolute path, see ValidateShell.
+func WithShell(ctx context.Context, shell string) context.Context {
+	return context.WithValue(ctx, shellContextKey{}, shell)
+}
+
+// commandShell returns the shell requested by the context, empty for the login shell
+func commandShell(ctx context.Context) string {
+	shell, _ := ctx.Value(shellContextKey{}).(string)
+	return shell
+}
+
+type ptyContextKey struct{}
+
+// ptyModes are the terminal modes of a PTY requested for a command. Echo is disabled, since nothing is
+// typed, and newlines are not translated to "\r\n", so the output looks like the output without a PTY.
+var ptyModes = ssh.TerminalModes{
+	ssh.ECHO:          0,
+	ssh.ONLCR:         0,
+	ssh.TTY_OP_ISPEED: 14400,
+	ssh.TTY_OP_OSPEED: 14400,
+}
+
+// WithPTY returns a context in which remote commands run with a pseudo terminal, for commands that
+// refuse to run without one. The terminal merges the error output into the standard output.
+func WithPTY(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ptyContextKey{}, true)
+}
+
+// usesPTY reports whether the context requests a pseudo terminal for commands
+func usesPTY(ctx context.Context) bool {
+	pty, _ := ctx.Value(ptyContextKey{}).(bool)
+	return pty
+}
+
+// RunCommand runs a shell command on the remote host and returns its standard output. The output is returned
+// as is, including trailing newlines, so callers that need the exact bytes, e.g. for checksums, get them;
+// callers that compare it with a value trim it themselves. If the command fails, the returned error
+// includes its error output, e.g. "chown: invalid user: 'bob'".
+// If the context was created with WithSudo, the command runs through sudo, if it was created with
+// WithEnvironment, the command runs with the environment variables, if it was created with WithShell, the
+// command runs in the shell, and if it was created with WithPTY, the command runs in a pseudo terminal.
+func (c *SSHClient) RunCommand(ctx context.Context, cmd string) (string, error) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "RunCommand")
+	defer span.End()
+
+	// The timing is recorded on the span and in the debug log, also for failed commands
+	startedAt := time.Now()
+	stdout, _, err := c.runCommand(ctx, cmd)
+	finishedAt := time.Now()
+	duration := finishedAt.Sub(startedAt)
+
+	span.SetAttributes(
+		attribute.String("command.started_at", startedAt.UTC().Format(time.RFC3339Nano)),
+		attribute.String("command.finished_at", finishedAt.UTC().Format(time.RFC3339Nano)),
+		attribute.Int64("command.duration_ms", duration.Milliseconds()),
+	)
+	c.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"started_at":  startedAt.UTC().Format(time.RFC3339Nano),
+		"finished_at": finishedAt.UTC().Format(time.RFC3339Nano),
+		"duration_ms": duration.Milliseconds(),
+		"failed":      err != nil,
+	}).Debug("Command finished")
+
+	return stdout, err
+}
+
+// sessionRetryDelay is the wait before the first retry of a command whose session could not be opened,
+// doubled for every further retry
+const sessionRetryDelay = 100 * time.Millisecond
+
+// withSessionRetry runs op, which runs a remote command, and retries it as configured by
+// SSHConfig.SessionRetries if no session could be opened, e.g. because the server briefly reached
+// MaxSessions under load. Commands that ran and failed are not retried, as they would fail again.
+func withSessionRetry[T any](ctx context.Context, c *SSHClient, op func() (T, error)) (T, error) {
+	delay := sessionRetryDelay
+	for attempt := 0; ; attempt++ {
+		value, err := op()
+		if !errors.Is(err, ErrSession) || attempt >= c.sessionRetries || c.isClosed() {
+			return value, err
+		}
+		c.logger.WithContext(ctx).WithError(err).WithField("retry", attempt+1).Warn("Failed to create SSH session, retrying")
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return value, err
+		}
+		delay *= 2
+	}
+}
+
+// staleHandleRetries is how often an operation that failed on a stale NFS file handle is retried
+const staleHandleRetries = 3
+
+// staleHandleRetryDelay is the wait before the first retry of an operation that failed on a stale NFS
+// file handle, doubled for every further retry
+const staleHandleRetryDelay = 200 * time.Millisecond
+
+// withStaleHandleRetry runs op and retries it if it may have failed on a stale NFS file handle, see
+// mayBeStaleHandle. NFS clients drop stale handles, so running op again looks the path up anew and usually
+// gets a fresh handle, unlike a connection retry, which wouldn't help as the connection is fine. Requests on
+// an already open file can't recover this way, so op must look up the path itself, e.g. stat it or open
+// and read the file, instead of being a single request on an open file.
+func withStaleHandleRetry[T any](ctx context.Context, c *SSHClient, op func() (T, error)) (T, error) {
+	delay := staleHandleRetryDelay
+	for attempt := 0; ; attempt++ {
+		value, err := op()
+		if !mayBeStaleHandle(err) {
+			return value, err
+		}
+		if attempt >= staleHandleRetries {
+			return value, fmt.Errorf("%w, the file may have been replaced on the NFS server (gave up after %d retries)", classifyOperationError(err), staleHandleRetries)
+		}
+		c.logger.WithContext(ctx).WithError(err).WithField("retry", attempt+1).Warn("Stale file handle, retrying")
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return value, err
+		}
+		delay *= 2
+	}
+}
+
+// runCommandWithRetry is RunCommand with withSessionRetry and withStaleHandleRetry, for idempotent commands
+func (c *SSHClient) runCommandWithRetry(ctx context.Context, cmd string) (string, error) {
+	return withStaleHandleRetry(ctx, c, func() (string, error) {
+		return withSessionRetry(ctx, c, func() (string, error) {
+			return c.RunCommand(ctx, cmd)
+		})
+	})
+}
+
+// runCommand runs a shell command on the remote host and returns its standard output and standard error.
+// If the command fails, its error output is included in the returned error.
+func (c *SSHClient) runCommand(ctx context.Context, cmd string) (string, string, error) {
+	session, err := c.newSession()
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to create SSH session")
+		return "", "", fmt.Errorf("%w: %w", ErrSession, err)
+	}
+	defer session.Close()
+
+	shell := commandShell(ctx)
+	if shell != "" {
+		if err := ValidateShell(shell); err != nil {
+			return "", "", err
+		}
+	}
+
+	// The environment is set inside sudo, which would reset it otherwise
+	if env := commandEnvironment(ctx); len(env) > 0 {
+		cmd, err = envCommand(env, shell, cmd)
+		if err != nil {
+			return "", "", err
+		}
+	} else if shell != "" {
+		cmd = shellCommand(shell, cmd)
+	}
+	if usesSudo(ctx) {
+		cmd = "sudo -n sh -c " + shellQuote(cmd)
+	}
+
+	if usesPTY(ctx) {
+		if err := session.RequestPty("xterm", 24, 80, ptyModes); err != nil {
+			c.logger.WithContext(ctx).WithError(err).Error("Failed to request PTY")
+			return "", "", fmt.Errorf("failed to request PTY: %w", err)
+		}
+	}
+
+	var stdout, stderr syncBuffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	if err := c.runSession(ctx, session, cmd); err != nil {
+		// With a PTY, the error output is part of the standard output. A stopped command reports all
+		// output it produced so far.
+		errOutput := stderr.String()
+		if usesPTY(ctx) {
+			errOutput = stdout.String()
+		} else if errors.Is(err, ErrOperationTimeout) || errors.Is(err, context.Canceled) {
+			errOutput = stdout.String() + stderr.String()
+		}
+		return stdout.String(), stderr.String(), commandError(err, errOutput)
+	}
+
+	return stdout.String(), stderr.String(), nil
+}
@@ -0,0 +1,59 @@
+package ssh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestRunCommandTiming(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, _ := newCommandTestClient(t, func(cmd string) commandResult {
+		time.Sleep(50 * time.Millisecond)
+		if cmd == "false" {
+			return commandResult{status: 1}
+		}
+		return commandResult{stdout: "done\n"}
+	})
+	client.logger.SetLevel(logrus.DebugLevel)
+	hook := logtest.NewLocal(client.logger)
+	ctx := context.Background()
+
+	timing := func() logrus.Fields {
+		for _, entry := range hook.AllEntries() {
+			if entry.Message == "Command finished" {
+				return entry.Data
+			}
+		}
+		return nil
+	}
+
+	t.Log("The duration of a command covers its run on the server")
+	before := time.Now().UTC()
+	Expect(client.RunCommand(ctx, "true")).To(Equal("done\n"))
+	fields := timing()
+	Expect(fields).ToNot(BeNil())
+	Expect(fields["duration_ms"]).To(BeNumerically(">=", 50))
+	Expect(fields["failed"]).To(BeFalse())
+
+	startedAt, err := time.Parse(time.RFC3339Nano, fields["started_at"].(string))
+	Expect(err).ToNot(HaveOccurred())
+	finishedAt, err := time.Parse(time.RFC3339Nano, fields["finished_at"].(string))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(startedAt).To(BeTemporally(">=", before))
+	Expect(finishedAt.Sub(startedAt).Milliseconds()).To(BeNumerically("~", fields["duration_ms"], 1))
+
+	t.Log("Failed commands are timed as well")
+	hook.Reset()
+	_, err = client.RunCommand(ctx, "false")
+	Expect(err).To(HaveOccurred())
+	fields = timing()
+	Expect(fields).ToNot(BeNil())
+	Expect(fields["duration_ms"]).To(BeNumerically(">=", 50))
+	Expect(fields["failed"]).To(BeTrue())
+}
@@ -0,0 +1,67 @@
+package ssh
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+// ProviderData is handed from the provider to resources, data sources and ephemeral resources
+type ProviderData struct {
+	// Connections are the named connections configured on the provider
+	Connections Connections
+}
+
+// Connections maps connection names to the SSH block configured for them on the provider
+type Connections map[string]SSHBlockModel
+
+// Config returns the SSH configuration for a resource, which either has its own ssh block
+// or references one of the provider's named connections
+func (c Connections) Config(sshBlock *SSHBlockModel, connection types.String) (SSHConfig, error) {
+	hasConnection := !connection.IsNull() && !connection.IsUnknown()
+
+	switch {
+	case sshBlock != nil && hasConnection:
+		return SSHConfig{}, fmt.Errorf("only one of ssh and connection can be set")
+	case sshBlock != nil:
+		return sshBlock.SSHConfig(), nil
+	case hasConnection:
+		block, ok := c[connection.ValueString()]
+		if !ok {
+			return SSHConfig{}, fmt.Errorf("connection %q is not configured on the provider, available connections: %s", connection.ValueString(), c.names())
+		}
+		return block.SSHConfig(), nil
+	default:
+		return SSHConfig{}, fmt.Errorf("either ssh or connection must be set")
+	}
+}
+
+// names returns the sorted connection names for error messages
+func (c Connections) names() string {
+	if len(c) == 0 {
+		return "none"
+	}
+
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ProviderDataFrom extracts the provider data passed to Configure. It returns nil if the
+// provider is not configured yet.
+func ProviderDataFrom(providerData any) (*ProviderData, error) {
+	if providerData == nil {
+		return nil, nil
+	}
+
+	data, ok := providerData.(*ProviderData)
+	if !ok {
+		return nil, fmt.Errorf("expected *ssh.ProviderData, got %T", providerData)
+	}
+	return data, nil
+}
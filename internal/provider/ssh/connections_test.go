@@ -0,0 +1,57 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	. "github.com/onsi/gomega"
+)
+
+func TestConnectionsConfig(t *testing.T) {
+	RegisterTestingT(t)
+
+	connections := Connections{
+		"web": SSHBlockModel{
+			Host:     types.StringValue("web.example.com"),
+			Username: types.StringValue("deploy"),
+		},
+	}
+	block := &SSHBlockModel{
+		Host:     types.StringValue("db.example.com"),
+		Username: types.StringValue("admin"),
+	}
+
+	config, err := connections.Config(nil, types.StringValue("web"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(config.Host).To(Equal("web.example.com"))
+	Expect(config.Username).To(Equal("deploy"))
+
+	config, err = connections.Config(block, types.StringNull())
+	Expect(err).NotTo(HaveOccurred())
+	Expect(config.Host).To(Equal("db.example.com"))
+
+	_, err = connections.Config(block, types.StringValue("web"))
+	Expect(err).To(MatchError(ContainSubstring("only one of ssh and connection")))
+
+	_, err = connections.Config(nil, types.StringNull())
+	Expect(err).To(MatchError(ContainSubstring("either ssh or connection")))
+
+	_, err = connections.Config(nil, types.StringValue("missing"))
+	Expect(err).To(MatchError(ContainSubstring(`connection "missing" is not configured on the provider, available connections: web`)))
+}
+
+func TestProviderDataFrom(t *testing.T) {
+	RegisterTestingT(t)
+
+	data, err := ProviderDataFrom(nil)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(data).To(BeNil())
+
+	expected := &ProviderData{}
+	data, err = ProviderDataFrom(expected)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(data).To(BeIdenticalTo(expected))
+
+	_, err = ProviderDataFrom("invalid")
+	Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,89 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+)
+
+// DefaultEnvironmentShell is the shell ReadEnvironment uses if none is given
+const DefaultEnvironmentShell = "/bin/bash"
+
+// ReadEnvironment reads environment variables as a shell on the remote host sees them and returns the
+// values of those that are set. Commands run over SSH are non-interactive, so shells don't read their
+// profiles. With login set, the shell runs as a login shell (-l), which reads /etc/profile and the
+// profile of the user, e.g. ~/.bash_profile or ~/.profile for bash.
+func (c *SSHClient) ReadEnvironment(ctx context.Context, shell string, login bool, names []string) (map[string]string, error) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "ReadEnvironment")
+	defer span.End()
+
+	if shell == "" {
+		shell = DefaultEnvironmentShell
+	}
+	if err := ValidateShell(shell); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := ValidateEnvName(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// Profiles may print to standard output, so the values are enclosed in a marker that they can't print
+	marker := "terraform-provider-ssh-environment-" + rand.Text()
+	cmd := shellQuote(shell)
+	if login {
+		cmd += " -l"
+	}
+	cmd += " -c " + shellQuote(environmentScript(marker, names))
+
+	output, err := c.RunCommand(ctx, cmd)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to read environment")
+		return nil, fmt.Errorf("failed to read environment with %s: %w", shell, err)
+	}
+
+	return parseEnvironmentOutput(output, marker)
+}
+
+// environmentScript prints a NAME=value entry for each variable that is set between two markers. The
+// entries end with a NUL byte, so values may contain newlines.
+func environmentScript(marker string, names []string) string {
+	var script strings.Builder
+	script.WriteString("printf '%s\\0' " + shellQuote(marker) + "\n")
+	for _, name := range names {
+		fmt.Fprintf(&script, "if [ \"${%s+set}\" = set ]; then printf '%%s=%%s\\0' %s \"$%s\"; fi\n", name, name, name)
+	}
+	script.WriteString("printf '%s\\0' " + shellQuote(marker) + "\n")
+	return script.String()
+}
+
+// parseEnvironmentOutput parses the output of environmentScript, ignoring anything printed around the
+// markers, e.g. by ~/.bash_logout
+func parseEnvironmentOutput(output string, marker string) (map[string]string, error) {
+	_, entries, found := strings.Cut(output, marker+"\x00")
+	if found {
+		entries, _, found = strings.Cut(entries, marker+"\x00")
+	}
+	if !found {
+		return nil, fmt.Errorf("failed to parse environment: output doesn't contain the values")
+	}
+
+	values := make(map[string]string)
+	for entries != "" {
+		entry, rest, found := strings.Cut(entries, "\x00")
+		if !found {
+			return nil, fmt.Errorf("failed to parse environment: incomplete entry %q", entry)
+		}
+		name, value, found := strings.Cut(entry, "=")
+		if !found {
+			return nil, fmt.Errorf("failed to parse environment: invalid entry %q", entry)
+		}
+		values[name] = value
+		entries = rest
+	}
+	return values, nil
+}
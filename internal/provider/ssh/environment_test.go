@@ -0,0 +1,50 @@
+package ssh
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseEnvironmentOutput(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Log("Output around the markers is ignored and values may contain newlines")
+	values, err := parseEnvironmentOutput("Welcome!\nmarker\x00PATH=/usr/bin\x00MULTI=a\nb=c\x00EMPTY=\x00marker\x00logout\n", "marker")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(Equal(map[string]string{"PATH": "/usr/bin", "MULTI": "a\nb=c", "EMPTY": ""}))
+
+	t.Log("No variables are set")
+	values, err = parseEnvironmentOutput("marker\x00marker\x00", "marker")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(BeEmpty())
+
+	t.Log("Reject output without both markers or with invalid entries")
+	for _, output := range []string{"", "marker\x00PATH=/usr/bin\x00", "marker\x00PATH\x00marker\x00"} {
+		_, err := parseEnvironmentOutput(output, "marker")
+		Expect(err).To(HaveOccurred(), "output %q", output)
+	}
+}
+
+func TestReadEnvironment(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	t.Log("Read variables from a login shell, leaving out unset ones")
+	values, err := client.ReadEnvironment(ctx, "/bin/sh", true, []string{"HOME", "PATH", "TERRAFORM_PROVIDER_SSH_UNSET"})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(values).To(HaveKeyWithValue("HOME", "/home/testuser"))
+	Expect(values).To(HaveKey("PATH"))
+	Expect(values).ToNot(HaveKey("TERRAFORM_PROVIDER_SSH_UNSET"))
+
+	t.Log("Reject invalid names and shells before running anything")
+	_, err = client.ReadEnvironment(ctx, "/bin/sh", true, []string{"$(id)"})
+	Expect(err).To(HaveOccurred())
+	_, err = client.ReadEnvironment(ctx, "sh", false, []string{"HOME"})
+	Expect(err).To(HaveOccurred())
+}
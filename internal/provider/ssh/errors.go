@@ -0,0 +1,273 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/sftp"
+)
+
+// Errors that classify why a connection or an operation failed. They are wrapped together with
+// the underlying error, so callers can check them with errors.Is.
+var (
+	ErrAuth            = errors.New("authentication failed")
+	ErrHostUnreachable = errors.New("host unreachable")
+	ErrHostKey         = errors.New("host key verification failed")
+	ErrPermission      = errors.New("permission denied")
+	ErrFileTooLarge    = errors.New("file too large")
+	ErrContentInvalid  = errors.New("content validation failed")
+	ErrIsDirectory     = errors.New("path is a directory")
+	ErrNotDirectory    = errors.New("path is not a directory")
+	ErrParentNotFound  = errors.New("parent directory does not exist")
+	ErrConnectionLost  = errors.New("connection lost")
+	ErrChecksum        = errors.New("checksum mismatch")
+	ErrBadPattern      = path.ErrBadPattern
+	// ErrSession marks commands that didn't run because no session could be opened, as opposed to
+	// commands that ran and failed
+	ErrSession = errors.New("failed to create SSH session")
+	// ErrAttributeMismatch marks attribute changes that chattr reported as applied but that lsattr doesn't show
+	ErrAttributeMismatch = errors.New("file attributes not applied")
+	// ErrSymlinkLoop marks paths whose symbolic links don't end in a file within maxSymlinkHops links
+	ErrSymlinkLoop = errors.New("too many levels of symbolic links")
+	// ErrCapabilitiesUnsupported marks capability reads on hosts without the libcap tools
+	ErrCapabilitiesUnsupported = errors.New("file capabilities not supported")
+	// ErrStaleHandle marks operations that failed with ESTALE, which NFS reports for files that were
+	// replaced or removed on the NFS server while the connection stays usable
+	ErrStaleHandle = errors.New("stale file handle")
+)
+
+// tooManyAuthFailures is the reason OpenSSH gives when it disconnects a client that exceeded MaxAuthTries
+const tooManyAuthFailures = "Too many authentication failures"
+
+// rejectedMethodsPattern extracts the methods from the error the ssh package returns when authentication failed
+var rejectedMethodsPattern = regexp.MustCompile(`attempted methods \[([^\]]*)\]`)
+
+// AuthError describes a failed authentication: the methods the client was configured with,
+// the ones the server let it attempt and the ones the server rejected. It wraps the error
+// classified with ErrAuth.
+type AuthError struct {
+	Username        string
+	Configured      []string // Methods the client was configured with, e.g. "publickey" and "password"
+	Offered         []string // Configured methods the server let the client attempt
+	Rejected        []string // Methods the server rejected, including "none"
+	Keys            int      // Number of keys offered for public key authentication
+	TooManyAttempts bool     // Whether the server disconnected after too many failed attempts
+	err             error
+}
+
+func (e *AuthError) Error() string {
+	return e.err.Error()
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.err
+}
+
+// Hint explains the likely cause of the failed authentication and how to fix it
+func (e *AuthError) Hint() string {
+	if e.TooManyAttempts {
+		return fmt.Sprintf("The server closed the connection after too many failed authentication attempts. Every offered key counts as one attempt "+
+			"and %d keys were offered, while OpenSSH allows 6 attempts by default (MaxAuthTries). Offer only the key for this server, "+
+			"e.g. with private_key instead of use_agent, or raise MaxAuthTries on the server.", e.Keys)
+	}
+
+	// The ssh package does not report methods that were accepted with partial success
+	for _, method := range e.Offered {
+		if !slices.Contains(e.Rejected, method) {
+			return fmt.Sprintf("The server accepted %s authentication but requires a further method that is not configured "+
+				"(AuthenticationMethods in sshd_config). Configure all methods the server requires.", authMethodName(method))
+		}
+	}
+
+	var hints []string
+	for _, method := range e.Configured {
+		switch {
+		case !slices.Contains(e.Offered, method):
+			hints = append(hints, fmt.Sprintf("The server does not allow %s authentication for user %q. %s",
+				authMethodName(method), e.Username, disabledAuthHint(method)))
+		case method == "publickey":
+			hints = append(hints, fmt.Sprintf("The server rejected all %d offered keys. Check that the public key is listed in "+
+				"~/.ssh/authorized_keys of user %q on the server, and that neither the file nor the home directory is writable "+
+				"by other users, as sshd ignores it then.", e.Keys, e.Username))
+		case method == "password":
+			hints = append(hints, fmt.Sprintf("The server rejected the password for user %q.", e.Username))
+		}
+	}
+
+	if slices.ContainsFunc(e.Configured, func(method string) bool { return slices.Contains(e.Rejected, method) }) {
+		hints = append(hints, fmt.Sprintf("Servers reject unknown users the same way, so also check that the username %q is correct.", e.Username))
+	}
+
+	if len(hints) == 0 {
+		return "The server rejected all offered credentials. Check the username and the configured password or keys."
+	}
+	return strings.Join(hints, "\n\n")
+}
+
+// authMethodName returns a readable name for an authentication method of the SSH protocol
+func authMethodName(method string) string {
+	if method == "publickey" {
+		return "public key"
+	}
+	return method
+}
+
+// disabledAuthHint advises on an authentication method the server does not allow
+func disabledAuthHint(method string) string {
+	if method == "password" {
+		return "Password authentication is probably disabled (PasswordAuthentication no in sshd_config). Use a private key instead."
+	}
+	return "Public key authentication is probably disabled (PubkeyAuthentication no in sshd_config). Use a password instead."
+}
+
+// rejectedAuthMethods returns the authentication methods listed in the error of a failed authentication
+func rejectedAuthMethods(err error) []string {
+	match := rejectedMethodsPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return nil
+	}
+	return strings.Fields(match[1])
+}
+
+// permissionMessages are printed by remote commands and sudo when the user lacks the required permissions
+var permissionMessages = []string{
+	"Permission denied",
+	"Operation not permitted",
+	"a password is required",
+	"is not in the sudoers file",
+}
+
+// staleHandleMessages are the messages of ESTALE in remote command output and SFTP status errors, the
+// latter only from servers that pass on the error message of the remote system
+var staleHandleMessages = []string{
+	"Stale file handle",
+	"Stale NFS file handle",
+}
+
+// isStaleHandle reports whether err or the error output of a command reports a stale NFS file handle
+func isStaleHandle(err error, stderr string) bool {
+	if errors.Is(err, syscall.ESTALE) {
+		return true
+	}
+	for _, message := range staleHandleMessages {
+		if strings.Contains(stderr, message) || strings.Contains(err.Error(), message) {
+			return true
+		}
+	}
+	return false
+}
+
+// mayBeStaleHandle reports whether an operation may have failed on a stale NFS file handle. OpenSSH's
+// sftp-server reports ESTALE as a generic SSH_FX_FAILURE without the message of the remote system, so
+// SFTP requests that failed with it are included.
+func mayBeStaleHandle(err error) bool {
+	if err == nil {
+		return false
+	}
+	var statusErr *sftp.StatusError
+	return errors.Is(err, ErrStaleHandle) || isStaleHandle(err, "") ||
+		(errors.As(err, &statusErr) && statusErr.FxCode() == sftp.ErrSSHFxFailure)
+}
+
+// classifyConnectError marks a failed connection attempt with ErrAuth or ErrHostUnreachable
+func classifyConnectError(err error) error {
+	if err == nil || errors.Is(err, ErrHostKey) || errors.Is(err, ErrHostUnreachable) {
+		return err
+	}
+
+	// The ssh package reports failed authentication as a plain error
+	if strings.Contains(err.Error(), "unable to authenticate") || strings.Contains(err.Error(), tooManyAuthFailures) {
+		return fmt.Errorf("%w: %w", ErrAuth, err)
+	}
+
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return fmt.Errorf("%w: %w", ErrHostUnreachable, err)
+	}
+
+	return err
+}
+
+// classifyOperationError marks a failed SFTP request with ErrPermission if the server denied access
+func classifyOperationError(err error) error {
+	if err == nil || errors.Is(err, ErrPermission) {
+		return err
+	}
+
+	var statusErr *sftp.StatusError
+	if errors.Is(err, os.ErrPermission) ||
+		(errors.As(err, &statusErr) && statusErr.FxCode() == sftp.ErrSSHFxPermissionDenied) {
+		return fmt.Errorf("%w: %w", ErrPermission, err)
+	}
+	if !errors.Is(err, ErrStaleHandle) && isStaleHandle(err, "") {
+		return fmt.Errorf("%w: %w", ErrStaleHandle, err)
+	}
+
+	return err
+}
+
+// classifyCommandError marks a failed remote command with ErrPermission if its error output reports missing permissions
+func classifyCommandError(err error, stderr string) error {
+	if err == nil {
+		return nil
+	}
+
+	for _, message := range permissionMessages {
+		if strings.Contains(stderr, message) {
+			return fmt.Errorf("%w: %w", ErrPermission, err)
+		}
+	}
+	if isStaleHandle(err, stderr) {
+		return fmt.Errorf("%w: %w", ErrStaleHandle, err)
+	}
+
+	return err
+}
+
+// commandError classifies the error of a failed command and adds its error output to the message
+func commandError(err error, stderr string) error {
+	err = classifyCommandError(err, stderr)
+	if msg := strings.TrimSpace(stderr); msg != "" {
+		return fmt.Errorf("%s (%w)", msg, err)
+	}
+	return err
+}
+
+// ConnectionErrorDetail describes a failed connection for a diagnostic, with advice for the known causes
+func ConnectionErrorDetail(err error) string {
+	detail := fmt.Sprintf("Could not create SSH client: %s", err)
+
+	var authErr *AuthError
+	switch {
+	case errors.As(err, &authErr):
+		detail += "\n\n" + authErr.Hint()
+	case errors.Is(err, ErrAuth):
+		detail += "\n\nThe server rejected all offered credentials. Check the username and the configured password or keys."
+	case errors.Is(err, ErrHostKey):
+		detail += "\n\nThe host key of the server could not be verified against known_hosts_file. If the key changed legitimately, update the file."
+	case errors.Is(err, ErrHostUnreachable):
+		detail += "\n\nThe server could not be reached. Check the host, the port and the proxy, and that the SSH server is running."
+	}
+
+	return detail
+}
+
+// FileReadErrorDetail describes why reading file content failed, with a hint for files above the size limit
+func FileReadErrorDetail(err error) string {
+	detail := fmt.Sprintf("Could not read file content: %s", err)
+
+	if errors.Is(err, ErrFileTooLarge) {
+		detail += "\n\nThe file is larger than max_read_size. Increase max_read_size (0 disables the limit) if the content is needed, " +
+			"or use the ssh_file_checksum data source to detect changes without reading the content."
+	}
+
+	return detail
+}
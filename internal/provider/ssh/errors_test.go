@@ -0,0 +1,252 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/sirupsen/logrus"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestClassifyConnectError(t *testing.T) {
+	RegisterTestingT(t)
+
+	authErr := classifyConnectError(fmt.Errorf("ssh: handshake failed: %w", errors.New("ssh: unable to authenticate, attempted methods [none password], no supported methods remain")))
+	Expect(authErr).To(MatchError(ErrAuth))
+	Expect(authErr).To(MatchError(ContainSubstring("unable to authenticate")))
+
+	dialErr := classifyConnectError(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")})
+	Expect(dialErr).To(MatchError(ErrHostUnreachable))
+
+	hostKeyErr := fmt.Errorf("ssh: handshake failed: %w", fmt.Errorf("%w: key mismatch", ErrHostKey))
+	Expect(classifyConnectError(hostKeyErr)).To(Equal(hostKeyErr))
+
+	Expect(classifyConnectError(nil)).To(BeNil())
+}
+
+func TestClassifyOperationError(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(classifyOperationError(os.ErrPermission)).To(MatchError(ErrPermission))
+	Expect(classifyOperationError(os.ErrPermission)).To(MatchError(os.ErrPermission))
+	Expect(classifyOperationError(os.ErrNotExist)).ToNot(MatchError(ErrPermission))
+	Expect(classifyOperationError(syscall.ESTALE)).To(MatchError(ErrStaleHandle))
+	Expect(classifyOperationError(errors.New("sftp: \"Stale file handle\" (SSH_FX_FAILURE)"))).To(MatchError(ErrStaleHandle))
+	Expect(classifyOperationError(errors.New("sftp: \"Failure\" (SSH_FX_FAILURE)"))).ToNot(MatchError(ErrStaleHandle))
+	Expect(classifyOperationError(nil)).To(BeNil())
+}
+
+func TestClassifyCommandError(t *testing.T) {
+	RegisterTestingT(t)
+
+	err := errors.New("Process exited with status 1")
+	Expect(classifyCommandError(err, "chown: /etc/passwd: Operation not permitted\n")).To(MatchError(ErrPermission))
+	Expect(classifyCommandError(err, "sudo: a password is required\n")).To(MatchError(ErrPermission))
+	Expect(classifyCommandError(err, "chown: unknown user bob\n")).To(Equal(err))
+	Expect(classifyCommandError(err, "cat: /home/bob/.bashrc: Stale file handle\n")).To(MatchError(ErrStaleHandle))
+	Expect(classifyCommandError(nil, "")).To(BeNil())
+}
+
+func TestStaleHandleRetry(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := &SSHClient{logger: logrus.New()}
+
+	t.Log("Retry until the path is looked up without a stale handle")
+	attempts := 0
+	value, err := withStaleHandleRetry(context.Background(), client, func() (string, error) {
+		attempts++
+		if attempts < 3 {
+			return "", syscall.ESTALE
+		}
+		return "content", nil
+	})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(value).To(Equal("content"))
+	Expect(attempts).To(Equal(3))
+
+	t.Log("Give up after the bounded number of retries")
+	attempts = 0
+	_, err = withStaleHandleRetry(context.Background(), client, func() (string, error) {
+		attempts++
+		return "", syscall.ESTALE
+	})
+	Expect(err).To(MatchError(ErrStaleHandle))
+	Expect(err).To(MatchError(ContainSubstring("gave up after 3 retries")))
+	Expect(attempts).To(Equal(staleHandleRetries + 1))
+
+	t.Log("Don't retry other errors")
+	attempts = 0
+	_, err = withStaleHandleRetry(context.Background(), client, func() (string, error) {
+		attempts++
+		return "", os.ErrNotExist
+	})
+	Expect(err).To(MatchError(os.ErrNotExist))
+	Expect(attempts).To(Equal(1))
+
+	t.Log("Single requests are not retried")
+	attempts = 0
+	_, err = withOperationTimeoutValue(context.Background(), client, func() (string, error) {
+		attempts++
+		return "", syscall.ESTALE
+	})
+	Expect(err).To(MatchError(ErrStaleHandle))
+	Expect(attempts).To(Equal(1))
+}
+
+// staleFileSystem is an SFTP request handler for a single file, /file, whose lookups and reads fail with
+// ESTALE a given number of times
+type staleFileSystem struct {
+	content    string
+	staleStats int
+	staleReads int
+	stats      int
+	reads      int
+}
+
+// opensshError is an errno that an SFTP server reports as OpenSSH's sftp-server does, as generic failure
+// without the message of the remote system
+type opensshError struct {
+	syscall.Errno
+}
+
+func (e opensshError) Error() string {
+	return "Failure"
+}
+
+func (e opensshError) Unwrap() error {
+	return e.Errno
+}
+
+func (fs *staleFileSystem) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	fs.stats++
+	if fs.stats <= fs.staleStats {
+		return nil, opensshError{syscall.ESTALE}
+	}
+	return staleFileInfos{staleFileInfo{size: int64(len(fs.content))}}, nil
+}
+
+func (fs *staleFileSystem) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	fs.reads++
+	if fs.reads <= fs.staleReads {
+		return staleReader{}, nil
+	}
+	return strings.NewReader(fs.content), nil
+}
+
+// staleReader is an open file whose handle went stale
+type staleReader struct{}
+
+func (staleReader) ReadAt([]byte, int64) (int, error) {
+	return 0, opensshError{syscall.ESTALE}
+}
+
+type staleFileInfos []os.FileInfo
+
+func (f staleFileInfos) ListAt(list []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(f)) {
+		return 0, io.EOF
+	}
+	return copy(list, f[offset:]), nil
+}
+
+type staleFileInfo struct {
+	size int64
+}
+
+func (i staleFileInfo) Name() string       { return "file" }
+func (i staleFileInfo) Size() int64        { return i.size }
+func (i staleFileInfo) Mode() os.FileMode  { return 0644 }
+func (i staleFileInfo) ModTime() time.Time { return time.Time{} }
+func (i staleFileInfo) IsDir() bool        { return false }
+func (i staleFileInfo) Sys() any           { return nil }
+
+func TestStaleHandleSFTP(t *testing.T) {
+	RegisterTestingT(t)
+
+	fs := &staleFileSystem{content: "content", staleStats: 2, staleReads: 1}
+	serverConn, clientConn := net.Pipe()
+	server := sftp.NewRequestServer(serverConn, sftp.Handlers{FileGet: fs, FileList: fs})
+	go server.Serve()
+	defer server.Close()
+	sftpClient, err := sftp.NewClientPipe(clientConn, clientConn)
+	Expect(err).ToNot(HaveOccurred())
+	defer sftpClient.Close()
+	client := &SSHClient{SftpClient: sftpClient, logger: logrus.New()}
+	ctx := context.Background()
+
+	t.Log("A path is looked up again after a generic failure, as OpenSSH reports ESTALE")
+	Expect(client.Exists(ctx, "/file")).To(BeTrue())
+	Expect(fs.stats).To(Equal(3))
+
+	t.Log("A file whose handle went stale while it's read is opened and read again")
+	Expect(client.ReadFileLimited(ctx, "/file", 0)).To(Equal("content"))
+	Expect(fs.reads).To(Equal(2))
+}
+
+func TestCommandError(t *testing.T) {
+	RegisterTestingT(t)
+
+	exitErr := errors.New("Process exited with status 1")
+	err := commandError(exitErr, "chown: unknown user bob\n")
+	Expect(err).To(MatchError("chown: unknown user bob (Process exited with status 1)"))
+	Expect(err).To(MatchError(exitErr))
+
+	Expect(commandError(exitErr, "")).To(Equal(exitErr))
+}
+
+func TestAuthErrorHint(t *testing.T) {
+	RegisterTestingT(t)
+
+	authErr := func(tracker *authTracker, err error) *AuthError {
+		var target *AuthError
+		Expect(errors.As(tracker.authError("deploy", classifyConnectError(err)), &target)).To(BeTrue())
+		return target
+	}
+
+	// Keys not in authorized_keys, or a wrong username
+	tracker := &authTracker{}
+	tracker.configure("publickey", 2)
+	tracker.attempt("publickey", "public key (2 keys offered)")
+	err := authErr(tracker, errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey], no supported methods remain"))
+	Expect(err).To(MatchError(ErrAuth))
+	Expect(err.Rejected).To(Equal([]string{"none", "publickey"}))
+	Expect(err.Hint()).To(ContainSubstring("rejected all 2 offered keys"))
+	Expect(err.Hint()).To(ContainSubstring("authorized_keys"))
+	Expect(err.Hint()).To(ContainSubstring(`username "deploy" is correct`))
+
+	// Password authentication disabled on the server
+	tracker = &authTracker{}
+	tracker.configure("password", 0)
+	err = authErr(tracker, errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none], no supported methods remain"))
+	Expect(err.Hint()).To(ContainSubstring("PasswordAuthentication no"))
+	Expect(err.Hint()).ToNot(ContainSubstring("username"))
+
+	// Key accepted, but the server requires a further method
+	tracker = &authTracker{}
+	tracker.configure("publickey", 1)
+	tracker.attempt("publickey", "public key (1 keys offered)")
+	err = authErr(tracker, errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none], no supported methods remain"))
+	Expect(err.Hint()).To(ContainSubstring("requires a further method"))
+
+	// Too many keys offered
+	tracker = &authTracker{}
+	tracker.configure("publickey", 8)
+	tracker.attempt("publickey", "public key (8 keys offered)")
+	err = authErr(tracker, errors.New("ssh: handshake failed: ssh: disconnect, reason 2: Too many authentication failures"))
+	Expect(err).To(MatchError(ErrAuth))
+	Expect(err.TooManyAttempts).To(BeTrue())
+	Expect(err.Hint()).To(ContainSubstring("8 keys were offered"))
+
+	Expect(ConnectionErrorDetail(fmt.Errorf("failed to connect to SSH server: %w", err))).To(ContainSubstring("MaxAuthTries"))
+}
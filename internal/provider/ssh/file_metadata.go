@@ -0,0 +1,334 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"golang.org/x/crypto/ssh"
+)
+
+// FileMetadata is the metadata of a file or directory to apply with ApplyFileMetadata. Nil fields are left untouched.
+type FileMetadata struct {
+	Ownership    *FileOwnership
+	Mode         *os.FileMode
+	Capabilities *string // An empty string removes all capabilities
+	Attributes   *FileAttributesUpdate
+}
+
+// fileMetadataState is the current metadata of a file, as far as it is needed to apply a FileMetadata
+type fileMetadataState struct {
+	mode         os.FileMode
+	uid          string
+	gid          string
+	user         string          // Name of uid, only looked up if a user name is wanted
+	group        string          // Name of gid, only looked up if a group name is wanted
+	attributes   map[string]bool // chattr flags, only read if attributes are applied
+	capabilities *string         // Only read if capabilities are applied, nil if setcap is not installed
+}
+
+// metadataStep is a command of the batch run by ApplyFileMetadata
+type metadataStep struct {
+	name string // What the step does, for errors, e.g. "set file ownership"
+	cmd  string
+	op   string // The chattr operation of an attribute step, e.g. "+i"
+}
+
+// metadataStepStatus is the exit status of the batch when its first step fails. The following steps exit with
+// the following statuses, so the status identifies the failed step. It is well below the statuses the shell
+// uses itself, e.g. 127 for a missing command.
+const metadataStepStatus = 64
+
+// ApplyFileMetadata applies the ownership, mode, capabilities and attributes of a file or directory with a
+// single remote command, instead of one or more commands per change like SetFileOwnership, SetFileMode,
+// SetCapabilities and SetFileAttributes, which saves round-trips on high-latency links. The current metadata
+// is read with one command first, so only the changes that are needed run. A mode alone is applied like
+// SetFileMode does.
+//
+// If a step fails, the error names the step and includes its error output, and restrictive attributes that
+// were lifted for the change are restored. Like SetFileAttributes, it returns an UnsupportedAttributesError
+// after applying everything else if the filesystem doesn't support some attributes.
+func (c *SSHClient) ApplyFileMetadata(ctx context.Context, path string, metadata *FileMetadata) error {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "ApplyFileMetadata")
+	defer span.End()
+
+	path, err := c.ResolvePath(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if metadata == nil {
+		return nil
+	}
+
+	var owner string
+	if metadata.Ownership != nil {
+		if owner, err = chownOwner(metadata.Ownership); err != nil {
+			return err
+		}
+	}
+
+	// A mode alone is applied over SFTP like SetFileMode does, which doesn't need a shell on the server
+	if owner == "" && metadata.Capabilities == nil && metadata.Attributes == nil {
+		if metadata.Mode == nil {
+			return nil
+		}
+		current, err := c.GetFileMode(ctx, path)
+		if err != nil || current == *metadata.Mode {
+			return err
+		}
+		return c.SetFileMode(ctx, path, *metadata.Mode)
+	}
+
+	current, err := c.readFileMetadata(ctx, path, metadata)
+	if err != nil {
+		return err
+	}
+	if metadata.Capabilities != nil && current.capabilities == nil {
+		c.logger.WithContext(ctx).Warn("setcap is not installed, skipping capabilities")
+	}
+
+	steps, lifted := fileMetadataSteps(c.commandPath(path), metadata, owner, current)
+	if len(steps) == 0 {
+		return nil
+	}
+
+	output, err := withSessionRetry(ctx, c, func() (string, error) {
+		return c.RunCommand(ctx, fileMetadataScript(steps))
+	})
+	if err != nil {
+		c.restoreAttributes(ctx, path, lifted)
+
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			if index := exitErr.ExitStatus() - metadataStepStatus; index >= 0 && index < len(steps) {
+				c.logger.WithContext(ctx).WithError(err).WithField("step", steps[index].name).Error("Failed to apply file metadata")
+				return fmt.Errorf("failed to %s: %w", steps[index].name, err)
+			}
+		}
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to apply file metadata")
+		return fmt.Errorf("failed to apply file metadata: %w", err)
+	}
+
+	var unsupported []string
+	var readBack *FileAttributes
+	for _, line := range strings.Split(output, "\n") {
+		kind, value, _ := strings.Cut(line, " ")
+		switch kind {
+		case "unsupported":
+			c.logger.WithContext(ctx).WithField("attribute", value).Warn("File attribute not supported by filesystem")
+			unsupported = append(unsupported, attributeNames[strings.TrimLeft(value, "+-")])
+		case "lsattr":
+			if readBack, err = parseLsattrOutput(value); err != nil {
+				return fmt.Errorf("failed to verify file attributes: %w", err)
+			}
+		}
+	}
+
+	// Some chattr versions report success without applying every change, so the result is read back
+	if readBack != nil {
+		mismatched := attributeMismatches(attributeFlags(readBack), metadata.Attributes, unsupported)
+		if len(mismatched) > 0 {
+			c.logger.WithContext(ctx).WithField("attributes", mismatched).Error("File attributes don't match after change")
+			return fmt.Errorf("%w: %s on %s", ErrAttributeMismatch, strings.Join(mismatched, ", "), path)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return &UnsupportedAttributesError{Path: path, Attributes: unsupported}
+	}
+
+	return nil
+}
+
+// readFileMetadata reads the current metadata of a resolved path that metadata changes with a single command:
+// the mode and owner with ls, the names of the owner with getent if names are wanted, the attributes with
+// lsattr and the capabilities with getcap
+func (c *SSHClient) readFileMetadata(ctx context.Context, path string, metadata *FileMetadata) (*fileMetadataState, error) {
+	quoted := shellQuote(c.commandPath(path))
+
+	// Each value is printed on a line of its own, prefixed with its kind. The fields of the ls output are
+	// split by the shell without expanding patterns, so the IDs can be passed to getent.
+	var script strings.Builder
+	fmt.Fprintf(&script, "set -f\nout=$(ls -ldn %s) || exit 1\nprintf 'ls %%s\\n' \"$out\"\nset -- $out\n", quoted)
+	if ownership := metadata.Ownership; ownership != nil {
+		if ownership.User != "" && !isNumericID(ownership.User) {
+			script.WriteString("printf 'user %s\\n' \"$(getent passwd \"$3\" | cut -d: -f1)\"\n")
+		}
+		if ownership.Group != "" && !isNumericID(ownership.Group) {
+			script.WriteString("printf 'group %s\\n' \"$(getent group \"$4\" | cut -d: -f1)\"\n")
+		}
+	}
+	if metadata.Attributes != nil {
+		fmt.Fprintf(&script, "out=$(lsattr -d %s) || exit 1\nprintf 'lsattr %%s\\n' \"$out\"\n", quoted)
+	}
+	if metadata.Capabilities != nil {
+		fmt.Fprintf(&script, "if command -v getcap >/dev/null && command -v setcap >/dev/null; then\n"+
+			"  out=$(getcap %s) || exit 1\n  printf 'getcap %%s\\n' \"$out\"\nfi\n", quoted)
+	}
+
+	output, err := c.runCommandWithRetry(ctx, script.String())
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get file metadata")
+		return nil, fmt.Errorf("failed to get file metadata: %w", err)
+	}
+
+	state, err := parseFileMetadata(output, path)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Invalid file metadata output")
+		return nil, err
+	}
+	return state, nil
+}
+
+// parseFileMetadata parses the output of the command of readFileMetadata for the given path
+func parseFileMetadata(output string, path string) (*fileMetadataState, error) {
+	state := &fileMetadataState{}
+	listed := false
+	for _, line := range strings.Split(output, "\n") {
+		kind, value, _ := strings.Cut(line, " ")
+		switch kind {
+		case "ls":
+			// Format: "-rw-r--r-- 1 1000 1000 0 Feb 19 13:23 /path/to/file"
+			fields := strings.Fields(value)
+			if len(fields) < 4 || !isNumericID(fields[2]) || !isNumericID(fields[3]) {
+				return nil, fmt.Errorf("invalid ls output format: %s", value)
+			}
+			mode, err := parseModeString(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			state.mode, state.uid, state.gid = mode, fields[2], fields[3]
+			listed = true
+		case "user":
+			state.user = value
+		case "group":
+			state.group = value
+		case "lsattr":
+			attrs, err := parseLsattrOutput(value)
+			if err != nil {
+				return nil, err
+			}
+			state.attributes = attributeFlags(attrs)
+		case "getcap":
+			capabilities := parseGetcapOutput(value, path)
+			state.capabilities = &capabilities
+		}
+	}
+	if !listed {
+		return nil, fmt.Errorf("invalid file metadata output: %q", output)
+	}
+	return state, nil
+}
+
+// fileMetadataSteps plans the commands that change the current metadata of a path, given as it is passed
+// to commands, to metadata. owner is the owner argument of chown for the wanted ownership.
+//
+// Restrictive attributes that are removed or lifted come first, so they don't block the other changes. The
+// ownership is changed before the mode and the capabilities, as chown clears setuid and setgid bits and
+// capabilities, and the other attributes come last, with restrictive attributes that are added at the end.
+// The restrictive attributes that are lifted and restored by the steps are returned as well.
+func fileMetadataSteps(path string, metadata *FileMetadata, owner string, current *fileMetadataState) (steps []metadataStep, lifted []string) {
+	quoted := shellQuote(path)
+
+	var changes []metadataStep
+	chown := false
+	if ownership := metadata.Ownership; owner != "" {
+		chown = !ownerMatchesState(ownership.User, current.uid, current.user) ||
+			!ownerMatchesState(ownership.Group, current.gid, current.group)
+	}
+	if chown {
+		changes = append(changes, metadataStep{
+			name: "set file ownership",
+			cmd:  fmt.Sprintf("chown %s %s", shellQuote(owner), quoted),
+		})
+	}
+
+	if mode := metadata.Mode; mode != nil && (current.mode != *mode || chown && *mode&06000 != 0) {
+		changes = append(changes, metadataStep{
+			name: "set file mode",
+			cmd:  fmt.Sprintf("chmod %04o %s", *mode, quoted),
+		})
+	}
+
+	if capabilities := metadata.Capabilities; capabilities != nil && current.capabilities != nil &&
+		(NormalizeCapabilities(*current.capabilities) != NormalizeCapabilities(*capabilities) || chown && *capabilities != "") {
+		cmd := fmt.Sprintf("setcap %s %s", shellQuote(*capabilities), quoted)
+		if *capabilities == "" {
+			cmd = fmt.Sprintf("setcap -r %s", quoted)
+		}
+		changes = append(changes, metadataStep{name: "set file capabilities", cmd: cmd})
+	}
+
+	// Restrictive attributes also make chown, chmod and setcap fail, so they are lifted for these as well
+	var ops []string
+	if metadata.Attributes != nil {
+		add, remove := attributeChanges(current.attributes, metadata.Attributes)
+		ops, lifted = attributeOps(current.attributes, add, remove, len(changes) > 0)
+	}
+	first := 0
+	for first < len(ops) && ops[first][0] == '-' && slices.Contains(restrictiveFlags, ops[first][1:]) {
+		first++
+	}
+	for _, op := range ops[:first] {
+		steps = append(steps, chattrStep(op, quoted))
+	}
+	steps = append(steps, changes...)
+	for _, op := range ops[first:] {
+		steps = append(steps, chattrStep(op, quoted))
+	}
+
+	if len(ops) > 0 {
+		steps = append(steps, metadataStep{
+			name: "verify file attributes",
+			cmd:  fmt.Sprintf("out=$(lsattr -d %s) && printf 'lsattr %%s\\n' \"$out\"", quoted),
+		})
+	}
+
+	return steps, lifted
+}
+
+// chattrStep is the step of a single attribute change such as "+i" or "-a"
+func chattrStep(op string, quotedPath string) metadataStep {
+	return metadataStep{
+		name: "change file attribute " + op,
+		cmd:  fmt.Sprintf("chattr %s %s", op, quotedPath),
+		op:   op,
+	}
+}
+
+// ownerMatchesState reports whether the wanted user or group, given as name or numeric ID, is the current one
+func ownerMatchesState(wanted string, id string, name string) bool {
+	if wanted == "" {
+		return true
+	}
+	if isNumericID(wanted) {
+		return wanted == id
+	}
+	return wanted == name
+}
+
+// fileMetadataScript composes the steps into a shell script that stops at the first failing step, exiting
+// with the status of the step. Attribute changes the filesystem doesn't support don't fail the script, they
+// are reported on the standard output like "unsupported +C" instead.
+func fileMetadataScript(steps []metadataStep) string {
+	var script strings.Builder
+	for i, step := range steps {
+		status := metadataStepStatus + i
+		if step.op == "" {
+			fmt.Fprintf(&script, "%s || exit %d\n", step.cmd, status)
+			continue
+		}
+		fmt.Fprintf(&script, "if ! out=$(%s 2>&1); then\n"+
+			"  case $out in\n"+
+			"    *'Operation not supported'*|*'Invalid argument'*) printf 'unsupported %%s\\n' %s ;;\n"+
+			"    *) printf '%%s\\n' \"$out\" >&2; exit %d ;;\n"+
+			"  esac\n"+
+			"fi\n", step.cmd, shellQuote(step.op), status)
+	}
+	return script.String()
+}
@@ -0,0 +1,199 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseFileMetadata(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Log("Parse all values")
+	state, err := parseFileMetadata("ls -rwsr-x--- 1 1000 100 0 Feb 19 13:23 /usr/bin/app\n"+
+		"user app\ngroup users\n"+
+		"lsattr ----i---------e------- /usr/bin/app\n"+
+		"getcap /usr/bin/app cap_net_bind_service=ep\n", "/usr/bin/app")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(state.mode).To(Equal(os.FileMode(04750)))
+	Expect(state.uid).To(Equal("1000"))
+	Expect(state.gid).To(Equal("100"))
+	Expect(state.user).To(Equal("app"))
+	Expect(state.group).To(Equal("users"))
+	Expect(state.attributes).To(HaveKeyWithValue("i", true))
+	Expect(state.attributes).To(HaveKeyWithValue("a", false))
+	Expect(state.capabilities).ToNot(BeNil())
+	Expect(*state.capabilities).To(Equal("cap_net_bind_service=ep"))
+
+	t.Log("Values that were not read stay empty, and an unknown owner has no name")
+	state, err = parseFileMetadata("ls -rw-r--r-- 1 1234 1234 0 Feb 19 13:23 /file\nuser \n", "/file")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(state.user).To(BeEmpty())
+	Expect(state.attributes).To(BeNil())
+	Expect(state.capabilities).To(BeNil())
+
+	t.Log("Reject output without a valid listing")
+	for _, output := range []string{"", "user root\n", "ls -rw-r--r-- 1 root root 0 Feb 19 13:23 /file\n", "ls ?rw-r--r-- 1\n"} {
+		_, err := parseFileMetadata(output, "/file")
+		Expect(err).To(HaveOccurred(), "output %q", output)
+	}
+}
+
+func TestFileMetadataSteps(t *testing.T) {
+	RegisterTestingT(t)
+
+	enabled, disabled := true, false
+	mode := os.FileMode(0644)
+	setuid := os.FileMode(04755)
+	capabilities := "cap_net_bind_service=ep"
+	current := func() *fileMetadataState {
+		return &fileMetadataState{mode: 0644, uid: "1000", gid: "1000", user: "app", group: "app"}
+	}
+	names := func(steps []metadataStep) []string {
+		var result []string
+		for _, step := range steps {
+			result = append(result, step.name)
+		}
+		return result
+	}
+
+	t.Log("Nothing runs if everything is applied, given by name or by ID")
+	steps, _ := fileMetadataSteps("/file", &FileMetadata{Ownership: &FileOwnership{User: "app", Group: "1000"}, Mode: &mode}, "app:1000", current())
+	Expect(steps).To(BeEmpty())
+
+	t.Log("Only the changed ownership is applied")
+	steps, _ = fileMetadataSteps("/file", &FileMetadata{Ownership: &FileOwnership{User: "root"}, Mode: &mode}, "root", current())
+	Expect(names(steps)).To(Equal([]string{"set file ownership"}))
+	Expect(steps[0].cmd).To(Equal("chown 'root' '/file'"))
+
+	t.Log("The mode and capabilities are applied again after chown, which clears them")
+	state := current()
+	state.mode = setuid
+	state.capabilities = &capabilities
+	steps, _ = fileMetadataSteps("/file", &FileMetadata{Ownership: &FileOwnership{User: "root"}, Mode: &setuid, Capabilities: &capabilities}, "root", state)
+	Expect(names(steps)).To(Equal([]string{"set file ownership", "set file mode", "set file capabilities"}))
+	Expect(steps[1].cmd).To(Equal("chmod 4755 '/file'"))
+	Expect(steps[2].cmd).To(Equal("setcap 'cap_net_bind_service=ep' '/file'"))
+
+	t.Log("Capabilities are skipped without setcap")
+	steps, _ = fileMetadataSteps("/file", &FileMetadata{Capabilities: &capabilities}, "", current())
+	Expect(steps).To(BeEmpty())
+
+	t.Log("Restrictive attributes are lifted around the other changes and added last")
+	state = current()
+	state.attributes = map[string]bool{"i": true}
+	steps, lifted := fileMetadataSteps("/file", &FileMetadata{
+		Mode:       &setuid,
+		Attributes: &FileAttributesUpdate{Immutable: &enabled, NoDump: &enabled, AppendOnly: &disabled},
+	}, "", state)
+	Expect(names(steps)).To(Equal([]string{
+		"change file attribute -i", "set file mode", "change file attribute +d", "change file attribute +i", "verify file attributes",
+	}))
+	Expect(steps[0].op).To(Equal("-i"))
+	Expect(lifted).To(Equal([]string{"i"}))
+
+	t.Log("Removed restrictive attributes don't block the other changes")
+	state = current()
+	state.attributes = map[string]bool{"a": true}
+	steps, lifted = fileMetadataSteps("/file", &FileMetadata{
+		Ownership:  &FileOwnership{Group: "root"},
+		Attributes: &FileAttributesUpdate{AppendOnly: &disabled},
+	}, ":root", state)
+	Expect(names(steps)).To(Equal([]string{"change file attribute -a", "set file ownership", "verify file attributes"}))
+	Expect(lifted).To(BeEmpty())
+
+	t.Log("An immutable file is lifted for chown and chmod and made immutable again afterwards")
+	private := os.FileMode(0600)
+	state = current()
+	state.attributes = map[string]bool{"i": true}
+	steps, lifted = fileMetadataSteps("/file", &FileMetadata{
+		Ownership:  &FileOwnership{User: "root"},
+		Mode:       &private,
+		Attributes: &FileAttributesUpdate{Immutable: &enabled},
+	}, "root", state)
+	Expect(names(steps)).To(Equal([]string{
+		"change file attribute -i", "set file ownership", "set file mode", "change file attribute +i", "verify file attributes",
+	}))
+	Expect(lifted).To(Equal([]string{"i"}))
+
+	t.Log("An immutable file that is already as configured is left alone")
+	steps, _ = fileMetadataSteps("/file", &FileMetadata{Attributes: &FileAttributesUpdate{Immutable: &enabled}}, "", state)
+	Expect(steps).To(BeEmpty())
+
+	t.Log("An unmanaged immutable attribute set outside of Terraform is restored after the other changes")
+	steps, lifted = fileMetadataSteps("/file", &FileMetadata{
+		Mode:       &private,
+		Attributes: &FileAttributesUpdate{NoDump: &enabled},
+	}, "", state)
+	Expect(names(steps)).To(Equal([]string{
+		"change file attribute -i", "set file mode", "change file attribute +d", "change file attribute +i", "verify file attributes",
+	}))
+	Expect(lifted).To(Equal([]string{"i"}))
+}
+
+func TestFileMetadataScript(t *testing.T) {
+	RegisterTestingT(t)
+
+	script := fileMetadataScript([]metadataStep{
+		{name: "set file mode", cmd: "chmod 0644 '/file'"},
+		{name: "change file attribute +C", cmd: "chattr +C '/file'", op: "+C"},
+	})
+	Expect(script).To(ContainSubstring("chmod 0644 '/file' || exit 64\n"))
+	Expect(script).To(ContainSubstring("if ! out=$(chattr +C '/file' 2>&1); then\n"))
+	Expect(script).To(ContainSubstring("printf 'unsupported %s\\n' '+C'"))
+	Expect(script).To(ContainSubstring("exit 65"))
+}
+
+func TestApplyFileMetadata(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	filePath := "/home/testuser/ssh_test_metadata_" + rand.Text()
+	Expect(client.CreateFile(ctx, filePath, "content", 0644)).To(Succeed())
+	defer client.DeleteFile(ctx, filePath)
+
+	current, err := client.readFileOwner(ctx, filePath)
+	Expect(err).ToNot(HaveOccurred())
+	mode := os.FileMode(0600)
+	enabled, disabled := true, false
+
+	t.Log("Apply ownership, mode and attributes in one batch")
+	Expect(client.ApplyFileMetadata(ctx, filePath, &FileMetadata{
+		Ownership:  &FileOwnership{User: current.user, Group: current.gid},
+		Mode:       &mode,
+		Attributes: &FileAttributesUpdate{NoDump: &enabled},
+	})).To(Succeed())
+	Expect(client.GetFileMode(ctx, filePath)).To(Equal(mode))
+	attrs, err := client.GetFileAttributes(ctx, filePath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(attrs.NoDump).To(BeTrue())
+
+	t.Log("Applying it again changes nothing")
+	Expect(client.ApplyFileMetadata(ctx, filePath, &FileMetadata{
+		Ownership:  &FileOwnership{User: current.user, Group: current.gid},
+		Mode:       &mode,
+		Attributes: &FileAttributesUpdate{NoDump: &enabled},
+	})).To(Succeed())
+
+	t.Log("A failing step is named in the error")
+	err = client.ApplyFileMetadata(ctx, filePath, &FileMetadata{
+		Ownership:  &FileOwnership{User: "bob"},
+		Attributes: &FileAttributesUpdate{NoDump: &disabled},
+	})
+	Expect(err).To(MatchError(ContainSubstring("failed to set file ownership")))
+	Expect(err).To(MatchError(ContainSubstring("bob")))
+	var unsupportedErr *UnsupportedAttributesError
+	Expect(errors.As(err, &unsupportedErr)).To(BeFalse())
+	Expect(client.GetFileOwnership(ctx, filePath)).To(Equal(&FileOwnership{User: current.user, Group: current.group}))
+
+	t.Log("Reject invalid owners before running anything")
+	Expect(client.ApplyFileMetadata(ctx, filePath, &FileMetadata{Ownership: &FileOwnership{User: "$(id)"}})).ToNot(Succeed())
+}
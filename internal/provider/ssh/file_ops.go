@@ -0,0 +1,89 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+)
+
+var _ FileOps = &SSHClient{}
+
+// FileOps are the operations the file, files and directory resources perform on the remote server.
+// SSHClient implements them over SSH; sshtest.FileSystem implements them in memory, so the resource
+// logic can be tested without an SSH server.
+type FileOps interface {
+	// Files
+	CreateFileWithOptions(ctx context.Context, path string, content string, permissions os.FileMode, opts CreateFileOptions) error
+	AppendFile(ctx context.Context, path string, content string) error
+	WritePseudoFile(ctx context.Context, path string, content string) error
+	ReadFileLimited(ctx context.Context, path string, maxSize int64) (string, error)
+	FileChecksum(ctx context.Context, path string) (string, error)
+	ValidateContent(ctx context.Context, content string, command string) error
+	CopyFile(ctx context.Context, oldPath string, newPath string) error
+	Move(ctx context.Context, oldPath string, newPath string) error
+	DeleteFile(ctx context.Context, path string) error
+
+	// Directories
+	CreateDirectory(ctx context.Context, path string, permissions os.FileMode) error
+	DeleteDirectory(ctx context.Context, path string) error
+	SetChildrenMode(ctx context.Context, path string, childType ChildType, mode os.FileMode) error
+	FindChildWithOtherMode(ctx context.Context, path string, childType ChildType, mode os.FileMode) (string, error)
+
+	// Metadata
+	Exists(ctx context.Context, path string) (bool, error)
+	CheckPathType(ctx context.Context, path string, directory bool) error
+	ReadSymlink(ctx context.Context, path string) (target string, isSymlink bool, err error)
+	GetFileMode(ctx context.Context, path string) (os.FileMode, error)
+	SetFileMode(ctx context.Context, path string, mode os.FileMode) error
+	GetFileOwnershipAs(ctx context.Context, path string, like *FileOwnership) (*FileOwnership, error)
+	SetFileOwnership(ctx context.Context, path string, ownership *FileOwnership) error
+	GetFileAttributes(ctx context.Context, path string) (*FileAttributes, error)
+	SetFileAttributes(ctx context.Context, path string, attrs *FileAttributesUpdate) error
+	GetCapabilities(ctx context.Context, path string) (string, error)
+	SetCapabilities(ctx context.Context, path string, capabilities string) error
+	ApplyFileMetadata(ctx context.Context, path string, metadata *FileMetadata) error
+
+	// Commands, for the pre_command and post_command hooks
+	RunCommand(ctx context.Context, cmd string) (string, error)
+
+	// Host, for only_on_os
+	LookupPlatform(ctx context.Context) (string, error)
+}
+
+// maxSymlinkHops is how many symbolic links ResolveSymlinks follows, like the limit of Linux path lookups
+const maxSymlinkHops = 40
+
+// ResolveSymlinks follows the symbolic links at p until it reaches a path that is no link, which doesn't
+// need to exist, so the target of a dangling link is returned as well. Only the last element of each
+// path is resolved, links in the directories are followed by the server as usual.
+func ResolveSymlinks(ctx context.Context, ops FileOps, p string) (string, error) {
+	for range maxSymlinkHops {
+		target, isSymlink, err := ops.ReadSymlink(ctx, p)
+		if err != nil {
+			return "", err
+		}
+		if !isSymlink {
+			return p, nil
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p), target)
+		}
+		p = target
+	}
+	return "", fmt.Errorf("%w: %s", ErrSymlinkLoop, p)
+}
+
+// NearestExisting returns p if it exists, or else its nearest parent directory that exists
+func NearestExisting(ctx context.Context, ops FileOps, p string) (string, error) {
+	for {
+		exists, err := ops.Exists(ctx, p)
+		if err != nil {
+			return "", err
+		}
+		if exists || path.Dir(p) == p {
+			return p, nil
+		}
+		p = path.Dir(p)
+	}
+}
@@ -0,0 +1,121 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+)
+
+// Group describes a group on the remote host
+type Group struct {
+	Name    string
+	GID     int64
+	Members []string
+}
+
+// GetGroup looks up a group with getent. It returns nil if the group does not exist.
+func (c *SSHClient) GetGroup(ctx context.Context, name string) (*Group, error) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "GetGroup")
+	defer span.End()
+
+	output, err := c.runCommandWithRetry(ctx, "getent group "+shellQuote(name))
+	if err != nil {
+		if isExitStatus(err, 2) {
+			return nil, nil
+		}
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get group")
+		return nil, fmt.Errorf("failed to get group %s: %w", name, err)
+	}
+
+	return parseGroupEntry(output)
+}
+
+// CreateGroup creates a group with groupadd. If the group already exists it is adopted,
+// and its GID is updated if one is requested. The returned bool reports whether the group was adopted.
+func (c *SSHClient) CreateGroup(ctx context.Context, name string, gid *int64, system bool) (bool, error) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "CreateGroup")
+	defer span.End()
+
+	var args []string
+	if gid != nil {
+		args = append(args, "-g", strconv.FormatInt(*gid, 10))
+	}
+	if system {
+		args = append(args, "-r")
+	}
+
+	cmd := "groupadd " + strings.Join(append(args, shellQuote(name)), " ")
+	if _, err := c.RunCommand(ctx, cmd); err != nil {
+		// groupadd exits with 9 if the group name is already in use
+		if isExitStatus(err, 9) {
+			c.logger.WithContext(ctx).WithField("group", name).Warn("Group already exists, adopting it")
+			return true, c.UpdateGroup(ctx, name, gid)
+		}
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to create group")
+		return false, fmt.Errorf("failed to create group %s: %w", name, privilegeHint(ctx, err))
+	}
+
+	return false, nil
+}
+
+// UpdateGroup changes the GID of a group with groupmod. Nothing is changed if gid is nil.
+func (c *SSHClient) UpdateGroup(ctx context.Context, name string, gid *int64) error {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "UpdateGroup")
+	defer span.End()
+
+	if gid == nil {
+		return nil
+	}
+
+	cmd := fmt.Sprintf("groupmod -g %d %s", *gid, shellQuote(name))
+	if _, err := c.RunCommand(ctx, cmd); err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to update group")
+		return fmt.Errorf("failed to update group %s: %w", name, privilegeHint(ctx, err))
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes a group with groupdel. A group that does not exist is treated as already deleted.
+func (c *SSHClient) DeleteGroup(ctx context.Context, name string) error {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "DeleteGroup")
+	defer span.End()
+
+	if _, err := c.RunCommand(ctx, "groupdel "+shellQuote(name)); err != nil {
+		// groupdel exits with 6 if the group does not exist
+		if isExitStatus(err, 6) {
+			return nil
+		}
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to delete group")
+		return fmt.Errorf("failed to delete group %s: %w", name, privilegeHint(ctx, err))
+	}
+
+	return nil
+}
+
+// parseGroupEntry parses a group line ("name:x:gid:member1,member2")
+func parseGroupEntry(entry string) (*Group, error) {
+	fields := strings.Split(strings.TrimSpace(entry), ":")
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid group entry: %s", entry)
+	}
+
+	gid, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid in group entry: %s", entry)
+	}
+
+	members := []string{}
+	if fields[3] != "" {
+		members = strings.Split(fields[3], ",")
+	}
+
+	return &Group{
+		Name:    fields[0],
+		GID:     gid,
+		Members: members,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package ssh
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseGroupEntry(t *testing.T) {
+	RegisterTestingT(t)
+
+	group, err := parseGroupEntry("developers:x:1002:alice,bob\n")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(group.Name).To(Equal("developers"))
+	Expect(group.GID).To(BeEquivalentTo(1002))
+	Expect(group.Members).To(Equal([]string{"alice", "bob"}))
+
+	group, err = parseGroupEntry("empty:x:1003:")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(group.Members).To(BeEmpty())
+
+	_, err = parseGroupEntry("invalid")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestGroupCommands(t *testing.T) {
+	RegisterTestingT(t)
+
+	results := map[string]commandResult{}
+	client, server := newCommandTestClient(t, func(cmd string) commandResult {
+		return results[cmd]
+	})
+	ctx := context.Background()
+	gid := int64(2000)
+
+	t.Log("Create a system group with a GID")
+	adopted, err := client.CreateGroup(ctx, "deploy", &gid, true)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(adopted).To(BeFalse())
+	Expect(server.Commands()).To(Equal([]string{"groupadd -g 2000 -r 'deploy'"}))
+
+	t.Log("An existing group is adopted and gets the requested GID")
+	results["groupadd -g 2000 'staff'"] = commandResult{stderr: "groupadd: group 'staff' already exists\n", status: 9}
+	adopted, err = client.CreateGroup(ctx, "staff", &gid, false)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(adopted).To(BeTrue())
+	Expect(server.Commands()[1:]).To(Equal([]string{"groupadd -g 2000 'staff'", "groupmod -g 2000 'staff'"}))
+
+	t.Log("Read a group, and a missing group as nil")
+	results["getent group 'deploy'"] = commandResult{stdout: "deploy:x:2000:alice\n"}
+	results["getent group 'gone'"] = commandResult{status: 2}
+	group, err := client.GetGroup(ctx, "deploy")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(group.GID).To(BeEquivalentTo(2000))
+	Expect(client.GetGroup(ctx, "gone")).To(BeNil())
+
+	t.Log("Deleting a group that doesn't exist succeeds")
+	results["groupdel 'gone'"] = commandResult{stderr: "groupdel: group 'gone' does not exist\n", status: 6}
+	Expect(client.DeleteGroup(ctx, "gone")).To(Succeed())
+
+	t.Log("Missing permissions suggest use_sudo, unless sudo is used")
+	results["groupadd 'ops'"] = commandResult{stderr: "groupadd: cannot lock /etc/group; try again later.\n", status: 10}
+	_, err = client.CreateGroup(ctx, "ops", nil, false)
+	Expect(err).To(MatchError(ContainSubstring("cannot lock /etc/group")))
+	Expect(err).To(MatchError(ContainSubstring("set use_sudo")))
+	results[`sudo -n sh -c 'groupadd '"'"'ops'"'"''`] = commandResult{stderr: "sudo: a password is required\n", status: 1}
+	_, err = client.CreateGroup(WithSudo(ctx), "ops", nil, false)
+	Expect(err).To(MatchError(ContainSubstring("a password is required")))
+	Expect(err).ToNot(MatchError(ContainSubstring("use_sudo")))
+}
@@ -0,0 +1,102 @@
+package ssh
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Key types accepted by GenerateKeyPair
+const (
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeRSA     = "rsa"
+)
+
+// DefaultKeyBits returns the key size used by GenerateKeyPair if bits is zero, like ssh-keygen
+func DefaultKeyBits(keyType string) int {
+	switch keyType {
+	case KeyTypeECDSA:
+		return 256
+	case KeyTypeRSA:
+		return 3072
+	default:
+		return 0
+	}
+}
+
+// KeyPair is a generated SSH key pair
+type KeyPair struct {
+	PrivateKey  string // Private key in OpenSSH format, as written by ssh-keygen
+	PublicKey   string // Public key in authorized_keys format, without a trailing newline
+	Fingerprint string // SHA256 fingerprint of the public key
+}
+
+// GenerateKeyPair generates a new key pair of the given type. bits is the key size for RSA (2048 to 8192)
+// and the curve size for ECDSA (256, 384 or 521); it must be zero for ed25519. Zero uses DefaultKeyBits.
+// The comment is stored in the private key and appended to the public key.
+func GenerateKeyPair(keyType string, bits int, comment string) (*KeyPair, error) {
+	if bits == 0 {
+		bits = DefaultKeyBits(keyType)
+	}
+
+	var privateKey crypto.Signer
+	var err error
+	switch keyType {
+	case KeyTypeEd25519:
+		if bits != 0 {
+			return nil, fmt.Errorf("ed25519 keys have a fixed size, got %d bits", bits)
+		}
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case KeyTypeECDSA:
+		var curve elliptic.Curve
+		switch bits {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("ecdsa keys must have 256, 384 or 521 bits, got %d", bits)
+		}
+		privateKey, err = ecdsa.GenerateKey(curve, rand.Reader)
+	case KeyTypeRSA:
+		if bits < 2048 || bits > 8192 {
+			return nil, fmt.Errorf("rsa keys must have between 2048 and 8192 bits, got %d", bits)
+		}
+		privateKey, err = rsa.GenerateKey(rand.Reader, bits)
+	default:
+		return nil, fmt.Errorf("unsupported key type %q, must be %q, %q or %q", keyType, KeyTypeEd25519, KeyTypeECDSA, KeyTypeRSA)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", keyType, err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(privateKey, comment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	publicKey, err := ssh.NewPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+	if comment != "" {
+		authorizedKey += " " + comment
+	}
+	return &KeyPair{
+		PrivateKey:  string(pem.EncodeToMemory(block)),
+		PublicKey:   authorizedKey,
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+	}, nil
+}
@@ -0,0 +1,53 @@
+package ssh
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestGenerateKeyPair(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		keyType string
+		bits    int
+		algo    string
+	}{
+		{KeyTypeEd25519, 0, ssh.KeyAlgoED25519},
+		{KeyTypeECDSA, 0, ssh.KeyAlgoECDSA256},
+		{KeyTypeECDSA, 384, ssh.KeyAlgoECDSA384},
+		{KeyTypeECDSA, 521, ssh.KeyAlgoECDSA521},
+		{KeyTypeRSA, 2048, ssh.KeyAlgoRSA},
+	}
+	for _, test := range tests {
+		t.Logf("A %s key with %d bits can be parsed and signs for its public key", test.keyType, test.bits)
+		keyPair, err := GenerateKeyPair(test.keyType, test.bits, "deploy@example.com")
+		Expect(err).ToNot(HaveOccurred())
+
+		signer, err := ssh.ParsePrivateKey([]byte(keyPair.PrivateKey))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(signer.PublicKey().Type()).To(Equal(test.algo))
+
+		publicKey, comment, _, _, err := ssh.ParseAuthorizedKey([]byte(keyPair.PublicKey))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(comment).To(Equal("deploy@example.com"))
+		Expect(publicKey.Marshal()).To(Equal(signer.PublicKey().Marshal()))
+		Expect(keyPair.Fingerprint).To(Equal(ssh.FingerprintSHA256(publicKey)))
+	}
+
+	t.Log("Without a comment, the public key consists of the type and key only")
+	keyPair, err := GenerateKeyPair(KeyTypeEd25519, 0, "")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(keyPair.PublicKey).To(MatchRegexp(`^ssh-ed25519 [A-Za-z0-9+/=]+$`))
+
+	t.Log("Invalid types and sizes")
+	for _, invalid := range []struct {
+		keyType string
+		bits    int
+	}{{"dsa", 0}, {KeyTypeEd25519, 256}, {KeyTypeECDSA, 512}, {KeyTypeRSA, 1024}} {
+		_, err := GenerateKeyPair(invalid.keyType, invalid.bits, "")
+		Expect(err).To(HaveOccurred(), "%s with %d bits", invalid.keyType, invalid.bits)
+	}
+}
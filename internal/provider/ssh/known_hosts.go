@@ -0,0 +1,126 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// knownHostsMu serializes reading and appending known_hosts files, so concurrent first
+// connections to the same host don't add duplicate entries
+var knownHostsMu sync.Mutex
+
+// knownHostsCallback returns a host key callback that verifies host keys against a known_hosts file.
+// With trustOnFirstUse, the key of a host that is not listed yet is appended to the file and accepted.
+// A host whose listed key doesn't match is always rejected.
+func knownHostsCallback(file string, trustOnFirstUse bool, logger *logrus.Logger) (ssh.HostKeyCallback, error) {
+	file, err := expandHome(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve known hosts file: %w", err)
+	}
+
+	if _, err := os.Stat(file); err != nil {
+		if !errors.Is(err, os.ErrNotExist) || !trustOnFirstUse {
+			return nil, fmt.Errorf("failed to read known hosts file %s: %w", file, err)
+		}
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsMu.Lock()
+		defer knownHostsMu.Unlock()
+
+		// Reload the file on every connection to see entries added by earlier connections
+		verify, err := loadKnownHosts(file)
+		if err != nil {
+			return err
+		}
+
+		err = verify(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !trustOnFirstUse || !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+			return err
+		}
+
+		// The host is unknown, trust it and remember its key
+		if err := appendKnownHost(file, hostname, key); err != nil {
+			return err
+		}
+		logger.WithFields(logrus.Fields{
+			"host":        hostname,
+			"fingerprint": ssh.FingerprintSHA256(key),
+			"file":        file,
+		}).Warn("Host key of unknown host trusted on first use and added to known hosts. Verify the fingerprint out of band.")
+
+		return nil
+	}, nil
+}
+
+// loadKnownHosts parses a known_hosts file. A missing file is treated as empty.
+func loadKnownHosts(file string) (ssh.HostKeyCallback, error) {
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		return func(string, net.Addr, ssh.PublicKey) error {
+			return &knownhosts.KeyError{}
+		}, nil
+	}
+
+	verify, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts file %s: %w", file, err)
+	}
+	return verify, nil
+}
+
+// appendKnownHost adds a host key to a known_hosts file, creating the file if needed
+func appendKnownHost(file string, hostname string, key ssh.PublicKey) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for known hosts file %s: %w", file, err)
+	}
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open known hosts file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	// Normalize yields "host" for port 22 and "[host]:port" otherwise, matching what knownhosts looks up
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key) + "\n"
+
+	// Don't glue the entry onto a last line without a trailing newline
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat known hosts file %s: %w", file, err)
+	}
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = "\n" + line
+		}
+	}
+
+	if _, err := f.WriteString(line); err != nil {
+		return fmt.Errorf("failed to write known hosts file %s: %w", file, err)
+	}
+
+	return nil
+}
+
+// PublicKeyFingerprint returns the SHA256 fingerprint of a public key given in authorized_keys format
+// ("ssh-ed25519 AAAA... comment") or as a known_hosts line ("example.com ssh-ed25519 AAAA...")
+func PublicKeyFingerprint(publicKey string) (string, error) {
+	if key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey)); err == nil {
+		return ssh.FingerprintSHA256(key), nil
+	}
+
+	_, _, key, _, _, err := ssh.ParseKnownHosts([]byte(publicKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return ssh.FingerprintSHA256(key), nil
+}
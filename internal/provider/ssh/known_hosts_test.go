@@ -0,0 +1,35 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestPublicKeyFingerprint(t *testing.T) {
+	RegisterTestingT(t)
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	key, err := ssh.NewPublicKey(pub)
+	Expect(err).ToNot(HaveOccurred())
+	expected := ssh.FingerprintSHA256(key)
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+
+	t.Log("Keys in authorized_keys format, with and without comment")
+	Expect(PublicKeyFingerprint(authorizedKey)).To(Equal(expected))
+	Expect(PublicKeyFingerprint(authorizedKey + " root@example.com")).To(Equal(expected))
+
+	t.Log("known_hosts lines")
+	Expect(PublicKeyFingerprint("example.com,192.0.2.1 " + authorizedKey)).To(Equal(expected))
+
+	t.Log("Invalid keys")
+	for _, invalid := range []string{"", "not a key", "ssh-ed25519 bm90IGEga2V5"} {
+		_, err := PublicKeyFingerprint(invalid)
+		Expect(err).To(HaveOccurred(), "key %q", invalid)
+	}
+}
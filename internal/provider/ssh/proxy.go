@@ -0,0 +1,110 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sync/atomic"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// DialFunc opens the transport an SSH connection runs over, e.g. a WebSocket or mTLS tunnel. network is
+// always "tcp" and addr is the host and port of the SSH server.
+type DialFunc func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// ConnDialer returns a DialFunc that hands out conn, a connection the caller established. It can only be
+// dialed once, so a client using it can't be reconnected.
+func ConnDialer(conn net.Conn) DialFunc {
+	var used atomic.Bool
+	return func(_ context.Context, _ string, _ string) (net.Conn, error) {
+		if used.Swap(true) {
+			return nil, fmt.Errorf("the pre-established connection was already used")
+		}
+		return conn, nil
+	}
+}
+
+// transportDialer returns the dialer for a configuration: the custom dialer if one is set, otherwise the
+// proxy if one is configured, otherwise a direct TCP connection
+func transportDialer(config SSHConfig) (DialFunc, error) {
+	if config.Dialer != nil {
+		return config.Dialer, nil
+	}
+
+	if config.Proxy == "" {
+		var dialer net.Dialer
+		return dialer.DialContext, nil
+	}
+
+	dialer, err := proxyDialer(config.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		var conn net.Conn
+		var err error
+		if contextDialer, ok := dialer.(proxy.ContextDialer); ok {
+			conn, err = contextDialer.DialContext(ctx, network, addr)
+		} else {
+			conn, err = dialer.Dial(network, addr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to connect through proxy: %w", ErrHostUnreachable, err)
+		}
+		return conn, nil
+	}, nil
+}
+
+// dial opens the transport with dialer and establishes the SSH connection to addr over it
+func dial(ctx context.Context, dialer DialFunc, addr string, config *ssh.ClientConfig) (*ssh.Client, NegotiatedAlgorithms, error) {
+	conn, err := dialer(ctx, "tcp", addr)
+	if err != nil {
+		return nil, NegotiatedAlgorithms{}, err
+	}
+
+	recorder := newKexInitRecorder(conn)
+	c, chans, reqs, err := ssh.NewClientConn(recorder, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, NegotiatedAlgorithms{}, err
+	}
+
+	// The algorithms are informational, so a connection is never refused because they couldn't be recorded
+	algorithms, _ := recorder.algorithms()
+
+	return ssh.NewClient(c, chans, reqs), algorithms, nil
+}
+
+// proxyDialer creates a dialer for a proxy URL. Credentials in the URL are used for proxy authentication.
+func proxyDialer(proxyURL string) (proxy.Dialer, error) {
+	u, err := parseProxyURL(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer, err := proxy.FromURL(u, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create proxy dialer: %w", err)
+	}
+
+	return dialer, nil
+}
+
+// parseProxyURL parses and checks a proxy URL
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	// The URL may contain credentials, so it's not included in errors
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL")
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected socks5 or socks5h", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %s has no host", u.Redacted())
+	}
+	return u, nil
+}
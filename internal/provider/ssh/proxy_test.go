@@ -0,0 +1,115 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// memoryPipe returns both ends of an in-memory connection. net.Pipe alone deadlocks the handshake,
+// because both sides write their version before reading, so each direction is copied by a goroutine.
+func memoryPipe() (net.Conn, net.Conn) {
+	client, clientSide := net.Pipe()
+	serverSide, server := net.Pipe()
+	go func() {
+		_, _ = io.Copy(clientSide, serverSide)
+		clientSide.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(serverSide, clientSide)
+		serverSide.Close()
+	}()
+	return client, server
+}
+
+// serveSFTP runs a minimal SSH server on conn that accepts testuser/testpass and serves SFTP from root
+func serveSFTP(conn net.Conn, root string) error {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		return err
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if meta.User() == "testuser" && string(password) == "testpass" {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+	}
+	config.AddHostKey(signer)
+
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return err
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				isSFTP := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(isSFTP, nil)
+				if isSFTP {
+					server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(root))
+					if err == nil {
+						_ = server.Serve()
+					}
+					return
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+func TestConnDialer(t *testing.T) {
+	RegisterTestingT(t)
+
+	clientConn, serverConn := memoryPipe()
+	root := t.TempDir()
+	go func() {
+		_ = serveSFTP(serverConn, root)
+	}()
+
+	config := SSHConfig{
+		Host:     "memory",
+		Port:     22,
+		Username: "testuser",
+		Password: "testpass",
+		Dialer:   ConnDialer(clientConn),
+	}
+	client, err := NewSSHClient(context.Background(), config)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+
+	t.Log("Files can be written and read over the custom transport")
+	ctx := context.Background()
+	Expect(client.CreateFile(ctx, root+"/file.txt", "content", 0644)).To(Succeed())
+	Expect(client.ReadFile(ctx, root+"/file.txt")).To(Equal("content"))
+
+	t.Log("The pre-established connection can't be dialed twice")
+	_, err = NewSSHClient(ctx, config)
+	Expect(err).To(MatchError(ContainSubstring("already used")))
+}
@@ -0,0 +1,267 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/ephemeral"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+var (
+	_ validator.String = hostValidator{}
+	_ validator.Int64  = portValidator{}
+	_ validator.String = durationValidator{}
+	_ validator.String = proxyValidator{}
+	_ validator.Int64  = maxPacketValidator{}
+	_ validator.Int64  = sftpClientsValidator{}
+	_ validator.String = clientVersionValidator{}
+
+	_ resource.ConfigValidator   = ConnectionValidator{}
+	_ datasource.ConfigValidator = ConnectionValidator{}
+	_ ephemeral.ConfigValidator  = ConnectionValidator{}
+)
+
+// hostValidator ensures the host is not empty or whitespace
+type hostValidator struct{}
+
+func (v hostValidator) Description(_ context.Context) string {
+	return "value must not be empty"
+}
+
+func (v hostValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v hostValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if strings.TrimSpace(req.ConfigValue.ValueString()) == "" {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid SSH host",
+			"The host must be a non-empty hostname or IP address.",
+		)
+	}
+}
+
+// portValidator ensures the port is within the valid TCP port range
+type portValidator struct{}
+
+func (v portValidator) Description(_ context.Context) string {
+	return "value must be between 1 and 65535"
+}
+
+func (v portValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v portValidator) ValidateInt64(_ context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	port := req.ConfigValue.ValueInt64()
+	if port < 1 || port > 65535 {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid SSH port",
+			fmt.Sprintf("The port must be between 1 and 65535, got %d.", port),
+		)
+	}
+}
+
+// maxPacketValidator ensures the SFTP packet size is within what SFTP servers accept
+type maxPacketValidator struct{}
+
+func (v maxPacketValidator) Description(_ context.Context) string {
+	return fmt.Sprintf("value must be between 1 and %d", maxPacketLimit)
+}
+
+func (v maxPacketValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v maxPacketValidator) ValidateInt64(_ context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	size := req.ConfigValue.ValueInt64()
+	if size < 1 || size > maxPacketLimit {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid SFTP packet size",
+			fmt.Sprintf("The packet size must be between 1 and %d bytes, got %d.", maxPacketLimit, size),
+		)
+	}
+}
+
+// sftpClientsValidator ensures the number of SFTP clients leaves sessions for remote commands
+type sftpClientsValidator struct{}
+
+func (v sftpClientsValidator) Description(_ context.Context) string {
+	return fmt.Sprintf("value must be between 1 and %d", maxSFTPClients)
+}
+
+func (v sftpClientsValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v sftpClientsValidator) ValidateInt64(_ context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	count := req.ConfigValue.ValueInt64()
+	if count < 1 || count > maxSFTPClients {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid number of SFTP clients",
+			fmt.Sprintf("The number of SFTP clients must be between 1 and %d, got %d.", maxSFTPClients, count),
+		)
+	}
+}
+
+// durationValidator ensures the value is a positive Go duration such as "30s" or "5m"
+type durationValidator struct{}
+
+func (v durationValidator) Description(_ context.Context) string {
+	return "value must be a positive duration such as \"30s\" or \"5m\""
+}
+
+func (v durationValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v durationValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	duration, err := time.ParseDuration(req.ConfigValue.ValueString())
+	if err != nil || duration <= 0 {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid duration",
+			fmt.Sprintf("Expected a positive duration such as \"30s\" or \"5m\", got %q.", req.ConfigValue.ValueString()),
+		)
+	}
+}
+
+// proxyValidator ensures the value is a supported proxy URL such as "socks5://host:1080"
+type proxyValidator struct{}
+
+func (v proxyValidator) Description(_ context.Context) string {
+	return "value must be a proxy URL such as \"socks5://host:1080\""
+}
+
+func (v proxyValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v proxyValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if _, err := parseProxyURL(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid proxy",
+			fmt.Sprintf("Expected a proxy URL such as \"socks5://host:1080\": %s.", err),
+		)
+	}
+}
+
+// clientVersionValidator ensures the value is a valid SSH identification string such as "SSH-2.0-Terraform"
+type clientVersionValidator struct{}
+
+func (v clientVersionValidator) Description(_ context.Context) string {
+	return "value must be an SSH identification string such as \"SSH-2.0-Terraform\""
+}
+
+func (v clientVersionValidator) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v clientVersionValidator) ValidateString(_ context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+
+	if err := checkClientVersion(req.ConfigValue.ValueString()); err != nil {
+		resp.Diagnostics.AddAttributeError(
+			req.Path,
+			"Invalid client version",
+			fmt.Sprintf("Expected an SSH identification string such as \"SSH-2.0-Terraform\": %s.", err),
+		)
+	}
+}
+
+// ConnectionValidator ensures exactly one of the ssh and connection attributes is set, so a missing
+// or ambiguous connection is reported while validating the configuration instead of when it's applied
+type ConnectionValidator struct{}
+
+func (v ConnectionValidator) Description(_ context.Context) string {
+	return "exactly one of ssh and connection must be set"
+}
+
+func (v ConnectionValidator) MarkdownDescription(_ context.Context) string {
+	return "exactly one of `ssh` and `connection` must be set"
+}
+
+func (v ConnectionValidator) ValidateResource(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	resp.Diagnostics.Append(v.validate(ctx, req.Config)...)
+}
+
+func (v ConnectionValidator) ValidateDataSource(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	resp.Diagnostics.Append(v.validate(ctx, req.Config)...)
+}
+
+func (v ConnectionValidator) ValidateEphemeralResource(ctx context.Context, req ephemeral.ValidateConfigRequest, resp *ephemeral.ValidateConfigResponse) {
+	resp.Diagnostics.Append(v.validate(ctx, req.Config)...)
+}
+
+func (v ConnectionValidator) validate(ctx context.Context, config tfsdk.Config) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	var sshBlock types.Object
+	var connection types.String
+	diags.Append(config.GetAttribute(ctx, path.Root("ssh"), &sshBlock)...)
+	diags.Append(config.GetAttribute(ctx, path.Root("connection"), &connection)...)
+	if diags.HasError() {
+		return diags
+	}
+
+	// An unknown value may still turn out to be null, which is checked again when it's known
+	if sshBlock.IsUnknown() || connection.IsUnknown() {
+		return diags
+	}
+
+	switch {
+	case !sshBlock.IsNull() && !connection.IsNull():
+		diags.AddAttributeError(
+			path.Root("connection"),
+			"Invalid connection",
+			"Only one of ssh and connection can be set.",
+		)
+	case sshBlock.IsNull() && connection.IsNull():
+		diags.AddAttributeError(
+			path.Root("connection"),
+			"Missing connection",
+			"Either ssh or connection must be set.",
+		)
+	}
+	return diags
+}
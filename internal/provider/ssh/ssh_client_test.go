@@ -2,12 +2,22 @@ package ssh
 
 import (
 	"context"
+	"crypto/ed25519"
 	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"os"
 	"path"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
 )
 
 var sshConfig = SSHConfig{
@@ -98,3 +108,953 @@ func TestDirectoryOperations(t *testing.T) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(exists).To(BeFalse())
 }
+
+func TestAppendFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	filePath := "/home/testuser/ssh_test_append_" + rand.Text()
+	defer client.DeleteFile(ctx, filePath)
+
+	Expect(client.CreateFile(ctx, filePath, "first\n", 0644)).To(Succeed())
+	Expect(client.AppendFile(ctx, filePath, "second\n")).To(Succeed())
+	Expect(client.ReadFile(ctx, filePath)).To(Equal("first\nsecond\n"))
+
+	t.Log("Appending to a missing file fails instead of creating it")
+	Expect(client.AppendFile(ctx, filePath+".missing", "content")).ToNot(Succeed())
+}
+
+func TestReadFileLimited(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	filePath := "/home/testuser/ssh_test_limited_" + rand.Text()
+	defer client.DeleteFile(ctx, filePath)
+	Expect(client.CreateFile(ctx, filePath, "0123456789", 0644)).To(Succeed())
+
+	Expect(client.ReadFileLimited(ctx, filePath, 10)).To(Equal("0123456789"))
+	Expect(client.ReadFileLimited(ctx, filePath, 0)).To(Equal("0123456789"))
+
+	_, err = client.ReadFileLimited(ctx, filePath, 9)
+	Expect(err).To(MatchError(ErrFileTooLarge))
+}
+
+func TestCreateDirectoryExistingParent(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	basePath := "/home/testuser/ssh_test_" + rand.Text()
+	defer client.DeleteDirectory(ctx, basePath)
+
+	t.Log("Create the parent directory upfront")
+	Expect(client.CreateDirectory(ctx, basePath, 0755)).To(Succeed())
+
+	t.Log("Only the missing components below the existing parent are created")
+	directoryPath := path.Join(basePath, "nested/child")
+	Expect(client.CreateDirectory(ctx, directoryPath, 0750)).To(Succeed())
+	Expect(client.GetFileMode(ctx, directoryPath)).To(Equal(os.FileMode(0750)))
+	Expect(client.GetFileMode(ctx, basePath)).To(Equal(os.FileMode(0755)))
+
+	t.Log("A file in the way of a component fails the creation")
+	filePath := path.Join(basePath, "file")
+	Expect(client.CreateFile(ctx, filePath, "Hello World", 0644)).To(Succeed())
+	Expect(client.CreateDirectory(ctx, path.Join(filePath, "child"), 0755)).To(MatchError(ContainSubstring("is not a directory")))
+}
+
+func TestDirectoryStickyBit(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	directoryPath := "/home/testuser/ssh_test_sticky_" + rand.Text()
+	defer client.DeleteDirectory(ctx, directoryPath)
+
+	Expect(client.CreateDirectory(ctx, directoryPath, os.FileMode(ParsePermissions("1777")))).To(Succeed())
+	Expect(client.GetFileMode(ctx, directoryPath)).To(Equal(os.FileMode(01777)))
+
+	Expect(client.SetFileMode(ctx, directoryPath, 02755)).To(Succeed())
+	Expect(client.GetFileMode(ctx, directoryPath)).To(Equal(os.FileMode(02755)))
+}
+
+func TestCreateFileParentDirectories(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	ctx := context.Background()
+	basePath := "/home/testuser/ssh_test_" + rand.Text()
+
+	t.Log("Creating a file with a missing parent should fail when parents are not created")
+	err = client.CreateFileWithOptions(ctx, path.Join(basePath, "disabled/file"), "Hello World", 0644, CreateFileOptions{
+		CreateParents: false,
+	})
+	Expect(err).To(MatchError(ErrParentNotFound))
+	Expect(err).To(MatchError(ContainSubstring(path.Join(basePath, "disabled"))))
+	Expect(client.Exists(ctx, basePath)).To(BeFalse())
+
+	t.Log("Creating a file with a missing parent should use the given parent permissions")
+	parentPath := path.Join(basePath, "secret")
+	err = client.CreateFileWithOptions(ctx, path.Join(parentPath, "file"), "Hello World", 0600, CreateFileOptions{
+		CreateParents:     true,
+		ParentPermissions: 0700,
+	})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(client.GetFileMode(ctx, parentPath)).To(BeEquivalentTo(0700))
+
+	t.Log("All parent directories created on the way get the parent permissions and ownership")
+	nestedPath := path.Join(basePath, "nested")
+	err = client.CreateFileWithOptions(ctx, path.Join(nestedPath, "deeper/file"), "Hello World", 0600, CreateFileOptions{
+		CreateParents:     true,
+		ParentPermissions: 0750,
+		ParentOwner:       "testuser",
+		ParentGroup:       "testuser",
+	})
+	Expect(err).ToNot(HaveOccurred())
+	for _, dir := range []string{nestedPath, path.Join(nestedPath, "deeper")} {
+		Expect(client.GetFileMode(ctx, dir)).To(BeEquivalentTo(0750))
+		Expect(client.GetFileOwnership(ctx, dir)).To(Equal(&FileOwnership{User: "testuser", Group: "testuser"}))
+	}
+}
+
+func TestDeleteNonexistentPaths(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	ctx := context.Background()
+	basePath := "/home/testuser/ssh_test_" + rand.Text()
+
+	t.Log("Deleting a nonexistent file should succeed")
+	Expect(client.DeleteFile(ctx, basePath+"_file")).Should(Succeed())
+
+	t.Log("Deleting a nonexistent directory should succeed")
+	Expect(client.DeleteDirectory(ctx, basePath+"_dir")).Should(Succeed())
+}
+
+func TestCheckPathType(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+	basePath := "/home/testuser/ssh_test_" + rand.Text()
+
+	Expect(client.CreateDirectory(ctx, basePath+"_dir", 0755)).To(Succeed())
+	defer client.DeleteDirectory(ctx, basePath+"_dir")
+	Expect(client.CreateFile(ctx, basePath+"_file", "content", 0644)).To(Succeed())
+	defer client.DeleteFile(ctx, basePath+"_file")
+
+	t.Log("Matching types and missing paths pass")
+	Expect(client.CheckPathType(ctx, basePath+"_dir", true)).To(Succeed())
+	Expect(client.CheckPathType(ctx, basePath+"_file", false)).To(Succeed())
+	Expect(client.CheckPathType(ctx, basePath+"_missing", false)).To(Succeed())
+
+	t.Log("Mismatched types fail")
+	Expect(client.CheckPathType(ctx, basePath+"_dir", false)).To(MatchError(ErrIsDirectory))
+	Expect(client.CheckPathType(ctx, basePath+"_file", true)).To(MatchError(ErrNotDirectory))
+
+	t.Log("With sudo, paths the SSH user can't look into are checked as well")
+	sudoCtx := WithSudo(ctx)
+	privatePath := "/home/keyowner/ssh_test_" + rand.Text()
+	Expect(client.CreateDirectory(sudoCtx, privatePath, 0700)).To(Succeed())
+	defer client.DeleteDirectory(sudoCtx, privatePath)
+	Expect(client.SetFileOwnership(sudoCtx, privatePath, &FileOwnership{User: "keyowner"})).To(Succeed())
+	Expect(client.CheckPathType(sudoCtx, privatePath, true)).To(Succeed())
+	Expect(client.CheckPathType(sudoCtx, privatePath, false)).To(MatchError(ErrIsDirectory))
+	Expect(client.CheckPathType(sudoCtx, privatePath+"/missing", false)).To(Succeed())
+}
+
+func TestExistsWithSudo(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := WithSudo(context.Background())
+
+	privatePath := "/home/keyowner/ssh_test_" + rand.Text()
+	Expect(client.CreateDirectory(ctx, privatePath, 0700)).To(Succeed())
+	defer client.DeleteDirectory(ctx, privatePath)
+	Expect(client.CreateFile(ctx, privatePath+"/file", "content", 04750)).To(Succeed())
+	Expect(client.SetFileOwnership(ctx, privatePath, &FileOwnership{User: "keyowner"})).To(Succeed())
+
+	t.Log("Files the SSH user can't look at are found through sudo")
+	Expect(client.Exists(ctx, privatePath+"/file")).To(BeTrue())
+	Expect(client.Exists(ctx, privatePath+"/missing")).To(BeFalse())
+	Expect(client.GetFileMode(ctx, privatePath)).To(BeEquivalentTo(0700))
+	Expect(client.GetFileMode(ctx, privatePath+"/file")).To(BeEquivalentTo(04750))
+	_, err = client.GetFileMode(ctx, privatePath+"/missing")
+	Expect(err).To(MatchError(os.ErrNotExist))
+}
+
+func TestPing(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+
+	t.Log("A healthy connection answers")
+	latency, err := client.Ping(context.Background())
+	Expect(err).ToNot(HaveOccurred())
+	Expect(latency).To(BeNumerically(">", 0))
+	Expect(latency).To(BeNumerically("<", 5*time.Second))
+
+	t.Log("A closed connection fails")
+	Expect(client.Close()).To(Succeed())
+	_, err = client.Ping(context.Background())
+	Expect(err).To(MatchError(ErrConnectionLost))
+}
+
+func TestCreateFileCancelled(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	dir := "/home/testuser/ssh_test_" + rand.Text()
+	Expect(client.CreateDirectory(context.Background(), dir, 0755)).To(Succeed())
+	defer client.DeleteDirectory(context.Background(), dir)
+	Expect(client.CreateFile(context.Background(), dir+"/file", "old", 0644)).To(Succeed())
+
+	t.Log("Cancelling a large write keeps the previous content")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = client.CreateFile(ctx, dir+"/file", strings.Repeat("x", 64*1024*1024), 0644)
+	Expect(err).To(MatchError(context.DeadlineExceeded))
+	Expect(client.ReadFile(context.Background(), dir+"/file")).To(Equal("old"))
+
+	t.Log("No temporary file is left behind")
+	entries, err := client.SftpClient.ReadDir(dir)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(entries).To(HaveLen(1))
+}
+
+func TestCreateFileSymlink(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+	dir := "/home/testuser/ssh_test_" + rand.Text()
+	Expect(client.CreateDirectory(ctx, dir, 0755)).To(Succeed())
+	defer client.DeleteDirectory(ctx, dir)
+	Expect(client.CreateFile(ctx, dir+"/target", "old", 0644)).To(Succeed())
+	Expect(client.SftpClient.Symlink(dir+"/target", dir+"/link")).To(Succeed())
+
+	t.Log("Writing a symbolic link writes its target and keeps the link")
+	Expect(client.CreateFile(ctx, dir+"/link", "new", 0600)).To(Succeed())
+	Expect(client.ReadFile(ctx, dir+"/target")).To(Equal("new"))
+	Expect(client.GetFileMode(ctx, dir+"/target")).To(BeEquivalentTo(0600))
+	_, isSymlink, err := client.ReadSymlink(ctx, dir+"/link")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(isSymlink).To(BeTrue())
+}
+
+func TestCreateDirectoryMode(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+	parent := "/home/testuser/ssh_test_" + rand.Text()
+	defer client.DeleteDirectory(ctx, parent)
+
+	t.Log("A directory is created with its final mode, regardless of the umask")
+	Expect(client.CreateDirectory(ctx, parent+"/secrets", 0700)).To(Succeed())
+	Expect(client.GetFileMode(ctx, parent+"/secrets")).To(Equal(os.FileMode(0700)))
+
+	t.Log("Only the directory itself is left in its parent")
+	entries, err := client.SftpClient.ReadDir(parent)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(entries).To(HaveLen(1))
+	Expect(entries[0].Name()).To(Equal("secrets"))
+}
+
+func TestUploadFileResume(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+	dir := "/home/testuser/ssh_test_" + rand.Text()
+	Expect(client.CreateDirectory(ctx, dir, 0755)).To(Succeed())
+	defer client.DeleteDirectory(ctx, dir)
+
+	content := strings.Repeat("0123456789", 300*1024)
+	localPath := path.Join(t.TempDir(), "upload")
+	Expect(os.WriteFile(localPath, []byte(content), 0644)).To(Succeed())
+
+	t.Log("An upload continues after the data of a previous attempt")
+	Expect(client.CreateFile(ctx, dir+"/.file.partial", content[:len(content)/2], 0600)).To(Succeed())
+	Expect(client.UploadFile(ctx, localPath, dir+"/file", 0640, UploadOptions{Resumable: true})).To(Succeed())
+	Expect(client.FileChecksum(ctx, dir+"/file")).To(Equal(ContentChecksum(content)))
+	Expect(client.GetFileMode(ctx, dir+"/file")).To(Equal(os.FileMode(0640)))
+	Expect(client.Exists(ctx, dir+"/.file.partial")).To(BeFalse())
+
+	t.Log("A corrupted partial file fails the checksum and is removed")
+	Expect(client.CreateFile(ctx, dir+"/.other.partial", strings.Repeat("x", 1000), 0600)).To(Succeed())
+	err = client.UploadFile(ctx, localPath, dir+"/other", 0644, UploadOptions{Resumable: true})
+	Expect(err).To(MatchError(ErrChecksum))
+	Expect(client.Exists(ctx, dir+"/.other.partial")).To(BeFalse())
+	Expect(client.Exists(ctx, dir+"/other")).To(BeFalse())
+
+	t.Log("A retry after a corrupted partial file starts over")
+	Expect(client.CreateFile(ctx, dir+"/.other.partial", strings.Repeat("x", 1000), 0600)).To(Succeed())
+	Expect(client.UploadFile(ctx, localPath, dir+"/other", 0644, UploadOptions{Resumable: true, Retries: 1})).To(Succeed())
+	Expect(client.FileChecksum(ctx, dir+"/other")).To(Equal(ContentChecksum(content)))
+}
+
+func TestTempDir(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+	dir := "/home/testuser/ssh_test_" + rand.Text()
+	Expect(client.CreateDirectory(ctx, dir, 0755)).To(Succeed())
+	defer client.DeleteDirectory(ctx, dir)
+
+	t.Log("Content is staged in the configured directory and removed afterwards")
+	config := sshConfig
+	config.TempDir = dir
+	staging, err := NewSSHClient(ctx, config)
+	Expect(err).ToNot(HaveOccurred())
+	defer staging.Close()
+	Expect(staging.ValidateContent(ctx, "content", "case %s in "+dir+"/*) grep -q content %s;; *) false;; esac")).To(Succeed())
+	entries, err := client.SftpClient.ReadDir(dir)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(entries).To(BeEmpty())
+
+	t.Log("A directory the user can't write to fails the connection")
+	config.TempDir = "/nonexistent"
+	_, err = NewSSHClient(ctx, config)
+	Expect(err).To(MatchError(ContainSubstring("temporary directory /nonexistent is not writable")))
+}
+
+func TestSFTPClients(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	config := sshConfig
+	config.Transfer.SFTPClients = 3
+	client, err := NewSSHClient(ctx, config)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+
+	t.Log("Concurrent operations are spread across the SFTP clients")
+	dir := "/home/testuser/ssh_test_" + rand.Text()
+	Expect(client.CreateDirectory(ctx, dir, 0755)).To(Succeed())
+	defer client.DeleteDirectory(ctx, dir)
+	errs := make(chan error, 6)
+	for i := range 6 {
+		go func() {
+			errs <- client.CreateFile(ctx, fmt.Sprintf("%s/file%d", dir, i), "content", 0644)
+		}()
+	}
+	for range 6 {
+		Expect(<-errs).To(Succeed())
+	}
+	// Operations that come along while a client is opened use the open ones, the rotation opens the rest
+	for range 3 {
+		client.sftp()
+	}
+	Expect(client.sftpClients).To(HaveLen(3))
+	entries, err := client.SftpClient.ReadDir(dir)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(entries).To(HaveLen(6))
+
+	t.Log("All SFTP clients are closed with the connection")
+	Expect(client.Close()).To(Succeed())
+	for _, sftpClient := range client.sftpClients {
+		_, err := sftpClient.Getwd()
+		Expect(err).To(HaveOccurred())
+	}
+}
+
+func TestSessionRetry(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	// The first sessions fail as if the server rejected them under load
+	sessions, failures := 0, 0
+	client.newSession = func() (*ssh.Session, error) {
+		sessions++
+		if sessions <= failures {
+			return nil, errors.New("ssh: rejected: administratively prohibited (open failed)")
+		}
+		return client.sshClient.NewSession()
+	}
+
+	t.Log("Failing to open a session is retried")
+	failures = 2
+	Expect(client.GetFileOwnership(ctx, "/home/testuser")).To(Equal(&FileOwnership{User: "testuser", Group: "testuser"}))
+
+	t.Log("Retries give up after the configured number")
+	sessions, failures = 0, 10
+	_, err = client.GetFileOwnership(ctx, "/home/testuser")
+	Expect(err).To(MatchError(ErrSession))
+	Expect(sessions).To(Equal(3))
+
+	t.Log("Commands that ran and failed are not retried")
+	sessions, failures = 0, 0
+	_, err = client.GetFileOwnership(ctx, "/nonexistent")
+	Expect(err).To(HaveOccurred())
+	Expect(err).ToNot(MatchError(ErrSession))
+	Expect(sessions).To(Equal(1))
+}
+
+func TestClientVersion(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Log("A custom identification string is accepted by the server")
+	config := sshConfig
+	config.ClientVersion = "SSH-2.0-TerraformTest"
+	client, err := NewSSHClient(context.Background(), config)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	Expect(string(client.sshClient.ClientVersion())).To(Equal("SSH-2.0-TerraformTest"))
+
+	t.Log("An invalid identification string fails before connecting")
+	config.ClientVersion = "TerraformTest"
+	_, err = NewSSHClient(context.Background(), config)
+	Expect(err).To(MatchError(ContainSubstring(`must start with "SSH-2.0-"`)))
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	RegisterTestingT(t)
+
+	keyFile := path.Join(t.TempDir(), "id_test")
+	Expect(os.WriteFile(keyFile, []byte("file-key"), 0600)).Should(Succeed())
+	t.Setenv("SSH_PROVIDER_TEST_KEY", "env-key")
+
+	t.Log("Inline key takes precedence")
+	Expect(loadPrivateKey(SSHConfig{PrivateKey: "inline-key", PrivateKeyPath: keyFile})).To(Equal("inline-key"))
+
+	t.Log("Key is read from file")
+	Expect(loadPrivateKey(SSHConfig{PrivateKeyPath: keyFile, PrivateKeyEnv: "SSH_PROVIDER_TEST_KEY"})).To(Equal("file-key"))
+
+	t.Log("Key is read from environment variable")
+	Expect(loadPrivateKey(SSHConfig{PrivateKeyEnv: "SSH_PROVIDER_TEST_KEY"})).To(Equal("env-key"))
+
+	t.Log("Missing file fails")
+	_, err := loadPrivateKey(SSHConfig{PrivateKeyPath: keyFile + "_missing"})
+	Expect(err).To(HaveOccurred())
+
+	t.Log("Missing environment variable fails")
+	_, err = loadPrivateKey(SSHConfig{PrivateKeyEnv: "SSH_PROVIDER_TEST_KEY_MISSING"})
+	Expect(err).To(HaveOccurred())
+}
+
+func TestPlatform(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+
+	Expect(client.Platform(context.Background())).To(Equal(PlatformLinux))
+	Expect(client.LookupPlatform(context.Background())).To(Equal(PlatformLinux))
+}
+
+func TestResolvePath(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	Expect(client.HomeDir(ctx)).To(Equal("/home/testuser"))
+	Expect(client.ResolvePath(ctx, "~/config")).To(Equal("/home/testuser/config"))
+
+	fileName := "tilde_test_" + rand.Text()
+	Expect(client.CreateFile(ctx, "~/"+fileName, "Hello World", 0644)).To(Succeed())
+	Expect(client.Exists(ctx, "/home/testuser/"+fileName)).To(BeTrue())
+	Expect(client.DeleteFile(ctx, "~/"+fileName)).To(Succeed())
+	Expect(client.Exists(ctx, "/home/testuser/"+fileName)).To(BeFalse())
+}
+
+func TestResolvePathWorkingDir(t *testing.T) {
+	RegisterTestingT(t)
+
+	config := sshConfig
+	config.WorkingDir = "~/app"
+	client, err := NewSSHClient(context.Background(), config)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	Expect(client.ResolvePath(ctx, "config/app.conf")).To(Equal("/home/testuser/app/config/app.conf"))
+	Expect(client.ResolvePath(ctx, "~/other")).To(Equal("/home/testuser/other"))
+	Expect(client.ResolvePath(ctx, "/etc/app.conf")).To(Equal("/etc/app.conf"))
+}
+
+func TestErrorTypes(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Log("Wrong credentials")
+	config := sshConfig
+	config.Password = "wrongpass"
+	_, err := NewSSHClient(context.Background(), config)
+	Expect(err).To(MatchError(ErrAuth))
+
+	t.Log("Nothing listening on the port")
+	config = sshConfig
+	config.Port = 1
+	_, err = NewSSHClient(context.Background(), config)
+	Expect(err).To(MatchError(ErrHostUnreachable))
+
+	t.Log("No write access")
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	err = client.CreateFile(context.Background(), "/etc/ssh_test_"+rand.Text(), "content", 0644)
+	Expect(err).To(MatchError(ErrPermission))
+}
+
+func TestCommandErrorOutput(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	filePath := "/home/testuser/ssh_test_owner_" + rand.Text()
+	Expect(client.CreateFile(ctx, filePath, "content", 0644)).To(Succeed())
+	defer client.DeleteFile(ctx, filePath)
+
+	err = client.SetFileOwnership(ctx, filePath, &FileOwnership{User: "bob", Group: "bob"})
+	Expect(err).To(MatchError(ContainSubstring("bob")))
+	Expect(err).To(MatchError(ContainSubstring("exited with status")))
+}
+
+func TestSetFileOwnershipUnchanged(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	filePath := "/home/testuser/ssh_test_owner_unchanged_" + rand.Text()
+	Expect(client.CreateFile(ctx, filePath, "content", 0644)).To(Succeed())
+	defer client.DeleteFile(ctx, filePath)
+
+	current, err := client.readFileOwner(ctx, filePath)
+	Expect(err).ToNot(HaveOccurred())
+
+	t.Log("Matching ownership is left alone, given by name or by ID")
+	Expect(client.SetFileOwnership(ctx, filePath, &FileOwnership{User: current.user, Group: current.group})).To(Succeed())
+	Expect(client.SetFileOwnership(ctx, filePath, &FileOwnership{User: current.uid})).To(Succeed())
+	Expect(client.SetFileOwnership(ctx, filePath, &FileOwnership{Group: current.gid})).To(Succeed())
+	Expect(client.GetFileOwnership(ctx, filePath)).To(Equal(&FileOwnership{User: current.user, Group: current.group}))
+}
+
+func TestSpecialPaths(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	dir := "/home/testuser/ssh_test_special_" + rand.Text()
+	Expect(client.CreateDirectory(ctx, dir, 0755)).To(Succeed())
+	defer client.DeleteDirectory(ctx, dir)
+	canary := dir + "/injected"
+
+	names := []string{
+		"with space",
+		`double"quote`,
+		"single'quote",
+		`back\slash`,
+		"new\nline",
+		"$(touch " + canary + ")",
+		"`touch " + canary + "`",
+		"; touch " + canary,
+		"-rf",
+		strings.Repeat("a", 255),
+	}
+	for _, name := range names {
+		t.Logf("Operate on %q", name)
+		filePath := dir + "/" + name
+		Expect(client.CreateFile(ctx, filePath, "content", 0644)).To(Succeed())
+		Expect(client.GetFileOwnership(ctx, filePath)).ToNot(BeNil())
+		attrs, err := client.GetFileAttributes(ctx, filePath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(attrs.NoDump).To(BeFalse())
+		Expect(client.CopyFile(ctx, filePath, filePath+".copy")).To(Succeed())
+		Expect(client.ReadFile(ctx, filePath+".copy")).To(Equal("content"))
+		Expect(client.DeleteFile(ctx, filePath+".copy")).To(Succeed())
+		Expect(client.DeleteFile(ctx, filePath)).To(Succeed())
+		Expect(client.Exists(ctx, filePath)).To(BeFalse())
+	}
+	Expect(client.Exists(ctx, canary)).To(BeFalse())
+
+	t.Log("Operate on a long path")
+	longDir := dir
+	for range 16 {
+		longDir += "/" + strings.Repeat("d", 200)
+	}
+	filePath := longDir + "/file with space"
+	Expect(client.CreateFile(ctx, filePath, "content", 0644)).To(Succeed())
+	Expect(client.GetFileOwnership(ctx, filePath)).ToNot(BeNil())
+	Expect(client.FindChildWithOtherMode(ctx, dir, ChildFiles, 0644)).To(BeEmpty())
+	Expect(client.DeleteDirectory(ctx, dir+"/"+strings.Repeat("d", 200))).To(Succeed())
+}
+
+func TestOwnershipInjection(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	filePath := "/home/testuser/ssh_test_injection_" + rand.Text()
+	Expect(client.CreateFile(ctx, filePath, "content", 0644)).To(Succeed())
+	defer client.DeleteFile(ctx, filePath)
+	canary := filePath + ".injected"
+
+	for _, value := range []string{"foo; touch " + canary, "$(touch " + canary + ")", "`touch " + canary + "`", "foo' 'bar"} {
+		t.Logf("Reject %q", value)
+		Expect(client.SetFileOwnership(ctx, filePath, &FileOwnership{User: value})).ToNot(Succeed())
+		Expect(client.SetFileOwnership(ctx, filePath, &FileOwnership{Group: value})).ToNot(Succeed())
+		Expect(client.CreateFileWithOptions(WithSudo(ctx), filePath, "content", 0644, CreateFileOptions{Owner: value})).ToNot(Succeed())
+	}
+	Expect(client.Exists(ctx, canary)).To(BeFalse())
+	Expect(client.ReadFile(ctx, filePath)).To(Equal("content"))
+}
+
+func TestHostKeyFingerprint(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+
+	Expect(client.HostKey()).ToNot(BeNil())
+	Expect(client.HostKeyFingerprint()).To(HavePrefix("SHA256:"))
+}
+
+func TestSetFileAttributesLeavesUnmanagedAttributes(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	ctx := context.Background()
+	filePath := "/home/testuser/ssh_test_" + rand.Text()
+	enabled, disabled := true, false
+
+	Expect(client.CreateFile(ctx, filePath, "Hello World", 0644)).Should(Succeed())
+
+	t.Log("Set an attribute out-of-band")
+	Expect(client.SetFileAttributes(ctx, filePath, &FileAttributesUpdate{NoDump: &enabled})).Should(Succeed())
+
+	t.Log("Apply a sparse update that doesn't mention the attribute")
+	Expect(client.SetFileAttributes(ctx, filePath, &FileAttributesUpdate{NoAtime: &disabled})).Should(Succeed())
+
+	attrs, err := client.GetFileAttributes(ctx, filePath)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(attrs.NoDump).To(BeTrue())
+}
+
+func TestAttributeOps(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		name    string
+		current map[string]bool
+		add     []string
+		remove  []string
+		ops     []string
+		lifted  []string
+	}{
+		{"add", map[string]bool{}, []string{"i", "d", "a"}, nil, []string{"+d", "+a", "+i"}, nil},
+		{"remove", map[string]bool{"i": true, "a": true, "d": true}, nil, []string{"d", "a", "i"}, []string{"-i", "-a", "-d"}, nil},
+		{"lift immutable", map[string]bool{"i": true}, []string{"d"}, nil, []string{"-i", "+d", "+i"}, []string{"i"}},
+		{"lift both", map[string]bool{"i": true, "a": true, "A": true}, nil, []string{"A"}, []string{"-i", "-a", "-A", "+a", "+i"}, []string{"i", "a"}},
+		{"replace append-only", map[string]bool{"a": true}, []string{"i"}, []string{"a"}, []string{"-a", "+i"}, nil},
+		{"lift for restrictive", map[string]bool{"i": true}, []string{"a"}, nil, []string{"-i", "+a", "+i"}, []string{"i"}},
+		{"nothing", map[string]bool{"i": true}, nil, nil, nil, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			RegisterTestingT(t)
+
+			ops, lifted := attributeOps(test.current, test.add, test.remove, false)
+			Expect(ops).To(Equal(test.ops))
+			Expect(lifted).To(Equal(test.lifted))
+		})
+	}
+}
+
+func TestOperationTimeout(t *testing.T) {
+	RegisterTestingT(t)
+
+	config := sshConfig
+	config.OperationTimeout = time.Second
+	client, err := NewSSHClient(context.Background(), config)
+	Expect(err).ToNot(HaveOccurred())
+	ctx := context.Background()
+
+	t.Log("A command exceeding the timeout fails with a timeout error")
+	_, err = client.RunCommand(ctx, "sleep 5")
+	Expect(err).To(MatchError(ErrOperationTimeout))
+
+	t.Log("The connection stays usable after a timeout")
+	output, err := client.RunCommand(ctx, "echo ok")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(output).To(Equal("ok\n"))
+}
+
+// lateCloser records whether it was closed
+type lateCloser struct {
+	closed chan struct{}
+}
+
+func (c *lateCloser) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestOperationTimeoutClosesLateResults(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := &SSHClient{operationTimeout: 50 * time.Millisecond, logger: logrus.New()}
+	result := &lateCloser{closed: make(chan struct{})}
+	release := make(chan struct{})
+
+	t.Log("A result that arrives after the timeout is closed")
+	_, err := withOperationTimeoutValue(context.Background(), client, func() (*lateCloser, error) {
+		<-release
+		return result, nil
+	})
+	Expect(err).To(MatchError(ErrOperationTimeout))
+	close(release)
+	Eventually(result.closed).Should(BeClosed())
+
+	t.Log("A result that arrives in time is handed out open")
+	handedOut, err := withOperationTimeoutValue(context.Background(), client, func() (*lateCloser, error) {
+		return &lateCloser{closed: make(chan struct{})}, nil
+	})
+	Expect(err).ToNot(HaveOccurred())
+	Consistently(handedOut.closed, 100*time.Millisecond).ShouldNot(BeClosed())
+}
+
+func TestCommandTimeout(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := WithCommandTimeout(context.Background(), time.Second)
+
+	t.Log("A command exceeding the timeout is stopped and reports its output so far")
+	started := time.Now()
+	_, err = client.RunCommand(ctx, "echo started; sleep 30")
+	Expect(err).To(MatchError(ErrOperationTimeout))
+	Expect(err).To(MatchError(ContainSubstring("started")))
+	Expect(time.Since(started)).To(BeNumerically("<", 10*time.Second))
+
+	t.Log("A cancelled context stops the command as well")
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Second, cancel)
+	_, err = client.RunCommand(cancelCtx, "sleep 30")
+	Expect(err).To(MatchError(context.Canceled))
+
+	t.Log("The connection stays usable")
+	output, err := client.RunCommand(ctx, "echo ok")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(output).To(Equal("ok\n"))
+}
+
+func TestRunCommandPTY(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := context.Background()
+
+	t.Log("Commands run without a terminal by default")
+	output, err := client.RunCommand(ctx, "if [ -t 0 ]; then echo tty; else echo notty; fi")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(output).To(Equal("notty\n"))
+
+	t.Log("A PTY is allocated on request, without translating newlines")
+	output, err = client.RunCommand(WithPTY(ctx), "if [ -t 0 ]; then echo tty; else echo notty; fi")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(output).To(Equal("tty\n"))
+
+	t.Log("The terminal merges the error output into the standard output")
+	output, err = client.RunCommand(WithPTY(ctx), "echo error >&2")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(output).To(Equal("error\n"))
+}
+
+func TestRunCommandShell(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+	ctx := WithShell(context.Background(), "/bin/sh")
+
+	t.Log("The command is passed to the shell with -c")
+	output, err := client.RunCommand(ctx, `echo "$0"`)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(output).To(Equal("/bin/sh\n"))
+
+	t.Log("The environment is set for the shell")
+	output, err = client.RunCommand(WithEnvironment(ctx, map[string]string{"GREETING": "hello"}), `echo "$0 $GREETING"`)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(output).To(Equal("/bin/sh hello\n"))
+
+	t.Log("Relative shells are rejected")
+	_, err = client.RunCommand(WithShell(context.Background(), "sh"), "true")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestKnownHosts(t *testing.T) {
+	RegisterTestingT(t)
+
+	knownHostsFile := path.Join(t.TempDir(), "known_hosts")
+
+	t.Log("An unknown host fails without trust on first use")
+	config := sshConfig
+	config.KnownHostsFile = knownHostsFile
+	_, err := NewSSHClient(context.Background(), config)
+	Expect(err).To(HaveOccurred())
+
+	t.Log("Trust on first use records the host key")
+	config.TrustOnFirstUse = true
+	client, err := NewSSHClient(context.Background(), config)
+	Expect(err).ToNot(HaveOccurred())
+	client.Close()
+
+	content, err := os.ReadFile(knownHostsFile)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(string(content)).To(HavePrefix("[localhost]:2222 "))
+
+	t.Log("The recorded key is verified strictly afterwards")
+	config.TrustOnFirstUse = false
+	client, err = NewSSHClient(context.Background(), config)
+	Expect(err).ToNot(HaveOccurred())
+	client.Close()
+
+	t.Log("A mismatching key is rejected even with trust on first use")
+	otherKey := "[localhost]:2222 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n"
+	Expect(os.WriteFile(knownHostsFile, []byte(otherKey), 0600)).Should(Succeed())
+	config.TrustOnFirstUse = true
+	_, err = NewSSHClient(context.Background(), config)
+	Expect(err).To(HaveOccurred())
+}
+
+func TestTunnel(t *testing.T) {
+	RegisterTestingT(t)
+
+	client, err := NewSSHClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	defer client.Close()
+
+	t.Log("Forward a local port to the SSH server inside the container")
+	tunnel, err := client.OpenTunnel(context.Background(), "127.0.0.1:0", "localhost:22")
+	Expect(err).ToNot(HaveOccurred())
+
+	conn, err := net.Dial("tcp", tunnel.LocalAddr())
+	Expect(err).ToNot(HaveOccurred())
+	banner := make([]byte, 7)
+	_, err = io.ReadFull(conn, banner)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(string(banner)).To(Equal("SSH-2.0"))
+	conn.Close()
+
+	t.Log("The local port is released when the tunnel is closed")
+	Expect(tunnel.Close()).Should(Succeed())
+	_, err = net.Dial("tcp", tunnel.LocalAddr())
+	Expect(err).To(HaveOccurred())
+}
+
+func TestAuthFallback(t *testing.T) {
+	RegisterTestingT(t)
+
+	generateKey := func() string {
+		_, key, err := ed25519.GenerateKey(nil)
+		Expect(err).ToNot(HaveOccurred())
+		block, err := ssh.MarshalPrivateKey(key, "")
+		Expect(err).ToNot(HaveOccurred())
+		return string(pem.EncodeToMemory(block))
+	}
+
+	t.Log("Keys unknown to the server are offered before falling back to the password")
+	config := sshConfig
+	config.PrivateKey = generateKey()
+	config.PrivateKeys = []string{generateKey(), generateKey()}
+	client, err := NewSSHClient(context.Background(), config)
+	Expect(err).ToNot(HaveOccurred())
+	client.Close()
+
+	t.Log("An invalid key in private_keys fails early")
+	config.PrivateKeys = []string{"invalid"}
+	_, err = NewSSHClient(context.Background(), config)
+	Expect(err).To(MatchError(ContainSubstring("private_keys")))
+}
+
+func BenchmarkCreateLargeFile(b *testing.B) {
+	content := strings.Repeat("0123456789abcdef", 4<<20/16)
+
+	benchmarks := []struct {
+		name     string
+		transfer TransferOptions
+	}{
+		{"Default", TransferOptions{}},
+		{"ConcurrentWrites", TransferOptions{ConcurrentWrites: true}},
+		{"ConcurrentWritesLargePackets", TransferOptions{ConcurrentWrites: true, MaxPacket: 256 * 1024}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			config := sshConfig
+			config.Transfer = bm.transfer
+			client, err := NewSSHClient(context.Background(), config)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer client.Close()
+
+			filePath := "/home/testuser/ssh_bench_" + rand.Text()
+			defer client.DeleteFile(context.Background(), filePath)
+
+			b.SetBytes(int64(len(content)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := client.CreateFile(context.Background(), filePath, content, 0644); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -2,25 +2,70 @@ package ssh
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ErrPoolAtCapacity is returned by GetClient when the pool holds the maximum number of connections
+var ErrPoolAtCapacity = errors.New("connection pool is at capacity")
+
+// maxAcquireRetryDelay caps the backoff between capacity retries
+const maxAcquireRetryDelay = 5 * time.Second
+
+const (
+	// pingIdleTime is how long a connection has to be idle before it is pinged when it's handed out again.
+	// Connections used more recently are assumed to be alive.
+	pingIdleTime = 10 * time.Second
+	// pingTimeout is how long the pool waits for the answer to a ping before it replaces the connection
+	pingTimeout = 5 * time.Second
+)
+
+// openPools tracks all pools that have not been closed yet, so they can be closed on shutdown
+var openPools = struct {
+	mu    sync.Mutex
+	pools map[*SSHPool]struct{}
+}{pools: make(map[*SSHPool]struct{})}
+
 // SSHPool manages a pool of SSH connections
 type SSHPool struct {
 	mu       sync.RWMutex
 	clients  map[string]*pooledClient
+	hosts    map[string]int // Number of clients per host, see hostKey
 	logger   *logrus.Logger
 	maxIdle  time.Duration
 	maxConns int
+	// maxConnsPerHost limits the clients per host, across all SSH configurations for it. Zero is unlimited.
+	maxConnsPerHost int
+	// evictIdle closes the least recently used idle connection when the pool is at capacity. Set when
+	// the cleanup goroutine is disabled, as idle connections would never make room otherwise.
+	evictIdle bool
+
+	acquireRetries    int
+	acquireRetryDelay time.Duration
+	capacityRetries   atomic.Int64 // Number of times GetClient waited for capacity
+	capacityErrors    atomic.Int64 // Number of times GetClient failed because the pool was at capacity
+
+	fileOps func(config SSHConfig) FileOps // Replaces the clients of GetFileOps if set
+
+	released  chan struct{} // Closed and replaced whenever a client is released, to wake up waiting callers
+	closed    bool          // Set by Close, no new connections are created afterwards
+	done      chan struct{} // Closed to stop the cleanup goroutine
+	stopped   chan struct{} // Closed once the cleanup goroutine has exited
+	closeOnce sync.Once
 }
 
 type pooledClient struct {
 	client    *SSHClient
+	host      string
 	lastUsed  time.Time
 	inUse     bool
 	closeOnce sync.Once
@@ -28,9 +73,32 @@ type pooledClient struct {
 
 // PoolConfig holds configuration for the SSH connection pool
 type PoolConfig struct {
-	MaxIdleTime time.Duration // Maximum time a connection can be idle before being closed
-	MaxConns    int           // Maximum number of connections in the pool
-	Logger      *logrus.Logger
+	MaxIdleTime     time.Duration // Maximum time a connection can be idle before being closed
+	MaxConns        int           // Maximum number of connections in the pool, across all hosts
+	CleanupInterval time.Duration // How often idle connections are checked, defaults to 30 seconds
+	// MaxConnsPerHost is the maximum number of connections to a single host, so one busy host doesn't take
+	// up the whole pool. Connections with different SSH configurations for the same host and port count
+	// together. Zero is unlimited.
+	MaxConnsPerHost int
+	// DisableCleanup keeps idle connections open until Close, without a cleanup goroutine. When the pool is
+	// at capacity, the least recently used idle connection is closed to make room.
+	DisableCleanup bool
+	// AcquireRetries is how often GetClient retries when the pool is at capacity, waiting for idle
+	// connections to be cleaned up or connections to be closed. Zero fails right away.
+	AcquireRetries int
+	// AcquireRetryDelay is the wait before the first capacity retry, doubled for every further retry up
+	// to 5 seconds. Defaults to 100 milliseconds.
+	AcquireRetryDelay time.Duration
+	Logger            *logrus.Logger
+	// FileOps, if set, replaces the SSH connections GetFileOps hands out, e.g. with an sshtest.FileSystem
+	// in unit tests of the file resources
+	FileOps func(config SSHConfig) FileOps
+}
+
+// PoolStats are counters of a pool, for monitoring how often its capacity is exhausted
+type PoolStats struct {
+	CapacityRetries int64 // Number of capacity retries of GetClient
+	CapacityErrors  int64 // Number of GetClient calls that failed because the pool stayed at capacity
 }
 
 // NewSSHPool creates a new SSH connection pool
@@ -41,48 +109,204 @@ func NewSSHPool(config PoolConfig) *SSHPool {
 	if config.MaxConns == 0 {
 		config.MaxConns = 10
 	}
+	if config.CleanupInterval == 0 {
+		config.CleanupInterval = 30 * time.Second
+	}
 	if config.Logger == nil {
 		config.Logger = logrus.New()
 	}
+	if config.AcquireRetryDelay == 0 {
+		config.AcquireRetryDelay = 100 * time.Millisecond
+	}
 
 	pool := &SSHPool{
 		clients:  make(map[string]*pooledClient),
+		hosts:    make(map[string]int),
 		logger:   config.Logger,
 		maxIdle:  config.MaxIdleTime,
 		maxConns: config.MaxConns,
+
+		maxConnsPerHost: config.MaxConnsPerHost,
+
+		evictIdle: config.DisableCleanup,
+
+		acquireRetries:    config.AcquireRetries,
+		acquireRetryDelay: config.AcquireRetryDelay,
+
+		fileOps: config.FileOps,
+
+		released: make(chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
 	}
 
 	// Start cleanup goroutine
-	go pool.cleanup()
+	if config.DisableCleanup {
+		close(pool.stopped)
+	} else {
+		go pool.cleanup(config.CleanupInterval)
+	}
+
+	openPools.mu.Lock()
+	openPools.pools[pool] = struct{}{}
+	openPools.mu.Unlock()
 
 	return pool
 }
 
-// GetClient gets or creates a client for the given configuration
+// GetClient gets or creates a client for the given configuration. A client is used by one caller
+// at a time; if the client for the configuration is in use, GetClient waits until it is released
+// or the context is done, so operations against the same host are serialized. If the pool is at
+// capacity, either in total or for the host, GetClient retries with backoff as configured by
+// PoolConfig.AcquireRetries.
 func (p *SSHPool) GetClient(ctx context.Context, config SSHConfig) (*SSHClient, error) {
 	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "SSHPool.GetClient")
 	defer span.End()
 
 	key := p.configKey(config)
+	retries := 0
+	delay := p.acquireRetryDelay
+	defer func() {
+		span.SetAttributes(attribute.Int("pool.capacity_retries", retries))
+	}()
 
-	// Try to get an existing client
-	p.mu.Lock()
-	defer p.mu.Unlock()
+	for {
+		p.mu.Lock()
+
+		if p.closed {
+			p.mu.Unlock()
+			return nil, fmt.Errorf("connection pool is closed")
+		}
+
+		pc, exists := p.clients[key]
+		if exists && pc.inUse {
+			// Wait for the current user to release the client
+			released := p.released
+			p.mu.Unlock()
+
+			select {
+			case <-released:
+				continue
+			case <-ctx.Done():
+				return nil, fmt.Errorf("failed to wait for SSH connection to %s: %w", config.Host, ctx.Err())
+			}
+		}
+
+		// A client that was idle for a while is pinged without holding p.mu, so a slow or dropped connection
+		// doesn't block the whole pool. It's reserved meanwhile, so nobody else takes it.
+		if exists && !pc.client.isClosed() && time.Since(pc.lastUsed) >= pingIdleTime {
+			pc.inUse = true
+			p.mu.Unlock()
+			alive := p.alive(ctx, pc.client)
+			p.mu.Lock()
+
+			if p.closed {
+				p.mu.Unlock()
+				return nil, fmt.Errorf("connection pool is closed")
+			}
+			if alive {
+				pc.lastUsed = time.Now()
+				p.mu.Unlock()
+				return pc.client, nil
+			}
+			p.replaceClient(key, pc)
+			pc = nil
+		}
+
+		client, err := p.acquire(ctx, key, pc, config)
+		p.mu.Unlock()
+		if !errors.Is(err, ErrPoolAtCapacity) {
+			return client, err
+		}
+
+		if retries >= p.acquireRetries {
+			p.capacityErrors.Add(1)
+			return nil, err
+		}
+		retries++
+		p.capacityRetries.Add(1)
+		p.logger.WithContext(ctx).WithField("retry", retries).WithField("delay", delay).Debug("Connection pool at capacity, retrying")
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to wait for connection pool capacity: %w", ctx.Err())
+		}
+		delay = min(delay*2, maxAcquireRetryDelay)
+	}
+}
+
+// Acquire gets a client for the given configuration for the duration of one operation: a pooled client,
+// or a dedicated client that bypasses the pool if dedicated is set. The returned function releases the
+// client once the operation has finished, not when ctx is done, so a cancelled operation can still clean
+// up before another one uses the connection. A dedicated client is closed on release.
+func (p *SSHPool) Acquire(ctx context.Context, config SSHConfig, dedicated bool) (*SSHClient, func(), error) {
+	if dedicated {
+		client, err := NewSSHClient(ctx, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		return client, func() { client.Close() }, nil
+	}
+
+	client, err := p.GetClient(ctx, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, func() { p.ReleaseClient(config) }, nil
+}
+
+// GetFileOps is Acquire for the file operations, which are replaced by PoolConfig.FileOps if it is set
+func (p *SSHPool) GetFileOps(ctx context.Context, config SSHConfig, dedicated bool) (FileOps, func(), error) {
+	if p.fileOps != nil {
+		return p.fileOps(config), func() {}, nil
+	}
 
-	if pc, exists := p.clients[key]; exists && !pc.inUse {
-		// Test if the connection is still alive
-		if err := pc.client.sshClient.Conn.Wait(); err == nil {
+	client, release, err := p.Acquire(ctx, config, dedicated)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, release, nil
+}
+
+// Stats returns the counters of the pool
+func (p *SSHPool) Stats() PoolStats {
+	return PoolStats{
+		CapacityRetries: p.capacityRetries.Load(),
+		CapacityErrors:  p.capacityErrors.Load(),
+	}
+}
+
+// acquire hands out the idle client for key, replacing it if its connection is closed. The caller must
+// hold p.mu.
+func (p *SSHPool) acquire(ctx context.Context, key string, pc *pooledClient, config SSHConfig) (*SSHClient, error) {
+	if pc != nil {
+		if !pc.client.isClosed() {
 			pc.inUse = true
 			pc.lastUsed = time.Now()
 			return pc.client, nil
 		}
 		// Connection is dead, remove it and create a new one
-		delete(p.clients, key)
+		p.replaceClient(key, pc)
 	}
 
-	// Check if we're at capacity
+	// Check if we're at capacity, first for the host and then in total. Connections that were closed
+	// meanwhile don't count.
+	host := hostKey(config)
+	if p.hostAtCapacity(host) || len(p.clients) >= p.maxConns {
+		p.removeClosedClients()
+	}
+	if p.hostAtCapacity(host) && p.evictIdle {
+		p.evictIdleClient(host)
+	}
+	if p.hostAtCapacity(host) {
+		return nil, fmt.Errorf("%w (max %d connections to %s)", ErrPoolAtCapacity, p.maxConnsPerHost, host)
+	}
+	if len(p.clients) >= p.maxConns && p.evictIdle {
+		p.evictIdleClient("")
+	}
 	if len(p.clients) >= p.maxConns {
-		return nil, fmt.Errorf("connection pool is at capacity (max %d connections)", p.maxConns)
+		return nil, fmt.Errorf("%w (max %d connections)", ErrPoolAtCapacity, p.maxConns)
 	}
 
 	// Create a new client
@@ -93,14 +317,94 @@ func (p *SSHPool) GetClient(ctx context.Context, config SSHConfig) (*SSHClient,
 
 	p.clients[key] = &pooledClient{
 		client:   client,
+		host:     host,
 		lastUsed: time.Now(),
 		inUse:    true,
 	}
+	p.hosts[host]++
 
 	return client, nil
 }
 
-// ReleaseClient marks a client as no longer in use
+// hostAtCapacity reports whether the pool holds the maximum number of clients for host.
+// The caller must hold p.mu.
+func (p *SSHPool) hostAtCapacity(host string) bool {
+	return p.maxConnsPerHost > 0 && p.hosts[host] >= p.maxConnsPerHost
+}
+
+// removeClient removes the client for key from the pool without closing it. The caller must hold p.mu.
+func (p *SSHPool) removeClient(key string) {
+	pc, exists := p.clients[key]
+	if !exists {
+		return
+	}
+	delete(p.clients, key)
+	if p.hosts[pc.host]--; p.hosts[pc.host] <= 0 {
+		delete(p.hosts, pc.host)
+	}
+}
+
+// replaceClient closes the client of a dead connection and removes it, so a new one is created for key.
+// Callers waiting for the client are woken up. The caller must hold p.mu.
+func (p *SSHPool) replaceClient(key string, pc *pooledClient) {
+	pc.closeOnce.Do(func() {
+		_ = pc.client.Close()
+	})
+	if p.clients[key] == pc {
+		p.removeClient(key)
+	}
+	p.notifyReleased()
+}
+
+// alive pings the connection of a client that was idle for longer than pingIdleTime, which also detects
+// connections the network dropped silently. The caller must not hold p.mu, since the ping may take up to
+// pingTimeout.
+func (p *SSHPool) alive(ctx context.Context, client *SSHClient) bool {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	if _, err := client.Ping(ctx); err != nil {
+		p.logger.WithContext(ctx).WithError(err).Warn("Replacing pooled SSH connection that does not respond")
+		return false
+	}
+	return true
+}
+
+// removeClosedClients removes idle clients whose connection was closed, e.g. by the server.
+// The caller must hold p.mu.
+func (p *SSHPool) removeClosedClients() {
+	for key, pc := range p.clients {
+		if !pc.inUse && pc.client.isClosed() {
+			p.removeClient(key)
+		}
+	}
+}
+
+// evictIdleClient closes and removes the least recently used idle client, if there is one. If host is
+// set, only clients for that host are considered. The caller must hold p.mu.
+func (p *SSHPool) evictIdleClient(host string) {
+	var oldestKey string
+	var oldest *pooledClient
+	for key, pc := range p.clients {
+		if host != "" && pc.host != host {
+			continue
+		}
+		if !pc.inUse && (oldest == nil || pc.lastUsed.Before(oldest.lastUsed)) {
+			oldestKey, oldest = key, pc
+		}
+	}
+	if oldest == nil {
+		return
+	}
+
+	oldest.closeOnce.Do(func() {
+		if err := oldest.client.Close(); err != nil {
+			p.logger.WithError(err).Error("Failed to close idle SSH client")
+		}
+	})
+	p.removeClient(oldestKey)
+}
+
+// ReleaseClient marks a client as no longer in use and wakes up callers waiting for it
 func (p *SSHPool) ReleaseClient(config SSHConfig) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -110,29 +414,72 @@ func (p *SSHPool) ReleaseClient(config SSHConfig) {
 		pc.inUse = false
 		pc.lastUsed = time.Now()
 	}
+	p.notifyReleased()
 }
 
-// Close closes all connections in the pool
+// notifyReleased wakes up all callers waiting in GetClient. The caller must hold p.mu.
+func (p *SSHPool) notifyReleased() {
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+// Close stops the cleanup goroutine and closes all connections in the pool.
+// It is safe to call Close multiple times and concurrently.
 func (p *SSHPool) Close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+	<-p.stopped
+
+	openPools.mu.Lock()
+	delete(openPools.pools, p)
+	openPools.mu.Unlock()
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.closed = true
+	p.notifyReleased()
+
 	for key, pc := range p.clients {
 		pc.closeOnce.Do(func() {
 			if err := pc.client.Close(); err != nil {
 				p.logger.WithError(err).Error("Failed to close SSH client")
 			}
 		})
-		delete(p.clients, key)
+		p.removeClient(key)
+	}
+}
+
+// CloseAllPools closes every pool that is still open. The provider calls it when the plugin
+// server stops, as Terraform doesn't guarantee that the provider is closed.
+func CloseAllPools() {
+	openPools.mu.Lock()
+	pools := make([]*SSHPool, 0, len(openPools.pools))
+	for pool := range openPools.pools {
+		pools = append(pools, pool)
+	}
+	openPools.mu.Unlock()
+
+	for _, pool := range pools {
+		pool.Close()
 	}
 }
 
-// cleanup periodically removes idle connections
-func (p *SSHPool) cleanup() {
-	ticker := time.NewTicker(30 * time.Second)
+// cleanup periodically removes idle connections until the pool is closed
+func (p *SSHPool) cleanup(interval time.Duration) {
+	defer close(p.stopped)
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
 		p.mu.Lock()
 		now := time.Now()
 		for key, pc := range p.clients {
@@ -142,14 +489,25 @@ func (p *SSHPool) cleanup() {
 						p.logger.WithError(err).Error("Failed to close idle SSH client")
 					}
 				})
-				delete(p.clients, key)
+				p.removeClient(key)
 			}
 		}
 		p.mu.Unlock()
 	}
 }
 
+// hostKey identifies the host of an SSH configuration for the per-host connection limit
+func hostKey(config SSHConfig) string {
+	return net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+}
+
 // configKey generates a unique key for an SSH configuration
 func (p *SSHPool) configKey(config SSHConfig) string {
-	return fmt.Sprintf("%s:%d:%s", config.Host, config.Port, config.Username)
+	key := fmt.Sprintf("%s:%d:%s:%s:%s:%s:%s:%t:%s:%s:%s:%+v", config.Host, config.Port, config.Username, config.OperationTimeout,
+		config.Proxy, config.ClientVersion, config.KnownHostsFile, config.TrustOnFirstUse, config.WorkingDir, config.SFTPRoot, config.TempDir, config.Transfer)
+	// Connections over different custom transports are never shared
+	if config.Dialer != nil {
+		key += fmt.Sprintf(":%p", config.Dialer)
+	}
+	return key
 }
@@ -0,0 +1,319 @@
+package ssh
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestPoolCloseStopsCleanup(t *testing.T) {
+	RegisterTestingT(t)
+
+	pool := NewSSHPool(PoolConfig{CleanupInterval: 10 * time.Millisecond})
+
+	Consistently(pool.stopped, 50*time.Millisecond).ShouldNot(BeClosed())
+
+	pool.Close()
+	Expect(pool.stopped).To(BeClosed())
+
+	t.Log("Closing twice is safe")
+	pool.Close()
+}
+
+func TestPoolCleanupClosesIdleConnections(t *testing.T) {
+	RegisterTestingT(t)
+
+	pool := NewSSHPool(PoolConfig{
+		MaxIdleTime:     10 * time.Millisecond,
+		CleanupInterval: 10 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	_, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	pool.ReleaseClient(sshConfig)
+
+	Eventually(func() int {
+		pool.mu.RLock()
+		defer pool.mu.RUnlock()
+		return len(pool.clients)
+	}, time.Second).Should(BeZero())
+}
+
+func TestPoolCloseTwice(t *testing.T) {
+	RegisterTestingT(t)
+
+	pool := NewSSHPool(PoolConfig{})
+
+	client, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+
+	pool.Close()
+	pool.Close()
+
+	t.Log("The connection was closed")
+	_, err = client.RunCommand(context.Background(), "true")
+	Expect(err).To(HaveOccurred())
+
+	t.Log("No new connections are created after Close")
+	_, err = pool.GetClient(context.Background(), sshConfig)
+	Expect(err).To(MatchError(ContainSubstring("closed")))
+}
+
+func TestCloseAllPools(t *testing.T) {
+	RegisterTestingT(t)
+
+	pool := NewSSHPool(PoolConfig{})
+
+	CloseAllPools()
+	Expect(pool.stopped).To(BeClosed())
+
+	openPools.mu.Lock()
+	defer openPools.mu.Unlock()
+	Expect(openPools.pools).ToNot(HaveKey(pool))
+}
+
+func TestPoolDisableCleanup(t *testing.T) {
+	RegisterTestingT(t)
+
+	pool := NewSSHPool(PoolConfig{
+		MaxIdleTime:    10 * time.Millisecond,
+		DisableCleanup: true,
+	})
+
+	_, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	pool.ReleaseClient(sshConfig)
+
+	t.Log("Idle connections are kept")
+	Consistently(func() int {
+		pool.mu.RLock()
+		defer pool.mu.RUnlock()
+		return len(pool.clients)
+	}, 100*time.Millisecond).Should(Equal(1))
+
+	t.Log("Close still tears everything down")
+	pool.Close()
+	Expect(pool.clients).To(BeEmpty())
+}
+
+func TestPoolConcurrentUse(t *testing.T) {
+	RegisterTestingT(t)
+
+	pool := NewSSHPool(PoolConfig{DisableCleanup: true})
+	defer pool.Close()
+
+	var holders, maxHolders atomic.Int32
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			client, err := pool.GetClient(context.Background(), sshConfig)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer pool.ReleaseClient(sshConfig)
+
+			current := holders.Add(1)
+			defer holders.Add(-1)
+			for {
+				highest := maxHolders.Load()
+				if current <= highest || maxHolders.CompareAndSwap(highest, current) {
+					break
+				}
+			}
+
+			if _, err := client.RunCommand(context.Background(), "true"); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	t.Log("The client was never shared and a single connection was used")
+	Expect(maxHolders.Load()).To(Equal(int32(1)))
+	Expect(pool.clients).To(HaveLen(1))
+}
+
+func TestPoolGetClientWaitRespectsContext(t *testing.T) {
+	RegisterTestingT(t)
+
+	pool := NewSSHPool(PoolConfig{DisableCleanup: true})
+	defer pool.Close()
+
+	_, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = pool.GetClient(ctx, sshConfig)
+	Expect(err).To(MatchError(context.DeadlineExceeded))
+}
+
+func TestPoolCapacityRetries(t *testing.T) {
+	RegisterTestingT(t)
+
+	// A different operation timeout makes the pool use a separate connection
+	otherConfig := sshConfig
+	otherConfig.OperationTimeout = time.Minute
+
+	t.Log("Without retries, a full pool fails right away")
+	pool := NewSSHPool(PoolConfig{MaxConns: 1, DisableCleanup: true})
+	defer pool.Close()
+
+	_, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = pool.GetClient(context.Background(), otherConfig)
+	Expect(err).To(MatchError(ErrPoolAtCapacity))
+	Expect(pool.Stats()).To(Equal(PoolStats{CapacityErrors: 1}))
+
+	t.Log("Retries stop once the configured number is reached")
+	pool = NewSSHPool(PoolConfig{MaxConns: 1, DisableCleanup: true, AcquireRetries: 2, AcquireRetryDelay: 10 * time.Millisecond})
+	defer pool.Close()
+
+	_, err = pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = pool.GetClient(context.Background(), otherConfig)
+	Expect(err).To(MatchError(ErrPoolAtCapacity))
+	Expect(pool.Stats()).To(Equal(PoolStats{CapacityRetries: 2, CapacityErrors: 1}))
+
+	t.Log("Retries wait for idle connections to be cleaned up")
+	pool = NewSSHPool(PoolConfig{
+		MaxConns:          1,
+		MaxIdleTime:       50 * time.Millisecond,
+		CleanupInterval:   10 * time.Millisecond,
+		AcquireRetries:    10,
+		AcquireRetryDelay: 20 * time.Millisecond,
+	})
+	defer pool.Close()
+
+	_, err = pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	pool.ReleaseClient(sshConfig)
+	_, err = pool.GetClient(context.Background(), otherConfig)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(pool.Stats().CapacityRetries).To(BeNumerically(">", 0))
+	Expect(pool.Stats().CapacityErrors).To(BeZero())
+}
+
+func TestPoolEvictsIdleClients(t *testing.T) {
+	RegisterTestingT(t)
+
+	otherConfig := sshConfig
+	otherConfig.OperationTimeout = time.Minute
+
+	pool := NewSSHPool(PoolConfig{MaxConns: 1, DisableCleanup: true})
+	defer pool.Close()
+
+	client, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	pool.ReleaseClient(sshConfig)
+
+	t.Log("Without cleanup, an idle connection makes room for a new one")
+	_, err = pool.GetClient(context.Background(), otherConfig)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(client.isClosed()).To(BeTrue())
+	Expect(pool.Stats().CapacityErrors).To(BeZero())
+}
+
+func TestPoolMaxConnsPerHost(t *testing.T) {
+	RegisterTestingT(t)
+
+	otherConfig := sshConfig
+	otherConfig.OperationTimeout = time.Minute
+	// The same server under another name counts as another host
+	otherHost := sshConfig
+	otherHost.Host = "127.0.0.1"
+
+	pool := NewSSHPool(PoolConfig{MaxConns: 3, MaxConnsPerHost: 1})
+	defer pool.Close()
+
+	_, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+
+	t.Log("A second configuration for the same host exceeds the per-host limit")
+	_, err = pool.GetClient(context.Background(), otherConfig)
+	Expect(err).To(MatchError(ErrPoolAtCapacity))
+	Expect(err.Error()).To(ContainSubstring("localhost:2222"))
+
+	t.Log("Other hosts still get a connection")
+	_, err = pool.GetClient(context.Background(), otherHost)
+	Expect(err).ToNot(HaveOccurred())
+
+	t.Log("Without cleanup, an idle connection to the host makes room for a new one")
+	pool = NewSSHPool(PoolConfig{MaxConns: 3, MaxConnsPerHost: 1, DisableCleanup: true})
+	defer pool.Close()
+	client, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	pool.ReleaseClient(sshConfig)
+	_, err = pool.GetClient(context.Background(), otherConfig)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(client.isClosed()).To(BeTrue())
+}
+
+func TestPoolCapacityRetriesRespectContext(t *testing.T) {
+	RegisterTestingT(t)
+
+	otherConfig := sshConfig
+	otherConfig.OperationTimeout = time.Minute
+
+	pool := NewSSHPool(PoolConfig{MaxConns: 1, DisableCleanup: true, AcquireRetries: 100, AcquireRetryDelay: time.Second})
+	defer pool.Close()
+
+	_, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = pool.GetClient(ctx, otherConfig)
+	Expect(err).To(MatchError(context.DeadlineExceeded))
+	Expect(time.Since(started)).To(BeNumerically("<", time.Second))
+}
+
+func TestPoolPingsIdleClients(t *testing.T) {
+	RegisterTestingT(t)
+
+	pool := NewSSHPool(PoolConfig{DisableCleanup: true})
+	defer pool.Close()
+
+	client, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	pool.ReleaseClient(sshConfig)
+
+	t.Log("A client idle for longer than the ping idle time is pinged and handed out again")
+	pool.mu.Lock()
+	pool.clients[pool.configKey(sshConfig)].lastUsed = time.Now().Add(-pingIdleTime)
+	pool.mu.Unlock()
+	again, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(again).To(BeIdenticalTo(client))
+
+	pool.mu.RLock()
+	pc := pool.clients[pool.configKey(sshConfig)]
+	Expect(pc.inUse).To(BeTrue())
+	Expect(time.Since(pc.lastUsed)).To(BeNumerically("<", pingIdleTime))
+	pool.mu.RUnlock()
+	pool.ReleaseClient(sshConfig)
+
+	t.Log("A closed client is replaced")
+	Expect(client.Close()).To(Succeed())
+	replaced, err := pool.GetClient(context.Background(), sshConfig)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(replaced).ToNot(BeIdenticalTo(client))
+	pool.ReleaseClient(sshConfig)
+}
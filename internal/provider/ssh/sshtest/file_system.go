@@ -0,0 +1,750 @@
+// Package sshtest provides an in-memory implementation of ssh.FileOps for unit tests of the file
+// resources, so their logic can be tested without an SSH server.
+package sshtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+)
+
+var _ ssh.FileOps = &FileSystem{}
+
+// Entry is a file or directory in a FileSystem
+type Entry struct {
+	Dir          bool
+	Content      string
+	Mode         os.FileMode
+	User         string // Numeric user ID
+	Group        string // Numeric group ID
+	Attributes   ssh.FileAttributes
+	Capabilities string
+	Symlink      string // Target of a symbolic link, only read by ReadSymlink
+}
+
+// FileSystem is an in-memory ssh.FileOps. It starts with an empty root directory owned by root and
+// knows the users and groups added with AddUser and AddGroup besides root. Relative paths are resolved
+// against the root directory.
+//
+// Like the kernel, it rejects changes to immutable entries and all changes but appending to append-only
+// files with ssh.ErrPermission.
+type FileSystem struct {
+	mu       sync.Mutex
+	entries  map[string]*Entry
+	users    map[string]string // Name to ID
+	groups   map[string]string // Name to ID
+	commands []string
+
+	// UnsupportedAttributes are the attribute names, e.g. "compressed", that SetFileAttributes rejects
+	// like a filesystem without support for them
+	UnsupportedAttributes []string
+	// OS is the platform reported by LookupPlatform, ssh.PlatformLinux if empty
+	OS string
+	// PlatformErr, if set, is returned by LookupPlatform like a failed detection
+	PlatformErr error
+	// CommandHandler, if set, runs the commands of RunCommand and ValidateContent. For ValidateContent,
+	// "%s" in the command is replaced with a path and stdin is the content to validate. Without a
+	// handler, commands succeed without output.
+	CommandHandler func(cmd string, stdin string) (string, error)
+}
+
+// NewFileSystem creates an empty FileSystem
+func NewFileSystem() *FileSystem {
+	return &FileSystem{
+		entries: map[string]*Entry{
+			"/": {Dir: true, Mode: 0755, User: "0", Group: "0"},
+		},
+		users:  map[string]string{"root": "0"},
+		groups: map[string]string{"root": "0"},
+	}
+}
+
+// AddUser makes a user known for ownership changes
+func (fs *FileSystem) AddUser(name string, id int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.users[name] = strconv.Itoa(id)
+}
+
+// AddGroup makes a group known for ownership changes
+func (fs *FileSystem) AddGroup(name string, id int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.groups[name] = strconv.Itoa(id)
+}
+
+// Put adds or replaces an entry, creating missing parent directories, e.g. to set up a test
+func (fs *FileSystem) Put(p string, entry Entry) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p = clean(p)
+	fs.mkdirAll(path.Dir(p), 0755, "0", "0")
+	if entry.User == "" {
+		entry.User = "0"
+	}
+	if entry.Group == "" {
+		entry.Group = "0"
+	}
+	fs.entries[p] = &entry
+}
+
+// Get returns a copy of the entry at p, e.g. to check the result of a test
+func (fs *FileSystem) Get(p string) (Entry, bool) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, ok := fs.entries[clean(p)]
+	if !ok {
+		return Entry{}, false
+	}
+	return *entry, true
+}
+
+// Commands returns the commands run so far, in order
+func (fs *FileSystem) Commands() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return slices.Clone(fs.commands)
+}
+
+func (fs *FileSystem) CreateFileWithOptions(_ context.Context, p string, content string, permissions os.FileMode, opts ssh.CreateFileOptions) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p = clean(p)
+	if err := fs.checkFileDestination(p); err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	parentDir := path.Dir(p)
+	if _, ok := fs.entries[parentDir]; !ok {
+		if !opts.CreateParents {
+			return fmt.Errorf("%w: %s (creating parent directories is disabled)", ssh.ErrParentNotFound, parentDir)
+		}
+		user, group, err := fs.ids(opts.ParentOwner, opts.ParentGroup)
+		if err != nil {
+			return fmt.Errorf("failed to create parent directories: %w", err)
+		}
+		fs.mkdirAll(parentDir, opts.ParentPermissions, user, group)
+	}
+	if parent := fs.entries[parentDir]; !parent.Dir {
+		return fmt.Errorf("failed to create file: %w: %s", ssh.ErrNotDirectory, parentDir)
+	}
+
+	user, group, err := fs.ids(opts.Owner, opts.Group)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	// The file is written to a temporary file and renamed into place, so it replaces an existing file
+	// with its attributes and capabilities
+	fs.entries[p] = &Entry{Content: content, Mode: permissions, User: user, Group: group}
+	return nil
+}
+
+func (fs *FileSystem) AppendFile(_ context.Context, p string, content string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.file(clean(p))
+	if err != nil {
+		return fmt.Errorf("failed to open file for appending: %w", err)
+	}
+	if entry.Attributes.Immutable {
+		return fmt.Errorf("failed to open file for appending: %w: %s is immutable", ssh.ErrPermission, p)
+	}
+	entry.Content += content
+	return nil
+}
+
+func (fs *FileSystem) WritePseudoFile(_ context.Context, p string, content string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.modifiable(clean(p))
+	if err != nil {
+		return fmt.Errorf("failed to open pseudo file: %w", err)
+	}
+	if entry.Dir {
+		return fmt.Errorf("failed to open pseudo file: %w: %s", ssh.ErrIsDirectory, p)
+	}
+	entry.Content = content
+	return nil
+}
+
+func (fs *FileSystem) ReadFileLimited(_ context.Context, p string, maxSize int64) (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.file(clean(p))
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if maxSize > 0 && int64(len(entry.Content)) > maxSize {
+		return "", fmt.Errorf("%w: %s is larger than the limit of %d bytes", ssh.ErrFileTooLarge, p, maxSize)
+	}
+	return entry.Content, nil
+}
+
+func (fs *FileSystem) FileChecksum(_ context.Context, p string) (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.file(clean(p))
+	if err != nil {
+		return "", fmt.Errorf("failed to compute file checksum: %w", err)
+	}
+	return ssh.ContentChecksum(entry.Content), nil
+}
+
+func (fs *FileSystem) ValidateContent(_ context.Context, content string, command string) error {
+	cmd := strings.ReplaceAll(command, "%s", "'/tmp/.terraform-provider-ssh-validate'")
+	if _, err := fs.run(cmd, content); err != nil {
+		return fmt.Errorf("%w: %w", ssh.ErrContentInvalid, err)
+	}
+	return nil
+}
+
+func (fs *FileSystem) CopyFile(_ context.Context, oldPath string, newPath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	oldPath, newPath = clean(oldPath), clean(newPath)
+	entry, err := fs.file(oldPath)
+	if err == nil {
+		err = fs.checkFileDestination(newPath)
+	}
+	if err == nil {
+		err = fs.checkParent(newPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %w", oldPath, newPath, err)
+	}
+
+	fs.entries[newPath] = &Entry{Content: entry.Content, Mode: entry.Mode, User: entry.User, Group: entry.Group}
+	return nil
+}
+
+func (fs *FileSystem) Move(_ context.Context, oldPath string, newPath string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	oldPath, newPath = clean(oldPath), clean(newPath)
+	entry, ok := fs.entries[oldPath]
+	var err error
+	switch {
+	case !ok:
+		err = fmt.Errorf("%w: %s", os.ErrNotExist, oldPath)
+	case entry.Attributes.Immutable || entry.Attributes.AppendOnly:
+		err = fmt.Errorf("%w: %s is immutable or append-only", ssh.ErrPermission, oldPath)
+	case entry.Dir:
+		if _, ok := fs.entries[newPath]; ok {
+			err = fmt.Errorf("%w: %s", os.ErrExist, newPath)
+		} else {
+			err = fs.checkParent(newPath)
+		}
+	default:
+		if err = fs.checkFileDestination(newPath); err == nil {
+			err = fs.checkParent(newPath)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", oldPath, newPath, err)
+	}
+
+	for _, p := range fs.tree(oldPath) {
+		fs.entries[newPath+strings.TrimPrefix(p, oldPath)] = fs.entries[p]
+		delete(fs.entries, p)
+	}
+	return nil
+}
+
+func (fs *FileSystem) DeleteFile(_ context.Context, p string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p = clean(p)
+	entry, ok := fs.entries[p]
+	if !ok {
+		return nil
+	}
+	if entry.Dir {
+		return fmt.Errorf("failed to delete file: %w: %s", ssh.ErrIsDirectory, p)
+	}
+	if err := fs.checkReplaceable(p); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	delete(fs.entries, p)
+	return nil
+}
+
+func (fs *FileSystem) CreateDirectory(_ context.Context, p string, permissions os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p = clean(p)
+	if _, ok := fs.entries[p]; ok {
+		return fmt.Errorf("directory %s already exists", p)
+	}
+	fs.mkdirAll(path.Dir(p), 0755, "0", "0")
+	fs.entries[p] = &Entry{Dir: true, Mode: permissions, User: "0", Group: "0"}
+	return nil
+}
+
+func (fs *FileSystem) DeleteDirectory(_ context.Context, p string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p = clean(p)
+	tree := fs.tree(p)
+	for _, child := range tree {
+		if err := fs.checkReplaceable(child); err != nil {
+			return fmt.Errorf("failed to delete directory: %w", err)
+		}
+	}
+	for _, child := range tree {
+		delete(fs.entries, child)
+	}
+	return nil
+}
+
+func (fs *FileSystem) SetChildrenMode(_ context.Context, p string, childType ssh.ChildType, mode os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, child := range fs.children(clean(p), childType) {
+		entry := fs.entries[child]
+		if entry.Mode == mode {
+			continue
+		}
+		if entry.Attributes.Immutable {
+			return fmt.Errorf("failed to set mode of children of %s: %w: %s is immutable", p, ssh.ErrPermission, child)
+		}
+		entry.Mode = mode
+	}
+	return nil
+}
+
+func (fs *FileSystem) FindChildWithOtherMode(_ context.Context, p string, childType ssh.ChildType, mode os.FileMode) (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	for _, child := range fs.children(clean(p), childType) {
+		if fs.entries[child].Mode != mode {
+			return child, nil
+		}
+	}
+	return "", nil
+}
+
+func (fs *FileSystem) Exists(_ context.Context, p string) (bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	_, ok := fs.entries[clean(p)]
+	return ok, nil
+}
+
+func (fs *FileSystem) CheckPathType(_ context.Context, p string, directory bool) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, ok := fs.entries[clean(p)]
+	switch {
+	case !ok:
+		return nil
+	case entry.Dir && !directory:
+		return fmt.Errorf("%w: %s", ssh.ErrIsDirectory, p)
+	case !entry.Dir && directory:
+		return fmt.Errorf("%w: %s", ssh.ErrNotDirectory, p)
+	}
+	return nil
+}
+
+func (fs *FileSystem) ReadSymlink(_ context.Context, p string) (string, bool, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, ok := fs.entries[clean(p)]
+	if !ok || entry.Symlink == "" {
+		return "", false, nil
+	}
+	return entry.Symlink, true, nil
+}
+
+func (fs *FileSystem) GetFileMode(_ context.Context, p string) (os.FileMode, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.entry(clean(p))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get file mode: %w", err)
+	}
+	return entry.Mode, nil
+}
+
+func (fs *FileSystem) SetFileMode(_ context.Context, p string, mode os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.modifiable(clean(p))
+	if err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	entry.Mode = mode
+	return nil
+}
+
+func (fs *FileSystem) GetFileOwnershipAs(_ context.Context, p string, like *ssh.FileOwnership) (*ssh.FileOwnership, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.entry(clean(p))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file ownership: %w", err)
+	}
+
+	ownership := &ssh.FileOwnership{User: entry.User, Group: entry.Group}
+	if !isNumeric(like.User) {
+		ownership.User = name(fs.users, entry.User)
+	}
+	if !isNumeric(like.Group) {
+		ownership.Group = name(fs.groups, entry.Group)
+	}
+	return ownership, nil
+}
+
+func (fs *FileSystem) SetFileOwnership(_ context.Context, p string, ownership *ssh.FileOwnership) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if ownership == nil || (ownership.User == "" && ownership.Group == "") {
+		return nil
+	}
+	entry, err := fs.entry(clean(p))
+	if err != nil {
+		return fmt.Errorf("failed to set file ownership: %w", err)
+	}
+	user, group, err := fs.ids(ownership.User, ownership.Group)
+	if err != nil {
+		return fmt.Errorf("failed to set file ownership: %w", err)
+	}
+	if ownership.User == "" {
+		user = entry.User
+	}
+	if ownership.Group == "" {
+		group = entry.Group
+	}
+	if user == entry.User && group == entry.Group {
+		return nil
+	}
+
+	if _, err := fs.modifiable(clean(p)); err != nil {
+		return fmt.Errorf("failed to set file ownership: %w", err)
+	}
+	entry.User, entry.Group = user, group
+	return nil
+}
+
+func (fs *FileSystem) GetFileAttributes(_ context.Context, p string) (*ssh.FileAttributes, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.entry(clean(p))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file attributes: %w", err)
+	}
+	attrs := entry.Attributes
+	return &attrs, nil
+}
+
+func (fs *FileSystem) SetFileAttributes(_ context.Context, p string, attrs *ssh.FileAttributesUpdate) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	p = clean(p)
+	entry, err := fs.entry(p)
+	if err != nil || attrs == nil {
+		return err
+	}
+
+	current := &entry.Attributes
+	var unsupported []string
+	for name, change := range map[string]struct {
+		set     *bool
+		current *bool
+	}{
+		"immutable":       {attrs.Immutable, &current.Immutable},
+		"append_only":     {attrs.AppendOnly, &current.AppendOnly},
+		"no_dump":         {attrs.NoDump, &current.NoDump},
+		"synchronous":     {attrs.Synchronous, &current.Synchronous},
+		"no_atime":        {attrs.NoAtime, &current.NoAtime},
+		"compressed":      {attrs.Compressed, &current.Compressed},
+		"no_cow":          {attrs.NoCoW, &current.NoCoW},
+		"undeletable":     {attrs.Undeletable, &current.Undeletable},
+		"data_journaling": {attrs.DataJournaling, &current.DataJournaling},
+		"no_tail_merge":   {attrs.NoTailMerge, &current.NoTailMerge},
+		"top_dir":         {attrs.TopDir, &current.TopDir},
+		"dir_sync":        {attrs.DirSync, &current.DirSync},
+	} {
+		if change.set == nil || *change.set == *change.current {
+			continue
+		}
+		if slices.Contains(fs.UnsupportedAttributes, name) {
+			unsupported = append(unsupported, name)
+			continue
+		}
+		*change.current = *change.set
+	}
+
+	if len(unsupported) > 0 {
+		slices.Sort(unsupported)
+		return &ssh.UnsupportedAttributesError{Path: p, Attributes: unsupported}
+	}
+	return nil
+}
+
+func (fs *FileSystem) GetCapabilities(_ context.Context, p string) (string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.file(clean(p))
+	if err != nil {
+		return "", fmt.Errorf("failed to get file capabilities: %w", err)
+	}
+	return entry.Capabilities, nil
+}
+
+func (fs *FileSystem) SetCapabilities(_ context.Context, p string, capabilities string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	entry, err := fs.modifiable(clean(p))
+	if err != nil {
+		return fmt.Errorf("failed to set file capabilities: %w", err)
+	}
+	entry.Capabilities = capabilities
+	return nil
+}
+
+// ApplyFileMetadata applies the changes one after another like SSHClient.ApplyFileMetadata, skipping those
+// that are already applied, so unchanged metadata of an immutable entry doesn't fail
+func (fs *FileSystem) ApplyFileMetadata(ctx context.Context, p string, metadata *ssh.FileMetadata) error {
+	if metadata == nil {
+		return nil
+	}
+
+	// Restrictive attributes that are removed are removed first, so they don't block the other changes
+	if attrs := metadata.Attributes; attrs != nil {
+		removed := &ssh.FileAttributesUpdate{}
+		if attrs.Immutable != nil && !*attrs.Immutable {
+			removed.Immutable = attrs.Immutable
+		}
+		if attrs.AppendOnly != nil && !*attrs.AppendOnly {
+			removed.AppendOnly = attrs.AppendOnly
+		}
+		if err := fs.SetFileAttributes(ctx, p, removed); err != nil {
+			return err
+		}
+	}
+	if metadata.Ownership != nil {
+		if err := fs.SetFileOwnership(ctx, p, metadata.Ownership); err != nil {
+			return err
+		}
+	}
+	if metadata.Mode != nil {
+		current, err := fs.GetFileMode(ctx, p)
+		if err != nil {
+			return err
+		}
+		if current != *metadata.Mode {
+			if err := fs.SetFileMode(ctx, p, *metadata.Mode); err != nil {
+				return err
+			}
+		}
+	}
+	if metadata.Capabilities != nil {
+		current, err := fs.GetCapabilities(ctx, p)
+		if err != nil {
+			return err
+		}
+		if ssh.NormalizeCapabilities(current) != ssh.NormalizeCapabilities(*metadata.Capabilities) {
+			if err := fs.SetCapabilities(ctx, p, *metadata.Capabilities); err != nil {
+				return err
+			}
+		}
+	}
+	return fs.SetFileAttributes(ctx, p, metadata.Attributes)
+}
+
+func (fs *FileSystem) RunCommand(_ context.Context, cmd string) (string, error) {
+	return fs.run(cmd, "")
+}
+
+func (fs *FileSystem) LookupPlatform(_ context.Context) (string, error) {
+	if fs.PlatformErr != nil {
+		return "", fs.PlatformErr
+	}
+	if fs.OS == "" {
+		return ssh.PlatformLinux, nil
+	}
+	return fs.OS, nil
+}
+
+// run records cmd and runs it with the command handler
+func (fs *FileSystem) run(cmd string, stdin string) (string, error) {
+	fs.mu.Lock()
+	fs.commands = append(fs.commands, cmd)
+	handler := fs.CommandHandler
+	fs.mu.Unlock()
+
+	if handler == nil {
+		return "", nil
+	}
+	return handler(cmd, stdin)
+}
+
+// entry returns the entry at p. The caller must hold fs.mu.
+func (fs *FileSystem) entry(p string) (*Entry, error) {
+	entry, ok := fs.entries[p]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", os.ErrNotExist, p)
+	}
+	return entry, nil
+}
+
+// file returns the entry at p if it's no directory. The caller must hold fs.mu.
+func (fs *FileSystem) file(p string) (*Entry, error) {
+	entry, err := fs.entry(p)
+	if err == nil && entry.Dir {
+		return nil, fmt.Errorf("%w: %s", ssh.ErrIsDirectory, p)
+	}
+	return entry, err
+}
+
+// modifiable returns the entry at p if its metadata can be changed. The caller must hold fs.mu.
+func (fs *FileSystem) modifiable(p string) (*Entry, error) {
+	entry, err := fs.entry(p)
+	if err == nil && (entry.Attributes.Immutable || entry.Attributes.AppendOnly) {
+		return nil, fmt.Errorf("%w: %s is immutable or append-only", ssh.ErrPermission, p)
+	}
+	return entry, err
+}
+
+// checkReplaceable fails if p exists and is immutable or append-only, so it can't be replaced or removed.
+// The caller must hold fs.mu.
+func (fs *FileSystem) checkReplaceable(p string) error {
+	entry, ok := fs.entries[p]
+	if !ok {
+		return nil
+	}
+	if entry.Attributes.Immutable || entry.Attributes.AppendOnly {
+		return fmt.Errorf("%w: %s is immutable or append-only", ssh.ErrPermission, p)
+	}
+	return nil
+}
+
+// checkFileDestination fails if a file can't be written to p because p is a directory or can't be
+// replaced. The caller must hold fs.mu.
+func (fs *FileSystem) checkFileDestination(p string) error {
+	if entry, ok := fs.entries[p]; ok && entry.Dir {
+		return fmt.Errorf("%w: %s", ssh.ErrIsDirectory, p)
+	}
+	return fs.checkReplaceable(p)
+}
+
+// checkParent fails if the parent directory of p doesn't exist. The caller must hold fs.mu.
+func (fs *FileSystem) checkParent(p string) error {
+	parent, ok := fs.entries[path.Dir(p)]
+	if !ok {
+		return fmt.Errorf("%w: %s", os.ErrNotExist, path.Dir(p))
+	}
+	if !parent.Dir {
+		return fmt.Errorf("%w: %s", ssh.ErrNotDirectory, path.Dir(p))
+	}
+	return nil
+}
+
+// mkdirAll creates p and its missing parents. The caller must hold fs.mu.
+func (fs *FileSystem) mkdirAll(p string, permissions os.FileMode, user string, group string) {
+	if _, ok := fs.entries[p]; ok {
+		return
+	}
+	fs.mkdirAll(path.Dir(p), permissions, user, group)
+	fs.entries[p] = &Entry{Dir: true, Mode: permissions, User: user, Group: group}
+}
+
+// tree returns p and all paths below it, sorted. The caller must hold fs.mu.
+func (fs *FileSystem) tree(p string) []string {
+	var paths []string
+	for entryPath := range fs.entries {
+		if entryPath == p || strings.HasPrefix(entryPath, strings.TrimSuffix(p, "/")+"/") {
+			paths = append(paths, entryPath)
+		}
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// children returns the files or directories below p, sorted. The caller must hold fs.mu.
+func (fs *FileSystem) children(p string, childType ssh.ChildType) []string {
+	var paths []string
+	for _, child := range fs.tree(p) {
+		if child != p && fs.entries[child].Dir == (childType == ssh.ChildDirectories) {
+			paths = append(paths, child)
+		}
+	}
+	return paths
+}
+
+// ids returns the numeric IDs of a user and a group given as names or IDs. Empty values are root.
+// The caller must hold fs.mu.
+func (fs *FileSystem) ids(user string, group string) (string, string, error) {
+	userID, err := id(fs.users, user, "user")
+	if err != nil {
+		return "", "", err
+	}
+	groupID, err := id(fs.groups, group, "group")
+	if err != nil {
+		return "", "", err
+	}
+	return userID, groupID, nil
+}
+
+func id(ids map[string]string, value string, kind string) (string, error) {
+	if value == "" {
+		return "0", nil
+	}
+	if isNumeric(value) {
+		return value, nil
+	}
+	if id, ok := ids[value]; ok {
+		return id, nil
+	}
+	return "", fmt.Errorf("invalid %s: '%s'", kind, value)
+}
+
+// name returns the name of an ID, or the ID if it has no name
+func name(ids map[string]string, id string) string {
+	for name, nameID := range ids {
+		if nameID == id {
+			return name
+		}
+	}
+	return id
+}
+
+func isNumeric(value string) bool {
+	_, err := strconv.ParseUint(value, 10, 32)
+	return err == nil
+}
+
+func clean(p string) string {
+	return path.Clean("/" + p)
+}
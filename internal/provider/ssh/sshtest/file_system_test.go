@@ -0,0 +1,140 @@
+package sshtest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
+	. "github.com/onsi/gomega"
+)
+
+func TestFileSystem(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	fs := NewFileSystem()
+	fs.AddUser("app", 1000)
+	fs.AddGroup("app", 1000)
+
+	t.Log("Create a file with its parent directories")
+	Expect(fs.CreateFileWithOptions(ctx, "/etc/app/config", "port=80\n", 0640, ssh.DefaultCreateFileOptions())).Should(Succeed())
+	content, err := fs.ReadFileLimited(ctx, "/etc/app/config", 0)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(content).To(Equal("port=80\n"))
+	_, err = fs.ReadFileLimited(ctx, "/etc/app/config", 4)
+	Expect(err).To(MatchError(ssh.ErrFileTooLarge))
+	Expect(fs.CheckPathType(ctx, "/etc/app", false)).To(MatchError(ssh.ErrIsDirectory))
+	Expect(fs.CreateFileWithOptions(ctx, "/etc/missing/config", "", 0640, ssh.CreateFileOptions{})).To(MatchError(ssh.ErrParentNotFound))
+
+	t.Log("Change the ownership by name and read it back by ID")
+	Expect(fs.SetFileOwnership(ctx, "/etc/app/config", &ssh.FileOwnership{User: "app"})).Should(Succeed())
+	ownership, err := fs.GetFileOwnershipAs(ctx, "/etc/app/config", &ssh.FileOwnership{User: "1000", Group: "root"})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(*ownership).To(Equal(ssh.FileOwnership{User: "1000", Group: "root"}))
+	Expect(fs.SetFileOwnership(ctx, "/etc/app/config", &ssh.FileOwnership{User: "nobody"})).ToNot(Succeed())
+
+	t.Log("Write a pseudo file in place, which must exist")
+	fs.Put("/proc/sys/vm/swappiness", Entry{Content: "60\n", Mode: 0644})
+	Expect(fs.WritePseudoFile(ctx, "/proc/sys/vm/swappiness", "10")).Should(Succeed())
+	entry, _ := fs.Get("/proc/sys/vm/swappiness")
+	Expect(entry.Content).To(Equal("10"))
+	Expect(fs.WritePseudoFile(ctx, "/proc/sys/vm/missing", "10")).ToNot(Succeed())
+
+	t.Log("Reject changes to an immutable file")
+	enabled := true
+	Expect(fs.SetFileAttributes(ctx, "/etc/app/config", &ssh.FileAttributesUpdate{Immutable: &enabled})).Should(Succeed())
+	Expect(fs.SetFileMode(ctx, "/etc/app/config", 0600)).To(MatchError(ssh.ErrPermission))
+	Expect(fs.DeleteFile(ctx, "/etc/app/config")).To(MatchError(ssh.ErrPermission))
+
+	t.Log("Apply metadata in a batch, leaving unchanged metadata of an immutable file alone")
+	mode, disabled := os.FileMode(0640), false
+	Expect(fs.ApplyFileMetadata(ctx, "/etc/app/config", &ssh.FileMetadata{Mode: &mode, Ownership: &ssh.FileOwnership{User: "app"}})).Should(Succeed())
+	mode = 0600
+	Expect(fs.ApplyFileMetadata(ctx, "/etc/app/config", &ssh.FileMetadata{Mode: &mode})).To(MatchError(ssh.ErrPermission))
+	Expect(fs.ApplyFileMetadata(ctx, "/etc/app/config", &ssh.FileMetadata{
+		Mode:       &mode,
+		Attributes: &ssh.FileAttributesUpdate{Immutable: &disabled},
+	})).Should(Succeed())
+	entry, _ = fs.Get("/etc/app/config")
+	Expect(entry.Mode).To(Equal(os.FileMode(0600)))
+	Expect(entry.Attributes.Immutable).To(BeFalse())
+
+	t.Log("Report unsupported attributes")
+	fs.UnsupportedAttributes = []string{"compressed"}
+	var unsupportedErr *ssh.UnsupportedAttributesError
+	Expect(errors.As(fs.SetFileAttributes(ctx, "/etc/app", &ssh.FileAttributesUpdate{Compressed: &enabled}), &unsupportedErr)).To(BeTrue())
+	Expect(unsupportedErr.Attributes).To(Equal([]string{"compressed"}))
+
+	t.Log("Validate content with the command handler")
+	fs.CommandHandler = func(cmd string, stdin string) (string, error) {
+		if stdin == "invalid" {
+			return "", errors.New("syntax error")
+		}
+		return "", nil
+	}
+	Expect(fs.ValidateContent(ctx, "valid", "nginx -t -c %s")).Should(Succeed())
+	Expect(fs.ValidateContent(ctx, "invalid", "nginx -t -c %s")).To(MatchError(ssh.ErrContentInvalid))
+	Expect(fs.Commands()).To(HaveLen(2))
+
+	t.Log("Report linux unless another platform or an error is set")
+	Expect(fs.LookupPlatform(ctx)).To(Equal(ssh.PlatformLinux))
+	fs.OS = ssh.PlatformFreeBSD
+	Expect(fs.LookupPlatform(ctx)).To(Equal(ssh.PlatformFreeBSD))
+	fs.PlatformErr = errors.New("uname not found")
+	_, err = fs.LookupPlatform(ctx)
+	Expect(err).To(HaveOccurred())
+}
+
+func TestFileSystemDirectories(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	fs := NewFileSystem()
+	fs.Put("/srv/www/index.html", Entry{Content: "<html>", Mode: 0600})
+	fs.Put("/srv/www/assets/app.js", Entry{Content: "app()", Mode: 0644})
+
+	t.Log("Find and fix children with another mode")
+	child, err := fs.FindChildWithOtherMode(ctx, "/srv/www", ssh.ChildFiles, 0644)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(child).To(Equal("/srv/www/index.html"))
+	Expect(fs.SetChildrenMode(ctx, "/srv/www", ssh.ChildFiles, 0644)).Should(Succeed())
+	Expect(fs.FindChildWithOtherMode(ctx, "/srv/www", ssh.ChildFiles, 0644)).To(BeEmpty())
+
+	t.Log("Move the directory with its children")
+	Expect(fs.Move(ctx, "/srv/www", "/srv/site")).Should(Succeed())
+	entry, ok := fs.Get("/srv/site/assets/app.js")
+	Expect(ok).To(BeTrue())
+	Expect(entry.Content).To(Equal("app()"))
+	Expect(fs.Exists(ctx, "/srv/www")).To(BeFalse())
+
+	t.Log("Delete the directory with its children")
+	Expect(fs.DeleteDirectory(ctx, "/srv/site")).Should(Succeed())
+	Expect(fs.Exists(ctx, "/srv/site/index.html")).To(BeFalse())
+	Expect(fs.Exists(ctx, "/srv")).To(BeTrue())
+}
+
+func TestResolveSymlinks(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctx := context.Background()
+	fs := NewFileSystem()
+	fs.Put("/etc/app/config", Entry{Content: "port=80\n", Mode: 0644})
+	fs.Put("/etc/app/current", Entry{Symlink: "config"})
+	fs.Put("/etc/config", Entry{Symlink: "/etc/app/current"})
+	fs.Put("/etc/dangling", Entry{Symlink: "../srv/missing"})
+	fs.Put("/etc/loop", Entry{Symlink: "loop"})
+
+	t.Log("Follow relative and absolute links to the final target")
+	Expect(ssh.ResolveSymlinks(ctx, fs, "/etc/config")).To(Equal("/etc/app/config"))
+	Expect(ssh.ResolveSymlinks(ctx, fs, "/etc/app/config")).To(Equal("/etc/app/config"))
+
+	t.Log("Resolve dangling links and missing paths")
+	Expect(ssh.ResolveSymlinks(ctx, fs, "/etc/dangling")).To(Equal("/srv/missing"))
+	Expect(ssh.ResolveSymlinks(ctx, fs, "/etc/missing")).To(Equal("/etc/missing"))
+
+	t.Log("Give up on loops")
+	_, err := ssh.ResolveSymlinks(ctx, fs, "/etc/loop")
+	Expect(err).To(MatchError(ssh.ErrSymlinkLoop))
+}
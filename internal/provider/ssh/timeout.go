@@ -0,0 +1,158 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrOperationTimeout is returned when a single SFTP request or remote command exceeds the operation timeout
+var ErrOperationTimeout = errors.New("operation timed out")
+
+// withOperationTimeout runs op and stops waiting for it once the operation timeout elapses or ctx is done.
+// SFTP requests cannot be cancelled, so a timed out request keeps running in the background until the
+// server answers or the connection is closed; the connection itself stays usable for other operations.
+func (c *SSHClient) withOperationTimeout(ctx context.Context, op func() error) error {
+	_, err := withOperationTimeoutValue(ctx, c, func() (struct{}, error) {
+		return struct{}{}, op()
+	})
+	return err
+}
+
+// withOperationTimeoutValue is withOperationTimeout for operations that return a value. A value that
+// implements io.Closer, like a file, is closed if it arrives after the timeout. Errors of requests the
+// server denied are marked with ErrPermission.
+func withOperationTimeoutValue[T any](ctx context.Context, c *SSHClient, op func() (T, error)) (T, error) {
+	if c.operationTimeout <= 0 {
+		value, err := op()
+		return value, classifyOperationError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.operationTimeout)
+	defer cancel()
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result)
+	abandoned := make(chan struct{})
+	go func() {
+		value, err := op()
+		select {
+		case done <- result{value: value, err: err}:
+		case <-abandoned:
+			// Nobody takes a result that arrives after the timeout, so e.g. a file opened late is closed here
+			if closer, ok := any(value).(io.Closer); ok && err == nil {
+				_ = closer.Close()
+			}
+		}
+	}()
+
+	select {
+	case res := <-done:
+		return res.value, classifyOperationError(res.err)
+	case <-ctx.Done():
+		close(abandoned)
+		var zero T
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			c.logger.WithContext(ctx).WithField("timeout", c.operationTimeout).Error("Operation timed out")
+			return zero, fmt.Errorf("%w after %s", ErrOperationTimeout, c.operationTimeout)
+		}
+		return zero, ctx.Err()
+	}
+}
+
+// commandKillGrace is how long a stopped command gets to exit after SIGTERM before it is sent SIGKILL,
+// and after SIGKILL before its session is closed
+const commandKillGrace = 2 * time.Second
+
+type commandTimeoutContextKey struct{}
+
+// WithCommandTimeout returns a context in which remote commands are stopped once they run longer than
+// timeout. It replaces the operation timeout for commands; file operations are not affected.
+func WithCommandTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, commandTimeoutContextKey{}, timeout)
+}
+
+// commandTimeout returns the timeout for remote commands, which is the operation timeout unless the
+// context sets its own
+func (c *SSHClient) commandTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(commandTimeoutContextKey{}).(time.Duration); ok {
+		return timeout
+	}
+	return c.operationTimeout
+}
+
+// runSession runs a command in the session and stops it once the command timeout elapses or ctx is done.
+// A stopped command is sent SIGTERM, then SIGKILL, and finally its session is closed, since not every
+// server delivers signals. Without a PTY, a command that ignores the signals may keep running on the
+// server after its session was closed.
+func (c *SSHClient) runSession(ctx context.Context, session *ssh.Session, cmd string) error {
+	timeout := c.commandTimeout(ctx)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := session.Start(cmd); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return classifyOperationError(err)
+	case <-ctx.Done():
+	}
+
+	c.stopCommand(ctx, session, done)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && timeout > 0 {
+		c.logger.WithContext(ctx).WithField("timeout", timeout).Error("Command timed out")
+		return fmt.Errorf("%w after %s", ErrOperationTimeout, timeout)
+	}
+	return ctx.Err()
+}
+
+// stopCommand stops a running command, escalating from SIGTERM to SIGKILL to closing the session
+func (c *SSHClient) stopCommand(ctx context.Context, session *ssh.Session, done <-chan error) {
+	for _, signal := range []ssh.Signal{ssh.SIGTERM, ssh.SIGKILL} {
+		if err := session.Signal(signal); err != nil {
+			c.logger.WithContext(ctx).WithError(err).WithField("signal", signal).Debug("Failed to signal command")
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(commandKillGrace):
+		}
+	}
+	session.Close()
+}
+
+// syncBuffer is a bytes.Buffer that can be read while a session still writes to it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
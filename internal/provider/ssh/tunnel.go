@@ -0,0 +1,132 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+)
+
+// Tunnel forwards connections accepted on a local listener to an address reachable from the SSH server
+type Tunnel struct {
+	client     *SSHClient
+	listener   net.Listener
+	remoteAddr string
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// OpenTunnel listens on localAddr and forwards every accepted connection to remoteAddr over the SSH
+// connection. Use port 0 in localAddr to pick a free port; LocalAddr reports the address in use.
+// The tunnel stays open until it or the client is closed.
+func (c *SSHClient) OpenTunnel(ctx context.Context, localAddr string, remoteAddr string) (*Tunnel, error) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "OpenTunnel")
+	defer span.End()
+
+	listener, err := net.Listen("tcp", localAddr)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to listen for tunnel")
+		return nil, fmt.Errorf("failed to listen on %s: %w", localAddr, err)
+	}
+
+	t := &Tunnel{
+		client:     c,
+		listener:   listener,
+		remoteAddr: remoteAddr,
+	}
+
+	c.tunnelsMu.Lock()
+	if c.tunnels == nil {
+		c.tunnels = make(map[*Tunnel]struct{})
+	}
+	c.tunnels[t] = struct{}{}
+	c.tunnelsMu.Unlock()
+
+	t.wg.Add(1)
+	go t.serve()
+
+	return t, nil
+}
+
+// LocalAddr returns the local address the tunnel listens on, e.g. "127.0.0.1:54321"
+func (t *Tunnel) LocalAddr() string {
+	return t.listener.Addr().String()
+}
+
+// Close stops accepting connections and closes all forwarded connections
+func (t *Tunnel) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		err = t.listener.Close()
+		t.wg.Wait()
+
+		t.client.tunnelsMu.Lock()
+		delete(t.client.tunnels, t)
+		t.client.tunnelsMu.Unlock()
+	})
+	return err
+}
+
+// serve accepts local connections until the listener is closed
+func (t *Tunnel) serve() {
+	defer t.wg.Done()
+
+	// Deferred in this order so open connections are closed before waiting for their handlers
+	var handlers sync.WaitGroup
+	var conns sync.Map
+	defer handlers.Wait()
+	defer func() {
+		conns.Range(func(conn, _ any) bool {
+			conn.(net.Conn).Close()
+			return true
+		})
+	}()
+
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				t.client.logger.WithError(err).Error("Failed to accept tunnel connection")
+			}
+			return
+		}
+
+		conns.Store(local, struct{}{})
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			defer conns.Delete(local)
+			t.forward(local)
+		}()
+	}
+}
+
+// forward copies data between a local connection and the remote address until either side closes
+func (t *Tunnel) forward(local net.Conn) {
+	defer local.Close()
+
+	remote, err := t.client.sshClient.Dial("tcp", t.remoteAddr)
+	if err != nil {
+		t.client.logger.WithError(err).WithField("remote", t.remoteAddr).Error("Failed to dial tunnel destination")
+		return
+	}
+	defer remote.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+
+	// Closing both connections once either direction finishes unblocks the other copy
+	<-done
+}
@@ -0,0 +1,184 @@
+package ssh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/pkg/sftp"
+	"go.opentelemetry.io/otel"
+)
+
+// uploadChunkSize is the amount of data written per request, so the operation timeout applies to each
+// chunk instead of the whole transfer
+const uploadChunkSize = 1024 * 1024
+
+// UploadOptions controls how UploadFile transfers a file
+type UploadOptions struct {
+	// Resumable keeps the partially uploaded file when a transfer fails, and continues where it stopped
+	// on the next attempt, including attempts of later runs, instead of starting over
+	Resumable bool
+	// Retries is how often a failed transfer is retried before UploadFile gives up
+	Retries int
+}
+
+// UploadFile copies a local file to the remote server. The content is written to a partial file next to
+// the destination, verified against the SHA-256 checksum of the local file and then moved into place, so
+// the destination never holds a partial upload. Through sudo, the partial file is staged in the temporary
+// directory and installed to the destination.
+func (c *SSHClient) UploadFile(ctx context.Context, localPath string, remotePath string, permissions os.FileMode, opts UploadOptions) error {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "UploadFile")
+	defer span.End()
+
+	remotePath, err := c.ResolvePath(ctx, remotePath)
+	if err != nil {
+		return err
+	}
+
+	local, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer local.Close()
+
+	info, err := local.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat local file: %w", err)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, local); err != nil {
+		return fmt.Errorf("failed to read local file: %w", err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	// The partial file has a fixed name, so a later attempt can find it
+	partialPath := filepath.Join(filepath.Dir(remotePath), "."+filepath.Base(remotePath)+".partial")
+	if usesSudo(ctx) {
+		partialPath = path.Join(c.tempDir, ".terraform-provider-ssh-upload-"+ContentChecksum(remotePath)[:16])
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = c.uploadPartial(ctx, local, info.Size(), partialPath, opts.Resumable)
+		if err == nil {
+			err = c.verifyUpload(ctx, partialPath, checksum)
+		}
+		// Resuming a corrupted partial file fails the checksum again, so the next attempt starts over
+		if errors.Is(err, ErrChecksum) {
+			c.removePartial(ctx, partialPath)
+		}
+		if err == nil || attempt >= opts.Retries || ctx.Err() != nil {
+			break
+		}
+		c.logger.WithContext(ctx).WithError(err).WithField("attempt", attempt+1).Warn("Upload failed, retrying")
+	}
+	if err != nil {
+		if !opts.Resumable {
+			c.removePartial(ctx, partialPath)
+		}
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to upload file")
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	if usesSudo(ctx) {
+		defer c.removePartial(ctx, partialPath)
+		cmd := fmt.Sprintf("install -m %04o %s %s", permissions, shellQuote(c.commandPath(partialPath)), shellQuote(c.commandPath(remotePath)))
+		if _, err := c.RunCommand(ctx, cmd); err != nil {
+			c.logger.WithContext(ctx).WithError(err).Error("Failed to install file")
+			return fmt.Errorf("failed to install file: %w", err)
+		}
+		return nil
+	}
+
+	if err := c.withOperationTimeout(ctx, func() error {
+		return c.sftp().Chmod(partialPath, permissions)
+	}); err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to set file permissions")
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := c.withOperationTimeout(ctx, func() error {
+		return c.sftp().PosixRename(partialPath, remotePath)
+	}); err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to move file into place")
+		return fmt.Errorf("failed to move file into place: %w", err)
+	}
+
+	return nil
+}
+
+// uploadPartial writes the local file to partialPath. If resume is set, data already in partialPath is
+// kept and the transfer continues at its end.
+func (c *SSHClient) uploadPartial(ctx context.Context, local *os.File, size int64, partialPath string, resume bool) error {
+	var offset int64
+	if resume {
+		info, err := c.statPath(ctx, partialPath)
+		// A partial file larger than the local file is from another upload and starts over
+		if err == nil && info.Size() <= size {
+			offset = info.Size()
+		} else if err != nil && !isNotExist(err) {
+			return fmt.Errorf("failed to stat partial file: %w", err)
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	remote, err := withOperationTimeoutValue(ctx, c, func() (*sftp.File, error) {
+		return c.sftp().OpenFile(partialPath, flags)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open partial file: %w", err)
+	}
+	defer remote.Close()
+
+	if offset > 0 {
+		c.logger.WithContext(ctx).WithField("offset", offset).Info("Resuming upload")
+	}
+
+	buf := make([]byte, uploadChunkSize)
+	for offset < size {
+		n, err := local.ReadAt(buf, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read local file: %w", err)
+		}
+		if n == 0 {
+			break
+		}
+		if _, err := withOperationTimeoutValue(ctx, c, func() (int, error) {
+			return remote.WriteAt(buf[:n], offset)
+		}); err != nil {
+			return fmt.Errorf("failed to write partial file at offset %d: %w", offset, err)
+		}
+		offset += int64(n)
+	}
+
+	if err := remote.Close(); err != nil {
+		return fmt.Errorf("failed to write partial file: %w", err)
+	}
+	return nil
+}
+
+// verifyUpload compares the SHA-256 checksum of the partial file with the one of the local file
+func (c *SSHClient) verifyUpload(ctx context.Context, partialPath string, checksum string) error {
+	remoteChecksum, err := c.FileDigest(ctx, partialPath, "sha256")
+	if err != nil {
+		return fmt.Errorf("failed to verify upload: %w", err)
+	}
+	if remoteChecksum != checksum {
+		return fmt.Errorf("%w: uploaded file has checksum %s, local file %s", ErrChecksum, remoteChecksum, checksum)
+	}
+	return nil
+}
+
+// removePartial removes a partial upload. It doesn't depend on ctx, so it also works after ctx was cancelled.
+func (c *SSHClient) removePartial(ctx context.Context, partialPath string) {
+	if err := c.sftp().Remove(partialPath); err != nil && !isNotExist(err) {
+		c.logger.WithContext(ctx).WithError(err).Warn("Failed to remove partial upload")
+	}
+}
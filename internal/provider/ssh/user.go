@@ -0,0 +1,187 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"golang.org/x/crypto/ssh"
+)
+
+// User describes a user account on the remote host
+type User struct {
+	Name   string
+	UID    int64
+	GID    int64
+	Home   string
+	Shell  string
+	Groups []string // Supplementary groups, excluding the primary group
+}
+
+// UserSpec describes the desired state of a user account. Empty fields are left to the system defaults
+// on creation and unchanged on modification.
+type UserSpec struct {
+	Name   string
+	UID    *int64
+	Home   string
+	Shell  string
+	Groups []string // Supplementary groups; nil leaves them unchanged, an empty slice removes all
+	System bool     // Create a system account, only used on creation
+}
+
+// GetUser looks up a user account with getent. It returns nil if the user does not exist.
+func (c *SSHClient) GetUser(ctx context.Context, name string) (*User, error) {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "GetUser")
+	defer span.End()
+
+	output, err := c.runCommandWithRetry(ctx, "getent passwd "+shellQuote(name))
+	if err != nil {
+		if isExitStatus(err, 2) {
+			return nil, nil
+		}
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get user")
+		return nil, fmt.Errorf("failed to get user %s: %w", name, err)
+	}
+
+	user, err := parsePasswdEntry(output)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := c.RunCommand(ctx, "id -Gn "+shellQuote(name))
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get user groups")
+		return nil, fmt.Errorf("failed to get groups of user %s: %w", name, err)
+	}
+	primaryGroup, err := c.RunCommand(ctx, "id -gn "+shellQuote(name))
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get user primary group")
+		return nil, fmt.Errorf("failed to get primary group of user %s: %w", name, err)
+	}
+
+	user.Groups = []string{}
+	for _, group := range strings.Fields(groups) {
+		if group != strings.TrimSpace(primaryGroup) {
+			user.Groups = append(user.Groups, group)
+		}
+	}
+
+	return user, nil
+}
+
+// CreateUser creates a user account with useradd
+func (c *SSHClient) CreateUser(ctx context.Context, spec UserSpec) error {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "CreateUser")
+	defer span.End()
+
+	args := userArgs(spec)
+	if spec.System {
+		args = append(args, "-r")
+	}
+
+	cmd := "useradd " + strings.Join(append(args, shellQuote(spec.Name)), " ")
+	if _, err := c.RunCommand(ctx, cmd); err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to create user")
+		return fmt.Errorf("failed to create user %s: %w", spec.Name, privilegeHint(ctx, err))
+	}
+
+	return nil
+}
+
+// UpdateUser modifies a user account with usermod
+func (c *SSHClient) UpdateUser(ctx context.Context, spec UserSpec) error {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "UpdateUser")
+	defer span.End()
+
+	args := userArgs(spec)
+	if len(args) == 0 {
+		return nil
+	}
+
+	cmd := "usermod " + strings.Join(append(args, shellQuote(spec.Name)), " ")
+	if _, err := c.RunCommand(ctx, cmd); err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to update user")
+		return fmt.Errorf("failed to update user %s: %w", spec.Name, privilegeHint(ctx, err))
+	}
+
+	return nil
+}
+
+// DeleteUser deletes a user account with userdel. The home directory is kept.
+// A user that does not exist is treated as already deleted.
+func (c *SSHClient) DeleteUser(ctx context.Context, name string) error {
+	ctx, span := otel.Tracer("ssh-provider").Start(ctx, "DeleteUser")
+	defer span.End()
+
+	if _, err := c.RunCommand(ctx, "userdel "+shellQuote(name)); err != nil {
+		// userdel exits with 6 if the user does not exist
+		if isExitStatus(err, 6) {
+			return nil
+		}
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to delete user")
+		return fmt.Errorf("failed to delete user %s: %w", name, privilegeHint(ctx, err))
+	}
+
+	return nil
+}
+
+// userArgs builds the options shared by useradd and usermod
+func userArgs(spec UserSpec) []string {
+	var args []string
+	if spec.UID != nil {
+		args = append(args, "-u", strconv.FormatInt(*spec.UID, 10))
+	}
+	if spec.Home != "" {
+		args = append(args, "-d", shellQuote(spec.Home))
+	}
+	if spec.Shell != "" {
+		args = append(args, "-s", shellQuote(spec.Shell))
+	}
+	if spec.Groups != nil {
+		args = append(args, "-G", shellQuote(strings.Join(spec.Groups, ",")))
+	}
+	return args
+}
+
+// parsePasswdEntry parses a passwd line ("name:x:uid:gid:gecos:home:shell")
+func parsePasswdEntry(entry string) (*User, error) {
+	fields := strings.Split(strings.TrimSpace(entry), ":")
+	if len(fields) < 7 {
+		return nil, fmt.Errorf("invalid passwd entry: %s", entry)
+	}
+
+	uid, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid in passwd entry: %s", entry)
+	}
+	gid, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid in passwd entry: %s", entry)
+	}
+
+	return &User{
+		Name:  fields[0],
+		UID:   uid,
+		GID:   gid,
+		Home:  fields[5],
+		Shell: fields[6],
+	}, nil
+}
+
+// privilegeHint adds a hint to set use_sudo to the error of a user or group management command that
+// failed with a status these commands use for files they can't update (1 or 10), unless it ran through sudo
+func privilegeHint(ctx context.Context, err error) error {
+	if usesSudo(ctx) || !isExitStatus(err, 1) && !isExitStatus(err, 10) {
+		return err
+	}
+	return fmt.Errorf("%w (managing users and groups requires root, set use_sudo unless the SSH user is root)", err)
+}
+
+// isExitStatus reports whether err is a remote command that exited with the given status
+func isExitStatus(err error, status int) bool {
+	var exitErr *ssh.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitStatus() == status
+}
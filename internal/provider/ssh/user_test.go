@@ -0,0 +1,196 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// commandResult is the scripted outcome of a remote command
+type commandResult struct {
+	stdout string
+	stderr string
+	status uint32
+}
+
+// commandServer is a minimal SSH server that records the commands it is asked to run and answers them
+// with the result of its handler instead of running them
+type commandServer struct {
+	handle func(cmd string) commandResult
+
+	mu       sync.Mutex
+	commands []string
+}
+
+// Commands returns the commands run so far
+func (s *commandServer) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// newCommandTestClient returns a client connected to a commandServer that answers commands with handle
+func newCommandTestClient(t *testing.T, handle func(cmd string) commandResult) (*SSHClient, *commandServer) {
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	Expect(err).ToNot(HaveOccurred())
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	server := &commandServer{handle: handle}
+	clientConn, serverConn := memoryPipe()
+	go server.serve(serverConn, serverConfig)
+
+	conn, chans, reqs, err := ssh.NewClientConn(clientConn, "memory", &ssh.ClientConfig{
+		User:            "testuser",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	Expect(err).ToNot(HaveOccurred())
+	sshClient := ssh.NewClient(conn, chans, reqs)
+	t.Cleanup(func() { sshClient.Close() })
+
+	return &SSHClient{
+		sshClient:  sshClient,
+		logger:     logrus.New(),
+		newSession: sshClient.NewSession,
+		closed:     make(chan struct{}),
+	}, server
+}
+
+// serve accepts the SSH connection on conn and answers the exec requests of its sessions
+func (s *commandServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				var exec struct{ Command string }
+				if req.Type != "exec" || ssh.Unmarshal(req.Payload, &exec) != nil {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				_ = req.Reply(true, nil)
+
+				s.mu.Lock()
+				s.commands = append(s.commands, exec.Command)
+				s.mu.Unlock()
+
+				result := s.handle(exec.Command)
+				_, _ = channel.Write([]byte(result.stdout))
+				_, _ = channel.Stderr().Write([]byte(result.stderr))
+				_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{result.status}))
+				return
+			}
+		}()
+	}
+}
+
+func TestUserArgs(t *testing.T) {
+	RegisterTestingT(t)
+
+	uid := int64(1001)
+	tests := []struct {
+		name     string
+		spec     UserSpec
+		expected []string
+	}{
+		{"nothing set", UserSpec{Name: "app"}, nil},
+		{"uid", UserSpec{Name: "app", UID: &uid}, []string{"-u", "1001"}},
+		{"home and shell", UserSpec{Name: "app", Home: "/srv/app", Shell: "/bin/bash"}, []string{"-d", "'/srv/app'", "-s", "'/bin/bash'"}},
+		{"groups", UserSpec{Name: "app", Groups: []string{"docker", "adm"}}, []string{"-G", "'docker,adm'"}},
+		{"no groups", UserSpec{Name: "app", Groups: []string{}}, []string{"-G", "''"}},
+		{"quoted home", UserSpec{Name: "app", Home: "/srv/it's"}, []string{"-d", `'/srv/it'"'"'s'`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			Expect(userArgs(test.spec)).To(Equal(test.expected))
+		})
+	}
+}
+
+func TestUserCommands(t *testing.T) {
+	RegisterTestingT(t)
+
+	results := map[string]commandResult{}
+	client, server := newCommandTestClient(t, func(cmd string) commandResult {
+		return results[cmd]
+	})
+	ctx := context.Background()
+	uid := int64(1001)
+
+	t.Log("Create a system user with all options")
+	Expect(client.CreateUser(ctx, UserSpec{Name: "app", UID: &uid, Home: "/srv/app", Shell: "/bin/bash", Groups: []string{"docker"}, System: true})).To(Succeed())
+	Expect(server.Commands()).To(Equal([]string{"useradd -u 1001 -d '/srv/app' -s '/bin/bash' -G 'docker' -r 'app'"}))
+
+	t.Log("Modify only the given options, and nothing without options")
+	Expect(client.UpdateUser(ctx, UserSpec{Name: "app", Shell: "/bin/sh"})).To(Succeed())
+	Expect(client.UpdateUser(ctx, UserSpec{Name: "app"})).To(Succeed())
+	Expect(server.Commands()[1:]).To(Equal([]string{"usermod -s '/bin/sh' 'app'"}))
+
+	t.Log("Read a user with its supplementary groups")
+	results["getent passwd 'app'"] = commandResult{stdout: "app:x:1001:1001::/srv/app:/bin/sh\n"}
+	results["id -Gn 'app'"] = commandResult{stdout: "app docker adm\n"}
+	results["id -gn 'app'"] = commandResult{stdout: "app\n"}
+	user, err := client.GetUser(ctx, "app")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(user.UID).To(BeEquivalentTo(1001))
+	Expect(user.Groups).To(Equal([]string{"docker", "adm"}))
+
+	t.Log("A missing user is reported as nil")
+	results["getent passwd 'gone'"] = commandResult{status: 2}
+	Expect(client.GetUser(ctx, "gone")).To(BeNil())
+
+	t.Log("Deleting a user that doesn't exist succeeds")
+	results["userdel 'gone'"] = commandResult{stderr: "userdel: user 'gone' does not exist\n", status: 6}
+	Expect(client.DeleteUser(ctx, "gone")).To(Succeed())
+
+	t.Log("Other failures are reported, with a hint to use sudo if permissions are missing")
+	results["userdel 'app'"] = commandResult{stderr: "userdel: Permission denied.\n", status: 1}
+	err = client.DeleteUser(ctx, "app")
+	Expect(err).To(MatchError(ContainSubstring("userdel: Permission denied.")))
+	Expect(err).To(MatchError(ContainSubstring("set use_sudo")))
+	results["useradd 'app'"] = commandResult{stderr: "useradd: user 'app' already exists\n", status: 9}
+	err = client.CreateUser(ctx, UserSpec{Name: "app"})
+	Expect(err).To(MatchError(ContainSubstring("already exists")))
+	Expect(err).ToNot(MatchError(ContainSubstring("use_sudo")))
+
+	t.Log("With sudo, commands run through sudo and failures don't suggest it")
+	sudoDelete := `sudo -n sh -c 'userdel '"'"'app'"'"''`
+	results[sudoDelete] = commandResult{stderr: "userdel: user app is currently used by process 1\n", status: 8}
+	err = client.DeleteUser(WithSudo(ctx), "app")
+	Expect(err).To(MatchError(ContainSubstring("currently used")))
+	Expect(err).ToNot(MatchError(ContainSubstring("use_sudo")))
+	Expect(server.Commands()).To(ContainElement(sudoDelete))
+}
+
+func TestParsePasswdEntry(t *testing.T) {
+	RegisterTestingT(t)
+
+	user, err := parsePasswdEntry("testuser:x:1000:1001:Test User:/home/testuser:/bin/sh\n")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(user.Name).To(Equal("testuser"))
+	Expect(user.UID).To(BeEquivalentTo(1000))
+	Expect(user.GID).To(BeEquivalentTo(1001))
+	Expect(user.Home).To(Equal("/home/testuser"))
+	Expect(user.Shell).To(Equal("/bin/sh"))
+
+	_, err = parsePasswdEntry("invalid")
+	Expect(err).To(HaveOccurred())
+}
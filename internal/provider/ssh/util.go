@@ -1,6 +1,20 @@
 package ssh
 
-import "strconv"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
 
 func ParsePermissions(perms string) uint32 {
 	if perms == "" {
@@ -12,3 +26,498 @@ func ParsePermissions(perms string) uint32 {
 	}
 	return uint32(p)
 }
+
+// FileType names the type of a file mode: "file", "dir", "symlink", "block", "char", "fifo" or "socket"
+func FileType(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "dir"
+	case mode&os.ModeSymlink != 0:
+		return "symlink"
+	case mode&os.ModeCharDevice != 0:
+		return "char"
+	case mode&os.ModeDevice != 0:
+		return "block"
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	default:
+		return "file"
+	}
+}
+
+// PermissionBits returns the permission bits of mode in their octal positions, including the setuid (04000),
+// setgid (02000) and sticky (01000) bits that os.FileMode stores elsewhere, so that a directory with
+// mode 1777 formats as "1777" and compares equal to ParsePermissions("1777")
+func PermissionBits(mode os.FileMode) os.FileMode {
+	bits := mode.Perm()
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return bits
+}
+
+// ParseMode parses a file mode in octal ("0750") or symbolic ("u=rwx,g=rx,o=") notation. Unlike
+// ParsePermissions, invalid modes are reported as an error instead of falling back to 0644.
+//
+// Symbolic modes are a comma-separated list of clauses like chmod(1) accepts, applied to an empty
+// mode: who ("u", "g", "o", "a", or none for all), an operator ("=", "+" or "-") and the permissions
+// ("r", "w", "x", "s" for setuid/setgid and "t" for the sticky bit).
+func ParseMode(mode string) (uint32, error) {
+	mode = strings.TrimSpace(mode)
+	if mode == "" {
+		return 0, fmt.Errorf("mode must not be empty")
+	}
+
+	if mode[0] >= '0' && mode[0] <= '9' {
+		perms, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || perms > 07777 {
+			return 0, fmt.Errorf("invalid octal mode %q", mode)
+		}
+		return uint32(perms), nil
+	}
+
+	var perms uint32
+	for _, clause := range strings.Split(mode, ",") {
+		var err error
+		perms, err = applySymbolicClause(perms, clause)
+		if err != nil {
+			return 0, fmt.Errorf("invalid symbolic mode %q: %w", mode, err)
+		}
+	}
+	return perms, nil
+}
+
+// applySymbolicClause applies a single symbolic mode clause such as "g+rx" to perms
+func applySymbolicClause(perms uint32, clause string) (uint32, error) {
+	// Which permission classes the clause applies to, as masks of their rwx bits
+	var who uint32
+	i := 0
+	for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+		switch clause[i] {
+		case 'u':
+			who |= 04700
+		case 'g':
+			who |= 02070
+		case 'o':
+			who |= 01007
+		case 'a':
+			who |= 07777
+		}
+	}
+	if who == 0 {
+		who = 07777
+	}
+	if i == len(clause) {
+		return 0, fmt.Errorf("clause %q has no operator", clause)
+	}
+
+	for i < len(clause) {
+		op := clause[i]
+		if op != '=' && op != '+' && op != '-' {
+			return 0, fmt.Errorf("clause %q has an invalid operator %q", clause, op)
+		}
+		i++
+
+		var bits uint32
+		for ; i < len(clause) && strings.IndexByte("=+-", clause[i]) < 0; i++ {
+			switch clause[i] {
+			case 'r':
+				bits |= 0444
+			case 'w':
+				bits |= 0222
+			case 'x':
+				bits |= 0111
+			case 's':
+				bits |= 06000
+			case 't':
+				bits |= 01000
+			default:
+				return 0, fmt.Errorf("clause %q has an invalid permission %q", clause, clause[i])
+			}
+		}
+		bits &= who
+
+		switch op {
+		case '=':
+			perms = perms&^who | bits
+		case '+':
+			perms |= bits
+		case '-':
+			perms &^= bits
+		}
+	}
+
+	return perms, nil
+}
+
+// parseModeString parses the permissions of a mode string as printed by ls -l, e.g. "-rwsr-xr-t", including
+// the setuid, setgid and sticky bits. A trailing "+" or "." for ACLs or security contexts is ignored.
+func parseModeString(mode string) (os.FileMode, error) {
+	if len(mode) < 10 {
+		return 0, fmt.Errorf("invalid mode string %q", mode)
+	}
+
+	// The execute column of each class also shows its special bit: lowercase with execute, uppercase without
+	specialBits := [3]os.FileMode{04000, 02000, 01000}
+	specialLetters := [3]byte{'s', 's', 't'}
+
+	var perms os.FileMode
+	for i := range 9 {
+		c := mode[1+i]
+		bit := os.FileMode(0400 >> i)
+		class := i / 3
+		switch {
+		case c == '-':
+		case c == "rwx"[i%3]:
+			perms |= bit
+		case i%3 == 2 && c == specialLetters[class]:
+			perms |= bit | specialBits[class]
+		case i%3 == 2 && c == specialLetters[class]-'a'+'A':
+			perms |= specialBits[class]
+		default:
+			return 0, fmt.Errorf("invalid mode string %q", mode)
+		}
+	}
+	return perms, nil
+}
+
+// parseGetcapOutput extracts the capability text from getcap output. Both the current format
+// ("/path cap_net_bind_service=ep") and the legacy format ("/path = cap_net_bind_service+ep") are supported.
+func parseGetcapOutput(output string, path string) string {
+	caps := strings.TrimSpace(output)
+	caps = strings.TrimPrefix(caps, path)
+	caps = strings.TrimSpace(caps)
+	caps = strings.TrimPrefix(caps, "=")
+	return strings.TrimSpace(caps)
+}
+
+// parseLsattrOutput parses the output of "lsattr -d", e.g. "----i---------e------- /path/to/file".
+// The first field holds one column per flag, which is either "-" or the flag's letter. Only this field
+// is parsed, so letters in the path are never mistaken for flags. The number and order of columns
+// differ between e2fsprogs and busybox versions, so each column is identified by its letter.
+func parseLsattrOutput(output string) (*FileAttributes, error) {
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("unexpected lsattr output %q", output)
+	}
+	flags := fields[0]
+
+	set := make(map[byte]bool, len(flags))
+	for i := 0; i < len(flags); i++ {
+		column := flags[i]
+		switch {
+		case column == '-':
+		case column >= 'a' && column <= 'z', column >= 'A' && column <= 'Z':
+			set[column] = true
+		default:
+			return nil, fmt.Errorf("unexpected lsattr output %q", output)
+		}
+	}
+
+	return &FileAttributes{
+		Immutable:      set['i'],
+		AppendOnly:     set['a'],
+		NoDump:         set['d'],
+		Synchronous:    set['S'],
+		NoAtime:        set['A'],
+		Compressed:     set['c'],
+		NoCoW:          set['C'],
+		Undeletable:    set['u'],
+		DataJournaling: set['j'],
+		NoTailMerge:    set['t'],
+		TopDir:         set['T'],
+		DirSync:        set['D'],
+		Extents:        set['e'],
+		Encrypted:      set['E'],
+	}, nil
+}
+
+// capabilityFlags are the flags of a capability in the order of the canonical form
+const capabilityFlags = "eip"
+
+// NormalizeCapabilities converts a capability text in the format of cap_from_text(3), e.g.
+// "cap_net_raw,cap_net_admin+ep", into a canonical form with the resulting flags of each capability,
+// e.g. "cap_net_admin=ep cap_net_raw=ep". The clauses are applied in order like setcap does, so texts
+// that result in the same flags compare equal. A text that can't be parsed is returned trimmed.
+func NormalizeCapabilities(caps string) string {
+	flags, err := parseCapabilities(caps)
+	if err != nil {
+		return strings.TrimSpace(caps)
+	}
+
+	var clauses []string
+	for _, name := range slices.Sorted(maps.Keys(flags)) {
+		var set strings.Builder
+		for i, flag := range capabilityFlags {
+			if flags[name]&(1<<i) != 0 {
+				set.WriteRune(flag)
+			}
+		}
+		if set.Len() > 0 {
+			clauses = append(clauses, name+"="+set.String())
+		}
+	}
+	return strings.Join(clauses, " ")
+}
+
+// parseCapabilities applies the clauses of a capability text to empty capability sets and returns the
+// resulting flags by capability name, as bits in the order of capabilityFlags. An empty list of
+// capabilities, as in "=ep", means all of them and is reported as "all".
+func parseCapabilities(text string) (map[string]uint8, error) {
+	flags := make(map[string]uint8)
+	for _, clause := range strings.Fields(strings.ToLower(text)) {
+		start := strings.IndexAny(clause, "=+-")
+		if start < 0 {
+			return nil, fmt.Errorf("capability clause %q has no operator", clause)
+		}
+		names := strings.Split(clause[:start], ",")
+		if clause[:start] == "" {
+			names = []string{"all"}
+		}
+
+		for actions := clause[start:]; actions != ""; {
+			operator := actions[0]
+			end := strings.IndexAny(actions[1:], "=+-") + 1
+			if end == 0 {
+				end = len(actions)
+			}
+			var set uint8
+			for _, flag := range actions[1:end] {
+				i := strings.IndexRune(capabilityFlags, flag)
+				if i < 0 {
+					return nil, fmt.Errorf("capability clause %q has an unknown flag %q", clause, flag)
+				}
+				set |= 1 << i
+			}
+			for _, name := range names {
+				switch operator {
+				case '=':
+					flags[name] = set
+				case '+':
+					flags[name] |= set
+				case '-':
+					flags[name] &^= set
+				}
+			}
+			actions = actions[end:]
+		}
+	}
+	return flags, nil
+}
+
+// ContentChecksum returns the hex encoded SHA-256 checksum of content, matching SSHClient.FileChecksum
+func ContentChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// SplitLines splits content into lines on "\n". A trailing newline ends the last line instead of starting
+// an empty one, so "a\nb\n" and "a\nb" both have the lines "a" and "b", and empty content has no lines.
+func SplitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// shellQuote quotes s for use as a single argument in a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// expandHome replaces a leading "~/" in a local path with the home directory of the current user
+func expandHome(localPath string) (string, error) {
+	if !strings.HasPrefix(localPath, "~/") {
+		return localPath, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %w", err)
+	}
+	return filepath.Join(home, localPath[2:]), nil
+}
+
+// expandTilde replaces a leading "~" in a remote path with home
+func expandTilde(remotePath string, home string) string {
+	if remotePath == "~" {
+		return home
+	}
+	if strings.HasPrefix(remotePath, "~/") {
+		return path.Join(home, remotePath[2:])
+	}
+	return remotePath
+}
+
+// deriveSFTPRoot derives the SFTP root from the home directory seen by remote commands and the SFTP
+// working directory, e.g. "/srv/sftp" from "/srv/sftp/home/user" and "/home/user", or the home
+// directory itself if the server chroots into it and starts in "/".
+func deriveSFTPRoot(home string, workingDir string) string {
+	if home == workingDir {
+		return ""
+	}
+	if workingDir == "/" {
+		return home
+	}
+	if root, ok := strings.CutSuffix(home, workingDir); ok && root != "" {
+		return root
+	}
+	return ""
+}
+
+// envNamePattern matches environment variable names that can be set in a POSIX shell
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateEnvName checks that an environment variable name can be set in a POSIX shell
+func ValidateEnvName(name string) error {
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid environment variable name", name)
+	}
+	return nil
+}
+
+// envCommand wraps a shell command so that it runs with the environment variables, e.g.
+// env 'GREETING=hello world' sh -c 'echo "$GREETING"'. Values are quoted, so they may contain any
+// character. The command runs in its own shell, so the variables apply to all of its parts. The shell
+// is sh unless shell is set.
+func envCommand(env map[string]string, shell string, cmd string) (string, error) {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		if err := ValidateEnvName(name); err != nil {
+			return "", err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("env")
+	for _, name := range names {
+		b.WriteString(" " + shellQuote(name+"="+env[name]))
+	}
+	if shell == "" {
+		b.WriteString(" sh -c " + shellQuote(cmd))
+	} else {
+		b.WriteString(" " + shellCommand(shell, cmd))
+	}
+	return b.String(), nil
+}
+
+// shellCommand wraps a command so that it runs in the given shell, e.g. '/bin/bash' -c 'echo "${A[@]}"'
+func shellCommand(shell string, cmd string) string {
+	return shellQuote(shell) + " -c " + shellQuote(cmd)
+}
+
+// ValidateShell checks that a shell for commands is an absolute, clean path such as "/bin/bash", so which
+// shell runs doesn't depend on the PATH of the remote user
+func ValidateShell(shell string) error {
+	if !path.IsAbs(shell) {
+		return fmt.Errorf("shell %q must be an absolute path, e.g. \"/bin/sh\"", shell)
+	}
+	if path.Clean(shell) != shell || shell == "/" {
+		return fmt.Errorf("shell %q must be a clean path to an executable, e.g. \"/bin/sh\"", shell)
+	}
+	for _, r := range shell {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("shell %q must not contain whitespace or control characters", shell)
+		}
+	}
+	return nil
+}
+
+// ownerTokenPattern matches user and group names and numeric IDs accepted by chown
+var ownerTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.@-]*\$?$`)
+
+// ValidateOwnerToken checks that a user or group is a plausible name or numeric ID. Values are still
+// shell-quoted when passed to chown or install; this rejects values such as "alice;reboot" early, with
+// a clear error instead of a failing command.
+func ValidateOwnerToken(value string) error {
+	if !ownerTokenPattern.MatchString(value) {
+		return fmt.Errorf("%q is neither a valid name nor a numeric ID", value)
+	}
+	return nil
+}
+
+// isNumericID reports whether a user or group is given as numeric ID
+func isNumericID(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// chownOwner builds the owner argument of chown from a user and a group, each of which may be a name
+// or a numeric ID. chown leaves the group unchanged if only the user is given, and the user if only the
+// group is given. It is empty if neither is set.
+func chownOwner(ownership *FileOwnership) (string, error) {
+	for _, value := range []string{ownership.User, ownership.Group} {
+		if value == "" {
+			continue
+		}
+		if err := ValidateOwnerToken(value); err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case ownership.User != "" && ownership.Group != "":
+		return ownership.User + ":" + ownership.Group, nil
+	case ownership.Group != "":
+		return ":" + ownership.Group, nil
+	default:
+		return ownership.User, nil
+	}
+}
+
+// installOwnerArgs builds the owner options of install, e.g. " -o 'alice' -g 'staff'", from a user and a
+// group that may each be empty. Both are validated like the ones passed to chown.
+func installOwnerArgs(user string, group string) (string, error) {
+	var args string
+	for _, option := range []struct{ flag, value string }{{"-o", user}, {"-g", group}} {
+		if option.value == "" {
+			continue
+		}
+		if err := ValidateOwnerToken(option.value); err != nil {
+			return "", err
+		}
+		args += " " + option.flag + " " + shellQuote(option.value)
+	}
+	return args, nil
+}
+
+// clientVersionPrefix is the required start of an SSH identification string for protocol version 2
+const clientVersionPrefix = "SSH-2.0-"
+
+// checkClientVersion checks that an identification string is valid according to RFC 4253, section 4.2:
+// it starts with "SSH-2.0-", names a software version and consists of printable ASCII characters, with
+// at most 255 characters including the line break the ssh package appends.
+func checkClientVersion(version string) error {
+	software, ok := strings.CutPrefix(version, clientVersionPrefix)
+	if !ok {
+		return fmt.Errorf("client version %q must start with %q", version, clientVersionPrefix)
+	}
+	if software == "" || strings.HasPrefix(software, " ") {
+		return fmt.Errorf("client version %q must name a software version after %q", version, clientVersionPrefix)
+	}
+	if len(version) > 253 {
+		return fmt.Errorf("client version must not be longer than 253 characters, got %d", len(version))
+	}
+	for _, r := range version {
+		if r < 0x20 || r > 0x7e {
+			return fmt.Errorf("client version %q must only contain printable ASCII characters", version)
+		}
+	}
+	return nil
+}
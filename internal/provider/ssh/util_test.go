@@ -2,6 +2,7 @@ package ssh
 
 import (
 	. "github.com/onsi/gomega"
+	"os"
 	"testing"
 )
 
@@ -18,6 +19,9 @@ func TestParsePermissions(t *testing.T) {
 		{"0777", 0777},
 		{"0600", 0600},
 		{"600", 0600},
+		{"1777", 01777},
+		{"2755", 02755},
+		{"4755", 04755},
 	}
 	for _, test := range tests {
 		t.Run(test.str, func(t *testing.T) {
@@ -25,3 +29,332 @@ func TestParsePermissions(t *testing.T) {
 		})
 	}
 }
+
+func TestParseMode(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		mode     string
+		expected uint32
+	}{
+		{"0755", 0755},
+		{"640", 0640},
+		{"1777", 01777},
+		{"u=rwx,g=rx,o=", 0750},
+		{"u=rw,go=r", 0644},
+		{"a=r", 0444},
+		{"ug=rw", 0660},
+		{"+x", 0111},
+		{"a=rwx,o-w", 0775},
+		{"u=rw+x", 0700},
+		{"u=rwx,go=rx,+t", 01755},
+		{"u=rwxs,g=rxs", 06750},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			Expect(ParseMode(test.mode)).To(Equal(test.expected))
+		})
+	}
+
+	for _, mode := range []string{"", "u", "z=r", "u=q", "u=rw,", "08", "17777"} {
+		_, err := ParseMode(mode)
+		Expect(err).To(HaveOccurred(), "mode %q", mode)
+	}
+}
+
+func TestParseModeString(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		mode     string
+		expected os.FileMode
+	}{
+		{"-rw-r--r--", 0644},
+		{"drwxr-x---", 0750},
+		{"-rwsr-xr-x", 04755},
+		{"-rwSr--r--", 04644},
+		{"drwxrws---", 02770},
+		{"drwxrwxrwt", 01777},
+		{"drw-r--r-T", 01644},
+		{"-rw-r--r--+", 0644},
+		{"-rw-r--r--.", 0644},
+	}
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			Expect(parseModeString(test.mode)).To(Equal(test.expected))
+		})
+	}
+
+	for _, mode := range []string{"", "-rw-r--r", "-rwxrwxrws", "-rwtr--r--", "-wr-r--r--"} {
+		_, err := parseModeString(mode)
+		Expect(err).To(HaveOccurred(), "mode %q", mode)
+	}
+}
+
+func TestParseGetcapOutput(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		output   string
+		expected string
+	}{
+		{"/usr/bin/app cap_net_bind_service=ep\n", "cap_net_bind_service=ep"},
+		{"/usr/bin/app = cap_net_bind_service+ep\n", "cap_net_bind_service+ep"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.output, func(t *testing.T) {
+			Expect(parseGetcapOutput(test.output, "/usr/bin/app")).To(Equal(test.expected))
+		})
+	}
+}
+
+func TestNormalizeCapabilities(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Log("Texts with the same resulting flags are equal")
+	Expect(NormalizeCapabilities("cap_net_bind_service+ep")).To(Equal("cap_net_bind_service=ep"))
+	Expect(NormalizeCapabilities(" cap_net_raw,CAP_NET_ADMIN=pe ")).To(Equal("cap_net_admin=ep cap_net_raw=ep"))
+	Expect(NormalizeCapabilities("cap_net_raw=ep cap_net_admin+i")).To(Equal("cap_net_admin=i cap_net_raw=ep"))
+	Expect(NormalizeCapabilities("cap_net_raw+ep-e")).To(Equal("cap_net_raw=p"))
+	Expect(NormalizeCapabilities("cap_net_raw=")).To(Equal(""))
+	Expect(NormalizeCapabilities("")).To(Equal(""))
+
+	t.Log("Clauses are applied in order, so adding and setting flags differ")
+	Expect(NormalizeCapabilities("cap_net_raw=ep cap_net_raw+i")).To(Equal("cap_net_raw=eip"))
+	Expect(NormalizeCapabilities("cap_net_raw=ep cap_net_raw=i")).To(Equal("cap_net_raw=i"))
+
+	t.Log("Texts that can't be parsed are compared as they are")
+	Expect(NormalizeCapabilities(" cap_net_raw ")).To(Equal("cap_net_raw"))
+	Expect(NormalizeCapabilities("cap_net_raw=x")).To(Equal("cap_net_raw=x"))
+}
+
+func TestParseLsattrOutput(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		name     string
+		output   string
+		expected FileAttributes
+	}{
+		{
+			name:     "no flags",
+			output:   "---------------------- /home/user/file\n",
+			expected: FileAttributes{},
+		},
+		{
+			name:     "extents only",
+			output:   "--------------e------- /home/user/file\n",
+			expected: FileAttributes{Extents: true},
+		},
+		{
+			name:     "immutable",
+			output:   "----i---------e------- /etc/resolv.conf\n",
+			expected: FileAttributes{Immutable: true, Extents: true},
+		},
+		{
+			name:     "append-only log",
+			output:   "-----a--------e------- /var/log/audit/audit.log\n",
+			expected: FileAttributes{AppendOnly: true, Extents: true},
+		},
+		{
+			name:   "many flags",
+			output: "-uSDiadAc-Ej-tTeC----- /srv/data\n",
+			expected: FileAttributes{
+				Undeletable: true, Synchronous: true, DirSync: true, Immutable: true, AppendOnly: true,
+				NoDump: true, NoAtime: true, Compressed: true, Encrypted: true, DataJournaling: true,
+				NoTailMerge: true, TopDir: true, Extents: true, NoCoW: true,
+			},
+		},
+		{
+			name:     "letters in path are ignored",
+			output:   "--------------e------- /data/immutable-dumps/a\n",
+			expected: FileAttributes{Extents: true},
+		},
+		{
+			name:     "older lsattr with fewer columns",
+			output:   "-------------e-- /data/cat\n",
+			expected: FileAttributes{Extents: true},
+		},
+		{
+			name:     "leading whitespace and spaces in path",
+			output:   "  ------d-------e------- /home/user/my files\n",
+			expected: FileAttributes{NoDump: true, Extents: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attrs, err := parseLsattrOutput(test.output)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*attrs).To(Equal(test.expected))
+		})
+	}
+
+	_, err := parseLsattrOutput("")
+	Expect(err).To(HaveOccurred())
+	_, err = parseLsattrOutput("lsattr: Operation not supported While reading flags on /proc/1")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestContentChecksum(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(ContentChecksum("")).To(Equal("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"))
+	Expect(ContentChecksum("Hello, World!")).To(Equal("dffd6021bb2bd5b0af676290809ec3a53191dd81c7f70a4b28688a362182986f"))
+}
+
+func TestShellQuote(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(shellQuote("/tmp/file")).To(Equal(`'/tmp/file'`))
+	Expect(shellQuote("it's")).To(Equal(`'it'"'"'s'`))
+	Expect(shellQuote("$(reboot)")).To(Equal(`'$(reboot)'`))
+	Expect(shellQuote("`reboot`")).To(Equal("'`reboot`'"))
+	Expect(shellQuote(`a\b "c"`)).To(Equal(`'a\b "c"'`))
+	Expect(shellQuote("a\nb")).To(Equal("'a\nb'"))
+	Expect(shellQuote("")).To(Equal(`''`))
+}
+
+func TestExpandTilde(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(expandTilde("~", "/home/user")).To(Equal("/home/user"))
+	Expect(expandTilde("~/config", "/home/user")).To(Equal("/home/user/config"))
+	Expect(expandTilde("/etc/config", "/home/user")).To(Equal("/etc/config"))
+	Expect(expandTilde("~other/config", "/home/user")).To(Equal("~other/config"))
+}
+
+func TestPermissionBits(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(PermissionBits(0755)).To(Equal(os.FileMode(0755)))
+	Expect(PermissionBits(os.ModeDir | os.ModeSticky | 0777)).To(Equal(os.FileMode(01777)))
+	Expect(PermissionBits(os.ModeSetgid | 0755)).To(Equal(os.FileMode(02755)))
+	Expect(PermissionBits(os.ModeSetuid | 0755)).To(Equal(os.FileMode(04755)))
+}
+
+func TestFileType(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(FileType(0644)).To(Equal("file"))
+	Expect(FileType(os.ModeDir | 0755)).To(Equal("dir"))
+	Expect(FileType(os.ModeSymlink | 0777)).To(Equal("symlink"))
+	Expect(FileType(os.ModeDevice | 0660)).To(Equal("block"))
+	Expect(FileType(os.ModeDevice | os.ModeCharDevice | 0666)).To(Equal("char"))
+	Expect(FileType(os.ModeNamedPipe | 0600)).To(Equal("fifo"))
+	Expect(FileType(os.ModeSocket | 0777)).To(Equal("socket"))
+}
+
+func TestDeriveSFTPRoot(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(deriveSFTPRoot("/home/user", "/home/user")).To(BeEmpty())
+	Expect(deriveSFTPRoot("/srv/sftp/home/user", "/home/user")).To(Equal("/srv/sftp"))
+	Expect(deriveSFTPRoot("/srv/sftp/user", "/")).To(Equal("/srv/sftp/user"))
+
+	t.Log("A working directory unrelated to the home directory doesn't indicate a chroot")
+	Expect(deriveSFTPRoot("/home/user", "/data")).To(BeEmpty())
+}
+
+func TestSFTPRootPaths(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := &SSHClient{sftpRoot: "/srv/sftp"}
+	Expect(client.commandPath("/etc/app.conf")).To(Equal("/srv/sftp/etc/app.conf"))
+	Expect(client.commandPath("relative/app.conf")).To(Equal("relative/app.conf"))
+	Expect(client.sftpPath("/srv/sftp/home/user")).To(Equal("/home/user"))
+	Expect(client.sftpPath("/srv/sftp")).To(Equal("/"))
+	Expect(client.sftpPath("/srv/sftpother")).To(Equal("/srv/sftpother"))
+
+	t.Log("Without an SFTP root, paths are unchanged")
+	client = &SSHClient{}
+	Expect(client.commandPath("/etc/app.conf")).To(Equal("/etc/app.conf"))
+	Expect(client.sftpPath("/home/user")).To(Equal("/home/user"))
+}
+
+func TestChownOwner(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Log("Any mix of names and numeric IDs")
+	Expect(chownOwner(&FileOwnership{User: "1000", Group: "1000"})).To(Equal("1000:1000"))
+	Expect(chownOwner(&FileOwnership{User: "alice", Group: "1000"})).To(Equal("alice:1000"))
+	Expect(chownOwner(&FileOwnership{User: "1000", Group: "staff"})).To(Equal("1000:staff"))
+	Expect(chownOwner(&FileOwnership{User: "alice", Group: "staff"})).To(Equal("alice:staff"))
+
+	t.Log("Only one side")
+	Expect(chownOwner(&FileOwnership{User: "alice"})).To(Equal("alice"))
+	Expect(chownOwner(&FileOwnership{Group: "2000"})).To(Equal(":2000"))
+	Expect(chownOwner(&FileOwnership{})).To(BeEmpty())
+
+	t.Log("Invalid values")
+	for _, invalid := range []string{"alice bob", "alice;reboot", "-1", "$(id)", "alice:staff"} {
+		_, err := chownOwner(&FileOwnership{User: invalid})
+		Expect(err).To(HaveOccurred(), "user %q", invalid)
+	}
+}
+
+func TestValidateShell(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, valid := range []string{"/bin/sh", "/bin/bash", "/usr/local/bin/zsh"} {
+		Expect(ValidateShell(valid)).To(Succeed(), "shell %q", valid)
+	}
+	for _, invalid := range []string{"", "bash", "./sh", "/", "/bin/", "/bin/../sh", "/bin/bash -e", "/bin/sh\n"} {
+		Expect(ValidateShell(invalid)).ToNot(Succeed(), "shell %q", invalid)
+	}
+}
+
+func TestInstallOwnerArgs(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(installOwnerArgs("", "")).To(BeEmpty())
+	Expect(installOwnerArgs("alice", "")).To(Equal(" -o 'alice'"))
+	Expect(installOwnerArgs("", "1000")).To(Equal(" -g '1000'"))
+	Expect(installOwnerArgs("alice", "staff")).To(Equal(" -o 'alice' -g 'staff'"))
+
+	t.Log("Invalid values")
+	for _, invalid := range []string{"alice; rm -rf /", "$(id)", "`id`", "alice' -o 'root", "-1"} {
+		_, err := installOwnerArgs(invalid, "")
+		Expect(err).To(HaveOccurred(), "user %q", invalid)
+		_, err = installOwnerArgs("", invalid)
+		Expect(err).To(HaveOccurred(), "group %q", invalid)
+	}
+}
+
+func TestIsNumericID(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(isNumericID("1000")).To(BeTrue())
+	Expect(isNumericID("0")).To(BeTrue())
+	Expect(isNumericID("")).To(BeFalse())
+	Expect(isNumericID("alice")).To(BeFalse())
+	Expect(isNumericID("100a")).To(BeFalse())
+}
+
+func TestEnvCommand(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Log("Variables are sorted and quoted, the command runs in its own shell")
+	Expect(envCommand(map[string]string{"B": "2", "A": "it's"}, "", "echo $A && echo $B")).
+		To(Equal(`env 'A=it'"'"'s' 'B=2' sh -c 'echo $A && echo $B'`))
+
+	t.Log("The command runs in the configured shell")
+	Expect(envCommand(map[string]string{"A": "1"}, "/bin/bash", "echo $A")).
+		To(Equal(`env 'A=1' '/bin/bash' -c 'echo $A'`))
+
+	t.Log("Invalid names")
+	for _, invalid := range []string{"", "1A", "A-B", "A B", "A=B", "$(id)"} {
+		_, err := envCommand(map[string]string{invalid: "x"}, "", "true")
+		Expect(err).To(HaveOccurred(), "name %q", invalid)
+	}
+}
+
+func TestSplitLines(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(SplitLines("a\nb\n")).To(Equal([]string{"a", "b"}))
+	Expect(SplitLines("a\nb")).To(Equal([]string{"a", "b"}))
+	Expect(SplitLines("a\n\nb\n\n")).To(Equal([]string{"a", "", "b", ""}))
+	Expect(SplitLines("\n")).To(Equal([]string{""}))
+	Expect(SplitLines("")).To(BeEmpty())
+}
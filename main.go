@@ -6,6 +6,7 @@ import (
 	"log"
 
 	"github.com/askrella/askrella-ssh-provider/internal/provider"
+	"github.com/askrella/askrella-ssh-provider/internal/provider/ssh"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 )
 
@@ -25,6 +26,11 @@ func main() {
 	}
 
 	err := providerserver.Serve(context.Background(), provider.New(version), opts)
+
+	// Serve returns once Terraform stops the plugin. Close remaining connections
+	// explicitly, as the provider's Close is not guaranteed to be called.
+	ssh.CloseAllPools()
+
 	if err != nil {
 		log.Fatal(err.Error())
 	}